@@ -18,6 +18,7 @@ import (
 	"bitcoinpitch.org/internal/i18n"
 	"bitcoinpitch.org/internal/middleware"
 	"bitcoinpitch.org/internal/models"
+	"bitcoinpitch.org/internal/nip05"
 	"bitcoinpitch.org/internal/routes"
 	"github.com/CloudyKit/jet/v6"
 	"github.com/gofiber/fiber/v2"
@@ -127,6 +128,48 @@ func renderTemplate(c *fiber.Ctx, tmpl *jet.Template, vars jet.VarMap) error {
 	return c.Send(buf.Bytes())
 }
 
+// purgeDeletedAccounts permanently removes accounts whose scheduled deletion
+// is due, at startup and then on every interval
+func purgeDeletedAccounts(repo *database.Repository, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		ctx := context.Background()
+		now := time.Now()
+		userIDs, err := repo.GetAccountsDueForDeletion(ctx, now)
+		if err != nil {
+			log.Printf("[ERROR] purgeDeletedAccounts: %v", err)
+		}
+		for _, userID := range userIDs {
+			if err := repo.PurgeAccount(ctx, userID, now); err != nil {
+				if err != database.ErrNotFound {
+					log.Printf("[ERROR] purgeDeletedAccounts: user %s: %v", userID, err)
+				}
+				continue
+			}
+			log.Printf("Account %s permanently deleted", userID)
+		}
+
+		<-ticker.C
+	}
+}
+
+// recheckNIP05Identifiers looks up claimed NIP-05 identifiers again once
+// their cached result is stale, at startup and then on every interval
+func recheckNIP05Identifiers(service *nip05.Service, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := service.RecheckStale(context.Background()); err != nil {
+			log.Printf("[ERROR] recheckNIP05Identifiers: %v", err)
+		}
+
+		<-ticker.C
+	}
+}
+
 // adminRepositoryWrapper wraps the repository to match the AdminRepository interface
 type adminRepositoryWrapper struct {
 	repo *database.Repository
@@ -223,6 +266,13 @@ func main() {
 	antispamService := antispam.NewService(repo, configService)
 	log.Println("Antispam service initialized successfully")
 
+	// Remove accounts whose deletion grace period has ended
+	go purgeDeletedAccounts(repo, time.Hour)
+
+	// Verify claimed NIP-05 identifiers and keep re-checking them
+	nip05Service := nip05.NewService(repo, configService, nil)
+	go recheckNIP05Identifiers(nip05Service, time.Hour)
+
 	// Initialize internationalization
 	log.Println("Initializing i18n system...")
 	i18nManager := i18n.NewManager("en") // Default to English
@@ -262,6 +312,11 @@ func main() {
 		return reflect.ValueOf("")
 	})
 
+	// Active pitch categories, for navigation and the pitch form
+	view.AddGlobalFunc("categories", func(args jet.Arguments) reflect.Value {
+		return reflect.ValueOf(configService.Categories(context.Background()))
+	})
+
 	// Add translation helper function
 	view.AddGlobalFunc("t", func(args jet.Arguments) reflect.Value {
 		// Get current language from context (set by i18n middleware)
@@ -518,9 +573,21 @@ func main() {
 	// Add authentication middleware
 	app.Use(middleware.AuthMiddleware(repo))
 
+	// Personal API tokens for /api requests, replacing the session when present
+	app.Use(middleware.APITokenMiddleware(repo))
+
+	// NIP-98 signed /api requests from Nostr users, with no cookie involved
+	app.Use(middleware.NIP98AuthMiddleware(repo))
+
 	// Add antispam middleware
 	app.Use(middleware.AntiSpamMiddleware(antispamService))
 
+	// NIP-05 verification for profile and pitch author claims
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("nip05Service", nip05Service)
+		return c.Next()
+	})
+
 	// Re-enable static file serving
 	app.Static("/static", "./static")
 
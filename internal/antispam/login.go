@@ -0,0 +1,271 @@
+package antispam
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"bitcoinpitch.org/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// Login methods recorded with failed attempts
+const (
+	LoginMethodPassword   = "password"
+	LoginMethodTOTP       = "totp"
+	LoginMethodBackupCode = "backup_code"
+)
+
+// loginLimits holds the brute-force thresholds from the security config category
+type loginLimits struct {
+	window              time.Duration
+	lockoutDuration     time.Duration
+	backoffBase         time.Duration
+	backoffMax          time.Duration
+	accountBackoffAfter int
+	accountLockoutAfter int
+	ipBackoffAfter      int
+	ipLockoutAfter      int
+}
+
+// loginState summarises recent failed logins for an account or an IP address
+type loginState struct {
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+// CheckLogin checks whether a login attempt may proceed for the account (nil
+// when unknown) from the given IP address. Accounts and IPs are locked out
+// temporarily after too many failures, and attempts before that are spaced
+// out with exponential backoff.
+func (s *Service) CheckLogin(ctx context.Context, userID *uuid.UUID, ipAddress net.IP) (*models.AntiSpamCheck, error) {
+	result := models.NewAntiSpamCheck(true)
+	limits := s.loginLimits(ctx)
+
+	if userID != nil {
+		state, err := s.accountLoginState(ctx, *userID, limits)
+		if err != nil {
+			return nil, err
+		}
+		limits.apply(state, limits.accountBackoffAfter, "account", result)
+	}
+
+	if ipAddress != nil {
+		state, err := s.ipLoginState(ctx, ipAddress, limits)
+		if err != nil {
+			return nil, err
+		}
+		limits.apply(state, limits.ipBackoffAfter, "ip", result)
+	}
+
+	return result, nil
+}
+
+// RecordLoginFailure records a failed password, TOTP or backup code attempt and
+// locks the account or IP address once its threshold is reached. The returned
+// check reflects the new state; its "account_locked" metadata is set when this
+// failure locked the account, so the owner can be notified.
+func (s *Service) RecordLoginFailure(ctx context.Context, userID *uuid.UUID, method string, ipAddress net.IP, userAgent string) (*models.AntiSpamCheck, error) {
+	failure := models.NewUserActivity(userID, models.ActivityTypeLoginFailed, nil, ipOrNil(ipAddress), &userAgent)
+	failure.SetMetadata("method", method)
+	if err := s.repo.CreateUserActivity(ctx, failure); err != nil {
+		return nil, err
+	}
+
+	limits := s.loginLimits(ctx)
+	accountLocked := false
+
+	if userID != nil {
+		state, err := s.accountLoginState(ctx, *userID, limits)
+		if err != nil {
+			return nil, err
+		}
+		if state.lockedUntil.IsZero() && limits.accountLockoutAfter > 0 && state.failures >= limits.accountLockoutAfter {
+			// Account lockouts carry no IP so they are not mistaken for IP lockouts
+			lockout := models.NewUserActivity(userID, models.ActivityTypeLockout, nil, nil, &userAgent)
+			lockout.SetMetadata("failures", state.failures)
+			if err := s.repo.CreateUserActivity(ctx, lockout); err != nil {
+				return nil, err
+			}
+			accountLocked = true
+		}
+	}
+
+	if ipAddress != nil {
+		state, err := s.ipLoginState(ctx, ipAddress, limits)
+		if err != nil {
+			return nil, err
+		}
+		if state.lockedUntil.IsZero() && limits.ipLockoutAfter > 0 && state.failures >= limits.ipLockoutAfter {
+			lockout := models.NewUserActivity(nil, models.ActivityTypeLockout, nil, &ipAddress, &userAgent)
+			lockout.SetMetadata("failures", state.failures)
+			if err := s.repo.CreateUserActivity(ctx, lockout); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	result, err := s.CheckLogin(ctx, userID, ipAddress)
+	if err != nil {
+		return nil, err
+	}
+	if accountLocked {
+		result.SetMetadata("account_locked", true)
+	}
+	return result, nil
+}
+
+// RecordLoginSuccess records a completed login, which resets the account's
+// failure count. Failures counted against the IP address are kept.
+func (s *Service) RecordLoginSuccess(ctx context.Context, userID uuid.UUID, method string, ipAddress net.IP, userAgent string) error {
+	return s.RecordActivity(ctx, &userID, models.ActivityTypeLogin, nil, ipAddress, userAgent, map[string]interface{}{
+		"method": method,
+	})
+}
+
+// LockoutDuration returns how long an account or IP address stays locked
+func (s *Service) LockoutDuration(ctx context.Context) time.Duration {
+	return s.loginLimits(ctx).lockoutDuration
+}
+
+// loginLimits reads the brute-force thresholds from the config
+func (s *Service) loginLimits(ctx context.Context) loginLimits {
+	return loginLimits{
+		window:              time.Duration(s.configService.GetInt(ctx, "security.login_failure_window_minutes", 15)) * time.Minute,
+		lockoutDuration:     time.Duration(s.configService.GetInt(ctx, "security.lockout_duration_minutes", 30)) * time.Minute,
+		backoffBase:         time.Duration(s.configService.GetInt(ctx, "security.login_backoff_base_seconds", 1)) * time.Second,
+		backoffMax:          time.Duration(s.configService.GetInt(ctx, "security.login_backoff_max_seconds", 60)) * time.Second,
+		accountBackoffAfter: s.configService.GetInt(ctx, "security.account_backoff_after_failures", 3),
+		accountLockoutAfter: s.configService.GetInt(ctx, "security.account_lockout_failures", 10),
+		ipBackoffAfter:      s.configService.GetInt(ctx, "security.ip_backoff_after_failures", 10),
+		ipLockoutAfter:      s.configService.GetInt(ctx, "security.ip_lockout_failures", 50),
+	}
+}
+
+// accountLoginState counts the account's failures since the start of the
+// window, its last lockout or its last successful login, whichever is latest
+func (s *Service) accountLoginState(ctx context.Context, userID uuid.UUID, limits loginLimits) (loginState, error) {
+	state := loginState{}
+	since := time.Now().Add(-limits.window)
+
+	lockout, err := s.repo.GetLastUserActivity(ctx, userID, models.ActivityTypeLockout)
+	if err != nil {
+		return state, err
+	}
+	if lockout != nil {
+		if until := lockout.CreatedAt.Add(limits.lockoutDuration); until.After(time.Now()) {
+			state.lockedUntil = until
+			return state, nil
+		}
+		if lockout.CreatedAt.After(since) {
+			since = lockout.CreatedAt
+		}
+	}
+
+	success, err := s.repo.GetLastUserActivity(ctx, userID, models.ActivityTypeLogin)
+	if err != nil {
+		return state, err
+	}
+	if success != nil && success.CreatedAt.After(since) {
+		since = success.CreatedAt
+	}
+
+	state.failures, err = s.repo.CountUserActivitiesSince(ctx, userID, models.ActivityTypeLoginFailed, since)
+	if err != nil {
+		return state, err
+	}
+	if state.failures > 0 {
+		last, err := s.repo.GetLastUserActivity(ctx, userID, models.ActivityTypeLoginFailed)
+		if err != nil {
+			return state, err
+		}
+		if last != nil {
+			state.lastFailure = last.CreatedAt
+		}
+	}
+
+	return state, nil
+}
+
+// ipLoginState counts failures from an IP address since the start of the
+// window or its last lockout. Successful logins do not reset it, so one valid
+// account cannot be used to keep guessing others.
+func (s *Service) ipLoginState(ctx context.Context, ipAddress net.IP, limits loginLimits) (loginState, error) {
+	state := loginState{}
+	since := time.Now().Add(-limits.window)
+
+	lockout, err := s.repo.GetLastIPActivity(ctx, ipAddress, models.ActivityTypeLockout)
+	if err != nil {
+		return state, err
+	}
+	if lockout != nil {
+		if until := lockout.CreatedAt.Add(limits.lockoutDuration); until.After(time.Now()) {
+			state.lockedUntil = until
+			return state, nil
+		}
+		if lockout.CreatedAt.After(since) {
+			since = lockout.CreatedAt
+		}
+	}
+
+	state.failures, err = s.repo.CountIPActivitiesSince(ctx, ipAddress, models.ActivityTypeLoginFailed, since)
+	if err != nil {
+		return state, err
+	}
+	if state.failures > 0 {
+		last, err := s.repo.GetLastIPActivity(ctx, ipAddress, models.ActivityTypeLoginFailed)
+		if err != nil {
+			return state, err
+		}
+		if last != nil {
+			state.lastFailure = last.CreatedAt
+		}
+	}
+
+	return state, nil
+}
+
+// apply blocks the check while the state is locked out or backing off,
+// keeping the longest wait when several limits apply
+func (l loginLimits) apply(state loginState, backoffAfter int, scope string, result *models.AntiSpamCheck) {
+	now := time.Now()
+
+	var wait time.Duration
+	if state.lockedUntil.After(now) {
+		wait = state.lockedUntil.Sub(now)
+		result.SetMetadata("locked", scope)
+	} else if backoffAfter > 0 && state.failures >= backoffAfter {
+		wait = state.lastFailure.Add(l.backoff(state.failures - backoffAfter)).Sub(now)
+	}
+	if wait <= 0 {
+		return
+	}
+
+	if result.RetryAfter == nil || *result.RetryAfter < wait {
+		result.Allowed = false
+		result.SetReason("Too many failed login attempts")
+		result.SetRetryAfter(wait)
+	}
+}
+
+// backoff doubles the delay for each failure past the backoff threshold
+func (l loginLimits) backoff(extraFailures int) time.Duration {
+	delay := l.backoffBase
+	for i := 0; i < extraFailures && delay < l.backoffMax; i++ {
+		delay *= 2
+	}
+	if delay > l.backoffMax {
+		delay = l.backoffMax
+	}
+	return delay
+}
+
+// ipOrNil returns a pointer to the IP address, or nil if it could not be parsed
+func ipOrNil(ipAddress net.IP) *net.IP {
+	if ipAddress == nil {
+		return nil
+	}
+	return &ipAddress
+}
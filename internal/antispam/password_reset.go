@@ -0,0 +1,71 @@
+package antispam
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"strings"
+	"time"
+
+	"bitcoinpitch.org/internal/models"
+)
+
+// passwordResetWindow is the period password reset requests are counted over
+const passwordResetWindow = time.Hour
+
+// passwordResetEmailKey is the metadata key holding the hashed email address
+const passwordResetEmailKey = "email_hash"
+
+// CheckPasswordReset checks whether a password reset email may be sent to an
+// address from the given IP address. Both are limited per hour, whether or
+// not the address belongs to an account, so the result reveals nothing about
+// which addresses are registered.
+func (s *Service) CheckPasswordReset(ctx context.Context, emailAddress string, ipAddress net.IP) (*models.AntiSpamCheck, error) {
+	result := models.NewAntiSpamCheck(true)
+	since := time.Now().Add(-passwordResetWindow)
+
+	maxPerEmail := s.configService.GetInt(ctx, "security.password_reset_per_email_per_hour", 3)
+	if maxPerEmail > 0 {
+		count, err := s.repo.CountActivitiesWithMetadataSince(ctx, models.ActivityTypePasswordReset,
+			passwordResetEmailKey, passwordResetEmailHash(emailAddress), since)
+		if err != nil {
+			return nil, err
+		}
+		if count >= maxPerEmail {
+			result.Allowed = false
+		}
+	}
+
+	maxPerIP := s.configService.GetInt(ctx, "security.password_reset_per_ip_per_hour", 10)
+	if result.Allowed && maxPerIP > 0 && ipAddress != nil {
+		count, err := s.repo.CountIPActivitiesSince(ctx, ipAddress, models.ActivityTypePasswordReset, since)
+		if err != nil {
+			return nil, err
+		}
+		if count >= maxPerIP {
+			result.Allowed = false
+		}
+	}
+
+	if !result.Allowed {
+		result.SetReason("Too many password reset requests")
+		result.SetRetryAfter(passwordResetWindow)
+	}
+
+	return result, nil
+}
+
+// RecordPasswordReset records an accepted password reset request. Only a hash
+// of the email address is stored.
+func (s *Service) RecordPasswordReset(ctx context.Context, emailAddress string, ipAddress net.IP, userAgent string) error {
+	activity := models.NewUserActivity(nil, models.ActivityTypePasswordReset, nil, ipOrNil(ipAddress), &userAgent)
+	activity.SetMetadata(passwordResetEmailKey, passwordResetEmailHash(emailAddress))
+	return s.repo.CreateUserActivity(ctx, activity)
+}
+
+// passwordResetEmailHash hashes a normalised email address for rate limiting
+func passwordResetEmailHash(emailAddress string) string {
+	hash := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(emailAddress))))
+	return hex.EncodeToString(hash[:])
+}
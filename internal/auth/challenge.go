@@ -0,0 +1,177 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultChallengeTTL is how long an issued login challenge stays valid
+const DefaultChallengeTTL = 5 * time.Minute
+
+// Challenge is a short-lived, single-use nonce issued for signature-based logins
+type Challenge struct {
+	Nonce     string    `json:"nonce"`
+	Domain    string    `json:"domain"`
+	Message   string    `json:"message"`
+	IssuedAt  time.Time `json:"issued_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Used      bool      `json:"-"`
+}
+
+// IsExpired checks if the challenge has expired
+func (c *Challenge) IsExpired() bool {
+	return time.Now().After(c.ExpiresAt)
+}
+
+// ChallengeStore issues login challenges and tracks their expiry and consumption
+type ChallengeStore struct {
+	mu         sync.Mutex
+	domain     string
+	ttl        time.Duration
+	challenges map[string]*Challenge
+}
+
+// NewChallengeStore creates a new challenge store bound to the given domain
+func NewChallengeStore(domain string, ttl time.Duration) *ChallengeStore {
+	if ttl <= 0 {
+		ttl = DefaultChallengeTTL
+	}
+	return &ChallengeStore{
+		domain:     domain,
+		ttl:        ttl,
+		challenges: make(map[string]*Challenge),
+	}
+}
+
+// ChallengeDomainFromEnv returns the host of SITE_URL, used to bind challenges to this site
+func ChallengeDomainFromEnv() string {
+	siteURL := os.Getenv("SITE_URL")
+	if siteURL == "" {
+		siteURL = "http://localhost:8090"
+	}
+	if u, err := url.Parse(siteURL); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return strings.TrimSuffix(siteURL, "/")
+}
+
+// Domain returns the domain challenges are bound to
+func (s *ChallengeStore) Domain() string {
+	return s.domain
+}
+
+// Issue creates and stores a new challenge
+func (s *ChallengeStore) Issue() (*Challenge, error) {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return nil, fmt.Errorf("error generating challenge nonce: %w", err)
+	}
+
+	now := time.Now()
+	challenge := &Challenge{
+		Nonce:     hex.EncodeToString(bytes),
+		Domain:    s.domain,
+		IssuedAt:  now,
+		ExpiresAt: now.Add(s.ttl),
+	}
+	challenge.Message = s.buildMessage(challenge)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cleanupLocked()
+	s.challenges[challenge.Nonce] = challenge
+
+	return challenge, nil
+}
+
+// Consume marks a challenge as used, rejecting unknown, expired or replayed nonces
+func (s *ChallengeStore) Consume(nonce string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	challenge, ok := s.challenges[nonce]
+	if !ok {
+		return ErrChallengeInvalid
+	}
+	if challenge.Used {
+		return ErrChallengeUsed
+	}
+	if challenge.IsExpired() {
+		delete(s.challenges, nonce)
+		return ErrChallengeExpired
+	}
+
+	challenge.Used = true
+	return nil
+}
+
+// VerifyMessage checks that a signed login message was built from a challenge
+// issued for this domain and consumes it
+func (s *ChallengeStore) VerifyMessage(message string) error {
+	domain, nonce := parseChallengeMessage(message)
+	if nonce == "" {
+		return ErrChallengeMissing
+	}
+	if domain != s.domain {
+		return ErrChallengeDomain
+	}
+	return s.Consume(nonce)
+}
+
+// VerifyNonce checks that a nonce was issued for the given domain and consumes it
+func (s *ChallengeStore) VerifyNonce(domain, nonce string) error {
+	if nonce == "" {
+		return ErrChallengeMissing
+	}
+	if domain != s.domain {
+		return ErrChallengeDomain
+	}
+	return s.Consume(nonce)
+}
+
+// buildMessage renders the human-readable text the user is asked to sign
+func (s *ChallengeStore) buildMessage(challenge *Challenge) string {
+	return fmt.Sprintf("%s wants you to sign in to BitcoinPitch.org\n\nNonce: %s\nIssued At: %s\nExpires At: %s",
+		challenge.Domain,
+		challenge.Nonce,
+		challenge.IssuedAt.UTC().Format(time.RFC3339),
+		challenge.ExpiresAt.UTC().Format(time.RFC3339),
+	)
+}
+
+// cleanupLocked removes expired challenges; the caller must hold the lock
+func (s *ChallengeStore) cleanupLocked() {
+	for nonce, challenge := range s.challenges {
+		if challenge.IsExpired() {
+			delete(s.challenges, nonce)
+		}
+	}
+}
+
+// parseChallengeMessage extracts the domain and nonce from a signed login message
+func parseChallengeMessage(message string) (domain, nonce string) {
+	lines := strings.Split(strings.ReplaceAll(message, "\r\n", "\n"), "\n")
+	if len(lines) == 0 {
+		return "", ""
+	}
+
+	if idx := strings.Index(lines[0], " wants you to sign in"); idx > 0 {
+		domain = strings.TrimSpace(lines[0][:idx])
+	}
+
+	for _, line := range lines[1:] {
+		if strings.HasPrefix(line, "Nonce: ") {
+			nonce = strings.TrimSpace(strings.TrimPrefix(line, "Nonce: "))
+			break
+		}
+	}
+
+	return domain, nonce
+}
@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChallengeIssue(t *testing.T) {
+	store := NewChallengeStore("bitcoinpitch.org", time.Minute)
+
+	challenge, err := store.Issue()
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	if len(challenge.Nonce) != 32 {
+		t.Errorf("nonce %q should be 32 hex characters", challenge.Nonce)
+	}
+	if challenge.Domain != "bitcoinpitch.org" {
+		t.Errorf("domain = %q, want bitcoinpitch.org", challenge.Domain)
+	}
+	if !strings.HasPrefix(challenge.Message, "bitcoinpitch.org wants you to sign in") {
+		t.Errorf("message does not start with the domain: %q", challenge.Message)
+	}
+	if !strings.Contains(challenge.Message, "Nonce: "+challenge.Nonce) {
+		t.Errorf("message does not contain the nonce: %q", challenge.Message)
+	}
+
+	other, err := store.Issue()
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	if other.Nonce == challenge.Nonce {
+		t.Error("two challenges got the same nonce")
+	}
+}
+
+func TestChallengeConsume(t *testing.T) {
+	store := NewChallengeStore("bitcoinpitch.org", time.Minute)
+	challenge, err := store.Issue()
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+
+	if err := store.Consume(challenge.Nonce); err != nil {
+		t.Fatalf("first Consume: %v", err)
+	}
+	if err := store.Consume(challenge.Nonce); err != ErrChallengeUsed {
+		t.Errorf("second Consume = %v, want %v", err, ErrChallengeUsed)
+	}
+	if err := store.Consume("0123456789abcdef0123456789abcdef"); err != ErrChallengeInvalid {
+		t.Errorf("Consume of unknown nonce = %v, want %v", err, ErrChallengeInvalid)
+	}
+}
+
+func TestChallengeExpiry(t *testing.T) {
+	store := NewChallengeStore("bitcoinpitch.org", time.Minute)
+	challenge, err := store.Issue()
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+
+	store.challenges[challenge.Nonce].ExpiresAt = time.Now().Add(-time.Second)
+
+	if err := store.Consume(challenge.Nonce); err != ErrChallengeExpired {
+		t.Errorf("Consume of expired challenge = %v, want %v", err, ErrChallengeExpired)
+	}
+	// Expired challenges are forgotten, so they stay unusable
+	if err := store.Consume(challenge.Nonce); err != ErrChallengeInvalid {
+		t.Errorf("second Consume of expired challenge = %v, want %v", err, ErrChallengeInvalid)
+	}
+}
+
+func TestChallengeVerifyMessage(t *testing.T) {
+	store := NewChallengeStore("bitcoinpitch.org", time.Minute)
+	challenge, err := store.Issue()
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+
+	if err := store.VerifyMessage("Sign in to BitcoinPitch.org"); err != ErrChallengeMissing {
+		t.Errorf("VerifyMessage without nonce = %v, want %v", err, ErrChallengeMissing)
+	}
+
+	foreign := strings.Replace(challenge.Message, "bitcoinpitch.org", "evil.example", 1)
+	if err := store.VerifyMessage(foreign); err != ErrChallengeDomain {
+		t.Errorf("VerifyMessage for another domain = %v, want %v", err, ErrChallengeDomain)
+	}
+
+	// Windows line endings are accepted
+	crlf := strings.ReplaceAll(challenge.Message, "\n", "\r\n")
+	if err := store.VerifyMessage(crlf); err != nil {
+		t.Fatalf("VerifyMessage: %v", err)
+	}
+	if err := store.VerifyMessage(challenge.Message); err != ErrChallengeUsed {
+		t.Errorf("replayed VerifyMessage = %v, want %v", err, ErrChallengeUsed)
+	}
+}
+
+func TestChallengeVerifyNonce(t *testing.T) {
+	store := NewChallengeStore("bitcoinpitch.org", time.Minute)
+	challenge, err := store.Issue()
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+
+	if err := store.VerifyNonce("bitcoinpitch.org", ""); err != ErrChallengeMissing {
+		t.Errorf("VerifyNonce without nonce = %v, want %v", err, ErrChallengeMissing)
+	}
+	if err := store.VerifyNonce("evil.example", challenge.Nonce); err != ErrChallengeDomain {
+		t.Errorf("VerifyNonce for another domain = %v, want %v", err, ErrChallengeDomain)
+	}
+	// A domain mismatch does not burn the challenge
+	if err := store.VerifyNonce("bitcoinpitch.org", challenge.Nonce); err != nil {
+		t.Fatalf("VerifyNonce: %v", err)
+	}
+	if err := store.VerifyNonce("bitcoinpitch.org", challenge.Nonce); err != ErrChallengeUsed {
+		t.Errorf("replayed VerifyNonce = %v, want %v", err, ErrChallengeUsed)
+	}
+}
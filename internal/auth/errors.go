@@ -8,6 +8,7 @@ var (
 	ErrPasswordTooLong  = errors.New("password must be no more than 128 characters long")
 	ErrPasswordNoLetter = errors.New("password must contain at least one letter")
 	ErrPasswordNoNumber = errors.New("password must contain at least one number")
+	ErrPasswordBreached = errors.New("this password has appeared in a data breach, please choose another one")
 )
 
 // Email validation errors
@@ -26,11 +27,35 @@ var (
 	ErrTokenAlreadyUsed   = errors.New("token has already been used")
 )
 
+// Login challenge errors
+var (
+	ErrChallengeMissing = errors.New("login challenge is missing")
+	ErrChallengeInvalid = errors.New("unknown login challenge")
+	ErrChallengeExpired = errors.New("login challenge has expired")
+	ErrChallengeUsed    = errors.New("login challenge has already been used")
+	ErrChallengeDomain  = errors.New("login challenge was issued for a different domain")
+)
+
+// LNURL-auth errors
+var (
+	ErrLNURLAuthInvalid = errors.New("unknown LNURL-auth request")
+	ErrLNURLAuthExpired = errors.New("LNURL-auth request has expired")
+	ErrLNURLAuthUsed    = errors.New("LNURL-auth request has already been signed")
+	ErrLNURLAuthPending = errors.New("LNURL-auth request has not been signed yet")
+)
+
 // TOTP errors
 var (
 	ErrTOTPInvalid    = errors.New("invalid TOTP code")
 	ErrTOTPNotEnabled = errors.New("TOTP is not enabled for this user")
 	ErrBackupCodeUsed = errors.New("backup code has already been used")
+	ErrTOTPReplayed   = errors.New("TOTP code has already been used")
+)
+
+// Pending login errors
+var (
+	ErrPendingLoginInvalid = errors.New("no pending login found, please sign in again")
+	ErrPendingLoginExpired = errors.New("pending login has expired, please sign in again")
 )
 
 // Admin errors
@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultLNURLAuthTTL is how long a wallet has to sign an LNURL-auth challenge
+const DefaultLNURLAuthTTL = 5 * time.Minute
+
+// LNURLAuthRequest is a k1 challenge shown to the browser as a QR code and
+// signed by a Lightning wallet through the LNURL-auth callback
+type LNURLAuthRequest struct {
+	K1         string
+	LinkingKey string
+	ExpiresAt  time.Time
+}
+
+// IsExpired checks if the request has expired
+func (r *LNURLAuthRequest) IsExpired() bool {
+	return time.Now().After(r.ExpiresAt)
+}
+
+// LNURLAuthStore tracks LNURL-auth challenges between the wallet callback and
+// the browser that is waiting to sign in
+type LNURLAuthStore struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	requests map[string]*LNURLAuthRequest
+}
+
+// NewLNURLAuthStore creates a new LNURL-auth store
+func NewLNURLAuthStore(ttl time.Duration) *LNURLAuthStore {
+	if ttl <= 0 {
+		ttl = DefaultLNURLAuthTTL
+	}
+	return &LNURLAuthStore{
+		ttl:      ttl,
+		requests: make(map[string]*LNURLAuthRequest),
+	}
+}
+
+// TTL returns how long LNURL-auth requests stay valid
+func (s *LNURLAuthStore) TTL() time.Duration {
+	return s.ttl
+}
+
+// Issue creates and stores a new k1 challenge
+func (s *LNURLAuthStore) Issue() (*LNURLAuthRequest, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return nil, fmt.Errorf("error generating LNURL-auth k1: %w", err)
+	}
+
+	request := &LNURLAuthRequest{
+		K1:        hex.EncodeToString(bytes),
+		ExpiresAt: time.Now().Add(s.ttl),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cleanupLocked()
+	s.requests[request.K1] = request
+
+	return request, nil
+}
+
+// Sign records the linking key whose signature over k1 the wallet presented.
+// The signature itself must already have been verified by the caller.
+func (s *LNURLAuthStore) Sign(k1, linkingKey string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	request, ok := s.requests[k1]
+	if !ok {
+		return ErrLNURLAuthInvalid
+	}
+	if request.IsExpired() {
+		delete(s.requests, k1)
+		return ErrLNURLAuthExpired
+	}
+	if request.LinkingKey != "" {
+		return ErrLNURLAuthUsed
+	}
+
+	request.LinkingKey = linkingKey
+	return nil
+}
+
+// Claim returns the linking key of a signed request and removes it, so each
+// signature signs in exactly one browser. Unsigned requests are left in place.
+func (s *LNURLAuthStore) Claim(k1 string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	request, ok := s.requests[k1]
+	if !ok {
+		return "", ErrLNURLAuthInvalid
+	}
+	if request.IsExpired() {
+		delete(s.requests, k1)
+		return "", ErrLNURLAuthExpired
+	}
+	if request.LinkingKey == "" {
+		return "", ErrLNURLAuthPending
+	}
+
+	delete(s.requests, k1)
+	return request.LinkingKey, nil
+}
+
+// cleanupLocked removes expired requests; the caller must hold the lock
+func (s *LNURLAuthStore) cleanupLocked() {
+	for k1, request := range s.requests {
+		if request.IsExpired() {
+			delete(s.requests, k1)
+		}
+	}
+}
@@ -1,36 +1,138 @@
 package auth
 
 import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
 )
 
-// PasswordService handles password hashing and verification
+// Argon2Params are the argon2id cost parameters for new password hashes
+type Argon2Params struct {
+	Memory      uint32 // KiB
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2Params follows the RFC 9106 second recommended option
+var DefaultArgon2Params = Argon2Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// BreachedPasswordsFileEnv names the environment variable holding the path of
+// the local breached password list. Each line is either a password or its
+// SHA-1 hex digest, optionally followed by ":count" as in Have I Been Pwned
+// downloads. Lines starting with "#" are ignored. A list of digests sorted in
+// ascending order is searched on disk; other lists are held in memory and
+// limited to maxBreachedPasswordsInMemory entries.
+const BreachedPasswordsFileEnv = "BREACHED_PASSWORDS_FILE"
+
+// PasswordService handles password hashing and verification. New hashes use
+// argon2id in PHC string format; legacy bcrypt hashes are still verified and
+// reported by NeedsRehash so they can be upgraded on the next login.
 type PasswordService struct {
-	cost int
+	params        Argon2Params
+	checkBreached bool
 }
 
-// NewPasswordService creates a new password service
+// NewPasswordService creates a new password service with the default parameters
 func NewPasswordService() *PasswordService {
+	return NewPasswordServiceWithParams(DefaultArgon2Params, true)
+}
+
+// NewPasswordServiceWithParams creates a password service hashing with the given
+// argon2id parameters; zero values fall back to the defaults. checkBreached
+// enables the breached password list check in ValidatePasswordStrength.
+func NewPasswordServiceWithParams(params Argon2Params, checkBreached bool) *PasswordService {
+	if params.Memory == 0 {
+		params.Memory = DefaultArgon2Params.Memory
+	}
+	if params.Iterations == 0 {
+		params.Iterations = DefaultArgon2Params.Iterations
+	}
+	if params.Parallelism == 0 {
+		params.Parallelism = DefaultArgon2Params.Parallelism
+	}
+	if params.SaltLength == 0 {
+		params.SaltLength = DefaultArgon2Params.SaltLength
+	}
+	if params.KeyLength == 0 {
+		params.KeyLength = DefaultArgon2Params.KeyLength
+	}
 	return &PasswordService{
-		cost: bcrypt.DefaultCost, // Currently 10
+		params:        params,
+		checkBreached: checkBreached,
 	}
 }
 
-// HashPassword hashes a plaintext password
+// HashPassword hashes a plaintext password with argon2id, e.g.
+// $argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>
 func (p *PasswordService) HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), p.cost)
-	if err != nil {
-		return "", err
+	salt := make([]byte, p.params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("error generating salt: %w", err)
 	}
-	return string(bytes), nil
+
+	key := argon2.IDKey([]byte(password), salt, p.params.Iterations, p.params.Memory, p.params.Parallelism, p.params.KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version,
+		p.params.Memory, p.params.Iterations, p.params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
 }
 
-// VerifyPassword verifies a plaintext password against a hash
+// VerifyPassword verifies a plaintext password against an argon2id or legacy bcrypt hash
 func (p *PasswordService) VerifyPassword(password, hash string) bool {
+	if strings.HasPrefix(hash, "$argon2id$") {
+		params, salt, key, err := decodeArgon2Hash(hash)
+		if err != nil {
+			return false
+		}
+		computed := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+		return subtle.ConstantTimeCompare(computed, key) == 1
+	}
+
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	return err == nil
 }
 
+// NeedsRehash reports whether a hash uses a legacy scheme or parameters other
+// than the current ones. Call it after a successful VerifyPassword.
+func (p *PasswordService) NeedsRehash(hash string) bool {
+	if !strings.HasPrefix(hash, "$argon2id$") {
+		return true
+	}
+	params, salt, key, err := decodeArgon2Hash(hash)
+	if err != nil {
+		return true
+	}
+	return params.Memory != p.params.Memory ||
+		params.Iterations != p.params.Iterations ||
+		params.Parallelism != p.params.Parallelism ||
+		uint32(len(salt)) != p.params.SaltLength ||
+		uint32(len(key)) != p.params.KeyLength
+}
+
 // ValidatePasswordStrength validates password strength
 func (p *PasswordService) ValidatePasswordStrength(password string) error {
 	if len(password) < 8 {
@@ -63,5 +165,288 @@ func (p *PasswordService) ValidatePasswordStrength(password string) error {
 		return ErrPasswordNoNumber
 	}
 
+	if p.checkBreached && isBreachedPassword(password) {
+		return ErrPasswordBreached
+	}
+
 	return nil
 }
+
+// decodeArgon2Hash parses an argon2id PHC string into its parameters, salt and key
+func decodeArgon2Hash(hash string) (Argon2Params, []byte, []byte, error) {
+	var params Argon2Params
+
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, key
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return params, nil, nil, fmt.Errorf("invalid argon2id hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("unsupported argon2 version %q", parts[2])
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id parameters: %w", err)
+	}
+	if params.Memory == 0 || params.Iterations == 0 || params.Parallelism == 0 {
+		return params, nil, nil, fmt.Errorf("invalid argon2id parameters")
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id salt: %w", err)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, fmt.Errorf("invalid argon2id key")
+	}
+
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+	return params, salt, key, nil
+}
+
+// maxBreachedPasswordsInMemory caps how many entries of an unsorted breached
+// password list are held in memory. Larger lists must be sorted SHA-1 digest
+// files, which are searched on disk.
+const maxBreachedPasswordsInMemory = 1000000
+
+// breachedPasswordList is a set of SHA-1 digests of breached passwords
+type breachedPasswordList interface {
+	contains(digest [sha1.Size]byte) bool
+}
+
+var (
+	breachedOnce sync.Once
+	breachedList breachedPasswordList
+)
+
+// isBreachedPassword checks a password against the local breached password
+// list, which is opened on first use. Without a list every password passes.
+func isBreachedPassword(password string) bool {
+	breachedOnce.Do(func() {
+		path := os.Getenv(BreachedPasswordsFileEnv)
+		if path == "" {
+			return
+		}
+		list, err := loadBreachedPasswords(path)
+		if err != nil {
+			log.Printf("[ERROR] loadBreachedPasswords: %v", err)
+			return
+		}
+		breachedList = list
+	})
+	if breachedList == nil {
+		return false
+	}
+
+	return breachedList.contains(sha1.Sum([]byte(password)))
+}
+
+// loadBreachedPasswords opens a breached password list. A file of SHA-1
+// digests sorted in ascending order, such as the Have I Been Pwned "ordered by
+// hash" download, is binary searched on disk and may be any size. Any other
+// list is read into memory, up to maxBreachedPasswordsInMemory entries.
+func loadBreachedPasswords(path string) (breachedPasswordList, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening %s: %w", path, err)
+	}
+
+	sorted, err := isSortedDigestFile(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("error reading %s: %w", path, err)
+	}
+	if sorted {
+		info, err := file.Stat()
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("error reading %s: %w", path, err)
+		}
+		log.Printf("Using sorted breached password list %s (%d bytes)", path, info.Size())
+		return &sortedDigestFile{file: file, size: info.Size()}, nil
+	}
+
+	defer file.Close()
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", path, err)
+	}
+
+	hashes := make(digestSet)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if len(hashes) >= maxBreachedPasswordsInMemory {
+			log.Printf("[ERROR] loadBreachedPasswords: %s has more than %d entries; only the first %d are checked. Use a sorted SHA-1 digest file for larger lists.",
+				path, maxBreachedPasswordsInMemory, maxBreachedPasswordsInMemory)
+			break
+		}
+
+		if digest, ok := parseDigestLine(line); ok {
+			hashes[digest] = struct{}{}
+			continue
+		}
+		hashes[sha1.Sum([]byte(line))] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", path, err)
+	}
+
+	log.Printf("Loaded %d breached password hashes", len(hashes))
+	return hashes, nil
+}
+
+// digestSet is a breached password list held in memory
+type digestSet map[[sha1.Size]byte]struct{}
+
+func (s digestSet) contains(digest [sha1.Size]byte) bool {
+	_, found := s[digest]
+	return found
+}
+
+// sortedDigestSampleSize is how much of a list is read to decide whether it
+// is a sorted digest file
+const sortedDigestSampleSize = 64 * 1024
+
+// isSortedDigestFile reports whether the start of a list consists only of
+// SHA-1 digest lines in ascending order, ignoring comments
+func isSortedDigestFile(file *os.File) (bool, error) {
+	sample := make([]byte, sortedDigestSampleSize)
+	n, err := file.ReadAt(sample, 0)
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	sample = sample[:n]
+	if n == sortedDigestSampleSize {
+		// The last line may be cut off
+		if i := bytes.LastIndexByte(sample, '\n'); i >= 0 {
+			sample = sample[:i]
+		}
+	}
+
+	var previous string
+	digests := 0
+	for _, line := range strings.Split(string(sample), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key := strings.ToUpper(digestKey(line))
+		if _, ok := parseDigestLine(line); !ok || key < previous {
+			return false, nil
+		}
+		previous = key
+		digests++
+	}
+	return digests > 0, nil
+}
+
+// sortedDigestFile is a breached password list of ascending SHA-1 digests,
+// binary searched on disk so it never has to fit in memory
+type sortedDigestFile struct {
+	file *os.File
+	size int64
+}
+
+// maxDigestLineLength bounds a line of a sorted digest file: 40 hex digits
+// and a breach count
+const maxDigestLineLength = 128
+
+func (f *sortedDigestFile) contains(digest [sha1.Size]byte) bool {
+	target := strings.ToUpper(hex.EncodeToString(digest[:]))
+
+	// Search the lines starting in [lo, hi)
+	lo, hi := int64(0), f.size
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		start, line, err := f.lineAt(mid)
+		if err != nil {
+			log.Printf("[ERROR] sortedDigestFile: %v", err)
+			return false
+		}
+		if start >= hi {
+			hi = mid
+			continue
+		}
+
+		key := strings.ToUpper(digestKey(line))
+		switch {
+		case key == target:
+			return true
+		case key < target:
+			lo = start + int64(len(line)) + 1
+		default:
+			hi = mid
+		}
+	}
+	return false
+}
+
+// lineAt returns the first line starting at or after offset, and where it starts
+func (f *sortedDigestFile) lineAt(offset int64) (int64, string, error) {
+	buf := make([]byte, 2*maxDigestLineLength)
+
+	start := offset
+	if offset > 0 {
+		// Skip the rest of the line the offset falls into
+		n, err := f.file.ReadAt(buf[:maxDigestLineLength], offset-1)
+		if err != nil && err != io.EOF {
+			return 0, "", err
+		}
+		i := bytes.IndexByte(buf[:n], '\n')
+		if i < 0 {
+			if err == io.EOF {
+				return f.size, "", nil
+			}
+			return 0, "", fmt.Errorf("line longer than %d bytes near offset %d", maxDigestLineLength, offset)
+		}
+		start = offset + int64(i)
+	}
+	if start >= f.size {
+		return f.size, "", nil
+	}
+
+	n, err := f.file.ReadAt(buf[:maxDigestLineLength], start)
+	if err != nil && err != io.EOF {
+		return 0, "", err
+	}
+	line := buf[:n]
+	if i := bytes.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	} else if err != io.EOF {
+		return 0, "", fmt.Errorf("line longer than %d bytes at offset %d", maxDigestLineLength, start)
+	}
+	return start, string(line), nil
+}
+
+// digestKey returns a list line without its ":count" suffix or line ending
+func digestKey(line string) string {
+	line = strings.TrimRight(line, "\r")
+	if i := strings.IndexByte(line, ':'); i >= 0 {
+		line = line[:i]
+	}
+	return line
+}
+
+// parseDigestLine parses a line holding a SHA-1 hex digest, optionally
+// followed by ":count"
+func parseDigestLine(line string) ([sha1.Size]byte, bool) {
+	var digest [sha1.Size]byte
+	key := digestKey(line)
+	if len(key) != 2*sha1.Size || !isHex(key) {
+		return digest, false
+	}
+	_, _ = hex.Decode(digest[:], []byte(key))
+	return digest, true
+}
+
+// isHex reports whether s consists only of hexadecimal digits
+func isHex(s string) bool {
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// writeBreachedList writes a breached password list to a temporary file
+func writeBreachedList(t *testing.T, lines []string, lineEnding string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, lineEnding)+lineEnding), 0o600); err != nil {
+		t.Fatalf("write list: %v", err)
+	}
+	return path
+}
+
+func sha1Hex(password string) string {
+	digest := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(digest[:]))
+}
+
+func TestSortedBreachedPasswordFile(t *testing.T) {
+	// Large enough that the list does not fit in the format sample
+	var passwords, lines []string
+	for i := 0; i < 3000; i++ {
+		passwords = append(passwords, fmt.Sprintf("breached-%d", i))
+	}
+	for i, password := range passwords {
+		lines = append(lines, fmt.Sprintf("%s:%d", sha1Hex(password), i+1))
+	}
+	sort.Strings(lines)
+
+	for _, lineEnding := range []string{"\n", "\r\n"} {
+		t.Run(fmt.Sprintf("%q", lineEnding), func(t *testing.T) {
+			list, err := loadBreachedPasswords(writeBreachedList(t, lines, lineEnding))
+			if err != nil {
+				t.Fatalf("loadBreachedPasswords: %v", err)
+			}
+			sorted, ok := list.(*sortedDigestFile)
+			if !ok {
+				t.Fatalf("list loaded as %T, want a sorted digest file", list)
+			}
+			defer sorted.file.Close()
+
+			for _, password := range passwords {
+				if !list.contains(sha1.Sum([]byte(password))) {
+					t.Fatalf("breached password %q not found", password)
+				}
+			}
+			for _, password := range []string{"", "breached-3000", "correct horse battery staple", "breached-"} {
+				if list.contains(sha1.Sum([]byte(password))) {
+					t.Errorf("password %q reported as breached", password)
+				}
+			}
+
+			// The first and last entries, and digests outside the range
+			first, _ := parseDigestLine(lines[0])
+			last, _ := parseDigestLine(lines[len(lines)-1])
+			if !list.contains(first) || !list.contains(last) {
+				t.Error("first or last entry not found")
+			}
+			var low, high [sha1.Size]byte
+			for i := range high {
+				high[i] = 0xff
+			}
+			if list.contains(low) || list.contains(high) {
+				t.Error("digest outside the list reported as breached")
+			}
+		})
+	}
+}
+
+func TestSortedBreachedPasswordFileWithComment(t *testing.T) {
+	lines := []string{
+		"# Have I Been Pwned, ordered by hash",
+		sha1Hex("password1"),
+		sha1Hex("hunter2"),
+	}
+	sort.Strings(lines[1:])
+
+	list, err := loadBreachedPasswords(writeBreachedList(t, lines, "\n"))
+	if err != nil {
+		t.Fatalf("loadBreachedPasswords: %v", err)
+	}
+	if _, ok := list.(*sortedDigestFile); !ok {
+		t.Fatalf("list loaded as %T, want a sorted digest file", list)
+	}
+	if !list.contains(sha1.Sum([]byte("password1"))) || !list.contains(sha1.Sum([]byte("hunter2"))) {
+		t.Error("breached password not found")
+	}
+	if list.contains(sha1.Sum([]byte("something else"))) {
+		t.Error("unlisted password reported as breached")
+	}
+}
+
+func TestUnsortedBreachedPasswordList(t *testing.T) {
+	lines := []string{
+		"# plain passwords and digests",
+		"password1",
+		strings.ToLower(sha1Hex("hunter2")) + ":42",
+		sha1Hex("letmein"),
+		"",
+		"qwerty123",
+	}
+
+	list, err := loadBreachedPasswords(writeBreachedList(t, lines, "\n"))
+	if err != nil {
+		t.Fatalf("loadBreachedPasswords: %v", err)
+	}
+	if _, ok := list.(digestSet); !ok {
+		t.Fatalf("list loaded as %T, want an in-memory set", list)
+	}
+	for _, password := range []string{"password1", "hunter2", "letmein", "qwerty123"} {
+		if !list.contains(sha1.Sum([]byte(password))) {
+			t.Errorf("breached password %q not found", password)
+		}
+	}
+	if list.contains(sha1.Sum([]byte("# plain passwords and digests"))) {
+		t.Error("comment line loaded as a password")
+	}
+}
+
+func TestLoadBreachedPasswordsMissingFile(t *testing.T) {
+	if _, err := loadBreachedPasswords(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("loadBreachedPasswords accepted a missing file")
+	}
+}
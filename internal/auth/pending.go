@@ -0,0 +1,139 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultPendingLoginTTL is how long a user has to complete the second factor
+	DefaultPendingLoginTTL = 5 * time.Minute
+	// DefaultPendingLoginAttempts is how many wrong codes are accepted before the login is dropped
+	DefaultPendingLoginAttempts = 5
+)
+
+// PendingLogin is a login that passed its primary credential and awaits the second factor
+type PendingLogin struct {
+	Token     string
+	UserID    uuid.UUID
+	Method    string
+	ExpiresAt time.Time
+	Attempts  int
+}
+
+// IsExpired checks if the pending login has expired
+func (p *PendingLogin) IsExpired() bool {
+	return time.Now().After(p.ExpiresAt)
+}
+
+// PendingLoginStore tracks short-lived pending 2FA logins
+type PendingLoginStore struct {
+	mu          sync.Mutex
+	ttl         time.Duration
+	maxAttempts int
+	logins      map[string]*PendingLogin
+}
+
+// NewPendingLoginStore creates a new pending login store
+func NewPendingLoginStore(ttl time.Duration, maxAttempts int) *PendingLoginStore {
+	if ttl <= 0 {
+		ttl = DefaultPendingLoginTTL
+	}
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultPendingLoginAttempts
+	}
+	return &PendingLoginStore{
+		ttl:         ttl,
+		maxAttempts: maxAttempts,
+		logins:      make(map[string]*PendingLogin),
+	}
+}
+
+// TTL returns how long pending logins stay valid
+func (s *PendingLoginStore) TTL() time.Duration {
+	return s.ttl
+}
+
+// Create starts a pending login for a user authenticated with the given method
+func (s *PendingLoginStore) Create(userID uuid.UUID, method string) (*PendingLogin, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return nil, fmt.Errorf("error generating pending login token: %w", err)
+	}
+
+	pending := &PendingLogin{
+		Token:     hex.EncodeToString(bytes),
+		UserID:    userID,
+		Method:    method,
+		ExpiresAt: time.Now().Add(s.ttl),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cleanupLocked()
+	s.logins[pending.Token] = pending
+
+	return pending, nil
+}
+
+// Get returns the pending login for a token
+func (s *PendingLoginStore) Get(token string) (*PendingLogin, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pending, ok := s.logins[token]
+	if !ok {
+		return nil, ErrPendingLoginInvalid
+	}
+	if pending.IsExpired() {
+		delete(s.logins, token)
+		return nil, ErrPendingLoginExpired
+	}
+
+	copied := *pending
+	return &copied, nil
+}
+
+// RecordFailure counts a wrong second-factor code and drops the login once
+// the attempt limit is reached. It returns the number of attempts left.
+func (s *PendingLoginStore) RecordFailure(token string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pending, ok := s.logins[token]
+	if !ok {
+		return 0
+	}
+
+	pending.Attempts++
+	remaining := s.maxAttempts - pending.Attempts
+	if remaining <= 0 {
+		delete(s.logins, token)
+		return 0
+	}
+
+	return remaining
+}
+
+// Complete removes a pending login once the second factor has been accepted
+func (s *PendingLoginStore) Complete(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.logins, token)
+}
+
+// cleanupLocked removes expired pending logins; the caller must hold the lock
+func (s *PendingLoginStore) cleanupLocked() {
+	for token, pending := range s.logins {
+		if pending.IsExpired() {
+			delete(s.logins, token)
+		}
+	}
+}
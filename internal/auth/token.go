@@ -0,0 +1,30 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// HashToken returns the SHA-256 hex digest of a bearer token. Only the digest
+// is stored, so a database leak does not expose usable tokens.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// APITokenPrefix marks personal API tokens so they are easy to recognize in logs and secret scanners
+const APITokenPrefix = "bp_"
+
+// GenerateAPIToken creates a new personal API token. It returns the token and
+// a short display prefix that identifies it without revealing the secret part.
+func GenerateAPIToken() (token, displayPrefix string, err error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", "", fmt.Errorf("error generating API token: %w", err)
+	}
+
+	token = APITokenPrefix + hex.EncodeToString(bytes)
+	return token, token[:len(APITokenPrefix)+8], nil
+}
@@ -2,6 +2,7 @@ package auth
 
 import (
 	"crypto/rand"
+	"crypto/subtle"
 	"encoding/base32"
 	"fmt"
 	"net/url"
@@ -10,6 +11,14 @@ import (
 
 	"github.com/pquerna/otp"
 	"github.com/pquerna/otp/totp"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// TOTPPeriod is the TOTP time step in seconds
+	TOTPPeriod = 30
+	// TOTPSkew is the number of time steps accepted before and after the current one
+	TOTPSkew = 1
 )
 
 // TOTPService handles TOTP operations
@@ -37,6 +46,34 @@ func (t *TOTPService) ValidateCode(secret, code string) bool {
 	return totp.Validate(code, secret)
 }
 
+// ValidateCodeStep validates a TOTP code and returns the time step it matched.
+// Steps at or before lastStep are rejected so a code cannot be replayed.
+func (t *TOTPService) ValidateCodeStep(secret, code string, lastStep int64) (int64, bool) {
+	code = strings.TrimSpace(code)
+	currentStep := time.Now().Unix() / TOTPPeriod
+
+	for step := currentStep - TOTPSkew; step <= currentStep+TOTPSkew; step++ {
+		if step <= lastStep {
+			continue
+		}
+
+		expected, err := totp.GenerateCodeCustom(secret, time.Unix(step*TOTPPeriod, 0), totp.ValidateOpts{
+			Period:    TOTPPeriod,
+			Digits:    otp.DigitsSix,
+			Algorithm: otp.AlgorithmSHA1,
+		})
+		if err != nil {
+			return 0, false
+		}
+
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+
+	return 0, false
+}
+
 // GenerateQRCodeURL generates a QR code URL for the secret
 func (t *TOTPService) GenerateQRCodeURL(secret, userEmail string) string {
 	return fmt.Sprintf("otpauth://totp/%s:%s?secret=%s&issuer=%s",
@@ -82,25 +119,41 @@ func (t *TOTPService) generateBackupCode() (string, error) {
 	return fmt.Sprintf("%s-%s", code[:4], code[4:8]), nil
 }
 
-// ValidateBackupCode checks if a backup code is valid and removes it from the list
-func (t *TOTPService) ValidateBackupCode(codes []string, inputCode string) (bool, []string) {
-	// Normalize input code (remove spaces, dashes, make uppercase)
-	inputCode = strings.ToUpper(strings.ReplaceAll(strings.ReplaceAll(inputCode, " ", ""), "-", ""))
-
+// HashBackupCodes hashes backup codes for storage
+func (t *TOTPService) HashBackupCodes(codes []string) ([]string, error) {
+	hashes := make([]string, len(codes))
 	for i, code := range codes {
-		// Normalize stored code
-		normalizedCode := strings.ToUpper(strings.ReplaceAll(strings.ReplaceAll(code, " ", ""), "-", ""))
-
-		if normalizedCode == inputCode {
-			// Remove the used backup code
-			newCodes := make([]string, len(codes)-1)
-			copy(newCodes[:i], codes[:i])
-			copy(newCodes[i:], codes[i+1:])
-			return true, newCodes
+		hash, err := bcrypt.GenerateFromPassword([]byte(normalizeBackupCode(code)), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash backup code: %w", err)
+		}
+		hashes[i] = string(hash)
+	}
+
+	return hashes, nil
+}
+
+// MatchBackupCode returns the stored hash matching the input backup code
+func (t *TOTPService) MatchBackupCode(hashes []string, inputCode string) (string, bool) {
+	inputCode = normalizeBackupCode(inputCode)
+
+	for _, hash := range hashes {
+		if bcrypt.CompareHashAndPassword([]byte(hash), []byte(inputCode)) == nil {
+			return hash, true
 		}
 	}
 
-	return false, codes
+	return "", false
+}
+
+// IsBackupCode reports whether the input looks like a backup code rather than a TOTP code
+func (t *TOTPService) IsBackupCode(inputCode string) bool {
+	return len(normalizeBackupCode(inputCode)) == 8
+}
+
+// normalizeBackupCode removes spaces and dashes and uppercases a backup code
+func normalizeBackupCode(code string) string {
+	return strings.ToUpper(strings.ReplaceAll(strings.ReplaceAll(strings.TrimSpace(code), " ", ""), "-", ""))
 }
 
 // GetCurrentCode gets the current TOTP code for a secret (useful for testing)
@@ -8,6 +8,7 @@ import (
 	"sync"
 
 	"bitcoinpitch.org/internal/models"
+	"bitcoinpitch.org/internal/ranking"
 	"github.com/google/uuid"
 )
 
@@ -21,13 +22,17 @@ type ConfigRepository interface {
 	DeleteConfigSetting(ctx context.Context, key string) error
 	CreateConfigAuditLog(ctx context.Context, log *models.ConfigAuditLog) error
 	GetConfigAuditLogs(ctx context.Context, configKey string, limit, offset int) ([]*models.ConfigAuditLog, error)
+	ListCategories(ctx context.Context) ([]*models.Category, error)
 }
 
-// Service manages configuration settings with caching
+// Service manages configuration settings and pitch categories with caching
 type Service struct {
 	repo  ConfigRepository
 	cache map[string]*models.ConfigSetting
 	mutex sync.RWMutex
+
+	// All categories in display order, nil until first loaded
+	categories []*models.Category
 }
 
 // NewService creates a new configuration service
@@ -268,6 +273,14 @@ func (s *Service) PitchLimits(ctx context.Context) PitchLimits {
 	}
 }
 
+// MinorEditLimits returns how small an edit must be to keep a pitch's votes
+func (s *Service) MinorEditLimits(ctx context.Context) MinorEditLimits {
+	return MinorEditLimits{
+		MaxDistance: s.GetInt(ctx, "pitch.minor_edit.max_distance", 10),
+		MaxPercent:  s.GetInt(ctx, "pitch.minor_edit.max_percent", 10),
+	}
+}
+
 // PaginationConfig returns current pagination configuration
 func (s *Service) PaginationConfig(ctx context.Context) PaginationConfig {
 	var pageSizeOptions []string
@@ -298,6 +311,92 @@ func (s *Service) PaginationConfig(ctx context.Context) PaginationConfig {
 	}
 }
 
+// PasswordHashing returns the current password hashing configuration
+func (s *Service) PasswordHashing(ctx context.Context) PasswordHashing {
+	return PasswordHashing{
+		Argon2MemoryKiB:   s.GetInt(ctx, "security.argon2_memory_kib", 65536),
+		Argon2Iterations:  s.GetInt(ctx, "security.argon2_iterations", 3),
+		Argon2Parallelism: s.GetInt(ctx, "security.argon2_parallelism", 2),
+		CheckBreached:     s.GetBool(ctx, "security.breached_password_check", true),
+	}
+}
+
+// RegistrationMode returns who can create an account. Unknown values fall
+// back to open registration.
+func (s *Service) RegistrationMode(ctx context.Context) string {
+	mode := s.GetString(ctx, "users.registration_mode", models.RegistrationModeOpen)
+	if !models.IsValidRegistrationMode(mode) {
+		return models.RegistrationModeOpen
+	}
+	return mode
+}
+
+// RefreshCategories reloads the pitch categories, for after an admin changed
+// one
+func (s *Service) RefreshCategories(ctx context.Context) error {
+	categories, err := s.repo.ListCategories(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load categories: %w", err)
+	}
+	if categories == nil {
+		categories = []*models.Category{}
+	}
+
+	s.mutex.Lock()
+	s.categories = categories
+	s.mutex.Unlock()
+	return nil
+}
+
+// allCategories returns every category, loading them on first use
+func (s *Service) allCategories(ctx context.Context) []*models.Category {
+	s.mutex.RLock()
+	categories := s.categories
+	s.mutex.RUnlock()
+	if categories != nil {
+		return categories
+	}
+
+	if err := s.RefreshCategories(ctx); err != nil {
+		fmt.Printf("[ERROR] Categories: %v\n", err)
+		return nil
+	}
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.categories
+}
+
+// Categories returns the active pitch categories in display order
+func (s *Service) Categories(ctx context.Context) []*models.Category {
+	var active []*models.Category
+	for _, category := range s.allCategories(ctx) {
+		if category.Active {
+			active = append(active, category)
+		}
+	}
+	return active
+}
+
+// Category returns the active category with a slug, or nil if there is none
+func (s *Service) Category(ctx context.Context, slug string) *models.Category {
+	for _, category := range s.allCategories(ctx) {
+		if category.Slug == slug && category.Active {
+			return category
+		}
+	}
+	return nil
+}
+
+// DefaultSort returns the ranking mode a category's listings use when no sort
+// is asked for. Unknown values fall back to hot.
+func (s *Service) DefaultSort(ctx context.Context, category string) string {
+	c := s.Category(ctx, category)
+	if c == nil || !ranking.IsValid(c.DefaultSort) {
+		return ranking.Hot
+	}
+	return c.DefaultSort
+}
+
 // PitchLimits holds the current pitch length configuration
 type PitchLimits struct {
 	OneLinerMin int `json:"one_liner_min"`
@@ -307,6 +406,18 @@ type PitchLimits struct {
 	ElevatorMax int `json:"elevator_max"`
 }
 
+// MinorEditLimits holds the thresholds below which a pitch edit is minor
+type MinorEditLimits struct {
+	MaxDistance int `json:"max_distance"`
+	MaxPercent  int `json:"max_percent"`
+}
+
+// ClassifyEdit returns whether changing a pitch's text from old to new is a
+// minor or a major edit
+func (l MinorEditLimits) ClassifyEdit(old, new string) string {
+	return models.ClassifyPitchEdit(old, new, l.MaxDistance, l.MaxPercent)
+}
+
 // PaginationConfig holds the current pagination configuration
 type PaginationConfig struct {
 	DefaultPageSize      int   `json:"default_page_size"`
@@ -317,6 +428,15 @@ type PaginationConfig struct {
 	ShowPageSizeSelector bool  `json:"show_page_size_selector"`
 }
 
+// PasswordHashing holds the argon2id parameters for new password hashes and
+// whether new passwords are checked against the breached password list
+type PasswordHashing struct {
+	Argon2MemoryKiB   int  `json:"argon2_memory_kib"`
+	Argon2Iterations  int  `json:"argon2_iterations"`
+	Argon2Parallelism int  `json:"argon2_parallelism"`
+	CheckBreached     bool `json:"check_breached"`
+}
+
 // GetFloat64 returns a configuration value as a float64
 func (s *Service) GetFloat64(ctx context.Context, key string, defaultValue float64) float64 {
 	s.mutex.RLock()
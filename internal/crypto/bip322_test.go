@@ -0,0 +1,338 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// Test vectors from BIP-322
+const (
+	bip322PrivateKey = "L3VFeEujGtevx9w18HD1fhRbCH67Az2dpCymeRE1SoPK6XQtaN2k"
+	bip322P2WPKH     = "bc1q9vza2e8x573nczrlzms0wvx3gsqjx7vavgkx0l"
+	bip322P2TR       = "bc1ppv609nr0vr25u07u95waq5lucwfm6tde4nydujnu8npg4q75mr5sxq8lt3"
+)
+
+var bip322SimpleVectors = []struct {
+	name      string
+	address   string
+	message   string
+	signature string
+}{
+	{
+		name:      "P2WPKH empty message",
+		address:   bip322P2WPKH,
+		message:   "",
+		signature: "AkcwRAIgM2gBAQqvZX15ZiysmKmQpDrG83avLIT492QBzLnQIxYCIBaTpOaD20qRlEylyxFSeEA2ba9YOixpX8z46TSDtS40ASECx/EgAxlkQpQ9hYjgGu6EBCPMVPwVIVJqO4XCsMvViHI=",
+	},
+	{
+		name:      "P2WPKH Hello World",
+		address:   bip322P2WPKH,
+		message:   "Hello World",
+		signature: "AkcwRAIgZRfIY3p7/DoVTty6YZbWS71bc5Vct9p9Fia83eRmw2QCICK/ENGfwLtptFluMGs2KsqoNSk89pO7F29zJLUx9a/sASECx/EgAxlkQpQ9hYjgGu6EBCPMVPwVIVJqO4XCsMvViHI=",
+	},
+	{
+		name:      "P2WPKH Hello World, second signature",
+		address:   bip322P2WPKH,
+		message:   "Hello World",
+		signature: "AkgwRQIhAOzyynlqt93lOKJr+wmmxIens//zPzl9tqIOua93wO6MAiBi5n5EyAcPScOjf1lAqIUIQtr3zKNeavYabHyR8eGhowEhAsfxIAMZZEKUPYWI4BruhAQjzFT8FSFSajuFwrDL1Yhy",
+	},
+	{
+		name:      "P2TR Hello World",
+		address:   bip322P2TR,
+		message:   "Hello World",
+		signature: "AUHd69PrJQEv+oKTfZ8l+WROBHuy9HKrbFCJu7U1iK2iiEy1vMU5EfMtjc+VSHM7aU0SDbak5IUZRVno2P5mjSafAQ==",
+	},
+}
+
+func TestBIP322MessageHash(t *testing.T) {
+	tests := []struct {
+		message string
+		hash    string
+	}{
+		{"", "c90c269c4f8fcbe6880f72a721ddfbf1914268a794cbb21cfafee13770ae19f1"},
+		{"Hello World", "f0eb03b1a75ac6d9847f55c624a99169b5dccba2a31f5b23bea77ba270de0a7a"},
+	}
+	for _, tt := range tests {
+		if got := hex.EncodeToString(bip322MessageHash(tt.message)); got != tt.hash {
+			t.Errorf("bip322MessageHash(%q) = %s, want %s", tt.message, got, tt.hash)
+		}
+	}
+}
+
+func TestBIP322TransactionHashes(t *testing.T) {
+	tests := []struct {
+		message string
+		toSpend string
+		toSign  string
+	}{
+		{"", "c5680aa69bb8d860bf82d4e9cd3504b55dde018de765a91bb566283c545a99a7", "1e9654e951a5ba44c8604c4de6c67fd78a27e81dcadcfe1edf638ba3aaebaed6"},
+		{"Hello World", "b79d196740ad5217771c1098fc4a4b51e0535c32236c71f1ea4d61a2d603352b", "88737ae86f2077145f93cc4b153ae9a1cb8d56afa511988c149c5c8c9d93bddf"},
+	}
+
+	scriptPubKey := mustScript(t, bip322P2WPKH)
+	for _, tt := range tests {
+		toSpend := buildBIP322ToSpend(scriptPubKey, tt.message)
+		if got := toSpend.TxHash().String(); got != tt.toSpend {
+			t.Errorf("to_spend(%q) = %s, want %s", tt.message, got, tt.toSpend)
+		}
+		toSign := buildBIP322ToSign(toSpend, nil, nil)
+		if got := toSign.TxHash().String(); got != tt.toSign {
+			t.Errorf("to_sign(%q) = %s, want %s", tt.message, got, tt.toSign)
+		}
+	}
+}
+
+func TestBIP322SimpleVectors(t *testing.T) {
+	for _, tt := range bip322SimpleVectors {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := VerifyBitcoinMessage(tt.message, tt.signature, tt.address); err != nil {
+				t.Errorf("VerifyBitcoinMessage: %v", err)
+			}
+		})
+	}
+}
+
+func TestBIP322FullVectors(t *testing.T) {
+	// A full signature is the whole "to_sign" transaction carrying the
+	// witness of the simple signature
+	for _, tt := range bip322SimpleVectors {
+		t.Run(tt.name, func(t *testing.T) {
+			signature := bip322FullSignature(t, tt.address, tt.message, tt.signature)
+			if err := VerifyBitcoinMessage(tt.message, signature, tt.address); err != nil {
+				t.Errorf("VerifyBitcoinMessage: %v", err)
+			}
+		})
+	}
+}
+
+func TestBIP322FullRejectsForeignTransaction(t *testing.T) {
+	vector := bip322SimpleVectors[1]
+	witness := decodeWitness(t, vector.signature)
+
+	// Spending a to_spend for a different message
+	toSpend := buildBIP322ToSpend(mustScript(t, vector.address), "Goodbye World")
+	toSign := buildBIP322ToSign(toSpend, nil, witness)
+	if err := VerifyBitcoinMessage(vector.message, serializeTx(t, toSign), vector.address); err == nil {
+		t.Error("accepted a to_sign that spends another message")
+	}
+
+	// A second output
+	toSpend = buildBIP322ToSpend(mustScript(t, vector.address), vector.message)
+	toSign = buildBIP322ToSign(toSpend, nil, witness)
+	toSign.AddTxOut(wire.NewTxOut(1000, mustScript(t, vector.address)))
+	if err := VerifyBitcoinMessage(vector.message, serializeTx(t, toSign), vector.address); err == nil {
+		t.Error("accepted a to_sign with an extra output")
+	}
+}
+
+func TestBIP322NestedSegwit(t *testing.T) {
+	wif, err := btcutil.DecodeWIF(bip322PrivateKey)
+	if err != nil {
+		t.Fatalf("DecodeWIF: %v", err)
+	}
+	address, err := pubKeyToNestedWitnessAddress(wif.PrivKey.PubKey())
+	if err != nil {
+		t.Fatalf("pubKeyToNestedWitnessAddress: %v", err)
+	}
+
+	for _, message := range []string{"", "Hello World"} {
+		signature := signBIP322NestedSegwit(t, wif.PrivKey, address, message)
+		if err := VerifyBitcoinMessage(message, signature, address); err != nil {
+			t.Errorf("VerifyBitcoinMessage(%q): %v", message, err)
+		}
+	}
+}
+
+func TestBIP322VectorAddresses(t *testing.T) {
+	wif, err := btcutil.DecodeWIF(bip322PrivateKey)
+	if err != nil {
+		t.Fatalf("DecodeWIF: %v", err)
+	}
+	address, err := pubKeyToWitnessAddress(wif.PrivKey.PubKey())
+	if err != nil {
+		t.Fatalf("pubKeyToWitnessAddress: %v", err)
+	}
+	if address != bip322P2WPKH {
+		t.Errorf("P2WPKH address = %s, want %s", address, bip322P2WPKH)
+	}
+}
+
+func TestBIP322Rejects(t *testing.T) {
+	p2wpkh := bip322SimpleVectors[1]
+	p2tr := bip322SimpleVectors[3]
+
+	// Lose the final byte of the public key
+	raw, _ := base64.StdEncoding.DecodeString(p2wpkh.signature)
+	truncated := base64.StdEncoding.EncodeToString(raw[:len(raw)-1])
+
+	// Claim more witness items than the signature holds
+	extended := append([]byte{}, raw...)
+	extended[0] = 3
+	overcounted := base64.StdEncoding.EncodeToString(extended)
+
+	tests := []struct {
+		name      string
+		address   string
+		message   string
+		signature string
+	}{
+		{"P2WPKH wrong message", p2wpkh.address, "Hello World!", p2wpkh.signature},
+		{"P2WPKH signature for the empty message", p2wpkh.address, "Hello World", bip322SimpleVectors[0].signature},
+		{"P2WPKH wrong address", "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4", p2wpkh.message, p2wpkh.signature},
+		{"P2WPKH truncated witness", p2wpkh.address, p2wpkh.message, truncated},
+		{"P2WPKH overcounted witness", p2wpkh.address, p2wpkh.message, overcounted},
+		{"P2WPKH empty signature", p2wpkh.address, p2wpkh.message, ""},
+		{"P2TR wrong message", p2tr.address, "Hello World!", p2tr.signature},
+		{"P2TR wrong address", "bc1p5cyxnuxmeuwuvkwfem96lqzszd02n6xdcjrs20cac6yqjjwudpxqkedrcr", p2tr.message, p2tr.signature},
+		{"P2WPKH signature for P2TR", p2tr.address, p2wpkh.message, p2wpkh.signature},
+		{"P2PKH address", "1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa", p2wpkh.message, p2wpkh.signature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := VerifyBitcoinMessage(tt.message, tt.signature, tt.address); err == nil {
+				t.Error("VerifyBitcoinMessage accepted an invalid signature")
+			}
+		})
+	}
+}
+
+func TestLegacyRecoveryFlags(t *testing.T) {
+	key, err := btcec.NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey: %v", err)
+	}
+	message := "bitcoinpitch.org wants you to sign in"
+
+	uncompressed, _ := pubKeyToUncompressedAddress(key.PubKey())
+	compressed, _ := pubKeyToCompressedAddress(key.PubKey())
+	nested, _ := pubKeyToNestedWitnessAddress(key.PubKey())
+	native, _ := pubKeyToWitnessAddress(key.PubKey())
+
+	tests := []struct {
+		name       string
+		compressed bool
+		flagOffset byte
+		address    string
+		valid      bool
+	}{
+		{"27-30 uncompressed P2PKH", false, 0, uncompressed, true},
+		{"31-34 compressed P2PKH", true, 0, compressed, true},
+		{"31-34 P2SH-P2WPKH (Electrum)", true, 0, nested, true},
+		{"31-34 P2WPKH (Electrum)", true, 0, native, true},
+		{"35-38 P2SH-P2WPKH (Trezor)", true, 4, nested, true},
+		{"39-42 P2WPKH (Trezor)", true, 8, native, true},
+		{"27-30 for a compressed address", false, 0, compressed, false},
+		{"31-34 for an uncompressed address", true, 0, uncompressed, false},
+		{"35-38 for a P2WPKH address", true, 4, native, false},
+		{"39-42 for a P2PKH address", true, 8, compressed, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hash := createMessageHash(message)
+			sig := ecdsa.SignCompact(key, hash[:], tt.compressed)
+			sig[0] += tt.flagOffset
+			if sig[0] < 27 || sig[0] > 42 {
+				t.Fatalf("header flag %d out of range", sig[0])
+			}
+			signature := base64.StdEncoding.EncodeToString(sig)
+
+			err := VerifyBitcoinMessage(message, signature, tt.address)
+			if tt.valid && err != nil {
+				t.Errorf("VerifyBitcoinMessage: %v", err)
+			}
+			if !tt.valid && err == nil {
+				t.Error("VerifyBitcoinMessage accepted a mismatched header flag")
+			}
+			if tt.valid {
+				if err := VerifyBitcoinMessage(message+".", signature, tt.address); err == nil {
+					t.Error("VerifyBitcoinMessage accepted a signature for another message")
+				}
+			}
+		})
+	}
+}
+
+func mustScript(t *testing.T, address string) []byte {
+	t.Helper()
+	addr, err := btcutil.DecodeAddress(address, &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("DecodeAddress(%s): %v", address, err)
+	}
+	script, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("PayToAddrScript(%s): %v", address, err)
+	}
+	return script
+}
+
+func decodeWitness(t *testing.T, signature string) wire.TxWitness {
+	t.Helper()
+	raw, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		t.Fatalf("decode signature: %v", err)
+	}
+	r := bytes.NewReader(raw)
+	count, err := wire.ReadVarInt(r, 0)
+	if err != nil {
+		t.Fatalf("read witness count: %v", err)
+	}
+	witness := make(wire.TxWitness, count)
+	for i := range witness {
+		if witness[i], err = wire.ReadVarBytes(r, 0, maxWitnessItemSize, "witness item"); err != nil {
+			t.Fatalf("read witness item: %v", err)
+		}
+	}
+	return witness
+}
+
+func serializeTx(t *testing.T, tx *wire.MsgTx) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		t.Fatalf("serialize: %v", err)
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func bip322FullSignature(t *testing.T, address, message, simple string) string {
+	t.Helper()
+	toSpend := buildBIP322ToSpend(mustScript(t, address), message)
+	return serializeTx(t, buildBIP322ToSign(toSpend, nil, decodeWitness(t, simple)))
+}
+
+// signBIP322NestedSegwit produces a simple BIP-322 signature for a P2SH-P2WPKH address
+func signBIP322NestedSegwit(t *testing.T, key *btcec.PrivateKey, address, message string) string {
+	t.Helper()
+	scriptPubKey := mustScript(t, address)
+	toSpend := buildBIP322ToSpend(scriptPubKey, message)
+	toSign := buildBIP322ToSign(toSpend, nil, nil)
+
+	pubKey := key.PubKey().SerializeCompressed()
+	witnessProgram := witnessRedeemScript(pubKey)
+	fetcher := txscript.NewCannedPrevOutputFetcher(scriptPubKey, 0)
+	witness, err := txscript.WitnessSignature(toSign, txscript.NewTxSigHashes(toSign, fetcher), 0, 0,
+		witnessProgram, txscript.SigHashAll, key, true)
+	if err != nil {
+		t.Fatalf("WitnessSignature: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := wire.WriteVarInt(&buf, 0, uint64(len(witness))); err != nil {
+		t.Fatalf("write witness: %v", err)
+	}
+	for _, item := range witness {
+		if err := wire.WriteVarBytes(&buf, 0, item); err != nil {
+			t.Fatalf("write witness: %v", err)
+		}
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
@@ -0,0 +1,162 @@
+package crypto
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// cborMaxDepth bounds nesting so a hostile attestation object cannot exhaust the stack
+const cborMaxDepth = 16
+
+// decodeCBOR decodes the subset of CBOR (RFC 8949) used by WebAuthn: integers,
+// byte and text strings, arrays, maps, tags and simple values. Maps decode to
+// map[interface{}]interface{} with int64 or string keys. It returns the number
+// of bytes consumed, since authenticator data carries CBOR followed by other data.
+func decodeCBOR(data []byte) (interface{}, int, error) {
+	d := &cborDecoder{data: data}
+	value, err := d.decode(0)
+	if err != nil {
+		return nil, 0, err
+	}
+	return value, d.pos, nil
+}
+
+type cborDecoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *cborDecoder) decode(depth int) (interface{}, error) {
+	if depth > cborMaxDepth {
+		return nil, errors.New("cbor: nesting too deep")
+	}
+
+	major, arg, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+
+	switch major {
+	case 0: // unsigned integer
+		if arg > math.MaxInt64 {
+			return nil, errors.New("cbor: integer overflow")
+		}
+		return int64(arg), nil
+	case 1: // negative integer
+		if arg > math.MaxInt64 {
+			return nil, errors.New("cbor: integer overflow")
+		}
+		return -1 - int64(arg), nil
+	case 2, 3: // byte string, text string
+		raw, err := d.take(arg)
+		if err != nil {
+			return nil, err
+		}
+		if major == 3 {
+			return string(raw), nil
+		}
+		return append([]byte(nil), raw...), nil
+	case 4: // array
+		if arg > uint64(len(d.data)-d.pos) {
+			return nil, errors.New("cbor: array length exceeds input")
+		}
+		items := make([]interface{}, 0, arg)
+		for i := uint64(0); i < arg; i++ {
+			item, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	case 5: // map
+		if arg > uint64(len(d.data)-d.pos) {
+			return nil, errors.New("cbor: map length exceeds input")
+		}
+		items := make(map[interface{}]interface{}, arg)
+		for i := uint64(0); i < arg; i++ {
+			key, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, errors.New("cbor: unsupported map key type")
+			}
+			value, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			if _, dup := items[key]; dup {
+				return nil, errors.New("cbor: duplicate map key")
+			}
+			items[key] = value
+		}
+		return items, nil
+	case 6: // tag, the tagged value is returned as-is
+		return d.decode(depth + 1)
+	default: // simple values
+		switch arg {
+		case 20:
+			return false, nil
+		case 21:
+			return true, nil
+		case 22, 23:
+			return nil, nil
+		}
+		return nil, fmt.Errorf("cbor: unsupported simple value %d", arg)
+	}
+}
+
+// head reads an item's major type and argument
+func (d *cborDecoder) head() (byte, uint64, error) {
+	if d.pos >= len(d.data) {
+		return 0, 0, errors.New("cbor: unexpected end of input")
+	}
+
+	initial := d.data[d.pos]
+	d.pos++
+	major, info := initial>>5, initial&0x1f
+
+	switch {
+	case info < 24:
+		return major, uint64(info), nil
+	case info <= 27:
+		size := 1 << (info - 24)
+		raw, err := d.take(uint64(size))
+		if err != nil {
+			return 0, 0, err
+		}
+		var arg uint64
+		switch size {
+		case 1:
+			arg = uint64(raw[0])
+		case 2:
+			arg = uint64(binary.BigEndian.Uint16(raw))
+		case 4:
+			arg = uint64(binary.BigEndian.Uint32(raw))
+		default:
+			arg = binary.BigEndian.Uint64(raw)
+		}
+		// Floats share major type 7 and are never used by WebAuthn
+		if major == 7 && size > 1 {
+			return 0, 0, errors.New("cbor: floating point values are not supported")
+		}
+		return major, arg, nil
+	default:
+		return 0, 0, errors.New("cbor: indefinite lengths are not supported")
+	}
+}
+
+// take consumes n bytes of input
+func (d *cborDecoder) take(n uint64) ([]byte, error) {
+	if n > uint64(len(d.data)-d.pos) {
+		return nil, errors.New("cbor: unexpected end of input")
+	}
+	raw := d.data[d.pos : d.pos+int(n)]
+	d.pos += int(n)
+	return raw, nil
+}
@@ -0,0 +1,196 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// cborPair is a map entry for encodeCBOR; cborMap keeps entries in order so
+// encodings are deterministic
+type cborPair struct {
+	key, value interface{}
+}
+
+type cborMap []cborPair
+
+// encodeCBOR encodes the values a WebAuthn authenticator produces
+func encodeCBOR(value interface{}) []byte {
+	var buf bytes.Buffer
+	writeCBOR(&buf, value)
+	return buf.Bytes()
+}
+
+func writeCBOR(buf *bytes.Buffer, value interface{}) {
+	switch v := value.(type) {
+	case int:
+		writeCBOR(buf, int64(v))
+	case int64:
+		if v >= 0 {
+			writeCBORHead(buf, 0, uint64(v))
+		} else {
+			writeCBORHead(buf, 1, uint64(-1-v))
+		}
+	case []byte:
+		writeCBORHead(buf, 2, uint64(len(v)))
+		buf.Write(v)
+	case string:
+		writeCBORHead(buf, 3, uint64(len(v)))
+		buf.WriteString(v)
+	case []interface{}:
+		writeCBORHead(buf, 4, uint64(len(v)))
+		for _, item := range v {
+			writeCBOR(buf, item)
+		}
+	case cborMap:
+		writeCBORHead(buf, 5, uint64(len(v)))
+		for _, pair := range v {
+			writeCBOR(buf, pair.key)
+			writeCBOR(buf, pair.value)
+		}
+	case bool:
+		if v {
+			buf.WriteByte(0xf5)
+		} else {
+			buf.WriteByte(0xf4)
+		}
+	case nil:
+		buf.WriteByte(0xf6)
+	default:
+		panic("encodeCBOR: unsupported type")
+	}
+}
+
+func writeCBORHead(buf *bytes.Buffer, major byte, arg uint64) {
+	switch {
+	case arg < 24:
+		buf.WriteByte(major<<5 | byte(arg))
+	case arg <= 0xff:
+		buf.WriteByte(major<<5 | 24)
+		buf.WriteByte(byte(arg))
+	case arg <= 0xffff:
+		buf.WriteByte(major<<5 | 25)
+		_ = binary.Write(buf, binary.BigEndian, uint16(arg))
+	case arg <= 0xffffffff:
+		buf.WriteByte(major<<5 | 26)
+		_ = binary.Write(buf, binary.BigEndian, uint32(arg))
+	default:
+		buf.WriteByte(major<<5 | 27)
+		_ = binary.Write(buf, binary.BigEndian, arg)
+	}
+}
+
+func TestDecodeCBOR(t *testing.T) {
+	tests := []struct {
+		name  string
+		input []byte
+		want  interface{}
+	}{
+		{"small integer", []byte{0x17}, int64(23)},
+		{"one byte integer", []byte{0x18, 0x18}, int64(24)},
+		{"two byte integer", []byte{0x19, 0x03, 0xe8}, int64(1000)},
+		{"four byte integer", []byte{0x1a, 0x00, 0x0f, 0x42, 0x40}, int64(1000000)},
+		{"eight byte integer", []byte{0x1b, 0, 0, 0, 0xe8, 0xd4, 0xa5, 0x10, 0x00}, int64(1000000000000)},
+		{"negative integer", []byte{0x38, 0x63}, int64(-100)},
+		{"COSE RS256 algorithm", []byte{0x39, 0x01, 0x00}, int64(-257)},
+		{"byte string", []byte{0x44, 1, 2, 3, 4}, []byte{1, 2, 3, 4}},
+		{"text string", []byte{0x64, 'I', 'E', 'T', 'F'}, "IETF"},
+		{"array", []byte{0x83, 0x01, 0x02, 0x03}, []interface{}{int64(1), int64(2), int64(3)}},
+		{"map", []byte{0xa2, 0x01, 0x02, 0x61, 'a', 0xf5}, map[interface{}]interface{}{int64(1): int64(2), "a": true}},
+		{"tag", []byte{0xc2, 0x41, 0x01}, []byte{1}},
+		{"false", []byte{0xf4}, false},
+		{"null", []byte{0xf6}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, n, err := decodeCBOR(tt.input)
+			if err != nil {
+				t.Fatalf("decodeCBOR: %v", err)
+			}
+			if n != len(tt.input) {
+				t.Errorf("consumed %d bytes, want %d", n, len(tt.input))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeCBOR = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeCBORTrailingData(t *testing.T) {
+	// Authenticator data carries the public key followed by extensions
+	input := append(encodeCBOR(cborMap{{int64(1), int64(2)}}), 0xa0, 0xff)
+	_, n, err := decodeCBOR(input)
+	if err != nil {
+		t.Fatalf("decodeCBOR: %v", err)
+	}
+	if n != 3 {
+		t.Errorf("consumed %d bytes, want 3", n)
+	}
+}
+
+func TestDecodeCBORRejects(t *testing.T) {
+	deep := bytes.Repeat([]byte{0x81}, cborMaxDepth+2)
+	deep = append(deep, 0x01)
+
+	tests := []struct {
+		name  string
+		input []byte
+		want  string
+	}{
+		{"empty", []byte{}, "unexpected end"},
+		{"truncated argument", []byte{0x19, 0x03}, "unexpected end"},
+		{"truncated byte string", []byte{0x45, 1, 2}, "unexpected end"},
+		{"truncated text string", []byte{0x7a, 0xff, 0xff, 0xff, 0xff, 'a'}, "unexpected end"},
+		{"truncated array item", []byte{0x82, 0x01, 0x42, 0x01}, "unexpected end"},
+		{"array shorter than its length", []byte{0x83, 0x01, 0x02}, "exceeds input"},
+		{"truncated map value", []byte{0xa1, 0x01}, "unexpected end"},
+		{"array longer than input", []byte{0x9b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, "exceeds input"},
+		{"map longer than input", []byte{0xbb, 0x7f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, "exceeds input"},
+		{"integer overflow", []byte{0x1b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, "overflow"},
+		{"negative integer overflow", []byte{0x3b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, "overflow"},
+		{"indefinite length", []byte{0x5f, 0x41, 0x01, 0xff}, "indefinite"},
+		{"reserved additional info", []byte{0x1c}, "indefinite"},
+		{"float", []byte{0xf9, 0x3c, 0x00}, "floating point"},
+		{"unsupported simple value", []byte{0xf0}, "simple value"},
+		{"byte string map key", []byte{0xa1, 0x41, 0x01, 0x01}, "map key"},
+		{"duplicate map key", []byte{0xa2, 0x01, 0x01, 0x01, 0x02}, "duplicate"},
+		{"nesting too deep", deep, "too deep"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := decodeCBOR(tt.input)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("decodeCBOR error = %v, want one mentioning %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func FuzzDecodeCBOR(f *testing.F) {
+	f.Add([]byte{0xa2, 0x01, 0x02, 0x61, 'a', 0xf5})
+	f.Add(encodeCBOR(cborMap{
+		{"fmt", "none"},
+		{"attStmt", cborMap{}},
+		{"authData", bytes.Repeat([]byte{0x42}, 64)},
+	}))
+	f.Add(encodeCBOR(cborMap{{int64(1), int64(2)}, {int64(3), int64(-7)}, {int64(-1), int64(1)}}))
+	f.Add([]byte{0x9b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		value, n, err := decodeCBOR(data)
+		if err != nil {
+			return
+		}
+		if n <= 0 || n > len(data) {
+			t.Fatalf("consumed %d bytes of %d", n, len(data))
+		}
+		// Anything decoded must decode the same from just the consumed bytes
+		again, m, err := decodeCBOR(data[:n])
+		if err != nil || m != n || !reflect.DeepEqual(value, again) {
+			t.Fatalf("decoding the consumed prefix gave %#v, %d, %v", again, m, err)
+		}
+	})
+}
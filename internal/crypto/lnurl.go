@@ -0,0 +1,67 @@
+package crypto
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/btcsuite/btcd/btcutil/bech32"
+)
+
+// EncodeLNURL encodes a URL as an uppercase LNURL bech32 string (LUD-01).
+// Uppercase keeps the QR code in alphanumeric mode, which makes it smaller.
+func EncodeLNURL(rawURL string) (string, error) {
+	// Convert to 5-bit for bech32
+	converted, err := bech32.ConvertBits([]byte(rawURL), 8, 5, true)
+	if err != nil {
+		return "", fmt.Errorf("failed to convert bits: %v", err)
+	}
+
+	// Encode as bech32; LNURLs are longer than the 90 character address limit,
+	// which only applies when decoding
+	lnurl, err := bech32.Encode("lnurl", converted)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode bech32: %v", err)
+	}
+
+	return strings.ToUpper(lnurl), nil
+}
+
+// VerifyLNURLAuth verifies an LNURL-auth (LUD-04) callback: sig must be a DER
+// encoded secp256k1 signature over the 32 k1 bytes by the linking key. It
+// returns the linking key as compressed hex, so it can be used as an auth ID.
+func VerifyLNURLAuth(k1, sig, key string) (string, error) {
+	k1Bytes, err := hex.DecodeString(k1)
+	if err != nil || len(k1Bytes) != 32 {
+		return "", fmt.Errorf("k1 must be 32 bytes of hex")
+	}
+
+	sigBytes, err := hex.DecodeString(sig)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode signature: %v", err)
+	}
+
+	signature, err := ecdsa.ParseDERSignature(sigBytes)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse signature: %v", err)
+	}
+
+	keyBytes, err := hex.DecodeString(key)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode linking key: %v", err)
+	}
+
+	pubKey, err := btcec.ParsePubKey(keyBytes)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse linking key: %v", err)
+	}
+
+	// k1 is signed as-is, without hashing it again
+	if !signature.Verify(k1Bytes, pubKey) {
+		return "", fmt.Errorf("signature verification failed")
+	}
+
+	return hex.EncodeToString(pubKey.SerializeCompressed()), nil
+}
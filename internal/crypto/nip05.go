@@ -0,0 +1,169 @@
+package crypto
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// nip05MaxResponseSize caps how much of a nostr.json document is read
+const nip05MaxResponseSize = 64 << 10
+
+var (
+	// ErrNIP05NameNotFound is returned when the domain answered but does not
+	// list the name, so any earlier verification no longer holds
+	ErrNIP05NameNotFound = errors.New("name not listed in nostr.json")
+
+	nip05NameRegex   = regexp.MustCompile(`^[a-z0-9._-]{1,64}$`)
+	nip05DomainRegex = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)*(:[0-9]{1,5})?$`)
+)
+
+// ParseNIP05Identifier splits a NIP-05 identifier into its local part and
+// domain. A bare domain stands for the root identifier "_@domain".
+func ParseNIP05Identifier(identifier string) (name, domain string, err error) {
+	identifier = strings.ToLower(strings.TrimSpace(identifier))
+	name, domain, found := strings.Cut(identifier, "@")
+	if !found {
+		name, domain = "_", identifier
+	}
+
+	if !nip05NameRegex.MatchString(name) {
+		return "", "", errors.New("invalid NIP-05 name")
+	}
+	if len(domain) > 253 || !nip05DomainRegex.MatchString(domain) {
+		return "", "", errors.New("invalid NIP-05 domain")
+	}
+	return name, domain, nil
+}
+
+// IsValidNIP05Name checks if a string can be the local part of a NIP-05
+// identifier. Only lowercase letters, digits and "-_." are allowed.
+func IsValidNIP05Name(name string) bool {
+	return nip05NameRegex.MatchString(name)
+}
+
+// NormalizeNIP05Identifier returns the canonical "name@domain" form of an
+// identifier, which is how claims and cached lookups are stored
+func NormalizeNIP05Identifier(identifier string) (string, error) {
+	name, domain, err := ParseNIP05Identifier(identifier)
+	if err != nil {
+		return "", err
+	}
+	return name + "@" + domain, nil
+}
+
+// NIP05Resolver looks up the pubkey a NIP-05 identifier points to
+type NIP05Resolver interface {
+	ResolveNIP05(ctx context.Context, identifier string) (string, error)
+}
+
+// HTTPNIP05Resolver resolves identifiers by fetching
+// <scheme>://<domain>/.well-known/nostr.json?name=<name>. Redirects are not
+// followed, as NIP-05 requires.
+type HTTPNIP05Resolver struct {
+	Client *http.Client
+	Scheme string
+}
+
+// NewNIP05Resolver creates a resolver for production use. It only talks
+// HTTPS and refuses to connect to loopback, private and link-local
+// addresses, since the domain comes from user input.
+func NewNIP05Resolver(timeout time.Duration) *HTTPNIP05Resolver {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || !isPublicIP(ip) {
+				return fmt.Errorf("refusing to connect to non-public address %s", host)
+			}
+			return nil
+		},
+	}
+
+	return &HTTPNIP05Resolver{
+		Client: &http.Client{
+			Timeout: timeout,
+			Transport: &http.Transport{
+				Proxy:               nil,
+				DialContext:         dialer.DialContext,
+				TLSHandshakeTimeout: timeout,
+			},
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		Scheme: "https",
+	}
+}
+
+// ResolveNIP05 returns the hex pubkey that the identifier's domain lists for
+// its name, or ErrNIP05NameNotFound
+func (r *HTTPNIP05Resolver) ResolveNIP05(ctx context.Context, identifier string) (string, error) {
+	name, domain, err := ParseNIP05Identifier(identifier)
+	if err != nil {
+		return "", err
+	}
+
+	endpoint := url.URL{
+		Scheme:   r.Scheme,
+		Host:     domain,
+		Path:     "/.well-known/nostr.json",
+		RawQuery: url.Values{"name": {name}}.Encode(),
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to build request: %v", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch nostr.json: %v", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return "", ErrNIP05NameNotFound
+	case resp.StatusCode != http.StatusOK:
+		return "", fmt.Errorf("nostr.json returned status %d", resp.StatusCode)
+	}
+
+	var doc struct {
+		Names map[string]string `json:"names"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, nip05MaxResponseSize)).Decode(&doc); err != nil {
+		return "", fmt.Errorf("invalid nostr.json: %v", err)
+	}
+
+	pubkey, ok := doc.Names[name]
+	if !ok {
+		return "", ErrNIP05NameNotFound
+	}
+	pubkey = strings.ToLower(pubkey)
+	if err := ValidateNostrPubkey(pubkey); err != nil {
+		return "", fmt.Errorf("invalid pubkey in nostr.json: %v", err)
+	}
+	return pubkey, nil
+}
+
+// isPublicIP reports whether an address is routable on the public internet
+func isPublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast())
+}
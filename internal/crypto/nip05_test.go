@@ -0,0 +1,185 @@
+package crypto
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testNIP05Pubkey = "b0635d6a9851d3aed0cd6c495b282167acf761729078d975fc341b22650b07b9"
+
+// newTestNIP05Server serves nostr.json from handler and returns a resolver
+// that talks plain HTTP to it, plus the server's host for identifiers. The
+// resolver keeps the production client's redirect policy.
+func newTestNIP05Server(t *testing.T, handler http.HandlerFunc) (*HTTPNIP05Resolver, string) {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	resolver := NewNIP05Resolver(5 * time.Second)
+	resolver.Client.Transport = srv.Client().Transport
+	resolver.Scheme = "http"
+	return resolver, strings.TrimPrefix(srv.URL, "http://")
+}
+
+func TestResolveNIP05(t *testing.T) {
+	var gotPath, gotName string
+	resolver, host := newTestNIP05Server(t, func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotName = r.URL.Path, r.URL.Query().Get("name")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"names":{"bob":"` + strings.ToUpper(testNIP05Pubkey) + `","_":"` + testNIP05Pubkey + `"}}`))
+	})
+
+	pubkey, err := resolver.ResolveNIP05(context.Background(), "Bob@"+host)
+	if err != nil {
+		t.Fatalf("ResolveNIP05: %v", err)
+	}
+	if pubkey != testNIP05Pubkey {
+		t.Errorf("pubkey = %s, want %s", pubkey, testNIP05Pubkey)
+	}
+	if gotPath != "/.well-known/nostr.json" || gotName != "bob" {
+		t.Errorf("requested %s?name=%s, want /.well-known/nostr.json?name=bob", gotPath, gotName)
+	}
+
+	// A bare domain is the root identifier
+	if _, err := resolver.ResolveNIP05(context.Background(), host); err != nil {
+		t.Errorf("ResolveNIP05(root): %v", err)
+	}
+	if gotName != "_" {
+		t.Errorf("root identifier requested name=%s, want _", gotName)
+	}
+}
+
+func TestResolveNIP05Failures(t *testing.T) {
+	tests := []struct {
+		name         string
+		handler      http.HandlerFunc
+		wantNotFound bool
+		wantErr      string
+	}{
+		{
+			name: "name missing",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"names":{"alice":"` + testNIP05Pubkey + `"}}`))
+			},
+			wantNotFound: true,
+		},
+		{
+			name: "not found",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.NotFound(w, r)
+			},
+			wantNotFound: true,
+		},
+		{
+			name: "server error",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+			},
+			wantErr: "status 500",
+		},
+		{
+			name: "redirect",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Redirect(w, r, "/elsewhere/nostr.json", http.StatusFound)
+			},
+			wantErr: "status 302",
+		},
+		{
+			name: "oversize body",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"padding":"` + strings.Repeat("x", nip05MaxResponseSize) + `",`))
+				w.Write([]byte(`"names":{"bob":"` + testNIP05Pubkey + `"}}`))
+			},
+			wantErr: "invalid nostr.json",
+		},
+		{
+			name: "malformed JSON",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"names":`))
+			},
+			wantErr: "invalid nostr.json",
+		},
+		{
+			name: "invalid pubkey",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"names":{"bob":"npub1notahexkey"}}`))
+			},
+			wantErr: "invalid pubkey",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redirected := false
+			resolver, host := newTestNIP05Server(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/.well-known/nostr.json" {
+					redirected = true
+				}
+				tt.handler(w, r)
+			})
+
+			_, err := resolver.ResolveNIP05(context.Background(), "bob@"+host)
+			if redirected {
+				t.Error("resolver followed a redirect")
+			}
+			if tt.wantNotFound {
+				if !errors.Is(err, ErrNIP05NameNotFound) {
+					t.Errorf("ResolveNIP05 error = %v, want ErrNIP05NameNotFound", err)
+				}
+				return
+			}
+			if err == nil || errors.Is(err, ErrNIP05NameNotFound) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ResolveNIP05 error = %v, want one mentioning %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNIP05ResolverRefusesPrivateAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("resolver connected to a loopback address")
+	}))
+	defer srv.Close()
+
+	resolver := NewNIP05Resolver(5 * time.Second)
+	resolver.Scheme = "http"
+	_, err := resolver.ResolveNIP05(context.Background(), "bob@"+strings.TrimPrefix(srv.URL, "http://"))
+	if err == nil || !strings.Contains(err.Error(), "non-public") {
+		t.Errorf("ResolveNIP05 error = %v, want a refused connection", err)
+	}
+}
+
+func TestParseNIP05Identifier(t *testing.T) {
+	tests := []struct {
+		identifier string
+		name       string
+		domain     string
+		wantErr    bool
+	}{
+		{"bob@example.com", "bob", "example.com", false},
+		{" Bob@Example.COM ", "bob", "example.com", false},
+		{"example.com", "_", "example.com", false},
+		{"bob@localhost:8080", "bob", "localhost:8080", false},
+		{"bob smith@example.com", "", "", true},
+		{"bob@exa_mple.com", "", "", true},
+		{"bob@-example.com", "", "", true},
+		{"bob@", "", "", true},
+		{"@example.com", "", "", true},
+		{"bob@example.com/path", "", "", true},
+	}
+	for _, tt := range tests {
+		name, domain, err := ParseNIP05Identifier(tt.identifier)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseNIP05Identifier(%q) error = %v, wantErr %v", tt.identifier, err, tt.wantErr)
+			continue
+		}
+		if name != tt.name || domain != tt.domain {
+			t.Errorf("ParseNIP05Identifier(%q) = %q, %q, want %q, %q", tt.identifier, name, domain, tt.name, tt.domain)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+)
+
+// signTestEvent signs a Nostr event with a locally generated key
+func signTestEvent(t *testing.T, key *btcec.PrivateKey, event NostrEvent) NostrEvent {
+	t.Helper()
+	event.PubKey = hex.EncodeToString(schnorr.SerializePubKey(key.PubKey()))
+	if event.Tags == nil {
+		event.Tags = [][]string{}
+	}
+
+	serialized, err := json.Marshal([]interface{}{0, event.PubKey, event.CreatedAt, event.Kind, event.Tags, event.Content})
+	if err != nil {
+		t.Fatalf("serialize event: %v", err)
+	}
+	id := sha256.Sum256(serialized)
+	sig, err := schnorr.Sign(key, id[:])
+	if err != nil {
+		t.Fatalf("sign event: %v", err)
+	}
+	event.ID = hex.EncodeToString(id[:])
+	event.Sig = hex.EncodeToString(sig.Serialize())
+	return event
+}
+
+// nip98Header encodes an event as a NIP-98 Authorization header
+func nip98Header(t *testing.T, event NostrEvent) string {
+	t.Helper()
+	eventJSON, err := json.Marshal(event)
+	if err != nil {
+		t.Fatalf("marshal event: %v", err)
+	}
+	return NIP98AuthScheme + " " + base64.StdEncoding.EncodeToString(eventJSON)
+}
+
+func TestVerifyNIP98Auth(t *testing.T) {
+	key, err := btcec.NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey: %v", err)
+	}
+	pubkey := hex.EncodeToString(schnorr.SerializePubKey(key.PubKey()))
+
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"content":"Bitcoin is hope"}`)
+	bodyHash := sha256.Sum256(body)
+	req := NIP98Request{URL: "https://bitcoinpitch.org/api/pitches", Method: "POST", Body: body}
+
+	validEvent := func() NostrEvent {
+		return NostrEvent{
+			Kind:      NIP98EventKind,
+			CreatedAt: now.Unix(),
+			Tags: [][]string{
+				{"u", req.URL},
+				{"method", "POST"},
+				{"payload", hex.EncodeToString(bodyHash[:])},
+			},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		event   func() NostrEvent
+		tamper  func(NostrEvent) NostrEvent // applied after signing
+		wantErr string
+	}{
+		{
+			name:  "valid",
+			event: validEvent,
+		},
+		{
+			name: "valid without payload tag",
+			event: func() NostrEvent {
+				e := validEvent()
+				e.Tags = e.Tags[:2]
+				return e
+			},
+		},
+		{
+			name: "valid at the edge of the window",
+			event: func() NostrEvent {
+				e := validEvent()
+				e.CreatedAt = now.Add(-NIP98TimeWindow).Unix()
+				return e
+			},
+		},
+		{
+			name: "u mismatch",
+			event: func() NostrEvent {
+				e := validEvent()
+				e.Tags[0][1] = "https://bitcoinpitch.org/api/pitches/other"
+				return e
+			},
+			wantErr: "u tag",
+		},
+		{
+			name: "u missing",
+			event: func() NostrEvent {
+				e := validEvent()
+				e.Tags = e.Tags[1:]
+				return e
+			},
+			wantErr: "u tag",
+		},
+		{
+			name: "method mismatch",
+			event: func() NostrEvent {
+				e := validEvent()
+				e.Tags[1][1] = "DELETE"
+				return e
+			},
+			wantErr: "method tag",
+		},
+		{
+			name: "timestamp too old",
+			event: func() NostrEvent {
+				e := validEvent()
+				e.CreatedAt = now.Add(-NIP98TimeWindow - time.Second).Unix()
+				return e
+			},
+			wantErr: "window",
+		},
+		{
+			name: "timestamp in the future",
+			event: func() NostrEvent {
+				e := validEvent()
+				e.CreatedAt = now.Add(NIP98TimeWindow + time.Second).Unix()
+				return e
+			},
+			wantErr: "window",
+		},
+		{
+			name: "payload hash mismatch",
+			event: func() NostrEvent {
+				e := validEvent()
+				other := sha256.Sum256([]byte(`{"content":"something else"}`))
+				e.Tags[2][1] = hex.EncodeToString(other[:])
+				return e
+			},
+			wantErr: "payload tag",
+		},
+		{
+			name: "wrong kind",
+			event: func() NostrEvent {
+				e := validEvent()
+				e.Kind = 1
+				return e
+			},
+			wantErr: "kind",
+		},
+		{
+			name:  "bad signature",
+			event: validEvent,
+			tamper: func(e NostrEvent) NostrEvent {
+				sig := []byte(e.Sig)
+				if sig[0] == '0' {
+					sig[0] = '1'
+				} else {
+					sig[0] = '0'
+				}
+				e.Sig = string(sig)
+				return e
+			},
+			wantErr: "signature",
+		},
+		{
+			name:  "content changed after signing",
+			event: validEvent,
+			tamper: func(e NostrEvent) NostrEvent {
+				e.Content = "tampered"
+				return e
+			},
+			wantErr: "signature",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := signTestEvent(t, key, tt.event())
+			if tt.tamper != nil {
+				event = tt.tamper(event)
+			}
+
+			got, err := VerifyNIP98Auth(nip98Header(t, event), req, now)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("VerifyNIP98Auth: %v", err)
+				}
+				if got != pubkey {
+					t.Errorf("pubkey = %s, want %s", got, pubkey)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("VerifyNIP98Auth error = %v, want one mentioning %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyNIP98AuthHeader(t *testing.T) {
+	key, err := btcec.NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey: %v", err)
+	}
+	now := time.Now()
+	req := NIP98Request{URL: "https://bitcoinpitch.org/api/pitches", Method: "GET"}
+	event := signTestEvent(t, key, NostrEvent{
+		Kind:      NIP98EventKind,
+		CreatedAt: now.Unix(),
+		Tags:      [][]string{{"u", req.URL}, {"method", "GET"}},
+	})
+	header := nip98Header(t, event)
+
+	// Unpadded base64 and a lowercase scheme are accepted
+	unpadded := "nostr " + strings.TrimRight(strings.TrimPrefix(header, NIP98AuthScheme+" "), "=")
+	if _, err := VerifyNIP98Auth(unpadded, req, now); err != nil {
+		t.Errorf("unpadded header: %v", err)
+	}
+
+	for _, bad := range []string{
+		"",
+		"Bearer " + strings.TrimPrefix(header, NIP98AuthScheme+" "),
+		"Nostr not-base64!",
+		"Nostr " + base64.StdEncoding.EncodeToString([]byte("not json")),
+	} {
+		if _, err := VerifyNIP98Auth(bad, req, now); err == nil {
+			t.Errorf("VerifyNIP98Auth(%q) accepted a malformed header", bad)
+		}
+	}
+}
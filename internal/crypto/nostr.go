@@ -1,6 +1,7 @@
 package crypto
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
@@ -103,10 +104,15 @@ func VerifyNostrEvent(event interface{}) error {
 		eventMap["content"],
 	}
 
-	eventBytes, err := json.Marshal(eventData)
-	if err != nil {
+	// NIP-01 serializes without HTML escaping, so "<", ">" and "&" in the
+	// content must be hashed as they are
+	var eventBuf bytes.Buffer
+	encoder := json.NewEncoder(&eventBuf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(eventData); err != nil {
 		return fmt.Errorf("failed to serialize event: %v", err)
 	}
+	eventBytes := bytes.TrimSuffix(eventBuf.Bytes(), []byte("\n"))
 
 	eventHash := sha256.Sum256(eventBytes)
 
@@ -1,6 +1,7 @@
 package crypto
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"encoding/base64"
 	"errors"
@@ -10,12 +11,15 @@ import (
 	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
 )
 
 // BitcoinMessagePrefix is the standard Bitcoin message signing prefix
 const BitcoinMessagePrefix = "\x18Bitcoin Signed Message:\n"
 
-// VerifyBitcoinMessage verifies a Bitcoin message signature
+// VerifyBitcoinMessage verifies a Bitcoin message signature. It accepts legacy
+// "Bitcoin Signed Message" signatures (including the Trezor/Electrum segwit
+// header flags) as well as BIP-322 simple and full signatures.
 func VerifyBitcoinMessage(message, signature, address string) error {
 	// Decode the signature from base64
 	sigBytes, err := base64.StdEncoding.DecodeString(signature)
@@ -23,25 +27,55 @@ func VerifyBitcoinMessage(message, signature, address string) error {
 		return fmt.Errorf("invalid signature encoding: %v", err)
 	}
 
-	if len(sigBytes) != 65 {
-		return errors.New("signature must be 65 bytes")
+	addr, err := btcutil.DecodeAddress(address, &chaincfg.MainNetParams)
+	if err != nil {
+		return fmt.Errorf("invalid address: %v", err)
 	}
 
-	// Extract recovery flag and signature
-	recoveryFlag := sigBytes[0]
-	if recoveryFlag < 27 || recoveryFlag > 34 {
-		return errors.New("invalid recovery flag")
+	// Legacy compact signatures are exactly 65 bytes with a known header flag;
+	// anything else is treated as a BIP-322 signature
+	if len(sigBytes) == 65 && sigBytes[0] >= 27 && sigBytes[0] <= 42 {
+		return verifyLegacyMessage(message, sigBytes, addr)
 	}
 
-	// Convert recovery flag to standard format (0-3)
+	return verifyBIP322Message(message, sigBytes, addr)
+}
+
+// verifyLegacyMessage verifies a compact recoverable signature over the
+// standard Bitcoin message hash
+func verifyLegacyMessage(message string, sigBytes []byte, addr btcutil.Address) error {
+	// Header flags: 27-30 uncompressed P2PKH, 31-34 compressed P2PKH,
+	// 35-38 P2SH-P2WPKH and 39-42 P2WPKH (Trezor/Electrum)
+	recoveryFlag := sigBytes[0]
 	isCompressed := recoveryFlag >= 31
-	recoveryFlag = (recoveryFlag - 27) & 3
+	recoveryID := (recoveryFlag - 27) & 3
+
+	var addressType string
+	switch {
+	case recoveryFlag >= 39:
+		addressType = "p2wpkh"
+	case recoveryFlag >= 35:
+		addressType = "p2sh-p2wpkh"
+	case recoveryFlag >= 31:
+		// Compressed keys may have signed for any single-key address type
+		addressType = ""
+	default:
+		addressType = "p2pkh"
+	}
+
+	// Normalize the header so the recovery accepts it
+	compactSig := make([]byte, 65)
+	compactSig[0] = 27 + recoveryID
+	if isCompressed {
+		compactSig[0] += 4
+	}
+	copy(compactSig[1:], sigBytes[1:])
 
 	// Create the message hash using Bitcoin's message signing format
 	messageHash := createMessageHash(message)
 
 	// Recover the public key
-	pubKey, wasCompressed, err := ecdsa.RecoverCompact(sigBytes[1:], messageHash[:])
+	pubKey, wasCompressed, err := ecdsa.RecoverCompact(compactSig, messageHash[:])
 	if err != nil {
 		return fmt.Errorf("failed to recover public key: %v", err)
 	}
@@ -51,20 +85,40 @@ func VerifyBitcoinMessage(message, signature, address string) error {
 		return errors.New("compression flag mismatch")
 	}
 
+	if addressType == "" {
+		switch addr.(type) {
+		case *btcutil.AddressWitnessPubKeyHash:
+			addressType = "p2wpkh"
+		case *btcutil.AddressScriptHash:
+			addressType = "p2sh-p2wpkh"
+		case *btcutil.AddressPubKeyHash:
+			addressType = "p2pkh"
+		default:
+			return errors.New("legacy signatures are not supported for this address type")
+		}
+	}
+
 	// Convert public key to address
 	var pubKeyAddress string
-	if isCompressed {
-		pubKeyAddress, err = pubKeyToCompressedAddress(pubKey)
-	} else {
-		pubKeyAddress, err = pubKeyToUncompressedAddress(pubKey)
+	switch addressType {
+	case "p2wpkh":
+		pubKeyAddress, err = pubKeyToWitnessAddress(pubKey)
+	case "p2sh-p2wpkh":
+		pubKeyAddress, err = pubKeyToNestedWitnessAddress(pubKey)
+	default:
+		if isCompressed {
+			pubKeyAddress, err = pubKeyToCompressedAddress(pubKey)
+		} else {
+			pubKeyAddress, err = pubKeyToUncompressedAddress(pubKey)
+		}
 	}
 	if err != nil {
 		return fmt.Errorf("failed to convert public key to address: %v", err)
 	}
 
 	// Verify address matches
-	if pubKeyAddress != address {
-		return fmt.Errorf("address mismatch: expected %s, got %s", address, pubKeyAddress)
+	if pubKeyAddress != addr.EncodeAddress() {
+		return fmt.Errorf("address mismatch: expected %s, got %s", addr.EncodeAddress(), pubKeyAddress)
 	}
 
 	return nil
@@ -72,11 +126,13 @@ func VerifyBitcoinMessage(message, signature, address string) error {
 
 // createMessageHash creates a hash of the message using Bitcoin's message signing format
 func createMessageHash(message string) [32]byte {
-	// Create the full message with Bitcoin prefix
-	fullMessage := BitcoinMessagePrefix + fmt.Sprintf("%c", len(message)) + message
+	// Create the full message with Bitcoin prefix and a varint-prefixed message
+	var buf bytes.Buffer
+	buf.WriteString(BitcoinMessagePrefix)
+	_ = wire.WriteVarString(&buf, 0, message)
 
 	// Double SHA256 hash
-	firstHash := sha256.Sum256([]byte(fullMessage))
+	firstHash := sha256.Sum256(buf.Bytes())
 	return sha256.Sum256(firstHash[:])
 }
 
@@ -110,6 +166,33 @@ func pubKeyToUncompressedAddress(pubKey *btcec.PublicKey) (string, error) {
 	return addr.EncodeAddress(), nil
 }
 
+// pubKeyToWitnessAddress converts a public key to a native segwit (P2WPKH) address
+func pubKeyToWitnessAddress(pubKey *btcec.PublicKey) (string, error) {
+	pubKeyHash := btcutil.Hash160(pubKey.SerializeCompressed())
+	addr, err := btcutil.NewAddressWitnessPubKeyHash(pubKeyHash, &chaincfg.MainNetParams)
+	if err != nil {
+		return "", err
+	}
+
+	return addr.EncodeAddress(), nil
+}
+
+// pubKeyToNestedWitnessAddress converts a public key to a nested segwit (P2SH-P2WPKH) address
+func pubKeyToNestedWitnessAddress(pubKey *btcec.PublicKey) (string, error) {
+	redeemScript := witnessRedeemScript(pubKey.SerializeCompressed())
+	addr, err := btcutil.NewAddressScriptHash(redeemScript, &chaincfg.MainNetParams)
+	if err != nil {
+		return "", err
+	}
+
+	return addr.EncodeAddress(), nil
+}
+
+// witnessRedeemScript builds the P2SH-P2WPKH redeem script (OP_0 <20-byte key hash>)
+func witnessRedeemScript(pubKeyBytes []byte) []byte {
+	return append([]byte{0x00, 0x14}, btcutil.Hash160(pubKeyBytes)...)
+}
+
 // ValidateBitcoinAddress validates if a string is a valid Bitcoin address
 func ValidateBitcoinAddress(address string) error {
 	_, err := btcutil.DecodeAddress(address, &chaincfg.MainNetParams)
@@ -0,0 +1,361 @@
+package crypto
+
+import (
+	"bytes"
+	stdcrypto "crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+)
+
+// COSE algorithm identifiers accepted for WebAuthn credentials, in order of preference
+const (
+	WebAuthnAlgES256 = -7
+	WebAuthnAlgEdDSA = -8
+	WebAuthnAlgRS256 = -257
+)
+
+// WebAuthnAlgorithms lists the credential algorithms offered to authenticators
+var WebAuthnAlgorithms = []int{WebAuthnAlgES256, WebAuthnAlgEdDSA, WebAuthnAlgRS256}
+
+// Authenticator data flags
+const (
+	webAuthnFlagUserPresent    = 0x01
+	webAuthnFlagUserVerified   = 0x04
+	webAuthnFlagBackupEligible = 0x08
+	webAuthnFlagAttestedData   = 0x40
+)
+
+// WebAuthnRelyingParty identifies this site to authenticators
+type WebAuthnRelyingParty struct {
+	ID     string
+	Origin string
+	Name   string
+}
+
+// NewWebAuthnRelyingParty derives the relying party from the public site URL:
+// the RP ID is its host name and the origin its scheme and host
+func NewWebAuthnRelyingParty(siteURL, name string) (WebAuthnRelyingParty, error) {
+	u, err := url.Parse(siteURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return WebAuthnRelyingParty{}, fmt.Errorf("invalid site URL %q", siteURL)
+	}
+	return WebAuthnRelyingParty{
+		ID:     u.Hostname(),
+		Origin: u.Scheme + "://" + u.Host,
+		Name:   name,
+	}, nil
+}
+
+// WebAuthnCredential is a public key credential created by a registration ceremony
+type WebAuthnCredential struct {
+	ID             []byte
+	PublicKey      []byte // COSE_Key, as sent by the authenticator
+	Algorithm      int
+	SignCount      uint32
+	AAGUID         []byte
+	UserVerified   bool
+	BackupEligible bool
+	Challenge      []byte // challenge from the client data, to be checked by the caller
+}
+
+// WebAuthnAssertion is the authenticator response of an authentication ceremony
+type WebAuthnAssertion struct {
+	ClientDataJSON    []byte
+	AuthenticatorData []byte
+	Signature         []byte
+}
+
+// WebAuthnAssertionResult is the outcome of a verified authentication ceremony
+type WebAuthnAssertionResult struct {
+	SignCount    uint32
+	UserVerified bool
+	Challenge    []byte // challenge from the client data, to be checked by the caller
+}
+
+// webAuthnClientData is the JSON the browser signs over (CollectedClientData)
+type webAuthnClientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+// webAuthnAuthData is the parsed authenticator data
+type webAuthnAuthData struct {
+	RPIDHash  []byte
+	Flags     byte
+	SignCount uint32
+	AAGUID    []byte
+	CredID    []byte
+	PublicKey []byte
+}
+
+// VerifyWebAuthnRegistration verifies the response of a registration ceremony
+// (navigator.credentials.create) and returns the new credential. Attestation
+// statements are not checked: the site asks for "none" attestation and does
+// not restrict which authenticators may be used.
+func VerifyWebAuthnRegistration(rp WebAuthnRelyingParty, clientDataJSON, attestationObject []byte, requireUV bool) (*WebAuthnCredential, error) {
+	challenge, err := verifyWebAuthnClientData(rp, clientDataJSON, "webauthn.create")
+	if err != nil {
+		return nil, err
+	}
+
+	decoded, _, err := decodeCBOR(attestationObject)
+	if err != nil {
+		return nil, fmt.Errorf("invalid attestation object: %v", err)
+	}
+	attestation, ok := decoded.(map[interface{}]interface{})
+	if !ok {
+		return nil, errors.New("invalid attestation object: not a map")
+	}
+	rawAuthData, ok := attestation["authData"].([]byte)
+	if !ok {
+		return nil, errors.New("invalid attestation object: missing authData")
+	}
+
+	authData, err := parseWebAuthnAuthData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkWebAuthnAuthData(rp, authData, requireUV); err != nil {
+		return nil, err
+	}
+	if authData.Flags&webAuthnFlagAttestedData == 0 {
+		return nil, errors.New("authenticator data has no attested credential")
+	}
+
+	alg, _, err := parseCOSEKey(authData.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &WebAuthnCredential{
+		ID:             authData.CredID,
+		PublicKey:      authData.PublicKey,
+		Algorithm:      alg,
+		SignCount:      authData.SignCount,
+		AAGUID:         authData.AAGUID,
+		UserVerified:   authData.Flags&webAuthnFlagUserVerified != 0,
+		BackupEligible: authData.Flags&webAuthnFlagBackupEligible != 0,
+		Challenge:      challenge,
+	}, nil
+}
+
+// VerifyWebAuthnAssertion verifies the response of an authentication ceremony
+// (navigator.credentials.get) against a stored COSE public key
+func VerifyWebAuthnAssertion(rp WebAuthnRelyingParty, assertion WebAuthnAssertion, publicKey []byte, requireUV bool) (*WebAuthnAssertionResult, error) {
+	challenge, err := verifyWebAuthnClientData(rp, assertion.ClientDataJSON, "webauthn.get")
+	if err != nil {
+		return nil, err
+	}
+
+	authData, err := parseWebAuthnAuthData(assertion.AuthenticatorData)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkWebAuthnAuthData(rp, authData, requireUV); err != nil {
+		return nil, err
+	}
+
+	alg, key, err := parseCOSEKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// The signature covers the authenticator data followed by the client data hash
+	clientDataHash := sha256.Sum256(assertion.ClientDataJSON)
+	signed := append(append([]byte(nil), assertion.AuthenticatorData...), clientDataHash[:]...)
+	if err := verifyCOSESignature(alg, key, signed, assertion.Signature); err != nil {
+		return nil, err
+	}
+
+	return &WebAuthnAssertionResult{
+		SignCount:    authData.SignCount,
+		UserVerified: authData.Flags&webAuthnFlagUserVerified != 0,
+		Challenge:    challenge,
+	}, nil
+}
+
+// CheckWebAuthnSignCount checks that an authenticator's signature counter
+// increased since the stored value, which suggests the authenticator was
+// cloned when it did not. Authenticators that always report 0 are accepted.
+func CheckWebAuthnSignCount(stored, received uint32) error {
+	if stored == 0 && received == 0 {
+		return nil
+	}
+	if received <= stored {
+		return fmt.Errorf("signature counter went from %d to %d", stored, received)
+	}
+	return nil
+}
+
+// EncodeWebAuthnBase64 encodes binary WebAuthn data as unpadded base64url, the
+// encoding browsers use for challenges in the client data
+func EncodeWebAuthnBase64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeWebAuthnBase64 decodes base64url data, with or without padding
+func DecodeWebAuthnBase64(encoded string) ([]byte, error) {
+	if data, err := base64.RawURLEncoding.DecodeString(encoded); err == nil {
+		return data, nil
+	}
+	return base64.URLEncoding.DecodeString(encoded)
+}
+
+// verifyWebAuthnClientData checks the ceremony type and origin of the client
+// data and returns the decoded challenge
+func verifyWebAuthnClientData(rp WebAuthnRelyingParty, clientDataJSON []byte, ceremony string) ([]byte, error) {
+	var clientData webAuthnClientData
+	if err := json.Unmarshal(clientDataJSON, &clientData); err != nil {
+		return nil, fmt.Errorf("invalid client data: %v", err)
+	}
+	if clientData.Type != ceremony {
+		return nil, fmt.Errorf("client data type must be %s", ceremony)
+	}
+	if clientData.Origin != rp.Origin {
+		return nil, fmt.Errorf("client data origin %q does not match %q", clientData.Origin, rp.Origin)
+	}
+	if clientData.CrossOrigin {
+		return nil, errors.New("cross-origin ceremonies are not allowed")
+	}
+
+	challenge, err := DecodeWebAuthnBase64(clientData.Challenge)
+	if err != nil || len(challenge) == 0 {
+		return nil, errors.New("invalid client data challenge")
+	}
+	return challenge, nil
+}
+
+// parseWebAuthnAuthData parses authenticator data, including the attested
+// credential data when present
+func parseWebAuthnAuthData(data []byte) (*webAuthnAuthData, error) {
+	if len(data) < 37 {
+		return nil, errors.New("authenticator data is too short")
+	}
+
+	authData := &webAuthnAuthData{
+		RPIDHash:  data[:32],
+		Flags:     data[32],
+		SignCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+
+	if authData.Flags&webAuthnFlagAttestedData != 0 {
+		rest := data[37:]
+		if len(rest) < 18 {
+			return nil, errors.New("attested credential data is too short")
+		}
+		authData.AAGUID = rest[:16]
+		idLength := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if idLength == 0 || idLength > 1023 || len(rest) < idLength {
+			return nil, errors.New("invalid credential ID length")
+		}
+		authData.CredID = rest[:idLength]
+		rest = rest[idLength:]
+
+		// The public key is CBOR and may be followed by extension data
+		_, keyLength, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, fmt.Errorf("invalid credential public key: %v", err)
+		}
+		authData.PublicKey = rest[:keyLength]
+	}
+
+	return authData, nil
+}
+
+// checkWebAuthnAuthData checks the RP ID hash and the user presence and verification flags
+func checkWebAuthnAuthData(rp WebAuthnRelyingParty, authData *webAuthnAuthData, requireUV bool) error {
+	rpIDHash := sha256.Sum256([]byte(rp.ID))
+	if !bytes.Equal(authData.RPIDHash, rpIDHash[:]) {
+		return errors.New("authenticator data is for a different relying party")
+	}
+	if authData.Flags&webAuthnFlagUserPresent == 0 {
+		return errors.New("user presence was not confirmed")
+	}
+	if requireUV && authData.Flags&webAuthnFlagUserVerified == 0 {
+		return errors.New("user verification is required")
+	}
+	return nil
+}
+
+// parseCOSEKey parses a COSE_Key into its algorithm and Go public key
+func parseCOSEKey(data []byte) (int, stdcrypto.PublicKey, error) {
+	decoded, _, err := decodeCBOR(data)
+	if err != nil {
+		return 0, nil, fmt.Errorf("invalid COSE key: %v", err)
+	}
+	key, ok := decoded.(map[interface{}]interface{})
+	if !ok {
+		return 0, nil, errors.New("invalid COSE key: not a map")
+	}
+
+	kty, _ := key[int64(1)].(int64)
+	alg, _ := key[int64(3)].(int64)
+
+	switch {
+	case kty == 2 && alg == WebAuthnAlgES256:
+		crv, _ := key[int64(-1)].(int64)
+		x, _ := key[int64(-2)].([]byte)
+		y, _ := key[int64(-3)].([]byte)
+		if crv != 1 || len(x) != 32 || len(y) != 32 {
+			return 0, nil, errors.New("invalid P-256 COSE key")
+		}
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return 0, nil, errors.New("P-256 COSE key is not on the curve")
+		}
+		return WebAuthnAlgES256, pub, nil
+	case kty == 1 && alg == WebAuthnAlgEdDSA:
+		crv, _ := key[int64(-1)].(int64)
+		x, _ := key[int64(-2)].([]byte)
+		if crv != 6 || len(x) != ed25519.PublicKeySize {
+			return 0, nil, errors.New("invalid Ed25519 COSE key")
+		}
+		return WebAuthnAlgEdDSA, ed25519.PublicKey(x), nil
+	case kty == 3 && alg == WebAuthnAlgRS256:
+		n, _ := key[int64(-1)].([]byte)
+		e, _ := key[int64(-2)].([]byte)
+		if len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return 0, nil, errors.New("invalid RSA COSE key")
+		}
+		exponent := new(big.Int).SetBytes(e)
+		return WebAuthnAlgRS256, &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+	}
+
+	return 0, nil, fmt.Errorf("unsupported COSE key type %d with algorithm %d", kty, alg)
+}
+
+// verifyCOSESignature verifies a WebAuthn signature for the key's algorithm
+func verifyCOSESignature(alg int, key stdcrypto.PublicKey, signed, signature []byte) error {
+	switch alg {
+	case WebAuthnAlgES256:
+		digest := sha256.Sum256(signed)
+		if !ecdsa.VerifyASN1(key.(*ecdsa.PublicKey), digest[:], signature) {
+			return errors.New("signature verification failed")
+		}
+	case WebAuthnAlgEdDSA:
+		if !ed25519.Verify(key.(ed25519.PublicKey), signed, signature) {
+			return errors.New("signature verification failed")
+		}
+	case WebAuthnAlgRS256:
+		digest := sha256.Sum256(signed)
+		if err := rsa.VerifyPKCS1v15(key.(*rsa.PublicKey), stdcrypto.SHA256, digest[:], signature); err != nil {
+			return errors.New("signature verification failed")
+		}
+	default:
+		return fmt.Errorf("unsupported algorithm %d", alg)
+	}
+	return nil
+}
@@ -0,0 +1,335 @@
+package crypto
+
+import (
+	"bytes"
+	stdcrypto "crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+var testRelyingParty = WebAuthnRelyingParty{
+	ID:     "bitcoinpitch.org",
+	Origin: "https://bitcoinpitch.org",
+	Name:   "BitcoinPitch.org",
+}
+
+// softwareAuthenticator is a WebAuthn authenticator backed by an in-memory key
+type softwareAuthenticator struct {
+	credentialID []byte
+	signer       stdcrypto.Signer
+	alg          int
+	signCount    uint32
+}
+
+func newSoftwareAuthenticator(t *testing.T, alg int) *softwareAuthenticator {
+	t.Helper()
+	a := &softwareAuthenticator{credentialID: make([]byte, 16), alg: alg, signCount: 1}
+	if _, err := rand.Read(a.credentialID); err != nil {
+		t.Fatalf("rand: %v", err)
+	}
+
+	var err error
+	switch alg {
+	case WebAuthnAlgES256:
+		a.signer, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case WebAuthnAlgEdDSA:
+		_, a.signer, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		t.Fatalf("unsupported algorithm %d", alg)
+	}
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	return a
+}
+
+// coseKey encodes the authenticator's public key as a COSE_Key
+func (a *softwareAuthenticator) coseKey() []byte {
+	switch pub := a.signer.Public().(type) {
+	case *ecdsa.PublicKey:
+		x, y := make([]byte, 32), make([]byte, 32)
+		pub.X.FillBytes(x)
+		pub.Y.FillBytes(y)
+		return encodeCBOR(cborMap{{1, 2}, {3, WebAuthnAlgES256}, {-1, 1}, {-2, x}, {-3, y}})
+	case ed25519.PublicKey:
+		return encodeCBOR(cborMap{{1, 1}, {3, WebAuthnAlgEdDSA}, {-1, 6}, {-2, []byte(pub)}})
+	}
+	panic("unsupported key")
+}
+
+// authData builds authenticator data, with the attested credential when attested is set
+func (a *softwareAuthenticator) authData(rpID string, flags byte, attested bool) []byte {
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	var buf bytes.Buffer
+	buf.Write(rpIDHash[:])
+	if attested {
+		flags |= webAuthnFlagAttestedData
+	}
+	buf.WriteByte(flags)
+	_ = binary.Write(&buf, binary.BigEndian, a.signCount)
+	if attested {
+		buf.Write(make([]byte, 16)) // AAGUID
+		_ = binary.Write(&buf, binary.BigEndian, uint16(len(a.credentialID)))
+		buf.Write(a.credentialID)
+		buf.Write(a.coseKey())
+	}
+	return buf.Bytes()
+}
+
+// sign signs authenticator data and the client data hash
+func (a *softwareAuthenticator) sign(t *testing.T, authData, clientDataJSON []byte) []byte {
+	t.Helper()
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte(nil), authData...), clientDataHash[:]...)
+
+	var sig []byte
+	var err error
+	if a.alg == WebAuthnAlgES256 {
+		digest := sha256.Sum256(signed)
+		sig, err = a.signer.Sign(rand.Reader, digest[:], stdcrypto.SHA256)
+	} else {
+		sig, err = a.signer.Sign(rand.Reader, signed, stdcrypto.Hash(0))
+	}
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return sig
+}
+
+// clientData builds the JSON a browser passes to the authenticator
+func clientData(t *testing.T, ceremony, origin string, challenge []byte, crossOrigin bool) []byte {
+	t.Helper()
+	data, err := json.Marshal(map[string]interface{}{
+		"type":        ceremony,
+		"challenge":   EncodeWebAuthnBase64(challenge),
+		"origin":      origin,
+		"crossOrigin": crossOrigin,
+	})
+	if err != nil {
+		t.Fatalf("marshal client data: %v", err)
+	}
+	return data
+}
+
+// attestationObject wraps authenticator data in a "none" attestation
+func attestationObject(authData []byte) []byte {
+	return encodeCBOR(cborMap{{"fmt", "none"}, {"attStmt", cborMap{}}, {"authData", authData}})
+}
+
+var webAuthnTestAlgorithms = []struct {
+	name string
+	alg  int
+}{
+	{"P-256", WebAuthnAlgES256},
+	{"Ed25519", WebAuthnAlgEdDSA},
+}
+
+func TestVerifyWebAuthnRegistration(t *testing.T) {
+	for _, algorithm := range webAuthnTestAlgorithms {
+		t.Run(algorithm.name, func(t *testing.T) {
+			a := newSoftwareAuthenticator(t, algorithm.alg)
+			challenge := []byte("registration-challenge")
+			flags := byte(webAuthnFlagUserPresent | webAuthnFlagUserVerified | webAuthnFlagBackupEligible)
+
+			credential, err := VerifyWebAuthnRegistration(testRelyingParty,
+				clientData(t, "webauthn.create", testRelyingParty.Origin, challenge, false),
+				attestationObject(a.authData(testRelyingParty.ID, flags, true)), true)
+			if err != nil {
+				t.Fatalf("VerifyWebAuthnRegistration: %v", err)
+			}
+			if !bytes.Equal(credential.ID, a.credentialID) {
+				t.Errorf("credential ID = %x, want %x", credential.ID, a.credentialID)
+			}
+			if credential.Algorithm != algorithm.alg {
+				t.Errorf("algorithm = %d, want %d", credential.Algorithm, algorithm.alg)
+			}
+			if !bytes.Equal(credential.Challenge, challenge) {
+				t.Errorf("challenge = %q, want %q", credential.Challenge, challenge)
+			}
+			if !credential.UserVerified || !credential.BackupEligible {
+				t.Errorf("flags not reported: UV %v, BE %v", credential.UserVerified, credential.BackupEligible)
+			}
+			if credential.SignCount != a.signCount {
+				t.Errorf("sign count = %d, want %d", credential.SignCount, a.signCount)
+			}
+		})
+	}
+}
+
+func TestVerifyWebAuthnRegistrationRejects(t *testing.T) {
+	a := newSoftwareAuthenticator(t, WebAuthnAlgES256)
+	challenge := []byte("registration-challenge")
+	upuv := byte(webAuthnFlagUserPresent | webAuthnFlagUserVerified)
+	validClientData := clientData(t, "webauthn.create", testRelyingParty.Origin, challenge, false)
+	validAuthData := a.authData(testRelyingParty.ID, upuv, true)
+
+	tests := []struct {
+		name              string
+		clientDataJSON    []byte
+		attestationObject []byte
+		requireUV         bool
+		want              string
+	}{
+		{"rpIdHash mismatch", validClientData, attestationObject(a.authData("evil.example", upuv, true)), false, "different relying party"},
+		{"origin mismatch", clientData(t, "webauthn.create", "https://evil.example", challenge, false), attestationObject(validAuthData), false, "origin"},
+		{"assertion client data", clientData(t, "webauthn.get", testRelyingParty.Origin, challenge, false), attestationObject(validAuthData), false, "type"},
+		{"cross-origin", clientData(t, "webauthn.create", testRelyingParty.Origin, challenge, true), attestationObject(validAuthData), false, "cross-origin"},
+		{"empty challenge", clientData(t, "webauthn.create", testRelyingParty.Origin, nil, false), attestationObject(validAuthData), false, "challenge"},
+		{"missing UP", validClientData, attestationObject(a.authData(testRelyingParty.ID, webAuthnFlagUserVerified, true)), false, "presence"},
+		{"missing UV", validClientData, attestationObject(a.authData(testRelyingParty.ID, webAuthnFlagUserPresent, true)), true, "verification"},
+		{"no attested credential", validClientData, attestationObject(a.authData(testRelyingParty.ID, upuv, false)), false, "no attested credential"},
+		{"missing authData", validClientData, encodeCBOR(cborMap{{"fmt", "none"}}), false, "missing authData"},
+		{"not a map", validClientData, encodeCBOR([]interface{}{1}), false, "not a map"},
+		{"truncated attestation object", validClientData, attestationObject(validAuthData)[:40], false, "invalid attestation object"},
+		{"truncated credential public key", validClientData, attestationObject(validAuthData[:len(validAuthData)-10]), false, "public key"},
+		{"truncated credential data", validClientData, attestationObject(validAuthData[:50]), false, "credential"},
+		{"truncated authenticator data", validClientData, attestationObject(validAuthData[:36]), false, "too short"},
+		{"malformed client data", []byte("{"), attestationObject(validAuthData), false, "client data"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := VerifyWebAuthnRegistration(testRelyingParty, tt.clientDataJSON, tt.attestationObject, tt.requireUV)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("VerifyWebAuthnRegistration error = %v, want one mentioning %q", err, tt.want)
+			}
+		})
+	}
+
+	// Without requiring UV, a present but unverified user may register
+	if _, err := VerifyWebAuthnRegistration(testRelyingParty, validClientData,
+		attestationObject(a.authData(testRelyingParty.ID, webAuthnFlagUserPresent, true)), false); err != nil {
+		t.Errorf("registration without UV: %v", err)
+	}
+}
+
+func TestVerifyWebAuthnAssertion(t *testing.T) {
+	for _, algorithm := range webAuthnTestAlgorithms {
+		t.Run(algorithm.name, func(t *testing.T) {
+			a := newSoftwareAuthenticator(t, algorithm.alg)
+			a.signCount = 7
+			challenge := []byte("login-challenge")
+			clientDataJSON := clientData(t, "webauthn.get", testRelyingParty.Origin, challenge, false)
+			authData := a.authData(testRelyingParty.ID, webAuthnFlagUserPresent|webAuthnFlagUserVerified, false)
+
+			result, err := VerifyWebAuthnAssertion(testRelyingParty, WebAuthnAssertion{
+				ClientDataJSON:    clientDataJSON,
+				AuthenticatorData: authData,
+				Signature:         a.sign(t, authData, clientDataJSON),
+			}, a.coseKey(), true)
+			if err != nil {
+				t.Fatalf("VerifyWebAuthnAssertion: %v", err)
+			}
+			if result.SignCount != 7 || !result.UserVerified || !bytes.Equal(result.Challenge, challenge) {
+				t.Errorf("result = %+v", result)
+			}
+		})
+	}
+}
+
+func TestVerifyWebAuthnAssertionRejects(t *testing.T) {
+	for _, algorithm := range webAuthnTestAlgorithms {
+		t.Run(algorithm.name, func(t *testing.T) {
+			a := newSoftwareAuthenticator(t, algorithm.alg)
+			other := newSoftwareAuthenticator(t, algorithm.alg)
+			challenge := []byte("login-challenge")
+			upuv := byte(webAuthnFlagUserPresent | webAuthnFlagUserVerified)
+			validClientData := clientData(t, "webauthn.get", testRelyingParty.Origin, challenge, false)
+			validAuthData := a.authData(testRelyingParty.ID, upuv, false)
+
+			// signed builds an assertion signed by the authenticator over what it carries
+			signed := func(clientDataJSON, authData []byte) WebAuthnAssertion {
+				return WebAuthnAssertion{
+					ClientDataJSON:    clientDataJSON,
+					AuthenticatorData: authData,
+					Signature:         a.sign(t, authData, clientDataJSON),
+				}
+			}
+			tamperedClientData := signed(validClientData, validAuthData)
+			tamperedClientData.ClientDataJSON = clientData(t, "webauthn.get", testRelyingParty.Origin, []byte("other-challenge"), false)
+			tamperedAuthData := signed(validClientData, validAuthData)
+			tamperedAuthData.AuthenticatorData = a.authData(testRelyingParty.ID, upuv|webAuthnFlagBackupEligible, false)
+
+			tests := []struct {
+				name      string
+				assertion WebAuthnAssertion
+				publicKey []byte
+				requireUV bool
+				want      string
+			}{
+				{"rpIdHash mismatch", signed(validClientData, a.authData("evil.example", upuv, false)), a.coseKey(), false, "different relying party"},
+				{"origin mismatch", signed(clientData(t, "webauthn.get", "http://bitcoinpitch.org", challenge, false), validAuthData), a.coseKey(), false, "origin"},
+				{"registration client data", signed(clientData(t, "webauthn.create", testRelyingParty.Origin, challenge, false), validAuthData), a.coseKey(), false, "type"},
+				{"missing UP", signed(validClientData, a.authData(testRelyingParty.ID, webAuthnFlagUserVerified, false)), a.coseKey(), false, "presence"},
+				{"missing UV", signed(validClientData, a.authData(testRelyingParty.ID, webAuthnFlagUserPresent, false)), a.coseKey(), true, "verification"},
+				{"wrong key", signed(validClientData, validAuthData), other.coseKey(), false, "signature verification failed"},
+				{"client data changed after signing", tamperedClientData, a.coseKey(), false, "signature verification failed"},
+				{"authenticator data changed after signing", tamperedAuthData, a.coseKey(), false, "signature verification failed"},
+				{"truncated authenticator data", signed(validClientData, validAuthData[:20]), a.coseKey(), false, "too short"},
+				{"truncated COSE key", signed(validClientData, validAuthData), a.coseKey()[:10], false, "invalid COSE key"},
+				{"COSE key of another algorithm", signed(validClientData, validAuthData),
+					encodeCBOR(cborMap{{1, 2}, {3, -36}, {-1, 1}, {-2, make([]byte, 32)}, {-3, make([]byte, 32)}}), false, "unsupported"},
+			}
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					_, err := VerifyWebAuthnAssertion(testRelyingParty, tt.assertion, tt.publicKey, tt.requireUV)
+					if err == nil || !strings.Contains(err.Error(), tt.want) {
+						t.Errorf("VerifyWebAuthnAssertion error = %v, want one mentioning %q", err, tt.want)
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestParseCOSEKeyRejectsPointOffCurve(t *testing.T) {
+	x, y := make([]byte, 32), make([]byte, 32)
+	x[31], y[31] = 1, 1
+	key := encodeCBOR(cborMap{{1, 2}, {3, WebAuthnAlgES256}, {-1, 1}, {-2, x}, {-3, y}})
+	if _, _, err := parseCOSEKey(key); err == nil || !strings.Contains(err.Error(), "not on the curve") {
+		t.Errorf("parseCOSEKey error = %v, want an off-curve error", err)
+	}
+}
+
+func TestCheckWebAuthnSignCount(t *testing.T) {
+	tests := []struct {
+		stored, received uint32
+		valid            bool
+	}{
+		{0, 0, true},
+		{0, 1, true},
+		{5, 6, true},
+		{5, 100, true},
+		{5, 5, false},
+		{5, 4, false},
+		{5, 0, false},
+	}
+	for _, tt := range tests {
+		err := CheckWebAuthnSignCount(tt.stored, tt.received)
+		if tt.valid && err != nil {
+			t.Errorf("CheckWebAuthnSignCount(%d, %d): %v", tt.stored, tt.received, err)
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("CheckWebAuthnSignCount(%d, %d) accepted a counter regression", tt.stored, tt.received)
+		}
+	}
+}
+
+func TestNewWebAuthnRelyingParty(t *testing.T) {
+	rp, err := NewWebAuthnRelyingParty("https://bitcoinpitch.org:8443/some/path", "BitcoinPitch.org")
+	if err != nil {
+		t.Fatalf("NewWebAuthnRelyingParty: %v", err)
+	}
+	if rp.ID != "bitcoinpitch.org" || rp.Origin != "https://bitcoinpitch.org:8443" {
+		t.Errorf("relying party = %+v", rp)
+	}
+	if _, err := NewWebAuthnRelyingParty("bitcoinpitch.org", "x"); err == nil {
+		t.Error("accepted a site URL without a scheme")
+	}
+}
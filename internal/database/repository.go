@@ -3,6 +3,7 @@ package database
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net"
@@ -10,9 +11,11 @@ import (
 	"time"
 
 	"bitcoinpitch.org/internal/models"
+	"bitcoinpitch.org/internal/ranking"
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 // Common errors
@@ -20,6 +23,29 @@ var (
 	ErrNotFound = errors.New("not found")
 )
 
+// Identity errors
+var (
+	ErrIdentityTaken  = errors.New("identity is already linked to an account")
+	ErrLastIdentity   = errors.New("cannot remove the last login method of an account")
+	ErrMergeSameUser  = errors.New("cannot merge an account into itself")
+	ErrMergeEmailUsed = errors.New("both accounts have an email address")
+	ErrMergeTOTPUsed  = errors.New("both accounts have two-factor authentication enabled")
+	ErrNIP05NameTaken = errors.New("username is already published as a NIP-05 identifier")
+	ErrStaleMetadata  = errors.New("a newer Nostr profile was already imported")
+)
+
+// Invite errors
+var (
+	ErrInviteCodeInvalid   = errors.New("invitation code is not valid")
+	ErrInviteQuotaExceeded = errors.New("invitation quota exceeded")
+)
+
+// Category errors
+var (
+	ErrCategoryExists = errors.New("a category with this slug already exists")
+	ErrCategoryInUse  = errors.New("category still has pitches")
+)
+
 // Repository handles database operations for all models
 type Repository struct {
 	db *DB
@@ -42,68 +68,1040 @@ func (r *Repository) Ping(ctx context.Context) error {
 
 // User operations
 
-// CreateUser creates a new user
+// CreateUser creates a new user along with their primary login identity
 func (r *Repository) CreateUser(ctx context.Context, user *models.User) error {
+	return r.db.WithTx(ctx, func(tx *sqlx.Tx) error {
+		if err := createUserTx(ctx, tx, user); err != nil {
+			return err
+		}
+		identity := models.NewUserIdentity(user.ID, user.AuthType, user.AuthID)
+		return createUserIdentityTx(ctx, tx, identity)
+	})
+}
+
+// createUserTx inserts a user row within a transaction
+func createUserTx(ctx context.Context, tx *sqlx.Tx, user *models.User) error {
 	query := `
 		INSERT INTO users (
 			id, auth_type, auth_id, username, display_name, created_at, updated_at,
 			show_auth_method, show_username, show_profile_info,
 			email, password_hash, email_verified, email_verification_token, email_verification_expires_at,
 			role, totp_secret, totp_enabled, totp_backup_codes,
-			password_reset_token, password_reset_expires_at, page_size
+			password_reset_token, password_reset_expires_at, page_size, invite_code_id
 		)
 		VALUES (
 			:id, :auth_type, :auth_id, :username, :display_name, :created_at, :updated_at,
 			:show_auth_method, :show_username, :show_profile_info,
 			:email, :password_hash, :email_verified, :email_verification_token, :email_verification_expires_at,
 			:role, :totp_secret, :totp_enabled, :totp_backup_codes,
-			:password_reset_token, :password_reset_expires_at, :page_size
+			:password_reset_token, :password_reset_expires_at, :page_size, :invite_code_id
 		)
 	`
+	if _, err := tx.NamedExecContext(ctx, query, user); err != nil {
+		return fmt.Errorf("error creating user: %w", err)
+	}
+	return nil
+}
+
+// GetUserByAuth gets the user that owns a login identity
+func (r *Repository) GetUserByAuth(ctx context.Context, authType models.AuthType, authID string) (*models.User, error) {
+	var user models.User
+	query := `
+		SELECT u.* FROM users u
+		JOIN user_identities i ON i.user_id = u.id
+		WHERE i.auth_type = $1 AND i.auth_id = $2
+	`
+	err := r.db.GetContext(ctx, &user, query, authType, authID)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetUserByID gets a user by their ID
+func (r *Repository) GetUserByID(ctx context.Context, userID uuid.UUID) (*models.User, error) {
+	var user models.User
+	query := `SELECT * FROM users WHERE id = $1`
+	err := r.db.GetContext(ctx, &user, query, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// UpdateUser updates a user
+func (r *Repository) UpdateUser(ctx context.Context, user *models.User) error {
+	query := `
+		UPDATE users
+		SET username = :username, display_name = :display_name, updated_at = :updated_at, 
+		    show_auth_method = :show_auth_method, show_username = :show_username, show_profile_info = :show_profile_info,
+		    email = :email, password_hash = :password_hash, email_verified = :email_verified,
+		    email_verification_token = :email_verification_token, email_verification_expires_at = :email_verification_expires_at,
+		    role = :role, totp_secret = :totp_secret, totp_enabled = :totp_enabled, totp_backup_codes = :totp_backup_codes,
+		    totp_last_step = :totp_last_step, totp_enabled_at = :totp_enabled_at,
+		    password_reset_token = :password_reset_token, password_reset_expires_at = :password_reset_expires_at,
+		    page_size = :page_size, disabled = :disabled, hidden = :hidden, deleted_at = :deleted_at
+		WHERE id = :id
+	`
 	_, err := r.db.NamedExecContext(ctx, query, user)
+	if isPublishedNIP05Conflict(err) {
+		return ErrNIP05NameTaken
+	}
 	return err
 }
 
-// GetUserByAuth gets a user by their auth type and ID
-func (r *Repository) GetUserByAuth(ctx context.Context, authType models.AuthType, authID string) (*models.User, error) {
-	var user models.User
-	query := `SELECT * FROM users WHERE auth_type = $1 AND auth_id = $2`
-	err := r.db.GetContext(ctx, &user, query, authType, authID)
-	if err != nil {
-		return nil, err
+// UpdateTOTPLastStep records an accepted TOTP time step. It returns false if the
+// step is not newer than the last accepted one, i.e. the code was replayed.
+func (r *Repository) UpdateTOTPLastStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error) {
+	query := `
+		UPDATE users
+		SET totp_last_step = $1, updated_at = $2
+		WHERE id = $3 AND (totp_last_step IS NULL OR totp_last_step < $1)
+	`
+	result, err := r.db.ExecContext(ctx, query, step, time.Now(), userID)
+	if err != nil {
+		return false, fmt.Errorf("error updating TOTP step: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error checking TOTP step update: %w", err)
+	}
+	return rows == 1, nil
+}
+
+// ConsumeTOTPBackupCode removes a hashed backup code from a user. It returns
+// false if the code had already been removed by a concurrent login.
+func (r *Repository) ConsumeTOTPBackupCode(ctx context.Context, userID uuid.UUID, codeHash string) (bool, error) {
+	query := `
+		UPDATE users
+		SET totp_backup_codes = array_remove(totp_backup_codes, $1), updated_at = $2
+		WHERE id = $3 AND $1 = ANY(totp_backup_codes)
+	`
+	result, err := r.db.ExecContext(ctx, query, codeHash, time.Now(), userID)
+	if err != nil {
+		return false, fmt.Errorf("error consuming backup code: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error checking backup code update: %w", err)
+	}
+	return rows == 1, nil
+}
+
+// Identity operations
+
+// GetUserIdentities lists the login identities of a user, oldest first
+func (r *Repository) GetUserIdentities(ctx context.Context, userID uuid.UUID) ([]*models.UserIdentity, error) {
+	var identities []*models.UserIdentity
+	query := `SELECT * FROM user_identities WHERE user_id = $1 ORDER BY created_at`
+	if err := r.db.SelectContext(ctx, &identities, query, userID); err != nil {
+		return nil, fmt.Errorf("error listing user identities: %w", err)
+	}
+	return identities, nil
+}
+
+// CreateUserIdentity links a login identity to a user. It returns
+// ErrIdentityTaken if the identity already belongs to any account.
+func (r *Repository) CreateUserIdentity(ctx context.Context, identity *models.UserIdentity) error {
+	return r.db.WithTx(ctx, func(tx *sqlx.Tx) error {
+		return createUserIdentityTx(ctx, tx, identity)
+	})
+}
+
+// createUserIdentityTx inserts a login identity within a transaction
+func createUserIdentityTx(ctx context.Context, tx *sqlx.Tx, identity *models.UserIdentity) error {
+	query := `
+		INSERT INTO user_identities (id, user_id, auth_type, auth_id, created_at, updated_at)
+		VALUES (:id, :user_id, :auth_type, :auth_id, :created_at, :updated_at)
+	`
+	if _, err := tx.NamedExecContext(ctx, query, identity); err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrIdentityTaken
+		}
+		return fmt.Errorf("error creating user identity: %w", err)
+	}
+	return nil
+}
+
+// DeleteUserIdentity unlinks a login identity from a user. The last identity
+// cannot be removed. If the primary identity is removed, the oldest remaining
+// one becomes primary, and removing the email identity clears the password login.
+func (r *Repository) DeleteUserIdentity(ctx context.Context, userID, id uuid.UUID) error {
+	return r.db.WithTx(ctx, func(tx *sqlx.Tx) error {
+		var user models.User
+		// Lock the user so concurrent unlinks cannot remove every identity
+		if err := tx.GetContext(ctx, &user, `SELECT * FROM users WHERE id = $1 FOR UPDATE`, userID); err != nil {
+			if err == sql.ErrNoRows {
+				return ErrNotFound
+			}
+			return fmt.Errorf("error locking user: %w", err)
+		}
+
+		var identity models.UserIdentity
+		query := `SELECT * FROM user_identities WHERE id = $1 AND user_id = $2`
+		if err := tx.GetContext(ctx, &identity, query, id, userID); err != nil {
+			if err == sql.ErrNoRows {
+				return ErrNotFound
+			}
+			return fmt.Errorf("error getting user identity: %w", err)
+		}
+
+		var count int
+		if err := tx.GetContext(ctx, &count, `SELECT COUNT(*) FROM user_identities WHERE user_id = $1`, userID); err != nil {
+			return fmt.Errorf("error counting user identities: %w", err)
+		}
+		if count <= 1 {
+			return ErrLastIdentity
+		}
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM user_identities WHERE id = $1`, id); err != nil {
+			return fmt.Errorf("error deleting user identity: %w", err)
+		}
+
+		now := time.Now()
+		if identity.IsPrimaryFor(&user) {
+			query = `
+				UPDATE users u
+				SET auth_type = i.auth_type, auth_id = i.auth_id, updated_at = $2
+				FROM (
+					SELECT auth_type, auth_id FROM user_identities
+					WHERE user_id = $1 ORDER BY created_at LIMIT 1
+				) i
+				WHERE u.id = $1
+			`
+			if _, err := tx.ExecContext(ctx, query, userID, now); err != nil {
+				return fmt.Errorf("error promoting primary identity: %w", err)
+			}
+		}
+
+		if identity.AuthType == models.AuthTypeEmail {
+			query = `
+				UPDATE users
+				SET email = NULL, password_hash = NULL, email_verified = FALSE,
+				    email_verification_token = NULL, email_verification_expires_at = NULL, updated_at = $2
+				WHERE id = $1
+			`
+			if _, err := tx.ExecContext(ctx, query, userID, now); err != nil {
+				return fmt.Errorf("error clearing email login: %w", err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// MergeUsers moves everything owned by the source account to the target
+// account and retires the source. Votes both accounts cast on the same pitch
+// are de-duplicated by keeping the target's vote. The email login moves with
+// its password, and 2FA with it, so merging never weakens a login. It returns
+// ErrMergeEmailUsed or ErrMergeTOTPUsed if both accounts have one.
+func (r *Repository) MergeUsers(ctx context.Context, sourceID, targetID uuid.UUID) error {
+	if sourceID == targetID {
+		return ErrMergeSameUser
+	}
+
+	return r.db.WithTx(ctx, func(tx *sqlx.Tx) error {
+		var source, target models.User
+		if err := tx.GetContext(ctx, &source, `SELECT * FROM users WHERE id = $1 FOR UPDATE`, sourceID); err != nil {
+			if err == sql.ErrNoRows {
+				return ErrNotFound
+			}
+			return fmt.Errorf("error locking source user: %w", err)
+		}
+		if err := tx.GetContext(ctx, &target, `SELECT * FROM users WHERE id = $1 FOR UPDATE`, targetID); err != nil {
+			if err == sql.ErrNoRows {
+				return ErrNotFound
+			}
+			return fmt.Errorf("error locking target user: %w", err)
+		}
+
+		// users.email is unique and drives the password login, so only one can survive
+		if source.Email != nil && target.Email != nil {
+			return ErrMergeEmailUsed
+		}
+		// 2FA moves with the logins it protects, and one account can only have one
+		if source.TOTPEnabled && target.TOTPEnabled {
+			return ErrMergeTOTPUsed
+		}
+
+		var affectedPitches []uuid.UUID
+		query := `
+			DELETE FROM votes s
+			USING votes t
+			WHERE s.user_id = $1 AND t.user_id = $2 AND s.pitch_id = t.pitch_id
+			RETURNING s.pitch_id
+		`
+		if err := tx.SelectContext(ctx, &affectedPitches, query, sourceID, targetID); err != nil {
+			return fmt.Errorf("error removing duplicate votes: %w", err)
+		}
+
+		if _, err := tx.ExecContext(ctx, `UPDATE votes SET user_id = $1 WHERE user_id = $2`, targetID, sourceID); err != nil {
+			return fmt.Errorf("error moving votes: %w", err)
+		}
+
+		// Recount the pitches that lost a duplicate vote
+		if err := recountPitchVotesTx(ctx, tx, affectedPitches); err != nil {
+			return err
+		}
+
+		moves := []struct {
+			query string
+			what  string
+		}{
+			{`UPDATE pitches SET user_id = $1 WHERE user_id = $2`, "pitches"},
+			{`UPDATE pitches SET posted_by = $1 WHERE posted_by = $2`, "posted pitches"},
+			{`UPDATE user_identities SET user_id = $1 WHERE user_id = $2`, "identities"},
+			{`UPDATE user_activities SET user_id = $1 WHERE user_id = $2`, "activities"},
+			{`UPDATE user_penalties SET user_id = $1 WHERE user_id = $2`, "penalties"},
+			{`UPDATE content_hashes SET user_id = $1 WHERE user_id = $2`, "content hashes"},
+			{`UPDATE webauthn_credentials SET user_id = $1 WHERE user_id = $2`, "WebAuthn credentials"},
+			{`UPDATE invite_codes SET created_by = $1 WHERE created_by = $2`, "invitation codes"},
+			{`UPDATE pitch_revisions SET edited_by = $1 WHERE edited_by = $2`, "pitch revisions"},
+		}
+		for _, move := range moves {
+			if _, err := tx.ExecContext(ctx, move.query, targetID, sourceID); err != nil {
+				return fmt.Errorf("error moving %s: %w", move.what, err)
+			}
+		}
+
+		now := time.Now()
+		if source.Email != nil {
+			query = `
+				UPDATE users
+				SET email = NULL, password_hash = NULL, email_verified = FALSE, updated_at = $2
+				WHERE id = $1
+			`
+			if _, err := tx.ExecContext(ctx, query, sourceID, now); err != nil {
+				return fmt.Errorf("error clearing source email: %w", err)
+			}
+			query = `
+				UPDATE users
+				SET email = $2, password_hash = $3, email_verified = $4, updated_at = $5
+				WHERE id = $1
+			`
+			if _, err := tx.ExecContext(ctx, query, targetID, source.Email, source.PasswordHash, source.EmailVerified, now); err != nil {
+				return fmt.Errorf("error moving email: %w", err)
+			}
+		}
+
+		if source.TOTPEnabled {
+			query = `
+				UPDATE users t
+				SET totp_enabled = s.totp_enabled, totp_secret = s.totp_secret,
+				    totp_backup_codes = s.totp_backup_codes, totp_last_step = s.totp_last_step,
+				    totp_enabled_at = s.totp_enabled_at, updated_at = $3
+				FROM users s
+				WHERE t.id = $1 AND s.id = $2
+			`
+			if _, err := tx.ExecContext(ctx, query, targetID, sourceID, now); err != nil {
+				return fmt.Errorf("error moving 2FA: %w", err)
+			}
+		}
+
+		// The NIP-05 claim was verified against Nostr keys that now belong to
+		// the target; a claim the target already has wins
+		if source.NIP05 != nil && target.NIP05 == nil {
+			query = `UPDATE users SET nip05 = $2, nip05_verified_at = $3, updated_at = $4 WHERE id = $1`
+			if _, err := tx.ExecContext(ctx, query, targetID, source.NIP05, source.NIP05VerifiedAt, now); err != nil {
+				return fmt.Errorf("error moving NIP-05 claim: %w", err)
+			}
+		}
+
+		query = `
+			UPDATE users
+			SET totp_enabled = FALSE, totp_secret = NULL, totp_backup_codes = '{}', totp_last_step = NULL,
+			    totp_enabled_at = NULL, nip05 = NULL, nip05_verified_at = NULL, publish_nip05 = FALSE,
+			    updated_at = $2
+			WHERE id = $1
+		`
+		if _, err := tx.ExecContext(ctx, query, sourceID, now); err != nil {
+			return fmt.Errorf("error clearing source credentials: %w", err)
+		}
+
+		// The source can no longer sign in: its identities now belong to the
+		// target, and links emailed to it must not work on the retired account
+		cleanups := []struct {
+			query string
+			what  string
+		}{
+			{`DELETE FROM sessions WHERE user_id = $1`, "sessions"},
+			{`DELETE FROM api_tokens WHERE user_id = $1`, "API tokens"},
+			{`DELETE FROM email_verification_tokens WHERE user_id = $1`, "email tokens"},
+			{`DELETE FROM password_reset_tokens WHERE user_id = $1`, "reset tokens"},
+		}
+		for _, cleanup := range cleanups {
+			if _, err := tx.ExecContext(ctx, cleanup.query, sourceID); err != nil {
+				return fmt.Errorf("error removing source %s: %w", cleanup.what, err)
+			}
+		}
+
+		query = `UPDATE users SET disabled = TRUE, deleted_at = $2, updated_at = $2 WHERE id = $1`
+		if _, err := tx.ExecContext(ctx, query, sourceID, now); err != nil {
+			return fmt.Errorf("error retiring source user: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// recountPitchVotesTx recomputes the cached vote counts and score of the
+// given pitches from the votes table, within a transaction
+func recountPitchVotesTx(ctx context.Context, tx *sqlx.Tx, pitchIDs []uuid.UUID) error {
+	if len(pitchIDs) == 0 {
+		return nil
+	}
+
+	query := `
+		UPDATE pitches p
+		SET vote_count = COALESCE(v.total, 0), upvote_count = COALESCE(v.up, 0),
+		    downvote_count = COALESCE(v.down, 0), score = COALESCE(v.up, 0) - COALESCE(v.down, 0)
+		FROM pitches q
+		LEFT JOIN (
+			SELECT pitch_id,
+			       COUNT(*) AS total,
+			       COUNT(*) FILTER (WHERE vote_type = 'up') AS up,
+			       COUNT(*) FILTER (WHERE vote_type = 'down') AS down
+			FROM votes
+			WHERE pitch_id = ANY($1)
+			GROUP BY pitch_id
+		) v ON v.pitch_id = q.id
+		WHERE p.id = q.id AND q.id = ANY($1)
+	`
+	if _, err := tx.ExecContext(ctx, query, pq.Array(pitchIDs)); err != nil {
+		return fmt.Errorf("error recounting votes: %w", err)
+	}
+	return nil
+}
+
+// Account export and deletion
+
+// GetUserDataExport collects everything stored about a user for download,
+// including soft-deleted pitches and expired sessions
+func (r *Repository) GetUserDataExport(ctx context.Context, userID uuid.UUID) (*models.UserDataExport, error) {
+	user, err := r.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &models.UserDataExport{
+		ExportedAt: time.Now(),
+		Profile:    user,
+	}
+
+	if export.Identities, err = r.GetUserIdentities(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT p.*,
+		       COALESCE(json_agg(jsonb_build_object(
+		         'id', t.id,
+		         'name', t.name,
+		         'usage_count', t.usage_count,
+		         'created_at', t.created_at,
+		         'updated_at', t.updated_at
+		       )) FILTER (WHERE t.id IS NOT NULL), '[]') AS tags
+		FROM pitches p
+		LEFT JOIN pitch_tags pt ON p.id = pt.pitch_id
+		LEFT JOIN tags t ON pt.tag_id = t.id
+		WHERE p.user_id = $1
+		GROUP BY p.id
+		ORDER BY p.created_at
+	`
+	if err := r.db.SelectContext(ctx, &export.Pitches, query, userID); err != nil {
+		return nil, fmt.Errorf("error exporting pitches: %w", err)
+	}
+
+	if err := r.db.SelectContext(ctx, &export.Votes, `SELECT * FROM votes WHERE user_id = $1 ORDER BY created_at`, userID); err != nil {
+		return nil, fmt.Errorf("error exporting votes: %w", err)
+	}
+
+	if err := r.db.SelectContext(ctx, &export.Sessions, `SELECT * FROM sessions WHERE user_id = $1 ORDER BY created_at`, userID); err != nil {
+		return nil, fmt.Errorf("error exporting sessions: %w", err)
+	}
+
+	query = `
+		SELECT id, user_id, action_type, target_id, ip_address, user_agent, metadata, created_at
+		FROM user_activities
+		WHERE user_id = $1
+		ORDER BY created_at`
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error exporting activities: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		activity, err := r.scanUserActivity(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error exporting activities: %w", err)
+		}
+		export.Activities = append(export.Activities, activity)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error exporting activities: %w", err)
+	}
+
+	query = `
+		SELECT id, user_id, content_hash, original_content, pitch_id, created_at, created_at AS updated_at
+		FROM content_hashes
+		WHERE user_id = $1
+		ORDER BY created_at`
+	if err := r.db.SelectContext(ctx, &export.ContentHashes, query, userID); err != nil {
+		return nil, fmt.Errorf("error exporting content hashes: %w", err)
+	}
+
+	if export.InviteCodes, err = r.GetInviteCodesByCreator(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	return export, nil
+}
+
+// ScheduleAccountDeletion marks an account for permanent removal at the given
+// time and signs it out everywhere. Signing in again before then lets the
+// user cancel.
+func (r *Repository) ScheduleAccountDeletion(ctx context.Context, userID uuid.UUID, mode string, at time.Time) error {
+	return r.db.WithTx(ctx, func(tx *sqlx.Tx) error {
+		query := `
+			UPDATE users
+			SET deletion_scheduled_at = $1, deletion_mode = $2, updated_at = $3
+			WHERE id = $4 AND deleted_at IS NULL
+		`
+		result, err := tx.ExecContext(ctx, query, at, mode, time.Now(), userID)
+		if err != nil {
+			return fmt.Errorf("error scheduling account deletion: %w", err)
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("error checking rows affected: %w", err)
+		}
+		if rows == 0 {
+			return ErrNotFound
+		}
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM sessions WHERE user_id = $1`, userID); err != nil {
+			return fmt.Errorf("error invalidating sessions: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM api_tokens WHERE user_id = $1`, userID); err != nil {
+			return fmt.Errorf("error revoking API tokens: %w", err)
+		}
+		return nil
+	})
+}
+
+// CancelAccountDeletion clears a scheduled deletion. It returns false if none
+// was scheduled.
+func (r *Repository) CancelAccountDeletion(ctx context.Context, userID uuid.UUID) (bool, error) {
+	query := `
+		UPDATE users
+		SET deletion_scheduled_at = NULL, deletion_mode = NULL, updated_at = $1
+		WHERE id = $2 AND deletion_scheduled_at IS NOT NULL
+	`
+	result, err := r.db.ExecContext(ctx, query, time.Now(), userID)
+	if err != nil {
+		return false, fmt.Errorf("error cancelling account deletion: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error checking rows affected: %w", err)
+	}
+	return rows > 0, nil
+}
+
+// GetAccountsDueForDeletion lists the accounts whose grace period ended before now
+func (r *Repository) GetAccountsDueForDeletion(ctx context.Context, now time.Time) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	query := `SELECT id FROM users WHERE deletion_scheduled_at <= $1 ORDER BY deletion_scheduled_at`
+	if err := r.db.SelectContext(ctx, &ids, query, now); err != nil {
+		return nil, fmt.Errorf("error listing accounts due for deletion: %w", err)
+	}
+	return ids, nil
+}
+
+// PurgeAccount permanently removes an account whose scheduled deletion is due.
+// Its pitches are either reassigned to the Anonymous account or deleted, as
+// the user chose. Its votes are removed and the affected pitches recounted;
+// everything else the account owns goes with it. It returns ErrNotFound if
+// the deletion was cancelled or is not due yet.
+func (r *Repository) PurgeAccount(ctx context.Context, userID uuid.UUID, now time.Time) error {
+	if userID == models.AnonymousUserID {
+		return ErrNotFound
+	}
+
+	return r.db.WithTx(ctx, func(tx *sqlx.Tx) error {
+		var user models.User
+		query := `SELECT * FROM users WHERE id = $1 AND deletion_scheduled_at <= $2 FOR UPDATE`
+		if err := tx.GetContext(ctx, &user, query, userID, now); err != nil {
+			if err == sql.ErrNoRows {
+				return ErrNotFound
+			}
+			return fmt.Errorf("error locking user: %w", err)
+		}
+
+		var affectedPitches []uuid.UUID
+		if err := tx.SelectContext(ctx, &affectedPitches, `DELETE FROM votes WHERE user_id = $1 RETURNING pitch_id`, userID); err != nil {
+			return fmt.Errorf("error removing votes: %w", err)
+		}
+		if err := recountPitchVotesTx(ctx, tx, affectedPitches); err != nil {
+			return err
+		}
+
+		if user.GetDeletionMode() == models.DeletionModeDelete {
+			// Soft deletes keep tag usage, so count every pitch that goes
+			query = `
+				UPDATE tags t
+				SET usage_count = GREATEST(t.usage_count - d.uses, 0), updated_at = $2
+				FROM (
+					SELECT pt.tag_id, COUNT(*) AS uses
+					FROM pitch_tags pt
+					JOIN pitches p ON p.id = pt.pitch_id
+					WHERE p.user_id = $1
+					GROUP BY pt.tag_id
+				) d
+				WHERE t.id = d.tag_id
+			`
+			if _, err := tx.ExecContext(ctx, query, userID, now); err != nil {
+				return fmt.Errorf("error updating tag usage: %w", err)
+			}
+			if _, err := tx.ExecContext(ctx, `DELETE FROM pitches WHERE user_id = $1`, userID); err != nil {
+				return fmt.Errorf("error deleting pitches: %w", err)
+			}
+		}
+
+		reassigns := []struct {
+			query string
+			what  string
+		}{
+			{`UPDATE pitches SET user_id = $1 WHERE user_id = $2`, "pitches"},
+			{`UPDATE pitches SET posted_by = $1 WHERE posted_by = $2`, "posted pitches"},
+			{`UPDATE pitch_revisions SET edited_by = $1 WHERE edited_by = $2`, "pitch revisions"},
+			{`UPDATE config_audit_log SET changed_by = $1 WHERE changed_by = $2`, "config audit log"},
+		}
+		for _, reassign := range reassigns {
+			if _, err := tx.ExecContext(ctx, reassign.query, models.AnonymousUserID, userID); err != nil {
+				return fmt.Errorf("error reassigning %s: %w", reassign.what, err)
+			}
+		}
+
+		if _, err := tx.ExecContext(ctx, `UPDATE config_settings SET updated_by = NULL WHERE updated_by = $1`, userID); err != nil {
+			return fmt.Errorf("error clearing config settings: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE user_penalties SET created_by = NULL WHERE created_by = $1`, userID); err != nil {
+			return fmt.Errorf("error clearing penalties: %w", err)
+		}
+
+		// Sessions, tokens, identities, credentials, activities, penalties and
+		// content hashes are removed by their foreign keys; invitation codes
+		// the user created are kept without a creator
+		if _, err := tx.ExecContext(ctx, `DELETE FROM users WHERE id = $1`, userID); err != nil {
+			return fmt.Errorf("error deleting user: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// Invite code operations
+
+// inviteCodeColumns selects invitation codes with their creator's name
+const inviteCodeColumns = `
+	i.*, COALESCE(NULLIF(u.display_name, ''), u.username) AS created_by_name
+	FROM invite_codes i
+	LEFT JOIN users u ON u.id = i.created_by`
+
+// CreateInviteCode stores a new invitation code
+func (r *Repository) CreateInviteCode(ctx context.Context, invite *models.InviteCode) error {
+	return createInviteCodeTx(ctx, r.db, invite)
+}
+
+func createInviteCodeTx(ctx context.Context, db sqlx.ExtContext, invite *models.InviteCode) error {
+	query := `
+		INSERT INTO invite_codes (id, code, created_by, note, max_uses, use_count, expires_at, revoked_at, created_at)
+		VALUES (:id, :code, :created_by, :note, :max_uses, :use_count, :expires_at, :revoked_at, :created_at)
+	`
+	if _, err := sqlx.NamedExecContext(ctx, db, query, invite); err != nil {
+		return fmt.Errorf("error creating invitation code: %w", err)
+	}
+	return nil
+}
+
+// CreateUserInviteCode stores an invitation code created by a user, as long
+// as it stays within their quota. Codes that were revoked before anyone used
+// them don't count.
+func (r *Repository) CreateUserInviteCode(ctx context.Context, invite *models.InviteCode) error {
+	if invite.CreatedBy == nil {
+		return ErrInviteQuotaExceeded
+	}
+
+	return r.db.WithTx(ctx, func(tx *sqlx.Tx) error {
+		var quota int
+		query := `SELECT invite_quota FROM users WHERE id = $1 FOR UPDATE`
+		if err := tx.GetContext(ctx, &quota, query, *invite.CreatedBy); err != nil {
+			if err == sql.ErrNoRows {
+				return ErrNotFound
+			}
+			return fmt.Errorf("error locking user: %w", err)
+		}
+
+		var used int
+		query = `SELECT COUNT(*) FROM invite_codes WHERE created_by = $1 AND (revoked_at IS NULL OR use_count > 0)`
+		if err := tx.GetContext(ctx, &used, query, *invite.CreatedBy); err != nil {
+			return fmt.Errorf("error counting invitation codes: %w", err)
+		}
+		if used >= quota {
+			return ErrInviteQuotaExceeded
+		}
+
+		return createInviteCodeTx(ctx, tx, invite)
+	})
+}
+
+// GetInviteCode gets an invitation code by its code
+func (r *Repository) GetInviteCode(ctx context.Context, code string) (*models.InviteCode, error) {
+	var invite models.InviteCode
+	if err := r.db.GetContext(ctx, &invite, `SELECT`+inviteCodeColumns+` WHERE i.code = $1`, code); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("error getting invitation code: %w", err)
+	}
+	return &invite, nil
+}
+
+// GetInviteCodes lists all invitation codes, newest first
+func (r *Repository) GetInviteCodes(ctx context.Context, limit, offset int) ([]*models.InviteCode, error) {
+	var invites []*models.InviteCode
+	query := `SELECT` + inviteCodeColumns + ` ORDER BY i.created_at DESC LIMIT $1 OFFSET $2`
+	if err := r.db.SelectContext(ctx, &invites, query, limit, offset); err != nil {
+		return nil, fmt.Errorf("error listing invitation codes: %w", err)
+	}
+	return invites, nil
+}
+
+// CountInviteCodes counts all invitation codes
+func (r *Repository) CountInviteCodes(ctx context.Context) (int, error) {
+	var count int
+	if err := r.db.GetContext(ctx, &count, `SELECT COUNT(*) FROM invite_codes`); err != nil {
+		return 0, fmt.Errorf("error counting invitation codes: %w", err)
+	}
+	return count, nil
+}
+
+// GetInviteCodesByCreator lists the invitation codes a user created, newest first
+func (r *Repository) GetInviteCodesByCreator(ctx context.Context, userID uuid.UUID) ([]*models.InviteCode, error) {
+	var invites []*models.InviteCode
+	query := `SELECT` + inviteCodeColumns + ` WHERE i.created_by = $1 ORDER BY i.created_at DESC`
+	if err := r.db.SelectContext(ctx, &invites, query, userID); err != nil {
+		return nil, fmt.Errorf("error listing invitation codes: %w", err)
+	}
+	return invites, nil
+}
+
+// RevokeInviteCode revokes an unrevoked invitation code. With a creator
+// given, only that user's codes can be revoked.
+func (r *Repository) RevokeInviteCode(ctx context.Context, id uuid.UUID, createdBy *uuid.UUID) error {
+	query := `
+		UPDATE invite_codes
+		SET revoked_at = $1
+		WHERE id = $2 AND revoked_at IS NULL AND ($3::uuid IS NULL OR created_by = $3)
+	`
+	result, err := r.db.ExecContext(ctx, query, time.Now(), id, createdBy)
+	if err != nil {
+		return fmt.Errorf("error revoking invitation code: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error checking rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// CreateUserWithInvite creates a new user like CreateUser, redeeming an
+// invitation code in the same transaction. It returns ErrInviteCodeInvalid if
+// the code is unknown, revoked, expired or used up.
+func (r *Repository) CreateUserWithInvite(ctx context.Context, user *models.User, code string) error {
+	return r.db.WithTx(ctx, func(tx *sqlx.Tx) error {
+		var invite models.InviteCode
+		if err := tx.GetContext(ctx, &invite, `SELECT * FROM invite_codes WHERE code = $1 FOR UPDATE`, code); err != nil {
+			if err == sql.ErrNoRows {
+				return ErrInviteCodeInvalid
+			}
+			return fmt.Errorf("error locking invitation code: %w", err)
+		}
+		if !invite.IsUsable(time.Now()) {
+			return ErrInviteCodeInvalid
+		}
+
+		if _, err := tx.ExecContext(ctx, `UPDATE invite_codes SET use_count = use_count + 1 WHERE id = $1`, invite.ID); err != nil {
+			return fmt.Errorf("error redeeming invitation code: %w", err)
+		}
+
+		user.InviteCodeID = &invite.ID
+		if err := createUserTx(ctx, tx, user); err != nil {
+			return err
+		}
+		identity := models.NewUserIdentity(user.ID, user.AuthType, user.AuthID)
+		return createUserIdentityTx(ctx, tx, identity)
+	})
+}
+
+// SetUserInviteQuota sets how many invitation codes a user may create
+func (r *Repository) SetUserInviteQuota(ctx context.Context, userID uuid.UUID, quota int) error {
+	query := `UPDATE users SET invite_quota = $1, updated_at = $2 WHERE id = $3`
+	result, err := r.db.ExecContext(ctx, query, quota, time.Now(), userID)
+	if err != nil {
+		return fmt.Errorf("error setting invitation quota: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error checking rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// NIP-05 operations
+
+// GetNIP05Lookup returns the cached lookup of a NIP-05 identifier
+func (r *Repository) GetNIP05Lookup(ctx context.Context, identifier string) (*models.NIP05Lookup, error) {
+	var lookup models.NIP05Lookup
+	query := `SELECT * FROM nip05_lookups WHERE identifier = $1`
+	if err := r.db.GetContext(ctx, &lookup, query, identifier); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("error getting NIP-05 lookup: %w", err)
+	}
+	return &lookup, nil
+}
+
+// SaveNIP05Lookup stores the result of a NIP-05 lookup, replacing any earlier one
+func (r *Repository) SaveNIP05Lookup(ctx context.Context, lookup *models.NIP05Lookup) error {
+	query := `
+		INSERT INTO nip05_lookups (identifier, pubkey, last_error, resolved_at, checked_at)
+		VALUES (:identifier, :pubkey, :last_error, :resolved_at, :checked_at)
+		ON CONFLICT (identifier) DO UPDATE
+		SET pubkey = EXCLUDED.pubkey,
+			last_error = EXCLUDED.last_error,
+			resolved_at = EXCLUDED.resolved_at,
+			checked_at = EXCLUDED.checked_at
+	`
+	if _, err := r.db.NamedExecContext(ctx, query, lookup); err != nil {
+		return fmt.Errorf("error saving NIP-05 lookup: %w", err)
+	}
+	return nil
+}
+
+// GetStaleNIP05Identifiers lists claimed identifiers that were never looked up
+// or were last checked before the cutoff, least recently checked first
+func (r *Repository) GetStaleNIP05Identifiers(ctx context.Context, checkedBefore time.Time, limit int) ([]string, error) {
+	var identifiers []string
+	query := `
+		SELECT c.identifier
+		FROM (
+			SELECT nip05 AS identifier FROM users WHERE nip05 IS NOT NULL
+			UNION
+			SELECT author_nip05 FROM pitches WHERE author_nip05 IS NOT NULL AND deleted_at IS NULL
+		) c
+		LEFT JOIN nip05_lookups l ON l.identifier = c.identifier
+		WHERE l.checked_at IS NULL OR l.checked_at < $1
+		ORDER BY l.checked_at NULLS FIRST
+		LIMIT $2
+	`
+	if err := r.db.SelectContext(ctx, &identifiers, query, checkedBefore, limit); err != nil {
+		return nil, fmt.Errorf("error listing stale NIP-05 identifiers: %w", err)
+	}
+	return identifiers, nil
+}
+
+// GetNostrPubkeys lists the hex pubkeys of a user's linked Nostr identities
+func (r *Repository) GetNostrPubkeys(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	var pubkeys []string
+	query := `SELECT auth_id FROM user_identities WHERE user_id = $1 AND auth_type = $2 ORDER BY created_at`
+	if err := r.db.SelectContext(ctx, &pubkeys, query, userID, models.AuthTypeNostr); err != nil {
+		return nil, fmt.Errorf("error listing Nostr pubkeys: %w", err)
+	}
+	return pubkeys, nil
+}
+
+// GetUserIDsByNIP05 lists the users claiming a NIP-05 identifier
+func (r *Repository) GetUserIDsByNIP05(ctx context.Context, identifier string) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	query := `SELECT id FROM users WHERE nip05 = $1`
+	if err := r.db.SelectContext(ctx, &ids, query, identifier); err != nil {
+		return nil, fmt.Errorf("error listing users by NIP-05: %w", err)
+	}
+	return ids, nil
+}
+
+// GetPitchesByAuthorNIP05 lists the pitches whose author claims a NIP-05
+// identifier. Only the author fields are loaded.
+func (r *Repository) GetPitchesByAuthorNIP05(ctx context.Context, identifier string) ([]*models.Pitch, error) {
+	var pitches []*models.Pitch
+	query := `
+		SELECT id, author_type, author_handle, author_nip05, author_nip05_verified_at
+		FROM pitches
+		WHERE author_nip05 = $1 AND deleted_at IS NULL
+	`
+	if err := r.db.SelectContext(ctx, &pitches, query, identifier); err != nil {
+		return nil, fmt.Errorf("error listing pitches by author NIP-05: %w", err)
+	}
+	return pitches, nil
+}
+
+// SetUserNIP05 sets or clears the NIP-05 identifier a user claims
+func (r *Repository) SetUserNIP05(ctx context.Context, userID uuid.UUID, identifier *string, verifiedAt *time.Time) error {
+	query := `UPDATE users SET nip05 = $1, nip05_verified_at = $2, updated_at = $3 WHERE id = $4`
+	if _, err := r.db.ExecContext(ctx, query, identifier, verifiedAt, time.Now(), userID); err != nil {
+		return fmt.Errorf("error setting user NIP-05: %w", err)
 	}
-	return &user, nil
+	return nil
 }
 
-// GetUserByID gets a user by their ID
-func (r *Repository) GetUserByID(ctx context.Context, userID uuid.UUID) (*models.User, error) {
+// SetUserNIP05Verified records whether a user's claimed identifier is
+// verified, unless the claim changed in the meantime
+func (r *Repository) SetUserNIP05Verified(ctx context.Context, userID uuid.UUID, identifier string, verifiedAt *time.Time) error {
+	query := `UPDATE users SET nip05_verified_at = $1 WHERE id = $2 AND nip05 = $3`
+	if _, err := r.db.ExecContext(ctx, query, verifiedAt, userID, identifier); err != nil {
+		return fmt.Errorf("error updating user NIP-05 verification: %w", err)
+	}
+	return nil
+}
+
+// ImportNostrMetadata stores a user's Nostr profile metadata. The display name
+// is only replaced while the user hasn't set one themselves: when it is
+// empty, one of placeholderNames, or the name imported last time. It returns
+// the user as it was before the import, or ErrStaleMetadata if the event is
+// not newer than the one imported before.
+func (r *Repository) ImportNostrMetadata(ctx context.Context, userID uuid.UUID, meta *models.NostrMetadata, placeholderNames []string) (*models.User, error) {
 	var user models.User
-	query := `SELECT * FROM users WHERE id = $1`
-	err := r.db.GetContext(ctx, &user, query, userID)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, ErrNotFound
+	err := r.db.WithTx(ctx, func(tx *sqlx.Tx) error {
+		if err := tx.GetContext(ctx, &user, `SELECT * FROM users WHERE id = $1 FOR UPDATE`, userID); err != nil {
+			if err == sql.ErrNoRows {
+				return ErrNotFound
+			}
+			return fmt.Errorf("error locking user: %w", err)
+		}
+		if user.NostrMetadataAt != nil && !meta.CreatedAt.After(*user.NostrMetadataAt) {
+			return ErrStaleMetadata
+		}
+
+		displayName := user.DisplayName
+		if meta.Name != nil {
+			current := user.GetDisplayName()
+			replaceable := user.DisplayName == nil || *user.DisplayName == "" ||
+				(user.NostrName != nil && current == *user.NostrName)
+			for _, placeholder := range placeholderNames {
+				replaceable = replaceable || current == placeholder
+			}
+			if replaceable {
+				displayName = meta.Name
+			}
 		}
+
+		query := `
+			UPDATE users
+			SET display_name = $1, nostr_name = $2, nostr_about = $3, nostr_picture = $4,
+			    nostr_lud16 = $5, nostr_nip05 = $6, nostr_metadata_at = $7, updated_at = $8
+			WHERE id = $9
+		`
+		if _, err := tx.ExecContext(ctx, query, displayName, meta.Name, meta.About, meta.Picture,
+			meta.Lud16, meta.NIP05, meta.CreatedAt, time.Now(), userID); err != nil {
+			return fmt.Errorf("error importing Nostr metadata: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
 	return &user, nil
 }
 
-// UpdateUser updates a user
-func (r *Repository) UpdateUser(ctx context.Context, user *models.User) error {
+// ClearNostrMetadata removes a user's imported Nostr profile. A display name
+// that came from it is kept, as it is now the user's own.
+func (r *Repository) ClearNostrMetadata(ctx context.Context, userID uuid.UUID) error {
 	query := `
 		UPDATE users
-		SET username = :username, display_name = :display_name, updated_at = :updated_at, 
-		    show_auth_method = :show_auth_method, show_username = :show_username, show_profile_info = :show_profile_info,
-		    email = :email, password_hash = :password_hash, email_verified = :email_verified,
-		    email_verification_token = :email_verification_token, email_verification_expires_at = :email_verification_expires_at,
-		    role = :role, totp_secret = :totp_secret, totp_enabled = :totp_enabled, totp_backup_codes = :totp_backup_codes,
-		    password_reset_token = :password_reset_token, password_reset_expires_at = :password_reset_expires_at,
-		    page_size = :page_size, disabled = :disabled, hidden = :hidden, deleted_at = :deleted_at
-		WHERE id = :id
+		SET nostr_name = NULL, nostr_about = NULL, nostr_picture = NULL,
+		    nostr_lud16 = NULL, nostr_nip05 = NULL, nostr_metadata_at = NULL, updated_at = $1
+		WHERE id = $2
 	`
-	_, err := r.db.NamedExecContext(ctx, query, user)
-	return err
+	if _, err := r.db.ExecContext(ctx, query, time.Now(), userID); err != nil {
+		return fmt.Errorf("error clearing Nostr metadata: %w", err)
+	}
+	return nil
+}
+
+// SetUserPublishNIP05 turns listing the user's username in the site's
+// nostr.json on or off. It returns ErrNIP05NameTaken if another user already
+// publishes the same name.
+func (r *Repository) SetUserPublishNIP05(ctx context.Context, userID uuid.UUID, publish bool) error {
+	query := `UPDATE users SET publish_nip05 = $1, updated_at = $2 WHERE id = $3`
+	if _, err := r.db.ExecContext(ctx, query, publish, time.Now(), userID); err != nil {
+		if isPublishedNIP05Conflict(err) {
+			return ErrNIP05NameTaken
+		}
+		return fmt.Errorf("error setting NIP-05 publishing: %w", err)
+	}
+	return nil
+}
+
+// isPublishedNIP05Conflict checks if an error is a violation of the unique
+// index on published NIP-05 names
+func isPublishedNIP05Conflict(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code == "23505" && pqErr.Constraint == "idx_users_published_nip05_name"
+}
+
+// GetPublishedNostrPubkey returns the Nostr key listed for a name in the
+// site's nostr.json: the primary key of the user who published the name if
+// they sign in with Nostr, otherwise their oldest linked one. Users who hide
+// their username or profile info, or whose account is disabled, hidden or
+// being deleted, are not listed.
+func (r *Repository) GetPublishedNostrPubkey(ctx context.Context, name string) (string, error) {
+	var pubkey sql.NullString
+	query := `
+		SELECT COALESCE(
+			CASE WHEN u.auth_type = $2 THEN u.auth_id END,
+			(SELECT i.auth_id FROM user_identities i
+			 WHERE i.user_id = u.id AND i.auth_type = $2
+			 ORDER BY i.created_at LIMIT 1)
+		)
+		FROM users u
+		WHERE u.publish_nip05 AND lower(u.username) = $1
+		  AND u.show_username AND u.show_profile_info
+		  AND NOT u.disabled AND NOT u.hidden
+		  AND u.deleted_at IS NULL AND u.deletion_scheduled_at IS NULL
+	`
+	if err := r.db.GetContext(ctx, &pubkey, query, name, models.AuthTypeNostr); err != nil {
+		if err == sql.ErrNoRows {
+			return "", ErrNotFound
+		}
+		return "", fmt.Errorf("error getting published Nostr pubkey: %w", err)
+	}
+	if !pubkey.Valid {
+		return "", ErrNotFound
+	}
+	return pubkey.String, nil
+}
+
+// SetPitchAuthorNIP05Verified records whether a pitch author's claimed
+// identifier is verified, unless the claim changed in the meantime
+func (r *Repository) SetPitchAuthorNIP05Verified(ctx context.Context, pitchID uuid.UUID, identifier string, verifiedAt *time.Time) error {
+	query := `UPDATE pitches SET author_nip05_verified_at = $1 WHERE id = $2 AND author_nip05 = $3`
+	if _, err := r.db.ExecContext(ctx, query, verifiedAt, pitchID, identifier); err != nil {
+		return fmt.Errorf("error updating pitch author NIP-05 verification: %w", err)
+	}
+	return nil
 }
 
 // Session operations
@@ -111,24 +1109,49 @@ func (r *Repository) UpdateUser(ctx context.Context, user *models.User) error {
 // CreateSession creates a new session
 func (r *Repository) CreateSession(ctx context.Context, session *models.Session) error {
 	query := `
-		INSERT INTO sessions (id, user_id, token, expires_at, created_at, updated_at)
-		VALUES (:id, :user_id, :token, :expires_at, :created_at, :updated_at)
+		INSERT INTO sessions (id, user_id, token, expires_at, created_at, updated_at, read_only, ip_address, user_agent, last_seen_at, token_rotated_at)
+		VALUES (:id, :user_id, :token, :expires_at, :created_at, :updated_at, :read_only, :ip_address, :user_agent, :last_seen_at, :token_rotated_at)
 	`
 	_, err := r.db.NamedExecContext(ctx, query, session)
 	return err
 }
 
-// GetSessionByToken gets a session by its token
-func (r *Repository) GetSessionByToken(ctx context.Context, token string) (*models.Session, error) {
+// GetSessionByTokenHash gets a session by the digest of its token. The digest of
+// the token replaced by the last rotation is also accepted for previousGrace.
+func (r *Repository) GetSessionByTokenHash(ctx context.Context, tokenHash string, previousGrace time.Duration) (*models.Session, error) {
 	var session models.Session
-	query := `SELECT * FROM sessions WHERE token = $1`
-	err := r.db.GetContext(ctx, &session, query, token)
+	query := `
+		SELECT * FROM sessions
+		WHERE token = $1
+		   OR (previous_token = $1 AND token_rotated_at > $2)
+		LIMIT 1
+	`
+	err := r.db.GetContext(ctx, &session, query, tokenHash, time.Now().Add(-previousGrace))
 	if err != nil {
 		return nil, err
 	}
 	return &session, nil
 }
 
+// RotateSessionToken replaces the token digest of a session. It only succeeds
+// if the session still holds oldHash, so concurrent requests rotate at most once.
+func (r *Repository) RotateSessionToken(ctx context.Context, id uuid.UUID, oldHash, newHash string) (bool, error) {
+	query := `
+		UPDATE sessions
+		SET previous_token = token, token = $3, token_rotated_at = $4
+		WHERE id = $1 AND token = $2
+	`
+	result, err := r.db.ExecContext(ctx, query, id, oldHash, newHash, time.Now())
+	if err != nil {
+		return false, fmt.Errorf("error rotating session token: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error getting rows affected: %w", err)
+	}
+	return rows > 0, nil
+}
+
 // DeleteSession deletes a session
 func (r *Repository) DeleteSession(ctx context.Context, id uuid.UUID) error {
 	query := `DELETE FROM sessions WHERE id = $1`
@@ -136,6 +1159,72 @@ func (r *Repository) DeleteSession(ctx context.Context, id uuid.UUID) error {
 	return err
 }
 
+// GetSessionsByUser lists the unexpired sessions of a user, most recently used first
+func (r *Repository) GetSessionsByUser(ctx context.Context, userID uuid.UUID) ([]*models.Session, error) {
+	var sessions []*models.Session
+	query := `
+		SELECT * FROM sessions
+		WHERE user_id = $1 AND expires_at > $2
+		ORDER BY COALESCE(last_seen_at, created_at) DESC
+	`
+	err := r.db.SelectContext(ctx, &sessions, query, userID, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("error listing user sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+// TouchSession refreshes the last-seen time of a session
+func (r *Repository) TouchSession(ctx context.Context, id uuid.UUID, seenAt time.Time) error {
+	query := `UPDATE sessions SET last_seen_at = $2 WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, id, seenAt)
+	if err != nil {
+		return fmt.Errorf("error updating session last seen: %w", err)
+	}
+	return nil
+}
+
+// DeleteUserSession deletes one session, only if it belongs to the given user
+func (r *Repository) DeleteUserSession(ctx context.Context, userID, id uuid.UUID) error {
+	query := `DELETE FROM sessions WHERE id = $1 AND user_id = $2`
+	result, err := r.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return fmt.Errorf("error deleting session: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DeleteOtherSessions deletes every session of a user except the given one
+func (r *Repository) DeleteOtherSessions(ctx context.Context, userID, keepID uuid.UUID) (int64, error) {
+	query := `DELETE FROM sessions WHERE user_id = $1 AND id <> $2`
+	result, err := r.db.ExecContext(ctx, query, userID, keepID)
+	if err != nil {
+		return 0, fmt.Errorf("error deleting other sessions: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("error getting rows affected: %w", err)
+	}
+	return rows, nil
+}
+
+// DeleteSessionsByUser deletes all sessions of a user
+func (r *Repository) DeleteSessionsByUser(ctx context.Context, userID uuid.UUID) error {
+	query := `DELETE FROM sessions WHERE user_id = $1`
+	_, err := r.db.ExecContext(ctx, query, userID)
+	if err != nil {
+		return fmt.Errorf("error deleting user sessions: %w", err)
+	}
+	return nil
+}
+
 // DeleteExpiredSessions deletes all expired sessions
 func (r *Repository) DeleteExpiredSessions(ctx context.Context) error {
 	query := `DELETE FROM sessions WHERE expires_at < $1`
@@ -143,6 +1232,165 @@ func (r *Repository) DeleteExpiredSessions(ctx context.Context) error {
 	return err
 }
 
+// API token operations
+
+// CreateAPIToken stores a new personal API token
+func (r *Repository) CreateAPIToken(ctx context.Context, token *models.APIToken) error {
+	query := `
+		INSERT INTO api_tokens (id, user_id, name, token_hash, token_prefix, scopes, expires_at, created_at, updated_at)
+		VALUES (:id, :user_id, :name, :token_hash, :token_prefix, :scopes, :expires_at, :created_at, :updated_at)
+	`
+	_, err := r.db.NamedExecContext(ctx, query, token)
+	if err != nil {
+		return fmt.Errorf("error creating API token: %w", err)
+	}
+	return nil
+}
+
+// GetAPITokenByHash gets an API token by the digest of its value
+func (r *Repository) GetAPITokenByHash(ctx context.Context, tokenHash string) (*models.APIToken, error) {
+	var token models.APIToken
+	query := `SELECT * FROM api_tokens WHERE token_hash = $1`
+	err := r.db.GetContext(ctx, &token, query, tokenHash)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting API token: %w", err)
+	}
+	return &token, nil
+}
+
+// GetAPITokensByUser lists a user's API tokens, newest first
+func (r *Repository) GetAPITokensByUser(ctx context.Context, userID uuid.UUID) ([]*models.APIToken, error) {
+	var tokens []*models.APIToken
+	query := `SELECT * FROM api_tokens WHERE user_id = $1 ORDER BY created_at DESC`
+	err := r.db.SelectContext(ctx, &tokens, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error listing API tokens: %w", err)
+	}
+	return tokens, nil
+}
+
+// TouchAPIToken records when an API token was last used
+func (r *Repository) TouchAPIToken(ctx context.Context, id uuid.UUID, usedAt time.Time) error {
+	query := `UPDATE api_tokens SET last_used_at = $2 WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, id, usedAt)
+	if err != nil {
+		return fmt.Errorf("error updating API token last used: %w", err)
+	}
+	return nil
+}
+
+// DeleteAPIToken revokes an API token, only if it belongs to the given user
+func (r *Repository) DeleteAPIToken(ctx context.Context, userID, id uuid.UUID) error {
+	query := `DELETE FROM api_tokens WHERE id = $1 AND user_id = $2`
+	result, err := r.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return fmt.Errorf("error deleting API token: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// WebAuthn credential operations
+
+// CreateWebAuthnCredential stores a newly registered WebAuthn credential
+func (r *Repository) CreateWebAuthnCredential(ctx context.Context, credential *models.WebAuthnCredential) error {
+	query := `
+		INSERT INTO webauthn_credentials (
+			id, user_id, name, credential_id, public_key, algorithm, sign_count,
+			aaguid, backup_eligible, created_at, updated_at
+		) VALUES (
+			:id, :user_id, :name, :credential_id, :public_key, :algorithm, :sign_count,
+			:aaguid, :backup_eligible, :created_at, :updated_at
+		)
+	`
+	_, err := r.db.NamedExecContext(ctx, query, credential)
+	if err != nil {
+		return fmt.Errorf("error creating WebAuthn credential: %w", err)
+	}
+	return nil
+}
+
+// GetWebAuthnCredentialByCredentialID gets a credential by the authenticator's credential ID
+func (r *Repository) GetWebAuthnCredentialByCredentialID(ctx context.Context, credentialID []byte) (*models.WebAuthnCredential, error) {
+	var credential models.WebAuthnCredential
+	query := `SELECT * FROM webauthn_credentials WHERE credential_id = $1`
+	err := r.db.GetContext(ctx, &credential, query, credentialID)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting WebAuthn credential: %w", err)
+	}
+	return &credential, nil
+}
+
+// GetWebAuthnCredentialsByUser lists a user's WebAuthn credentials, newest first
+func (r *Repository) GetWebAuthnCredentialsByUser(ctx context.Context, userID uuid.UUID) ([]*models.WebAuthnCredential, error) {
+	var credentials []*models.WebAuthnCredential
+	query := `SELECT * FROM webauthn_credentials WHERE user_id = $1 ORDER BY created_at DESC`
+	err := r.db.SelectContext(ctx, &credentials, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error listing WebAuthn credentials: %w", err)
+	}
+	return credentials, nil
+}
+
+// HasWebAuthnCredentials checks if a user has registered any WebAuthn credential
+func (r *Repository) HasWebAuthnCredentials(ctx context.Context, userID uuid.UUID) (bool, error) {
+	var exists bool
+	query := `SELECT EXISTS(SELECT 1 FROM webauthn_credentials WHERE user_id = $1)`
+	if err := r.db.GetContext(ctx, &exists, query, userID); err != nil {
+		return false, fmt.Errorf("error checking WebAuthn credentials: %w", err)
+	}
+	return exists, nil
+}
+
+// UpdateWebAuthnSignCount records a successful use of a credential. It returns
+// false if the signature counter did not increase, which suggests the
+// authenticator was cloned. Authenticators that always report 0 are accepted.
+func (r *Repository) UpdateWebAuthnSignCount(ctx context.Context, id uuid.UUID, signCount uint32, usedAt time.Time) (bool, error) {
+	query := `
+		UPDATE webauthn_credentials
+		SET sign_count = $2, last_used_at = $3
+		WHERE id = $1 AND (sign_count < $2 OR (sign_count = 0 AND $2 = 0))
+	`
+	result, err := r.db.ExecContext(ctx, query, id, int64(signCount), usedAt)
+	if err != nil {
+		return false, fmt.Errorf("error updating WebAuthn sign count: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error getting rows affected: %w", err)
+	}
+	return rows > 0, nil
+}
+
+// DeleteWebAuthnCredential removes a credential, only if it belongs to the given user
+func (r *Repository) DeleteWebAuthnCredential(ctx context.Context, userID, id uuid.UUID) error {
+	query := `DELETE FROM webauthn_credentials WHERE id = $1 AND user_id = $2`
+	result, err := r.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return fmt.Errorf("error deleting WebAuthn credential: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
 // Pitch operations
 
 // CreatePitch creates a new pitch
@@ -152,11 +1400,11 @@ func (r *Repository) CreatePitch(ctx context.Context, pitch *models.Pitch) error
 		query := `
 			INSERT INTO pitches (
 				id, user_id, content, language, main_category, length_category,
-				created_at, updated_at, posted_by, author_type, author_name, author_handle, hidden
+				created_at, updated_at, posted_by, author_type, author_name, author_handle, author_nip05, hidden
 			)
 			VALUES (
 				:id, :user_id, :content, :language, :main_category, :length_category,
-				:created_at, :updated_at, :posted_by, :author_type, :author_name, :author_handle, :hidden
+				:created_at, :updated_at, :posted_by, :author_type, :author_name, :author_handle, :author_nip05, :hidden
 			)
 		`
 		_, err := tx.NamedExecContext(ctx, query, pitch)
@@ -164,6 +1412,11 @@ func (r *Repository) CreatePitch(ctx context.Context, pitch *models.Pitch) error
 			return fmt.Errorf("error creating pitch: %w", err)
 		}
 
+		// The first revision is the text as posted
+		if err := createPitchRevisionTx(ctx, tx, models.NewPitchRevision(pitch, pitch.UserID, nil)); err != nil {
+			return err
+		}
+
 		// Insert tags if any
 		if len(pitch.Tags) > 0 {
 			for _, tag := range pitch.Tags {
@@ -221,6 +1474,7 @@ func (r *Repository) GetPitch(ctx context.Context, id uuid.UUID) (*models.Pitch,
 		       u.display_name as posted_by_display_name,
 		       u.auth_type as posted_by_auth_type,
 		       u.username as posted_by_username,
+		       CASE WHEN u.nip05_verified_at IS NOT NULL THEN u.nip05 END as posted_by_nip05,
 		       COALESCE(json_agg(jsonb_build_object(
 		         'id', t.id,
 		         'name', t.name,
@@ -233,7 +1487,7 @@ func (r *Repository) GetPitch(ctx context.Context, id uuid.UUID) (*models.Pitch,
 		LEFT JOIN pitch_tags pt ON p.id = pt.pitch_id
 		LEFT JOIN tags t ON pt.tag_id = t.id
 		WHERE p.id = $1 AND p.deleted_at IS NULL
-		GROUP BY p.id, u.display_name, u.auth_type, u.username
+		GROUP BY p.id, u.display_name, u.auth_type, u.username, u.nip05, u.nip05_verified_at
 	`
 	err := r.db.GetContext(ctx, &pitch, query, id)
 	if err != nil {
@@ -245,85 +1499,214 @@ func (r *Repository) GetPitch(ctx context.Context, id uuid.UUID) (*models.Pitch,
 // UpdatePitch updates a pitch
 func (r *Repository) UpdatePitch(ctx context.Context, pitch *models.Pitch) error {
 	return r.db.WithTx(ctx, func(tx *sqlx.Tx) error {
-		// Update pitch
+		return updatePitchTx(ctx, tx, pitch)
+	})
+}
+
+// EditPitch saves a pitch whose content was edited and records the new content
+// as its latest revision. The replaced revision keeps the votes it collected.
+// A minor edit carries the pitch's current votes over, so votes cast while the
+// edit was being written are kept too.
+// restoredFrom is the revision number the content was restored from, if any.
+func (r *Repository) EditPitch(ctx context.Context, pitch *models.Pitch, editedBy uuid.UUID, restoredFrom *int) error {
+	return r.db.WithTx(ctx, func(tx *sqlx.Tx) error {
+		var votes struct {
+			VoteCount     int        `db:"vote_count"`
+			UpvoteCount   int        `db:"upvote_count"`
+			DownvoteCount int        `db:"downvote_count"`
+			Score         int        `db:"score"`
+			LastVoteAt    *time.Time `db:"last_vote_at"`
+		}
 		query := `
-			UPDATE pitches
-			SET content = :content,
-				language = :language,
-				main_category = :main_category,
-				length_category = :length_category,
-				updated_at = :updated_at,
-				last_edit_at = :last_edit_at,
-				author_type = :author_type,
-				author_name = :author_name,
-				author_handle = :author_handle,
-				vote_count = :vote_count,
-				upvote_count = :upvote_count,
-				downvote_count = :downvote_count,
-				score = :score,
-				last_vote_at = :last_vote_at,
-				hidden = :hidden
-			WHERE id = :id AND deleted_at IS NULL
+			SELECT vote_count, upvote_count, downvote_count, score, last_vote_at
+			FROM pitches
+			WHERE id = $1 AND deleted_at IS NULL
+			FOR UPDATE
 		`
-		_, err := tx.NamedExecContext(ctx, query, pitch)
+		if err := tx.GetContext(ctx, &votes, query, pitch.ID); err != nil {
+			if err == sql.ErrNoRows {
+				return ErrNotFound
+			}
+			return fmt.Errorf("error locking pitch: %w", err)
+		}
+
+		query = `
+			UPDATE pitch_revisions
+			SET vote_count = $2, upvote_count = $3, downvote_count = $4, score = $5, replaced_at = $6
+			WHERE pitch_id = $1 AND replaced_at IS NULL
+		`
+		if _, err := tx.ExecContext(ctx, query, pitch.ID, votes.VoteCount, votes.UpvoteCount, votes.DownvoteCount, votes.Score, pitch.UpdatedAt); err != nil {
+			return fmt.Errorf("error closing current revision: %w", err)
+		}
+
+		if pitch.IsMinorEdit() {
+			pitch.VoteCount = votes.VoteCount
+			pitch.UpvoteCount = votes.UpvoteCount
+			pitch.DownvoteCount = votes.DownvoteCount
+			pitch.Score = votes.Score
+			pitch.LastVoteAt = votes.LastVoteAt
+		}
+
+		if err := updatePitchTx(ctx, tx, pitch); err != nil {
+			return err
+		}
+
+		return createPitchRevisionTx(ctx, tx, models.NewPitchRevision(pitch, editedBy, restoredFrom))
+	})
+}
+
+// updatePitchTx updates a pitch and replaces its tags within a transaction
+func updatePitchTx(ctx context.Context, tx *sqlx.Tx, pitch *models.Pitch) error {
+	// Update pitch
+	query := `
+		UPDATE pitches
+		SET content = :content,
+			language = :language,
+			main_category = :main_category,
+			length_category = :length_category,
+			updated_at = :updated_at,
+			last_edit_at = :last_edit_at,
+			last_edit_kind = :last_edit_kind,
+			author_type = :author_type,
+			author_name = :author_name,
+			author_handle = :author_handle,
+			author_nip05 = :author_nip05,
+			author_nip05_verified_at = CASE
+				WHEN author_nip05 IS NOT DISTINCT FROM :author_nip05
+					AND author_handle IS NOT DISTINCT FROM :author_handle
+				THEN author_nip05_verified_at
+			END,
+			vote_count = :vote_count,
+			upvote_count = :upvote_count,
+			downvote_count = :downvote_count,
+			score = :score,
+			last_vote_at = :last_vote_at,
+			hidden = :hidden
+		WHERE id = :id AND deleted_at IS NULL
+	`
+	_, err := tx.NamedExecContext(ctx, query, pitch)
+	if err != nil {
+		return fmt.Errorf("error updating pitch: %w", err)
+	}
+
+	// Update tags if any
+	if len(pitch.Tags) > 0 {
+		// Delete existing tags
+		_, err = tx.ExecContext(ctx, "DELETE FROM pitch_tags WHERE pitch_id = $1", pitch.ID)
 		if err != nil {
-			return fmt.Errorf("error updating pitch: %w", err)
+			return fmt.Errorf("error deleting existing tags: %w", err)
 		}
 
-		// Update tags if any
-		if len(pitch.Tags) > 0 {
-			// Delete existing tags
-			_, err = tx.ExecContext(ctx, "DELETE FROM pitch_tags WHERE pitch_id = $1", pitch.ID)
+		// Insert new tags
+		for _, tag := range pitch.Tags {
+			// Insert or update tag
+			tagQuery := `
+				INSERT INTO tags (id, name, usage_count, created_at, updated_at)
+				VALUES (:id, :name, :usage_count, :created_at, :updated_at)
+				ON CONFLICT (name) DO UPDATE
+				SET usage_count = tags.usage_count + 1,
+					updated_at = :updated_at
+			`
+			_, err := tx.NamedExecContext(ctx, tagQuery, tag)
 			if err != nil {
-				return fmt.Errorf("error deleting existing tags: %w", err)
+				return fmt.Errorf("error upserting tag: %w", err)
 			}
 
-			// Insert new tags
-			for _, tag := range pitch.Tags {
-				// Insert or update tag
-				tagQuery := `
-					INSERT INTO tags (id, name, usage_count, created_at, updated_at)
-					VALUES (:id, :name, :usage_count, :created_at, :updated_at)
-					ON CONFLICT (name) DO UPDATE
-					SET usage_count = tags.usage_count + 1,
-						updated_at = :updated_at
-				`
-				_, err := tx.NamedExecContext(ctx, tagQuery, tag)
-				if err != nil {
-					return fmt.Errorf("error upserting tag: %w", err)
-				}
-
-				// Fetch tag ID by name
-				var tagID uuid.UUID
-				getTagIDQuery := `SELECT id FROM tags WHERE name = $1`
-				err = tx.GetContext(ctx, &tagID, getTagIDQuery, tag.Name)
-				if err != nil {
-					return fmt.Errorf("error fetching tag id: %w", err)
-				}
+			// Fetch tag ID by name
+			var tagID uuid.UUID
+			getTagIDQuery := `SELECT id FROM tags WHERE name = $1`
+			err = tx.GetContext(ctx, &tagID, getTagIDQuery, tag.Name)
+			if err != nil {
+				return fmt.Errorf("error fetching tag id: %w", err)
+			}
 
-				// Create pitch-tag relationship
-				pitchTag := models.PitchTag{
-					PitchID: pitch.ID,
-					TagID:   tagID,
-					BaseModel: models.BaseModel{
-						ID:        uuid.New(),
-						CreatedAt: time.Now(),
-						UpdatedAt: time.Now(),
-					},
-				}
-				pitchTagQuery := `
-					INSERT INTO pitch_tags (pitch_id, tag_id, id, created_at, updated_at)
-					VALUES (:pitch_id, :tag_id, :id, :created_at, :updated_at)
-				`
-				_, err = tx.NamedExecContext(ctx, pitchTagQuery, pitchTag)
-				if err != nil {
-					return fmt.Errorf("error creating pitch-tag relationship: %w", err)
-				}
+			// Create pitch-tag relationship
+			pitchTag := models.PitchTag{
+				PitchID: pitch.ID,
+				TagID:   tagID,
+				BaseModel: models.BaseModel{
+					ID:        uuid.New(),
+					CreatedAt: time.Now(),
+					UpdatedAt: time.Now(),
+				},
 			}
+			pitchTagQuery := `
+				INSERT INTO pitch_tags (pitch_id, tag_id, id, created_at, updated_at)
+				VALUES (:pitch_id, :tag_id, :id, :created_at, :updated_at)
+			`
+			_, err = tx.NamedExecContext(ctx, pitchTagQuery, pitchTag)
+			if err != nil {
+				return fmt.Errorf("error creating pitch-tag relationship: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// createPitchRevisionTx stores a revision as the pitch's next one
+func createPitchRevisionTx(ctx context.Context, tx *sqlx.Tx, revision *models.PitchRevision) error {
+	query := `
+		INSERT INTO pitch_revisions (id, pitch_id, revision_number, content, edited_by, restored_from, edit_kind, created_at)
+		VALUES (
+			$1, $2,
+			(SELECT COALESCE(MAX(revision_number), 0) + 1 FROM pitch_revisions WHERE pitch_id = $2),
+			$3, $4, $5, $6, $7
+		)
+		RETURNING revision_number
+	`
+	err := tx.GetContext(ctx, &revision.RevisionNumber, query,
+		revision.ID, revision.PitchID, revision.Content, revision.EditedBy, revision.RestoredFrom, revision.EditKind, revision.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("error creating pitch revision: %w", err)
+	}
+	return nil
+}
+
+// pitchRevisionColumns selects revisions with their editor's public name
+const pitchRevisionColumns = `
+	r.*, CASE WHEN u.show_username THEN COALESCE(NULLIF(u.display_name, ''), u.username) END AS edited_by_name
+	FROM pitch_revisions r
+	LEFT JOIN users u ON u.id = r.edited_by`
+
+// GetPitchRevisions returns every revision of a pitch, oldest first
+func (r *Repository) GetPitchRevisions(ctx context.Context, pitchID uuid.UUID) ([]*models.PitchRevision, error) {
+	var revisions []*models.PitchRevision
+	query := `SELECT ` + pitchRevisionColumns + ` WHERE r.pitch_id = $1 ORDER BY r.revision_number`
+	if err := r.db.SelectContext(ctx, &revisions, query, pitchID); err != nil {
+		return nil, fmt.Errorf("error getting pitch revisions: %w", err)
+	}
+	return revisions, nil
+}
+
+// GetPitchRevision returns one revision of a pitch by its number
+func (r *Repository) GetPitchRevision(ctx context.Context, pitchID uuid.UUID, number int) (*models.PitchRevision, error) {
+	var revision models.PitchRevision
+	query := `SELECT ` + pitchRevisionColumns + ` WHERE r.pitch_id = $1 AND r.revision_number = $2`
+	if err := r.db.GetContext(ctx, &revision, query, pitchID, number); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
 		}
+		return nil, fmt.Errorf("error getting pitch revision: %w", err)
+	}
+	return &revision, nil
+}
 
-		return nil
-	})
+// GetPitchVotedRevision returns the revision a pitch's current votes were cast
+// for: the latest one that was not a minor edit
+func (r *Repository) GetPitchVotedRevision(ctx context.Context, pitchID uuid.UUID) (*models.PitchRevision, error) {
+	var revision models.PitchRevision
+	query := `SELECT ` + pitchRevisionColumns + `
+		WHERE r.pitch_id = $1 AND r.edit_kind IS DISTINCT FROM $2
+		ORDER BY r.revision_number DESC
+		LIMIT 1`
+	if err := r.db.GetContext(ctx, &revision, query, pitchID, models.EditKindMinor); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("error getting voted pitch revision: %w", err)
+	}
+	return &revision, nil
 }
 
 // DeletePitch soft deletes a pitch
@@ -338,16 +1721,140 @@ func (r *Repository) DeletePitch(ctx context.Context, id uuid.UUID) error {
 	return err
 }
 
+// pitchRanking returns the ranking mode named by the "sort" filter and the
+// cursor in the "cursor" filter, if any
+func pitchRanking(filters map[string]interface{}) (ranking.Mode, bool, *ranking.Cursor) {
+	cursor, _ := filters["cursor"].(*ranking.Cursor)
+	name, _ := filters["sort"].(string)
+	mode, ok := ranking.Get(name)
+	return mode, ok, cursor
+}
+
+// pitchOrderBy returns the ORDER BY clause for the ranking mode named by the
+// "sort" filter, or fallback when no known mode is set. A cursor looking
+// backwards reverses the order; the listing flips its results back.
+func pitchOrderBy(filters map[string]interface{}, fallback string) string {
+	mode, ok, cursor := pitchRanking(filters)
+	if !ok {
+		return fallback
+	}
+	return mode.OrderBy(cursor != nil && cursor.Before)
+}
+
+// pitchRankKeyColumn selects the sort key of the ranking mode named by the
+// "sort" filter, which cursors for the listing are made from
+func pitchRankKeyColumn(filters map[string]interface{}) string {
+	mode, ok, _ := pitchRanking(filters)
+	if !ok || mode.Key == "" {
+		return ""
+	}
+	return "(" + mode.Key + ")::double precision AS rank_key,"
+}
+
+// pitchCursorCondition returns the condition selecting the pitches ranked
+// below a cursor, or above it when the cursor looks backwards
+func pitchCursorCondition(cursor *ranking.Cursor, argCount int) (string, []interface{}) {
+	mode, ok := ranking.Get(cursor.Sort)
+	if !ok {
+		return "", nil
+	}
+	op := "<"
+	if cursor.Before {
+		op = ">"
+	}
+	if mode.Key == "" {
+		return fmt.Sprintf(" AND (p.created_at, p.id) %s ($%d, $%d)", op, argCount, argCount+1),
+			[]interface{}{cursor.CreatedAt, cursor.ID}
+	}
+	return fmt.Sprintf(" AND (%s, p.created_at, p.id) %s ($%d::%s, $%d, $%d)", mode.Key, op, argCount, mode.KeyType, argCount+1, argCount+2),
+		[]interface{}{*cursor.Key, cursor.CreatedAt, cursor.ID}
+}
+
+// reversePitchesAfterCursor restores best-first order for pitches listed
+// backwards from a cursor
+func reversePitchesAfterCursor(filters map[string]interface{}, pitches []*models.Pitch) {
+	if _, ok, cursor := pitchRanking(filters); !ok || cursor == nil || !cursor.Before {
+		return
+	}
+	for i, j := 0, len(pitches)-1; i < j; i, j = i+1, j-1 {
+		pitches[i], pitches[j] = pitches[j], pitches[i]
+	}
+}
+
+// PitchPage is one page of a ranked pitch listing with the cursors for the
+// pages around it. A cursor is nil when there is no page in its direction.
+type PitchPage struct {
+	Pitches    []*models.Pitch
+	NextCursor *ranking.Cursor
+	PrevCursor *ranking.Cursor
+}
+
+// ListPitchPage lists a page of pitches ranked by the mode named by the
+// "sort" filter. It continues from cursor when one is given and otherwise
+// skips offset pitches. An empty tagName lists every tag.
+func (r *Repository) ListPitchPage(ctx context.Context, category, tagName string, filters map[string]interface{}, cursor *ranking.Cursor, limit, offset int) (*PitchPage, error) {
+	pageFilters := make(map[string]interface{}, len(filters)+2)
+	for key, value := range filters {
+		pageFilters[key] = value
+	}
+	if cursor != nil {
+		pageFilters["sort"] = cursor.Sort
+		pageFilters["cursor"] = cursor
+		offset = 0
+	}
+
+	// One extra pitch tells whether another page follows
+	var pitches []*models.Pitch
+	var err error
+	if tagName != "" {
+		pitches, err = r.ListPitchesByTagAndFilters(ctx, category, tagName, pageFilters, limit+1, offset)
+	} else {
+		if category != "" {
+			pageFilters["main_category"] = category
+		}
+		pitches, err = r.ListPitches(ctx, pageFilters, limit+1, offset)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	backwards := cursor != nil && cursor.Before
+	more := len(pitches) > limit
+	if more {
+		if backwards {
+			pitches = pitches[1:]
+		} else {
+			pitches = pitches[:limit]
+		}
+	}
+	page := &PitchPage{Pitches: pitches}
+
+	mode, ok, _ := pitchRanking(pageFilters)
+	if !ok || len(pitches) == 0 {
+		return page, nil
+	}
+	if more || backwards {
+		last := pitches[len(pitches)-1]
+		page.NextCursor = &ranking.Cursor{Sort: mode.Name, Key: last.RankKey, CreatedAt: last.CreatedAt, ID: last.ID}
+	}
+	if (backwards && more) || (!backwards && (cursor != nil || offset > 0)) {
+		first := pitches[0]
+		page.PrevCursor = &ranking.Cursor{Sort: mode.Name, Key: first.RankKey, CreatedAt: first.CreatedAt, ID: first.ID, Before: true}
+	}
+	return page, nil
+}
+
 // ListPitches lists pitches with optional filters
 func (r *Repository) ListPitches(ctx context.Context, filters map[string]interface{}, limit, offset int) ([]*models.Pitch, error) {
 	query := `
-		SELECT p.*, 
+		SELECT p.*, ` + pitchRankKeyColumn(filters) + `
 		       u.display_name as posted_by_display_name,
 		       u.auth_type as posted_by_auth_type,
 		       u.username as posted_by_username,
 		       u.show_auth_method as posted_by_show_auth_method,
 		       u.show_username as posted_by_show_username,
 		       u.show_profile_info as posted_by_show_profile_info,
+		       CASE WHEN u.nip05_verified_at IS NOT NULL THEN u.nip05 END as posted_by_nip05,
 		       COALESCE(json_agg(jsonb_build_object(
 		         'id', t.id,
 		         'name', t.name,
@@ -384,13 +1891,22 @@ func (r *Repository) ListPitches(ctx context.Context, filters map[string]interfa
 				query += fmt.Sprintf(" AND p.user_id = $%d", argCount)
 				args = append(args, value)
 				argCount++
+			case "since":
+				query += fmt.Sprintf(" AND p.created_at >= $%d", argCount)
+				args = append(args, value)
+				argCount++
+			case "cursor":
+				condition, cursorArgs := pitchCursorCondition(value.(*ranking.Cursor), argCount)
+				query += condition
+				args = append(args, cursorArgs...)
+				argCount += len(cursorArgs)
 			}
 		}
 	}
 
 	query += `
-		GROUP BY p.id, u.display_name, u.auth_type, u.username, u.show_auth_method, u.show_username, u.show_profile_info
-		ORDER BY p.score DESC, p.created_at DESC
+		GROUP BY p.id, u.display_name, u.auth_type, u.username, u.show_auth_method, u.show_username, u.show_profile_info, u.nip05, u.nip05_verified_at
+		ORDER BY ` + pitchOrderBy(filters, "p.score DESC, p.created_at DESC") + `
 		LIMIT $` + fmt.Sprintf("%d", argCount) + `
 		OFFSET $` + fmt.Sprintf("%d", argCount+1)
 	args = append(args, limit, offset)
@@ -400,6 +1916,7 @@ func (r *Repository) ListPitches(ctx context.Context, filters map[string]interfa
 	if err != nil {
 		return nil, err
 	}
+	reversePitchesAfterCursor(filters, pitches)
 	return pitches, nil
 }
 
@@ -429,6 +1946,10 @@ func (r *Repository) CountPitches(ctx context.Context, filters map[string]interf
 				query += fmt.Sprintf(" AND p.user_id = $%d", argCount)
 				args = append(args, value)
 				argCount++
+			case "since":
+				query += fmt.Sprintf(" AND p.created_at >= $%d", argCount)
+				args = append(args, value)
+				argCount++
 			}
 		}
 	}
@@ -623,6 +2144,7 @@ func (r *Repository) ListPitchesByTag(ctx context.Context, category, tagName str
 		       u.show_auth_method as posted_by_show_auth_method,
 		       u.show_username as posted_by_show_username,
 		       u.show_profile_info as posted_by_show_profile_info,
+		       CASE WHEN u.nip05_verified_at IS NOT NULL THEN u.nip05 END as posted_by_nip05,
 		       COALESCE(json_agg(jsonb_build_object(
 		         'id', t.id,
 		         'name', t.name,
@@ -642,7 +2164,7 @@ func (r *Repository) ListPitchesByTag(ctx context.Context, category, tagName str
 			  JOIN tags t2 ON pt2.tag_id = t2.id 
 			  WHERE t2.name = $2
 		  )
-		GROUP BY p.id, u.display_name, u.auth_type, u.username, u.show_auth_method, u.show_username, u.show_profile_info
+		GROUP BY p.id, u.display_name, u.auth_type, u.username, u.show_auth_method, u.show_username, u.show_profile_info, u.nip05, u.nip05_verified_at
 		ORDER BY p.score DESC, p.created_at DESC
 		LIMIT $3 OFFSET $4
 	`
@@ -654,6 +2176,20 @@ func (r *Repository) ListPitchesByTag(ctx context.Context, category, tagName str
 	return pitches, nil
 }
 
+// SumUserPitchScores adds up the scores of a user's visible pitches
+func (r *Repository) SumUserPitchScores(ctx context.Context, userID uuid.UUID) (int, error) {
+	query := `
+		SELECT COALESCE(SUM(score), 0)
+		FROM pitches
+		WHERE user_id = $1 AND deleted_at IS NULL AND (hidden = false OR hidden IS NULL)
+	`
+	var total int
+	if err := r.db.GetContext(ctx, &total, query, userID); err != nil {
+		return 0, fmt.Errorf("error summing pitch scores: %w", err)
+	}
+	return total, nil
+}
+
 // CountPitchesByTag counts pitches filtered by category and tag
 func (r *Repository) CountPitchesByTag(ctx context.Context, category, tagName string) (int, error) {
 	query := `
@@ -676,6 +2212,103 @@ func (r *Repository) CountPitchesByTag(ctx context.Context, category, tagName st
 	return count, nil
 }
 
+// ListCategories lists all categories, active or not, in display order
+func (r *Repository) ListCategories(ctx context.Context) ([]*models.Category, error) {
+	var categories []*models.Category
+	query := `SELECT * FROM categories ORDER BY display_order, slug`
+	if err := r.db.SelectContext(ctx, &categories, query); err != nil {
+		return nil, fmt.Errorf("error listing categories: %w", err)
+	}
+	return categories, nil
+}
+
+// ListCategoriesWithPitchCounts lists all categories like ListCategories,
+// with how many pitches each one has
+func (r *Repository) ListCategoriesWithPitchCounts(ctx context.Context) ([]*models.Category, error) {
+	var categories []*models.Category
+	query := `
+		SELECT c.*, (SELECT COUNT(*) FROM pitches p WHERE p.main_category = c.slug AND p.deleted_at IS NULL) AS pitch_count
+		FROM categories c
+		ORDER BY c.display_order, c.slug
+	`
+	if err := r.db.SelectContext(ctx, &categories, query); err != nil {
+		return nil, fmt.Errorf("error listing categories: %w", err)
+	}
+	return categories, nil
+}
+
+// GetCategory gets a category by its slug
+func (r *Repository) GetCategory(ctx context.Context, slug string) (*models.Category, error) {
+	var category models.Category
+	if err := r.db.GetContext(ctx, &category, `SELECT * FROM categories WHERE slug = $1`, slug); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("error getting category: %w", err)
+	}
+	return &category, nil
+}
+
+// CreateCategory stores a new category. It returns ErrCategoryExists if the
+// slug is taken.
+func (r *Repository) CreateCategory(ctx context.Context, category *models.Category) error {
+	query := `
+		INSERT INTO categories (slug, names, description, icon, display_order, active, default_sort, created_at, updated_at)
+		VALUES (:slug, :names, :description, :icon, :display_order, :active, :default_sort, :created_at, :updated_at)
+	`
+	if _, err := r.db.NamedExecContext(ctx, query, category); err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrCategoryExists
+		}
+		return fmt.Errorf("error creating category: %w", err)
+	}
+	return nil
+}
+
+// UpdateCategory saves a category's names, description, icon, order, active
+// flag and default sort. The slug cannot change.
+func (r *Repository) UpdateCategory(ctx context.Context, category *models.Category) error {
+	query := `
+		UPDATE categories
+		SET names = :names, description = :description, icon = :icon, display_order = :display_order,
+		    active = :active, default_sort = :default_sort
+		WHERE slug = :slug
+	`
+	result, err := r.db.NamedExecContext(ctx, query, category)
+	if err != nil {
+		return fmt.Errorf("error updating category: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error checking rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DeleteCategory deletes a category. Categories that pitches use, including
+// deleted ones, cannot be deleted and return ErrCategoryInUse; deactivate
+// them instead.
+func (r *Repository) DeleteCategory(ctx context.Context, slug string) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM categories WHERE slug = $1`, slug)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			return ErrCategoryInUse
+		}
+		return fmt.Errorf("error deleting category: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error checking rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
 // GetAvailableLanguages returns a list of available languages from pitches
 func (r *Repository) GetAvailableLanguages(ctx context.Context) ([]string, error) {
 	query := `
@@ -822,8 +2455,8 @@ func (r *Repository) CountAllUsers(ctx context.Context) (int, error) {
 // CreateEmailVerificationToken creates an email verification token
 func (r *Repository) CreateEmailVerificationToken(ctx context.Context, token *models.EmailVerificationToken) error {
 	query := `
-		INSERT INTO email_verification_tokens (id, user_id, token, email, expires_at, used, created_at, updated_at)
-		VALUES (:id, :user_id, :token, :email, :expires_at, :used, :created_at, :updated_at)
+		INSERT INTO email_verification_tokens (id, user_id, token, email, purpose, expires_at, used, created_at, updated_at)
+		VALUES (:id, :user_id, :token, :email, :purpose, :expires_at, :used, :created_at, :updated_at)
 	`
 	_, err := r.db.NamedExecContext(ctx, query, token)
 	return err
@@ -861,16 +2494,275 @@ func (r *Repository) DeleteEmailVerificationToken(ctx context.Context, token str
 	return err
 }
 
+// GetPendingEmailChange gets the newest unconfirmed email change of a user
+func (r *Repository) GetPendingEmailChange(ctx context.Context, userID uuid.UUID) (*models.EmailVerificationToken, error) {
+	var token models.EmailVerificationToken
+	query := `
+		SELECT * FROM email_verification_tokens
+		WHERE user_id = $1 AND purpose = $2 AND used = FALSE AND expires_at > $3
+		ORDER BY created_at DESC
+		LIMIT 1
+	`
+	err := r.db.GetContext(ctx, &token, query, userID, models.EmailTokenPurposeChange, time.Now())
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("error getting pending email change: %w", err)
+	}
+	return &token, nil
+}
+
+// ApplyEmailChange consumes an email change or revert token and switches the
+// account to the token's address, moving the email login identity with it.
+// All sessions and password reset links are invalidated. A revert also
+// cancels any pending change, revokes API tokens, clears the password and
+// removes 2FA enabled after the change was requested, since it means someone
+// else had access to the account. It returns ErrNotFound for unknown, used or
+// expired tokens and ErrIdentityTaken if another account now uses the address.
+func (r *Repository) ApplyEmailChange(ctx context.Context, token string) (*models.EmailVerificationToken, error) {
+	var consumed models.EmailVerificationToken
+	err := r.db.WithTx(ctx, func(tx *sqlx.Tx) error {
+		now := time.Now()
+
+		query := `
+			UPDATE email_verification_tokens
+			SET used = TRUE, updated_at = $1
+			WHERE token = $2 AND used = FALSE AND expires_at > $1 AND purpose IN ($3, $4)
+			RETURNING *
+		`
+		if err := tx.GetContext(ctx, &consumed, query, now, token, models.EmailTokenPurposeChange, models.EmailTokenPurposeRevert); err != nil {
+			if err == sql.ErrNoRows {
+				return ErrNotFound
+			}
+			return fmt.Errorf("error consuming email token: %w", err)
+		}
+
+		var user models.User
+		if err := tx.GetContext(ctx, &user, `SELECT * FROM users WHERE id = $1 FOR UPDATE`, consumed.UserID); err != nil {
+			if err == sql.ErrNoRows {
+				return ErrNotFound
+			}
+			return fmt.Errorf("error locking user: %w", err)
+		}
+		// The email login may have been removed since the link was sent
+		if user.Email == nil {
+			return ErrNotFound
+		}
+
+		oldEmail := *user.Email
+		if consumed.Email != oldEmail {
+			var taken bool
+			query = `
+				SELECT EXISTS (SELECT 1 FROM users WHERE email = $1 AND id <> $2)
+				    OR EXISTS (SELECT 1 FROM user_identities WHERE auth_type = $3 AND auth_id = $1 AND user_id <> $2)
+			`
+			if err := tx.GetContext(ctx, &taken, query, consumed.Email, user.ID, models.AuthTypeEmail); err != nil {
+				return fmt.Errorf("error checking email address: %w", err)
+			}
+			if taken {
+				return ErrIdentityTaken
+			}
+
+			// Following the emailed link proves control of the new address
+			query = `
+				UPDATE users
+				SET email = $1, email_verified = TRUE,
+				    email_verification_token = NULL, email_verification_expires_at = NULL,
+				    auth_id = CASE WHEN auth_type = $2 AND auth_id = $3 THEN $1 ELSE auth_id END,
+				    updated_at = $4
+				WHERE id = $5
+			`
+			if _, err := tx.ExecContext(ctx, query, consumed.Email, models.AuthTypeEmail, oldEmail, now, user.ID); err != nil {
+				if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+					return ErrIdentityTaken
+				}
+				return fmt.Errorf("error updating email address: %w", err)
+			}
+
+			query = `
+				UPDATE user_identities
+				SET auth_id = $1, updated_at = $2
+				WHERE user_id = $3 AND auth_type = $4 AND auth_id = $5
+			`
+			if _, err := tx.ExecContext(ctx, query, consumed.Email, now, user.ID, models.AuthTypeEmail, oldEmail); err != nil {
+				if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+					return ErrIdentityTaken
+				}
+				return fmt.Errorf("error updating email identity: %w", err)
+			}
+		}
+
+		// Links for the previous address are void; revert links stay valid
+		// after a change so the old address can still undo it
+		query = `
+			DELETE FROM email_verification_tokens
+			WHERE user_id = $1 AND used = FALSE AND ($2 OR purpose <> $3)
+		`
+		reverted := consumed.Purpose == models.EmailTokenPurposeRevert
+		if _, err := tx.ExecContext(ctx, query, user.ID, reverted, models.EmailTokenPurposeRevert); err != nil {
+			return fmt.Errorf("error removing email tokens: %w", err)
+		}
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM password_reset_tokens WHERE user_id = $1`, user.ID); err != nil {
+			return fmt.Errorf("error removing reset tokens: %w", err)
+		}
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM sessions WHERE user_id = $1`, user.ID); err != nil {
+			return fmt.Errorf("error invalidating sessions: %w", err)
+		}
+
+		if reverted {
+			if _, err := tx.ExecContext(ctx, `DELETE FROM api_tokens WHERE user_id = $1`, user.ID); err != nil {
+				return fmt.Errorf("error revoking API tokens: %w", err)
+			}
+
+			// Whoever requested the change knew the password and may have set
+			// up 2FA to lock the owner out. The password is cleared so it has
+			// to be reset from the restored address, and 2FA enabled since the
+			// change was requested is removed.
+			query = `
+				UPDATE users
+				SET password_hash = NULL, updated_at = $1,
+				    totp_enabled = CASE WHEN totp_enabled_at >= $2 THEN FALSE ELSE totp_enabled END,
+				    totp_secret = CASE WHEN totp_enabled_at >= $2 THEN NULL ELSE totp_secret END,
+				    totp_backup_codes = CASE WHEN totp_enabled_at >= $2 THEN '{}' ELSE totp_backup_codes END,
+				    totp_last_step = CASE WHEN totp_enabled_at >= $2 THEN NULL ELSE totp_last_step END,
+				    totp_enabled_at = CASE WHEN totp_enabled_at >= $2 THEN NULL ELSE totp_enabled_at END
+				WHERE id = $3
+			`
+			if _, err := tx.ExecContext(ctx, query, now, consumed.CreatedAt, user.ID); err != nil {
+				return fmt.Errorf("error clearing credentials: %w", err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &consumed, nil
+}
+
+// Password reset token operations
+
+// CreatePasswordResetToken stores a password reset token. The token field
+// holds the SHA-256 digest, never the token sent by email. Any earlier
+// unused tokens of the user are removed so only the latest link works.
+func (r *Repository) CreatePasswordResetToken(ctx context.Context, token *models.PasswordResetToken) error {
+	return r.db.WithTx(ctx, func(tx *sqlx.Tx) error {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM password_reset_tokens WHERE user_id = $1`, token.UserID); err != nil {
+			return fmt.Errorf("error removing old reset tokens: %w", err)
+		}
+
+		query := `
+			INSERT INTO password_reset_tokens (id, user_id, token, expires_at, used, created_at, updated_at)
+			VALUES (:id, :user_id, :token, :expires_at, :used, :created_at, :updated_at)
+		`
+		if _, err := tx.NamedExecContext(ctx, query, token); err != nil {
+			return fmt.Errorf("error creating reset token: %w", err)
+		}
+		return nil
+	})
+}
+
+// GetPasswordResetToken gets a password reset token by its digest
+func (r *Repository) GetPasswordResetToken(ctx context.Context, tokenHash string) (*models.PasswordResetToken, error) {
+	var resetToken models.PasswordResetToken
+	query := `SELECT * FROM password_reset_tokens WHERE token = $1`
+	err := r.db.GetContext(ctx, &resetToken, query, tokenHash)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &resetToken, nil
+}
+
+// ResetPassword consumes an unused, unexpired reset token, sets the new
+// password hash and signs the user out everywhere, revoking API tokens, all
+// in one transaction. It returns ErrNotFound if the token is unknown, used or
+// expired.
+func (r *Repository) ResetPassword(ctx context.Context, tokenHash, passwordHash string) (uuid.UUID, error) {
+	var userID uuid.UUID
+	err := r.db.WithTx(ctx, func(tx *sqlx.Tx) error {
+		now := time.Now()
+
+		query := `
+			UPDATE password_reset_tokens
+			SET used = TRUE, updated_at = $1
+			WHERE token = $2 AND used = FALSE AND expires_at > $1
+			RETURNING user_id
+		`
+		if err := tx.GetContext(ctx, &userID, query, now, tokenHash); err != nil {
+			if err == sql.ErrNoRows {
+				return ErrNotFound
+			}
+			return fmt.Errorf("error consuming reset token: %w", err)
+		}
+
+		// Following the emailed link proves control of the address
+		query = `
+			UPDATE users
+			SET password_hash = $1, email_verified = TRUE,
+			    password_reset_token = NULL, password_reset_expires_at = NULL, updated_at = $2
+			WHERE id = $3
+		`
+		if _, err := tx.ExecContext(ctx, query, passwordHash, now, userID); err != nil {
+			return fmt.Errorf("error updating password: %w", err)
+		}
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM password_reset_tokens WHERE user_id = $1 AND token <> $2`, userID, tokenHash); err != nil {
+			return fmt.Errorf("error removing other reset tokens: %w", err)
+		}
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM sessions WHERE user_id = $1`, userID); err != nil {
+			return fmt.Errorf("error invalidating sessions: %w", err)
+		}
+
+		// A reset may follow a compromise, and API tokens would outlive it
+		if _, err := tx.ExecContext(ctx, `DELETE FROM api_tokens WHERE user_id = $1`, userID); err != nil {
+			return fmt.Errorf("error revoking API tokens: %w", err)
+		}
+
+		return nil
+	})
+	return userID, err
+}
+
+// RehashPassword replaces a user's password hash with one using the current
+// scheme, but only if it is still the hash that was just verified, so a
+// concurrent password change is never overwritten
+func (r *Repository) RehashPassword(ctx context.Context, userID uuid.UUID, oldHash, newHash string) (bool, error) {
+	query := `
+		UPDATE users
+		SET password_hash = $1, updated_at = $2
+		WHERE id = $3 AND password_hash = $4
+	`
+	result, err := r.db.ExecContext(ctx, query, newHash, time.Now(), userID, oldHash)
+	if err != nil {
+		return false, fmt.Errorf("error rehashing password: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error checking rows affected: %w", err)
+	}
+	return rows > 0, nil
+}
+
 // ListPitchesByTagAndFilters lists pitches filtered by category, tag, and additional filters
 func (r *Repository) ListPitchesByTagAndFilters(ctx context.Context, category, tagName string, filters map[string]interface{}, limit, offset int) ([]*models.Pitch, error) {
 	query := `
-		SELECT p.*, 
+		SELECT p.*, ` + pitchRankKeyColumn(filters) + `
 		       u.display_name as posted_by_display_name,
 		       u.auth_type as posted_by_auth_type,
 		       u.username as posted_by_username,
 		       u.show_auth_method as posted_by_show_auth_method,
 		       u.show_username as posted_by_show_username,
 		       u.show_profile_info as posted_by_show_profile_info,
+		       CASE WHEN u.nip05_verified_at IS NOT NULL THEN u.nip05 END as posted_by_nip05,
 		       COALESCE(json_agg(jsonb_build_object(
 		         'id', t.id,
 		         'name', t.name,
@@ -907,13 +2799,22 @@ func (r *Repository) ListPitchesByTagAndFilters(ctx context.Context, category, t
 				query += fmt.Sprintf(" AND p.user_id = $%d", argCount)
 				args = append(args, value)
 				argCount++
+			case "since":
+				query += fmt.Sprintf(" AND p.created_at >= $%d", argCount)
+				args = append(args, value)
+				argCount++
+			case "cursor":
+				condition, cursorArgs := pitchCursorCondition(value.(*ranking.Cursor), argCount)
+				query += condition
+				args = append(args, cursorArgs...)
+				argCount += len(cursorArgs)
 			}
 		}
 	}
 
 	query += `
-		GROUP BY p.id, u.display_name, u.auth_type, u.username, u.show_auth_method, u.show_username, u.show_profile_info
-		ORDER BY p.score DESC, p.created_at DESC
+		GROUP BY p.id, u.display_name, u.auth_type, u.username, u.show_auth_method, u.show_username, u.show_profile_info, u.nip05, u.nip05_verified_at
+		ORDER BY ` + pitchOrderBy(filters, "p.score DESC, p.created_at DESC") + `
 		LIMIT $` + fmt.Sprintf("%d", argCount) + `
 		OFFSET $` + fmt.Sprintf("%d", argCount+1)
 	args = append(args, limit, offset)
@@ -923,6 +2824,7 @@ func (r *Repository) ListPitchesByTagAndFilters(ctx context.Context, category, t
 	if err != nil {
 		return nil, err
 	}
+	reversePitchesAfterCursor(filters, pitches)
 	return pitches, nil
 }
 
@@ -956,6 +2858,10 @@ func (r *Repository) CountPitchesByTagAndFilters(ctx context.Context, category,
 				query += fmt.Sprintf(" AND p.user_id = $%d", argCount)
 				args = append(args, value)
 				argCount++
+			case "since":
+				query += fmt.Sprintf(" AND p.created_at >= $%d", argCount)
+				args = append(args, value)
+				argCount++
 			}
 		}
 	}
@@ -1122,14 +3028,19 @@ func (r *Repository) CreateUserActivity(ctx context.Context, activity *models.Us
 		INSERT INTO user_activities (id, user_id, action_type, target_id, ip_address, user_agent, metadata, created_at)
 		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
 
-	_, err := r.db.ExecContext(ctx, query,
+	metadata, err := json.Marshal(activity.Metadata)
+	if err != nil {
+		return fmt.Errorf("error encoding activity metadata: %w", err)
+	}
+
+	_, err = r.db.ExecContext(ctx, query,
 		activity.ID,
 		activity.UserID,
 		activity.ActionType,
 		activity.TargetID,
-		activity.IPAddress,
+		inetParam(activity.IPAddress),
 		activity.UserAgent,
-		activity.Metadata,
+		metadata,
 		activity.CreatedAt,
 	)
 	return err
@@ -1137,24 +3048,47 @@ func (r *Repository) CreateUserActivity(ctx context.Context, activity *models.Us
 
 func (r *Repository) GetLastUserActivity(ctx context.Context, userID uuid.UUID, actionType models.ActivityType) (*models.UserActivity, error) {
 	query := `
-		SELECT id, user_id, action_type, target_id, ip_address, user_agent, metadata, created_at, created_at, created_at
+		SELECT id, user_id, action_type, target_id, ip_address, user_agent, metadata, created_at
 		FROM user_activities 
 		WHERE user_id = $1 AND action_type = $2 
 		ORDER BY created_at DESC 
 		LIMIT 1`
 
+	return r.scanUserActivity(r.db.QueryRowContext(ctx, query, userID, actionType))
+}
+
+// GetLastIPActivity returns the most recent activity of a type from an IP address,
+// or nil if there is none
+func (r *Repository) GetLastIPActivity(ctx context.Context, ipAddress net.IP, actionType models.ActivityType) (*models.UserActivity, error) {
+	query := `
+		SELECT id, user_id, action_type, target_id, ip_address, user_agent, metadata, created_at
+		FROM user_activities 
+		WHERE ip_address = $1 AND action_type = $2 
+		ORDER BY created_at DESC 
+		LIMIT 1`
+
+	return r.scanUserActivity(r.db.QueryRowContext(ctx, query, ipAddress.String(), actionType))
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanUserActivity scans a single user_activities row, returning nil if there is none
+func (r *Repository) scanUserActivity(row rowScanner) (*models.UserActivity, error) {
 	var activity models.UserActivity
-	err := r.db.QueryRowContext(ctx, query, userID, actionType).Scan(
+	var ipAddress sql.NullString
+	var metadata []byte
+	err := row.Scan(
 		&activity.ID,
 		&activity.UserID,
 		&activity.ActionType,
 		&activity.TargetID,
-		&activity.IPAddress,
+		&ipAddress,
 		&activity.UserAgent,
-		&activity.Metadata,
+		&metadata,
 		&activity.CreatedAt,
-		&activity.UpdatedAt,
-		&activity.CreatedAt, // Use created_at for updated_at as well
 	)
 
 	if err == sql.ErrNoRows {
@@ -1164,6 +3098,18 @@ func (r *Repository) GetLastUserActivity(ctx context.Context, userID uuid.UUID,
 		return nil, err
 	}
 
+	activity.UpdatedAt = activity.CreatedAt // Activities are never updated
+	if ipAddress.Valid {
+		ip := net.ParseIP(ipAddress.String)
+		activity.IPAddress = &ip
+	}
+	activity.Metadata = make(map[string]interface{})
+	if len(metadata) > 0 {
+		if err := json.Unmarshal(metadata, &activity.Metadata); err != nil {
+			return nil, fmt.Errorf("error decoding activity metadata: %w", err)
+		}
+	}
+
 	return &activity, nil
 }
 
@@ -1185,10 +3131,31 @@ func (r *Repository) CountIPActivitiesSince(ctx context.Context, ipAddress net.I
 		WHERE ip_address = $1 AND action_type = $2 AND created_at >= $3`
 
 	var count int
-	err := r.db.QueryRowContext(ctx, query, ipAddress, actionType, since).Scan(&count)
+	err := r.db.QueryRowContext(ctx, query, ipAddress.String(), actionType, since).Scan(&count)
+	return count, err
+}
+
+// CountActivitiesWithMetadataSince counts activities of a type whose metadata
+// has the given value under key
+func (r *Repository) CountActivitiesWithMetadataSince(ctx context.Context, actionType models.ActivityType, key, value string, since time.Time) (int, error) {
+	query := `
+		SELECT COUNT(*)
+		FROM user_activities
+		WHERE action_type = $1 AND metadata->>$2 = $3 AND created_at >= $4`
+
+	var count int
+	err := r.db.QueryRowContext(ctx, query, actionType, key, value, since).Scan(&count)
 	return count, err
 }
 
+// inetParam converts an optional IP address into a value for an INET column
+func inetParam(ip *net.IP) interface{} {
+	if ip == nil || *ip == nil {
+		return nil
+	}
+	return ip.String()
+}
+
 func (r *Repository) CleanupOldActivities(ctx context.Context, cutoff time.Time) error {
 	query := `DELETE FROM user_activities WHERE created_at < $1`
 	_, err := r.db.ExecContext(ctx, query, cutoff)
@@ -1322,6 +3289,7 @@ func (r *Repository) SearchPitches(ctx context.Context, query string, filters ma
 		       u.show_auth_method as posted_by_show_auth_method,
 		       u.show_username as posted_by_show_username,
 		       u.show_profile_info as posted_by_show_profile_info,
+		       CASE WHEN u.nip05_verified_at IS NOT NULL THEN u.nip05 END as posted_by_nip05,
 		       COALESCE(json_agg(jsonb_build_object(
 		         'id', t.id,
 		         'name', t.name,
@@ -1362,13 +3330,17 @@ func (r *Repository) SearchPitches(ctx context.Context, query string, filters ma
 				baseQuery += fmt.Sprintf(" AND p.user_id = $%d", argCount)
 				args = append(args, value)
 				argCount++
+			case "since":
+				baseQuery += fmt.Sprintf(" AND p.created_at >= $%d", argCount)
+				args = append(args, value)
+				argCount++
 			}
 		}
 	}
 
 	baseQuery += `
-		GROUP BY p.id, u.display_name, u.auth_type, u.username, u.show_auth_method, u.show_username, u.show_profile_info, p.search_vector
-		ORDER BY search_rank DESC, p.score DESC, p.created_at DESC
+		GROUP BY p.id, u.display_name, u.auth_type, u.username, u.show_auth_method, u.show_username, u.show_profile_info, u.nip05, u.nip05_verified_at, p.search_vector
+		ORDER BY ` + pitchOrderBy(filters, "search_rank DESC, p.score DESC, p.created_at DESC") + `
 		LIMIT $` + fmt.Sprintf("%d", argCount) + `
 		OFFSET $` + fmt.Sprintf("%d", argCount+1)
 	args = append(args, limit, offset)
@@ -1417,6 +3389,10 @@ func (r *Repository) CountSearchPitches(ctx context.Context, query string, filte
 				baseQuery += fmt.Sprintf(" AND p.user_id = $%d", argCount)
 				args = append(args, value)
 				argCount++
+			case "since":
+				baseQuery += fmt.Sprintf(" AND p.created_at >= $%d", argCount)
+				args = append(args, value)
+				argCount++
 			}
 		}
 	}
@@ -1442,6 +3418,7 @@ func (r *Repository) SearchPitchesByTagAndFilters(ctx context.Context, query, ca
 		       u.show_auth_method as posted_by_show_auth_method,
 		       u.show_username as posted_by_show_username,
 		       u.show_profile_info as posted_by_show_profile_info,
+		       CASE WHEN u.nip05_verified_at IS NOT NULL THEN u.nip05 END as posted_by_nip05,
 		       COALESCE(json_agg(jsonb_build_object(
 		         'id', t.id,
 		         'name', t.name,
@@ -1482,13 +3459,17 @@ func (r *Repository) SearchPitchesByTagAndFilters(ctx context.Context, query, ca
 				baseQuery += fmt.Sprintf(" AND p.user_id = $%d", argCount)
 				args = append(args, value)
 				argCount++
+			case "since":
+				baseQuery += fmt.Sprintf(" AND p.created_at >= $%d", argCount)
+				args = append(args, value)
+				argCount++
 			}
 		}
 	}
 
 	baseQuery += `
-		GROUP BY p.id, u.display_name, u.auth_type, u.username, u.show_auth_method, u.show_username, u.show_profile_info, p.search_vector
-		ORDER BY search_rank DESC, p.score DESC, p.created_at DESC
+		GROUP BY p.id, u.display_name, u.auth_type, u.username, u.show_auth_method, u.show_username, u.show_profile_info, u.nip05, u.nip05_verified_at, p.search_vector
+		ORDER BY ` + pitchOrderBy(filters, "search_rank DESC, p.score DESC, p.created_at DESC") + `
 		LIMIT $` + fmt.Sprintf("%d", argCount) + `
 		OFFSET $` + fmt.Sprintf("%d", argCount+1)
 	args = append(args, limit, offset)
@@ -1537,6 +3518,10 @@ func (r *Repository) CountSearchPitchesByTagAndFilters(ctx context.Context, quer
 				baseQuery += fmt.Sprintf(" AND p.user_id = $%d", argCount)
 				args = append(args, value)
 				argCount++
+			case "since":
+				baseQuery += fmt.Sprintf(" AND p.created_at >= $%d", argCount)
+				args = append(args, value)
+				argCount++
 			}
 		}
 	}
@@ -79,6 +79,11 @@ type EmailData struct {
 	SiteURL         string
 	VerificationURL string
 	ResetURL        string
+	RevertURL       string
+	NewEmail        string
+	LockedUntil     string
+	LoginURL        string
+	DeleteAt        string
 	Username        string
 	Token           string
 }
@@ -169,6 +174,181 @@ func (s *Service) SendPasswordResetEmail(toEmail, toName, resetURL string) error
 	return s.sendEmail(data, htmlBody)
 }
 
+// SendAccountLockedEmail tells a user their account was locked after repeated
+// failed sign-in attempts, with a link to reset the password if it wasn't them
+func (s *Service) SendAccountLockedEmail(toEmail, toName, resetURL string, lockedUntil time.Time) error {
+	data := EmailData{
+		ToEmail:     toEmail,
+		ToName:      toName,
+		Subject:     "Your account was temporarily locked - BitcoinPitch.org",
+		SiteName:    "BitcoinPitch.org",
+		SiteURL:     os.Getenv("SITE_URL"),
+		ResetURL:    resetURL,
+		LockedUntil: lockedUntil.UTC().Format("2006-01-02 15:04 MST"),
+	}
+
+	htmlBody := `
+<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="UTF-8">
+    <title>{{.Subject}}</title>
+</head>
+<body style="font-family: Arial, sans-serif; max-width: 600px; margin: 0 auto; padding: 20px;">
+    <div style="background-color: #f8f9fa; padding: 20px; border-radius: 5px;">
+        <h1 style="color: #f7931a;">{{.SiteName}}</h1>
+        <h2>Account Temporarily Locked</h2>
+        <p>Hello{{if .ToName}} {{.ToName}}{{end}},</p>
+        <p>There were too many failed sign-in attempts on your BitcoinPitch.org account, so sign-ins are paused until <strong>{{.LockedUntil}}</strong>.</p>
+        <p>If this was you, simply wait and try again. If it wasn't, someone may be trying to guess your password. We recommend choosing a new one:</p>
+        <div style="text-align: center; margin: 30px 0;">
+            <a href="{{.ResetURL}}" style="background-color: #f7931a; color: white; padding: 12px 24px; text-decoration: none; border-radius: 5px; display: inline-block;">Reset Password</a>
+        </div>
+        <p>Turning on two-factor authentication or adding a passkey in your profile also keeps your account safe.</p>
+        <hr style="margin: 30px 0; border: none; border-top: 1px solid #ddd;">
+        <p style="color: #666; font-size: 12px;">
+            This email was sent from BitcoinPitch.org<br>
+            If you have any questions, please contact us.
+        </p>
+    </div>
+</body>
+</html>`
+
+	return s.sendEmail(data, htmlBody)
+}
+
+// SendEmailChangeVerificationEmail asks the owner of a new email address to
+// confirm it before it replaces the account's current one
+func (s *Service) SendEmailChangeVerificationEmail(toEmail, toName, verificationURL string) error {
+	data := EmailData{
+		ToEmail:         toEmail,
+		ToName:          toName,
+		Subject:         "Confirm your new email address - BitcoinPitch.org",
+		SiteName:        "BitcoinPitch.org",
+		SiteURL:         os.Getenv("SITE_URL"),
+		VerificationURL: verificationURL,
+	}
+
+	htmlBody := `
+<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="UTF-8">
+    <title>{{.Subject}}</title>
+</head>
+<body style="font-family: Arial, sans-serif; max-width: 600px; margin: 0 auto; padding: 20px;">
+    <div style="background-color: #f8f9fa; padding: 20px; border-radius: 5px;">
+        <h1 style="color: #f7931a;">{{.SiteName}}</h1>
+        <h2>Confirm Your New Email Address</h2>
+        <p>Hello{{if .ToName}} {{.ToName}}{{end}},</p>
+        <p>You asked to use this address for your BitcoinPitch.org account. Your current address keeps working until you confirm the change by clicking the button below:</p>
+        <div style="text-align: center; margin: 30px 0;">
+            <a href="{{.VerificationURL}}" style="background-color: #f7931a; color: white; padding: 12px 24px; text-decoration: none; border-radius: 5px; display: inline-block;">Confirm Email</a>
+        </div>
+        <p>If the button doesn't work, you can copy and paste this link into your browser:</p>
+        <p style="word-break: break-all;"><a href="{{.VerificationURL}}">{{.VerificationURL}}</a></p>
+        <p><strong>This link will expire in 24 hours.</strong> You will need to sign in again after confirming.</p>
+        <p>If you didn't request this change, please ignore this email.</p>
+        <hr style="margin: 30px 0; border: none; border-top: 1px solid #ddd;">
+        <p style="color: #666; font-size: 12px;">
+            This email was sent from BitcoinPitch.org<br>
+            If you have any questions, please contact us.
+        </p>
+    </div>
+</body>
+</html>`
+
+	return s.sendEmail(data, htmlBody)
+}
+
+// SendEmailChangeNoticeEmail tells the current address that a change to
+// newEmail was requested, with a link to undo it if it wasn't the owner
+func (s *Service) SendEmailChangeNoticeEmail(toEmail, toName, newEmail, revertURL string) error {
+	data := EmailData{
+		ToEmail:   toEmail,
+		ToName:    toName,
+		Subject:   "Your email address is being changed - BitcoinPitch.org",
+		SiteName:  "BitcoinPitch.org",
+		SiteURL:   os.Getenv("SITE_URL"),
+		RevertURL: revertURL,
+		NewEmail:  newEmail,
+	}
+
+	htmlBody := `
+<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="UTF-8">
+    <title>{{.Subject}}</title>
+</head>
+<body style="font-family: Arial, sans-serif; max-width: 600px; margin: 0 auto; padding: 20px;">
+    <div style="background-color: #f8f9fa; padding: 20px; border-radius: 5px;">
+        <h1 style="color: #f7931a;">{{.SiteName}}</h1>
+        <h2>Email Address Change Requested</h2>
+        <p>Hello{{if .ToName}} {{.ToName}}{{end}},</p>
+        <p>Someone asked to change the email address of your BitcoinPitch.org account to <strong>{{.NewEmail}}</strong>. This address stays active until the new one is confirmed.</p>
+        <p>If this was you, there is nothing to do. If it wasn't, click the button below. It keeps or restores this address, cancels the change and signs out every session:</p>
+        <div style="text-align: center; margin: 30px 0;">
+            <a href="{{.RevertURL}}" style="background-color: #f7931a; color: white; padding: 12px 24px; text-decoration: none; border-radius: 5px; display: inline-block;">This Wasn't Me</a>
+        </div>
+        <p>If the button doesn't work, you can copy and paste this link into your browser:</p>
+        <p style="word-break: break-all;"><a href="{{.RevertURL}}">{{.RevertURL}}</a></p>
+        <p><strong>This link will expire in 7 days.</strong> Afterwards we recommend resetting your password.</p>
+        <hr style="margin: 30px 0; border: none; border-top: 1px solid #ddd;">
+        <p style="color: #666; font-size: 12px;">
+            This email was sent from BitcoinPitch.org<br>
+            If you have any questions, please contact us.
+        </p>
+    </div>
+</body>
+</html>`
+
+	return s.sendEmail(data, htmlBody)
+}
+
+// SendAccountDeletionScheduledEmail confirms a self-service account deletion
+// and explains that signing in before deleteAt cancels it
+func (s *Service) SendAccountDeletionScheduledEmail(toEmail, toName, loginURL string, deleteAt time.Time) error {
+	data := EmailData{
+		ToEmail:  toEmail,
+		ToName:   toName,
+		Subject:  "Your account is scheduled for deletion - BitcoinPitch.org",
+		SiteName: "BitcoinPitch.org",
+		SiteURL:  os.Getenv("SITE_URL"),
+		LoginURL: loginURL,
+		DeleteAt: deleteAt.UTC().Format("2006-01-02 15:04 MST"),
+	}
+
+	htmlBody := `
+<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="UTF-8">
+    <title>{{.Subject}}</title>
+</head>
+<body style="font-family: Arial, sans-serif; max-width: 600px; margin: 0 auto; padding: 20px;">
+    <div style="background-color: #f8f9fa; padding: 20px; border-radius: 5px;">
+        <h1 style="color: #f7931a;">{{.SiteName}}</h1>
+        <h2>Account Deletion Scheduled</h2>
+        <p>Hello{{if .ToName}} {{.ToName}}{{end}},</p>
+        <p>As requested, your BitcoinPitch.org account will be permanently deleted on <strong>{{.DeleteAt}}</strong>. You have been signed out everywhere.</p>
+        <p>Changed your mind? Sign in before then and cancel the deletion from your profile:</p>
+        <div style="text-align: center; margin: 30px 0;">
+            <a href="{{.LoginURL}}" style="background-color: #f7931a; color: white; padding: 12px 24px; text-decoration: none; border-radius: 5px; display: inline-block;">Sign In</a>
+        </div>
+        <p>If you didn't request this, sign in now, cancel the deletion and change your password.</p>
+        <hr style="margin: 30px 0; border: none; border-top: 1px solid #ddd;">
+        <p style="color: #666; font-size: 12px;">
+            This email was sent from BitcoinPitch.org<br>
+            If you have any questions, please contact us.
+        </p>
+    </div>
+</body>
+</html>`
+
+	return s.sendEmail(data, htmlBody)
+}
+
 // sendEmail sends an email using SMTP or logs to console in dev mode
 func (s *Service) sendEmail(data EmailData, htmlTemplate string) error {
 	// In development mode, just log the email
@@ -0,0 +1,335 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"bitcoinpitch.org/internal/config"
+	"bitcoinpitch.org/internal/database"
+	"bitcoinpitch.org/internal/email"
+	"bitcoinpitch.org/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// accountDeletionConfirmation must be typed to confirm an account deletion
+const accountDeletionConfirmation = "DELETE"
+
+// UserExportHandler downloads everything stored about the current user, as a
+// JSON document or, with format=csv, a zip archive of CSV files
+func UserExportHandler(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*models.User)
+	if !ok || user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+
+	repo := c.Locals("repo").(*database.Repository)
+	export, err := repo.GetUserDataExport(c.Context(), user.ID)
+	if err != nil {
+		log.Printf("[ERROR] UserExportHandler: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to export your data",
+		})
+	}
+
+	filename := "bitcoinpitch-data-" + export.ExportedAt.Format("20060102")
+
+	switch c.Query("format", "json") {
+	case "json":
+		body, err := json.MarshalIndent(export, "", "  ")
+		if err != nil {
+			log.Printf("[ERROR] UserExportHandler: %v", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to export your data",
+			})
+		}
+		c.Attachment(filename + ".json")
+		c.Type("json")
+		return c.Send(body)
+	case "csv":
+		body, err := exportCSVArchive(export)
+		if err != nil {
+			log.Printf("[ERROR] UserExportHandler: %v", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to export your data",
+			})
+		}
+		c.Attachment(filename + ".zip")
+		c.Type("zip")
+		return c.Send(body)
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Unsupported export format",
+		})
+	}
+}
+
+// UserDeleteAccountHandler schedules the current account for deletion after
+// the configured grace period and signs it out everywhere. The user chooses
+// whether their pitches are anonymized or deleted with it.
+func UserDeleteAccountHandler(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*models.User)
+	if !ok || user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+
+	if user.ID == models.AnonymousUserID {
+		return identityMessage(c, fiber.StatusBadRequest, "This account cannot be deleted.")
+	}
+	if user.IsDeletionScheduled() {
+		return identityMessage(c, fiber.StatusBadRequest, "Your account is already scheduled for deletion.")
+	}
+
+	mode := c.FormValue("mode")
+	if !models.IsValidDeletionMode(mode) {
+		return identityMessage(c, fiber.StatusBadRequest, "Choose what should happen to your pitches.")
+	}
+	if strings.TrimSpace(c.FormValue("confirm")) != accountDeletionConfirmation {
+		return identityMessage(c, fiber.StatusBadRequest, "Type "+accountDeletionConfirmation+" to confirm.")
+	}
+	if user.HasPassword() && !newPasswordService(c).VerifyPassword(c.FormValue("current_password"), *user.PasswordHash) {
+		return identityMessage(c, fiber.StatusUnauthorized, "Current password is incorrect.")
+	}
+
+	configService := c.Locals("configService").(*config.Service)
+	graceDays := configService.GetInt(c.Context(), "users.account_deletion_grace_days", 14)
+	if graceDays < 0 {
+		graceDays = 0
+	}
+	deleteAt := time.Now().AddDate(0, 0, graceDays)
+
+	repo := c.Locals("repo").(*database.Repository)
+	if err := repo.ScheduleAccountDeletion(c.Context(), user.ID, mode, deleteAt); err != nil {
+		log.Printf("[ERROR] UserDeleteAccountHandler: %v", err)
+		return identityMessage(c, fiber.StatusInternalServerError, "Failed to delete your account. Please try again.")
+	}
+
+	// The session in use was removed with all others
+	c.ClearCookie("session_token")
+
+	if user.Email != nil && user.EmailVerified {
+		go sendDeletionScheduledEmail(*user.Email, user.GetDisplayName(), siteBaseURL(c)+"/auth/login", deleteAt)
+	}
+
+	log.Printf("Account deletion scheduled for user %s at %s (%s)", user.ID, deleteAt.Format(time.RFC3339), mode)
+
+	return identityMessage(c, fiber.StatusOK, fmt.Sprintf(
+		"Your account will be deleted on %s and you have been signed out. Sign in again before then to cancel.",
+		deleteAt.UTC().Format("January 2, 2006"),
+	))
+}
+
+// UserCancelDeletionHandler keeps the current account after a deletion request
+func UserCancelDeletionHandler(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*models.User)
+	if !ok || user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+
+	repo := c.Locals("repo").(*database.Repository)
+	cancelled, err := repo.CancelAccountDeletion(c.Context(), user.ID)
+	if err != nil {
+		log.Printf("[ERROR] UserCancelDeletionHandler: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to cancel account deletion",
+		})
+	}
+	if cancelled {
+		log.Printf("Account deletion cancelled for user %s", user.ID)
+	}
+
+	return sessionsRedirect(c, "/user/profile")
+}
+
+// sendDeletionScheduledEmail tells a user when their account will be deleted
+// and how to cancel
+func sendDeletionScheduledEmail(emailAddress, name, loginURL string, deleteAt time.Time) {
+	emailService := email.NewService(email.NewConfigFromEnv())
+	if err := emailService.SendAccountDeletionScheduledEmail(emailAddress, name, loginURL, deleteAt); err != nil {
+		log.Printf("[ERROR] sendDeletionScheduledEmail: error sending email: %v", err)
+	}
+}
+
+// exportCSVArchive writes each section of a data export to its own CSV file
+// inside a zip archive
+func exportCSVArchive(export *models.UserDataExport) ([]byte, error) {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+
+	user := export.Profile
+	profile := [][]string{
+		{"id", user.ID.String()},
+		{"auth_type", string(user.AuthType)},
+		{"auth_id", user.AuthID},
+		{"username", optionalString(user.Username)},
+		{"display_name", optionalString(user.DisplayName)},
+		{"email", optionalString(user.Email)},
+		{"email_verified", strconv.FormatBool(user.EmailVerified)},
+		{"role", string(user.Role)},
+		{"totp_enabled", strconv.FormatBool(user.TOTPEnabled)},
+		{"show_auth_method", strconv.FormatBool(user.ShowAuthMethod)},
+		{"show_username", strconv.FormatBool(user.ShowUsername)},
+		{"show_profile_info", strconv.FormatBool(user.ShowProfileInfo)},
+		{"nip05", optionalString(user.NIP05)},
+		{"nip05_verified_at", optionalTime(user.NIP05VerifiedAt)},
+		{"publish_nip05", strconv.FormatBool(user.PublishNIP05)},
+		{"nostr_name", optionalString(user.NostrName)},
+		{"nostr_about", optionalString(user.NostrAbout)},
+		{"nostr_picture", optionalString(user.NostrPicture)},
+		{"nostr_lud16", optionalString(user.NostrLud16)},
+		{"nostr_nip05", optionalString(user.NostrNIP05)},
+		{"nostr_metadata_at", optionalTime(user.NostrMetadataAt)},
+		{"created_at", formatExportTime(user.CreatedAt)},
+		{"updated_at", formatExportTime(user.UpdatedAt)},
+		{"deletion_scheduled_at", optionalTime(user.DeletionScheduledAt)},
+		{"exported_at", formatExportTime(export.ExportedAt)},
+	}
+	if err := writeExportCSV(archive, "profile.csv", []string{"field", "value"}, profile); err != nil {
+		return nil, err
+	}
+
+	var rows [][]string
+	for _, identity := range export.Identities {
+		rows = append(rows, []string{
+			identity.ID.String(), string(identity.AuthType), identity.AuthID, formatExportTime(identity.CreatedAt),
+		})
+	}
+	if err := writeExportCSV(archive, "identities.csv", []string{"id", "auth_type", "auth_id", "created_at"}, rows); err != nil {
+		return nil, err
+	}
+
+	rows = nil
+	for _, pitch := range export.Pitches {
+		tags := make([]string, 0, len(pitch.Tags))
+		for _, tag := range pitch.Tags {
+			tags = append(tags, tag.Name)
+		}
+		rows = append(rows, []string{
+			pitch.ID.String(), pitch.Content, pitch.Language, string(pitch.MainCategory), string(pitch.LengthCategory),
+			string(pitch.AuthorType), optionalString(pitch.AuthorName), optionalString(pitch.AuthorHandle),
+			strings.Join(tags, " "), strconv.Itoa(pitch.Score), strconv.Itoa(pitch.UpvoteCount), strconv.Itoa(pitch.DownvoteCount),
+			formatExportTime(pitch.CreatedAt), formatExportTime(pitch.UpdatedAt), optionalTime(pitch.DeletedAt),
+		})
+	}
+	header := []string{
+		"id", "content", "language", "main_category", "length_category",
+		"author_type", "author_name", "author_handle",
+		"tags", "score", "upvotes", "downvotes",
+		"created_at", "updated_at", "deleted_at",
+	}
+	if err := writeExportCSV(archive, "pitches.csv", header, rows); err != nil {
+		return nil, err
+	}
+
+	rows = nil
+	for _, vote := range export.Votes {
+		rows = append(rows, []string{
+			vote.ID.String(), vote.PitchID.String(), string(vote.VoteType), formatExportTime(vote.CreatedAt), formatExportTime(vote.UpdatedAt),
+		})
+	}
+	if err := writeExportCSV(archive, "votes.csv", []string{"id", "pitch_id", "vote_type", "created_at", "updated_at"}, rows); err != nil {
+		return nil, err
+	}
+
+	rows = nil
+	for _, session := range export.Sessions {
+		rows = append(rows, []string{
+			session.ID.String(), optionalString(session.IPAddress), optionalString(session.UserAgent), strconv.FormatBool(session.ReadOnly),
+			formatExportTime(session.CreatedAt), optionalTime(session.LastSeenAt), formatExportTime(session.ExpiresAt),
+		})
+	}
+	header = []string{"id", "ip_address", "user_agent", "read_only", "created_at", "last_seen_at", "expires_at"}
+	if err := writeExportCSV(archive, "sessions.csv", header, rows); err != nil {
+		return nil, err
+	}
+
+	rows = nil
+	for _, activity := range export.Activities {
+		target, ip := "", ""
+		if activity.TargetID != nil {
+			target = activity.TargetID.String()
+		}
+		if activity.IPAddress != nil {
+			ip = activity.IPAddress.String()
+		}
+		metadata, err := json.Marshal(activity.Metadata)
+		if err != nil {
+			return nil, fmt.Errorf("error encoding activity metadata: %w", err)
+		}
+		rows = append(rows, []string{
+			activity.ID.String(), string(activity.ActionType), target, ip, optionalString(activity.UserAgent),
+			string(metadata), formatExportTime(activity.CreatedAt),
+		})
+	}
+	header = []string{"id", "action_type", "target_id", "ip_address", "user_agent", "metadata", "created_at"}
+	if err := writeExportCSV(archive, "activities.csv", header, rows); err != nil {
+		return nil, err
+	}
+
+	rows = nil
+	for _, hash := range export.ContentHashes {
+		rows = append(rows, []string{
+			hash.ID.String(), hash.PitchID.String(), hash.ContentHash, hash.OriginalContent, formatExportTime(hash.CreatedAt),
+		})
+	}
+	header = []string{"id", "pitch_id", "content_hash", "original_content", "created_at"}
+	if err := writeExportCSV(archive, "content_hashes.csv", header, rows); err != nil {
+		return nil, err
+	}
+
+	if err := archive.Close(); err != nil {
+		return nil, fmt.Errorf("error closing export archive: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// writeExportCSV adds a CSV file with a header row to the archive
+func writeExportCSV(archive *zip.Writer, name string, header []string, rows [][]string) error {
+	file, err := archive.Create(name)
+	if err != nil {
+		return fmt.Errorf("error creating %s: %w", name, err)
+	}
+
+	writer := csv.NewWriter(file)
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("error writing %s: %w", name, err)
+	}
+	if err := writer.WriteAll(rows); err != nil {
+		return fmt.Errorf("error writing %s: %w", name, err)
+	}
+	return nil
+}
+
+// formatExportTime formats a timestamp for the CSV export
+func formatExportTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// optionalTime formats an optional timestamp, empty when unset
+func optionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return formatExportTime(*t)
+}
+
+// optionalString dereferences an optional string, empty when unset
+func optionalString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
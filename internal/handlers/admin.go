@@ -3,12 +3,16 @@ package handlers
 import (
 	"fmt"
 	"log"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"bitcoinpitch.org/internal/config"
 	"bitcoinpitch.org/internal/database"
+	"bitcoinpitch.org/internal/i18n"
 	"bitcoinpitch.org/internal/models"
+	"bitcoinpitch.org/internal/ranking"
 
 	"github.com/CloudyKit/jet/v6"
 	"github.com/gofiber/fiber/v2"
@@ -416,6 +420,82 @@ func (h *AdminHandler) AdminUserDisableHandler(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).SendString("Failed to update user status")
 	}
 
+	// A disabled user must not keep using sessions opened before the change
+	if disabled {
+		if err := h.repo.DeleteSessionsByUser(c.Context(), targetUser.ID); err != nil {
+			log.Printf("[DEBUG] AdminUserDisable: failed to revoke sessions for %s: %v", targetUser.ID, err)
+			return c.Status(fiber.StatusInternalServerError).SendString("User disabled, but failed to revoke sessions")
+		}
+	}
+
+	// Redirect back to admin users page
+	return c.Redirect("/admin/users")
+}
+
+// AdminUserRevokeSessionsHandler signs a user out of every session
+func (h *AdminHandler) AdminUserRevokeSessionsHandler(c *fiber.Ctx) error {
+	userID := c.Params("id")
+	currentUser := c.Locals("user").(*models.User)
+
+	// Validate user ID
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid user ID")
+	}
+
+	// Get the target user
+	targetUser, err := h.repo.GetUserByID(c.Context(), userUUID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).SendString("User not found")
+	}
+
+	// Admins manage their own sessions from the sessions page
+	if targetUser.ID == currentUser.ID {
+		return c.Status(fiber.StatusBadRequest).SendString("Use the sessions page to manage your own sessions")
+	}
+
+	if err := h.repo.DeleteSessionsByUser(c.Context(), targetUser.ID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("Failed to revoke sessions")
+	}
+
+	log.Printf("[DEBUG] Admin %s revoked all sessions of user %s", currentUser.ID, targetUser.ID)
+
+	// Redirect back to admin users page
+	return c.Redirect("/admin/users")
+}
+
+// AdminUserMergeHandler merges a duplicate account into another one. The source
+// account's pitches, votes and login methods move to the target and it is retired.
+func (h *AdminHandler) AdminUserMergeHandler(c *fiber.Ctx) error {
+	currentUser := c.Locals("user").(*models.User)
+
+	// Validate user IDs
+	sourceID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid user ID")
+	}
+	targetID, err := uuid.Parse(strings.TrimSpace(c.FormValue("target_id")))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid target user ID")
+	}
+
+	if err := h.repo.MergeUsers(c.Context(), sourceID, targetID); err != nil {
+		switch err {
+		case database.ErrNotFound:
+			return c.Status(fiber.StatusNotFound).SendString("User not found")
+		case database.ErrMergeSameUser:
+			return c.Status(fiber.StatusBadRequest).SendString("Cannot merge an account into itself")
+		case database.ErrMergeEmailUsed:
+			return c.Status(fiber.StatusBadRequest).SendString("Both accounts have an email address; unlink one first")
+		case database.ErrMergeTOTPUsed:
+			return c.Status(fiber.StatusBadRequest).SendString("Both accounts have two-factor authentication enabled; disable it on one first")
+		}
+		log.Printf("[ERROR] AdminUserMergeHandler: %v", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("Failed to merge users")
+	}
+
+	log.Printf("[DEBUG] Admin %s merged user %s into %s", currentUser.ID, sourceID, targetID)
+
 	// Redirect back to admin users page
 	return c.Redirect("/admin/users")
 }
@@ -803,6 +883,14 @@ func (h *AdminHandler) AdminPitchesHandler(c *fiber.Ctx) error {
 	vars.Set("DeletedPitches", stats.Deleted)
 	vars.Set("CategoryFilter", categoryFilter)
 	vars.Set("StatusFilter", statusFilter)
+
+	// Inactive categories still have pitches to moderate
+	categories, err := h.repo.ListCategories(ctx)
+	if err != nil {
+		log.Printf("[DEBUG] AdminPitches: ListCategories error: %v", err)
+		categories = []*models.Category{}
+	}
+	vars.Set("Categories", categories)
 	vars.Set("CurrentPage", page)
 	vars.Set("TotalPages", (totalPitches+limit-1)/limit) // Ceiling division
 
@@ -829,3 +917,404 @@ func (h *AdminHandler) AdminPitchesHandler(c *fiber.Ctx) error {
 	log.Printf("[DEBUG] AdminPitches: Template rendered successfully")
 	return c.Type("html").SendString(buf.String())
 }
+
+// AdminInvitesHandler shows the invitation codes page
+func (h *AdminHandler) AdminInvitesHandler(c *fiber.Ctx) error {
+	log.Println("[DEBUG] AdminInvitesHandler called")
+	view := c.Locals("view").(*jet.Set)
+	user := c.Locals("user").(*models.User)
+
+	ctx := c.Context()
+
+	// Parse pagination parameters
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	limit := 50
+	offset := (page - 1) * limit
+
+	invites, err := h.repo.GetInviteCodes(ctx, limit, offset)
+	if err != nil {
+		log.Printf("[DEBUG] AdminInvites: GetInviteCodes error: %v", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("Failed to load invitations: " + err.Error())
+	}
+	totalInvites, err := h.repo.CountInviteCodes(ctx)
+	if err != nil {
+		log.Printf("[DEBUG] AdminInvites: CountInviteCodes error: %v", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("Failed to count invitations: " + err.Error())
+	}
+
+	vars := make(jet.VarMap)
+	vars.Set("Title", "Invitations")
+	vars.Set("User", user)
+	vars.Set("CurrentUser", user)
+	vars.Set("ShowUserMenu", true)
+
+	// Set current language from i18n middleware
+	if currentLang := c.Locals("currentLang"); currentLang != nil {
+		vars.Set("currentLang", currentLang)
+	} else {
+		vars.Set("currentLang", "en")
+	}
+
+	vars.Set("Invites", invites)
+	vars.Set("TotalInvites", totalInvites)
+	vars.Set("CurrentPage", page)
+	vars.Set("TotalPages", (totalInvites+limit-1)/limit)
+	vars.Set("RegistrationMode", h.configService.RegistrationMode(ctx))
+	vars.Set("InviteExpiryDays", h.configService.GetInt(ctx, "users.invite_expiry_days", 30))
+	vars.Set("InviteBaseURL", inviteURL(c, ""))
+
+	if csrfToken := c.Locals("csrf"); csrfToken != nil {
+		vars.Set("CsrfToken", csrfToken)
+	}
+
+	// Add footer configuration
+	addFooterConfig(c, vars)
+
+	t, err := view.GetTemplate("pages/admin/invites.jet")
+	if err != nil {
+		log.Printf("[DEBUG] AdminInvites: Template error: %v", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("Template error: " + err.Error())
+	}
+
+	var buf strings.Builder
+	if err := t.Execute(&buf, vars, nil); err != nil {
+		log.Printf("[DEBUG] AdminInvites: Template execution error: %v", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("Template execution error: " + err.Error())
+	}
+
+	return c.Type("html").SendString(buf.String())
+}
+
+// AdminInviteCreateHandler creates an invitation code outside any user's quota
+func (h *AdminHandler) AdminInviteCreateHandler(c *fiber.Ctx) error {
+	currentUser := c.Locals("user").(*models.User)
+
+	maxUses, err := strconv.Atoi(c.FormValue("max_uses", "1"))
+	if err != nil || maxUses < 1 || maxUses > 10000 {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid number of uses")
+	}
+	expiresDays, err := strconv.Atoi(c.FormValue("expires_days", "0"))
+	if err != nil || expiresDays < 0 || expiresDays > 3650 {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid expiry")
+	}
+
+	code, err := generateInviteCode()
+	if err != nil {
+		log.Printf("[ERROR] AdminInviteCreateHandler: %v", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("Failed to create invitation")
+	}
+
+	// Zero days means the code never expires
+	var expiresAt *time.Time
+	if expiresDays > 0 {
+		expiry := time.Now().Add(time.Duration(expiresDays) * 24 * time.Hour)
+		expiresAt = &expiry
+	}
+
+	invite := models.NewInviteCode(code, &currentUser.ID, maxUses, expiresAt)
+	if note := strings.TrimSpace(c.FormValue("note")); note != "" {
+		note = truncateRunes(note, 200)
+		invite.Note = &note
+	}
+
+	if err := h.repo.CreateInviteCode(c.Context(), invite); err != nil {
+		log.Printf("[ERROR] AdminInviteCreateHandler: %v", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("Failed to create invitation")
+	}
+
+	log.Printf("[DEBUG] Admin %s created invitation %s (%d uses)", currentUser.ID, invite.ID, maxUses)
+
+	// Redirect back to admin invites page
+	return c.Redirect("/admin/invites")
+}
+
+// AdminInviteRevokeHandler revokes any invitation code
+func (h *AdminHandler) AdminInviteRevokeHandler(c *fiber.Ctx) error {
+	currentUser := c.Locals("user").(*models.User)
+
+	inviteID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid invitation ID")
+	}
+
+	if err := h.repo.RevokeInviteCode(c.Context(), inviteID, nil); err != nil {
+		if err == database.ErrNotFound {
+			return c.Status(fiber.StatusNotFound).SendString("Invitation not found")
+		}
+		log.Printf("[ERROR] AdminInviteRevokeHandler: %v", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("Failed to revoke invitation")
+	}
+
+	log.Printf("[DEBUG] Admin %s revoked invitation %s", currentUser.ID, inviteID)
+
+	// Redirect back to admin invites page
+	return c.Redirect("/admin/invites")
+}
+
+// AdminRegistrationModeHandler switches between open, invite-only and closed
+// registration
+func (h *AdminHandler) AdminRegistrationModeHandler(c *fiber.Ctx) error {
+	currentUser := c.Locals("user").(*models.User)
+
+	mode := c.FormValue("mode")
+	if !models.IsValidRegistrationMode(mode) {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid registration mode")
+	}
+
+	if err := h.configService.SetString(c.Context(), "users.registration_mode", mode, currentUser.ID); err != nil {
+		log.Printf("[ERROR] AdminRegistrationModeHandler: %v", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("Failed to update registration mode")
+	}
+
+	log.Printf("[DEBUG] Admin %s set registration mode to %s", currentUser.ID, mode)
+
+	// Redirect back to admin invites page
+	return c.Redirect("/admin/invites")
+}
+
+// AdminUserInviteQuotaHandler sets how many invitations a user may create
+func (h *AdminHandler) AdminUserInviteQuotaHandler(c *fiber.Ctx) error {
+	userUUID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid user ID")
+	}
+
+	quota, err := strconv.Atoi(c.FormValue("invite_quota"))
+	if err != nil || quota < 0 || quota > 1000 {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid invitation quota")
+	}
+
+	if err := h.repo.SetUserInviteQuota(c.Context(), userUUID, quota); err != nil {
+		if err == database.ErrNotFound {
+			return c.Status(fiber.StatusNotFound).SendString("User not found")
+		}
+		return c.Status(fiber.StatusInternalServerError).SendString("Failed to update invitation quota")
+	}
+
+	// Redirect back to admin users page
+	return c.Redirect("/admin/users")
+}
+
+// categoryLanguages returns the languages category names can be given in,
+// English first since other languages fall back to it
+func categoryLanguages(c *fiber.Ctx) []string {
+	languages := []string{"en"}
+	if manager, ok := c.Locals("i18nManager").(*i18n.Manager); ok {
+		available := manager.GetAvailableLanguages()
+		sort.Strings(available)
+		for _, lang := range available {
+			if lang != "en" {
+				languages = append(languages, lang)
+			}
+		}
+	}
+	return languages
+}
+
+// reservedCategorySlugs are kept free for pages and directories that are
+// not registered as routes, such as static files, or may be added later
+var reservedCategorySlugs = map[string]bool{
+	"about": true, "admin": true, "api": true, "auth": true, "categories": true,
+	"category": true, "help": true, "login": true, "logout": true, "pitch": true,
+	"pitches": true, "register": true, "search": true, "settings": true,
+	"static": true, "tag": true, "tags": true, "terms": true, "user": true,
+	"users": true,
+}
+
+// isReservedCategorySlug checks if a slug is reserved or the first part of
+// another page's path, which a category listing at /<slug> would be confused
+// with
+func isReservedCategorySlug(c *fiber.Ctx, slug string) bool {
+	if reservedCategorySlugs[slug] {
+		return true
+	}
+	for _, route := range c.App().GetRoutes(true) {
+		first := strings.SplitN(strings.TrimPrefix(route.Path, "/"), "/", 2)[0]
+		if strings.TrimRight(first, "*") == slug {
+			return true
+		}
+	}
+	return false
+}
+
+// setCategoryFromForm copies the submitted names, description, icon, display
+// order and default sort into a category
+func setCategoryFromForm(c *fiber.Ctx, category *models.Category) error {
+	names := models.LocalizedNames{}
+	for _, lang := range categoryLanguages(c) {
+		if name := truncateRunes(strings.TrimSpace(c.FormValue("name_"+lang)), 100); name != "" {
+			names[lang] = name
+		}
+	}
+	if names["en"] == "" {
+		return fmt.Errorf("an English name is required")
+	}
+
+	displayOrder, err := strconv.Atoi(c.FormValue("display_order", "0"))
+	if err != nil || displayOrder < -10000 || displayOrder > 10000 {
+		return fmt.Errorf("invalid display order")
+	}
+
+	defaultSort := c.FormValue("default_sort", ranking.Hot)
+	if !ranking.IsValid(defaultSort) {
+		return fmt.Errorf("invalid default sort")
+	}
+
+	category.Names = names
+	category.Description = truncateRunes(strings.TrimSpace(c.FormValue("description")), 500)
+	category.Icon = truncateRunes(strings.TrimSpace(c.FormValue("icon")), 16)
+	category.DisplayOrder = displayOrder
+	category.DefaultSort = defaultSort
+	return nil
+}
+
+// refreshCategories reloads the cached categories after a change, so
+// navigation and listings pick it up right away
+func (h *AdminHandler) refreshCategories(c *fiber.Ctx) {
+	if err := h.configService.RefreshCategories(c.Context()); err != nil {
+		log.Printf("[ERROR] Failed to refresh categories: %v", err)
+	}
+}
+
+// AdminCategoriesHandler shows the pitch categories page
+func (h *AdminHandler) AdminCategoriesHandler(c *fiber.Ctx) error {
+	log.Println("[DEBUG] AdminCategoriesHandler called")
+	view := c.Locals("view").(*jet.Set)
+	user := c.Locals("user").(*models.User)
+
+	categories, err := h.repo.ListCategoriesWithPitchCounts(c.Context())
+	if err != nil {
+		log.Printf("[DEBUG] AdminCategories: ListCategoriesWithPitchCounts error: %v", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("Failed to load categories: " + err.Error())
+	}
+
+	vars := make(jet.VarMap)
+	vars.Set("Title", "Categories")
+	vars.Set("User", user)
+	vars.Set("CurrentUser", user)
+	vars.Set("ShowUserMenu", true)
+
+	// Set current language from i18n middleware
+	if currentLang := c.Locals("currentLang"); currentLang != nil {
+		vars.Set("currentLang", currentLang)
+	} else {
+		vars.Set("currentLang", "en")
+	}
+
+	vars.Set("Categories", categories)
+	vars.Set("Languages", categoryLanguages(c))
+	vars.Set("SortModes", ranking.Modes())
+
+	if csrfToken := c.Locals("csrf"); csrfToken != nil {
+		vars.Set("CsrfToken", csrfToken)
+	}
+
+	// Add footer configuration
+	addFooterConfig(c, vars)
+
+	t, err := view.GetTemplate("pages/admin/categories.jet")
+	if err != nil {
+		log.Printf("[DEBUG] AdminCategories: Template error: %v", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("Template error: " + err.Error())
+	}
+
+	var buf strings.Builder
+	if err := t.Execute(&buf, vars, nil); err != nil {
+		log.Printf("[DEBUG] AdminCategories: Template execution error: %v", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("Template execution error: " + err.Error())
+	}
+
+	return c.Type("html").SendString(buf.String())
+}
+
+// AdminCategoryCreateHandler adds a pitch category
+func (h *AdminHandler) AdminCategoryCreateHandler(c *fiber.Ctx) error {
+	currentUser := c.Locals("user").(*models.User)
+
+	slug := strings.ToLower(strings.TrimSpace(c.FormValue("slug")))
+	if !models.IsValidCategorySlug(slug) {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid slug: use lowercase letters, digits and dashes")
+	}
+	if isReservedCategorySlug(c, slug) {
+		return c.Status(fiber.StatusBadRequest).SendString(fmt.Sprintf("The slug %q is reserved for another page of the site, please choose another one", slug))
+	}
+
+	category := models.NewCategory(slug, nil, "", "", 0, ranking.Hot)
+	if err := setCategoryFromForm(c, category); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid category: " + err.Error())
+	}
+
+	if err := h.repo.CreateCategory(c.Context(), category); err != nil {
+		if err == database.ErrCategoryExists {
+			return c.Status(fiber.StatusConflict).SendString("A category with this slug already exists")
+		}
+		log.Printf("[ERROR] AdminCategoryCreateHandler: %v", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("Failed to create category")
+	}
+	h.refreshCategories(c)
+
+	log.Printf("[DEBUG] Admin %s created category %s", currentUser.ID, slug)
+
+	// Redirect back to admin categories page
+	return c.Redirect("/admin/categories")
+}
+
+// AdminCategoryUpdateHandler changes a pitch category. The slug stays the
+// same, since it is the category's URL and is stored with every pitch.
+func (h *AdminHandler) AdminCategoryUpdateHandler(c *fiber.Ctx) error {
+	currentUser := c.Locals("user").(*models.User)
+
+	category, err := h.repo.GetCategory(c.Context(), c.Params("slug"))
+	if err != nil {
+		if err == database.ErrNotFound {
+			return c.Status(fiber.StatusNotFound).SendString("Category not found")
+		}
+		log.Printf("[ERROR] AdminCategoryUpdateHandler: %v", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("Failed to load category")
+	}
+
+	if err := setCategoryFromForm(c, category); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid category: " + err.Error())
+	}
+	category.Active = c.FormValue("active") == "on"
+
+	if err := h.repo.UpdateCategory(c.Context(), category); err != nil {
+		if err == database.ErrNotFound {
+			return c.Status(fiber.StatusNotFound).SendString("Category not found")
+		}
+		log.Printf("[ERROR] AdminCategoryUpdateHandler: %v", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("Failed to update category")
+	}
+	h.refreshCategories(c)
+
+	log.Printf("[DEBUG] Admin %s updated category %s (active: %v)", currentUser.ID, category.Slug, category.Active)
+
+	// Redirect back to admin categories page
+	return c.Redirect("/admin/categories")
+}
+
+// AdminCategoryDeleteHandler deletes a pitch category that no pitch uses
+func (h *AdminHandler) AdminCategoryDeleteHandler(c *fiber.Ctx) error {
+	currentUser := c.Locals("user").(*models.User)
+	slug := c.Params("slug")
+
+	if err := h.repo.DeleteCategory(c.Context(), slug); err != nil {
+		switch err {
+		case database.ErrNotFound:
+			return c.Status(fiber.StatusNotFound).SendString("Category not found")
+		case database.ErrCategoryInUse:
+			return c.Status(fiber.StatusConflict).SendString("This category has pitches; deactivate it instead")
+		}
+		log.Printf("[ERROR] AdminCategoryDeleteHandler: %v", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("Failed to delete category")
+	}
+	h.refreshCategories(c)
+
+	log.Printf("[DEBUG] Admin %s deleted category %s", currentUser.ID, slug)
+
+	// Redirect back to admin categories page
+	return c.Redirect("/admin/categories")
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"io"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"bitcoinpitch.org/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestAdminCategoryCreateRejectsReservedSlugs(t *testing.T) {
+	// Slugs are rejected before the database is needed
+	h := &AdminHandler{}
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("user", &models.User{Role: models.UserRoleAdmin})
+		return c.Next()
+	})
+	app.Post("/admin/categories", h.AdminCategoryCreateHandler)
+	app.Get("/leaderboard/:period", func(c *fiber.Ctx) error { return nil })
+	app.Get("/:category", func(c *fiber.Ctx) error { return nil })
+
+	tests := []struct {
+		slug string
+		want string
+	}{
+		{"admin", "reserved"},
+		{"about", "reserved"},
+		{"static", "reserved"},
+		{"Search", "reserved"},
+		{"leaderboard", "reserved"},
+		{"not a slug", "Invalid slug"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.slug, func(t *testing.T) {
+			form := url.Values{"slug": {tt.slug}, "name_en": {"Test"}}
+			req := httptest.NewRequest("POST", "/admin/categories", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("request: %v", err)
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+
+			if resp.StatusCode != fiber.StatusBadRequest || !strings.Contains(string(body), tt.want) {
+				t.Errorf("slug %q: status %d, body %q, want 400 mentioning %q", tt.slug, resp.StatusCode, body, tt.want)
+			}
+		})
+	}
+}
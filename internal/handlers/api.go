@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 	"strings"
@@ -8,6 +9,7 @@ import (
 
 	"bitcoinpitch.org/internal/config"
 	"bitcoinpitch.org/internal/database"
+	"bitcoinpitch.org/internal/middleware"
 	"bitcoinpitch.org/internal/models"
 
 	"github.com/gofiber/fiber/v2"
@@ -18,6 +20,7 @@ import (
 func APIPitchesListHandler(c *fiber.Ctx) error {
 	// Get repository from context
 	repo := c.Locals("repo").(*database.Repository)
+	configService := c.Locals("configService").(*config.Service)
 
 	// Parse query parameters
 	limit := 10 // default limit
@@ -36,28 +39,52 @@ func APIPitchesListHandler(c *fiber.Ctx) error {
 
 	// Build filters from query parameters
 	filters := make(map[string]interface{})
-	if category := c.Query("category"); category != "" {
+	category := c.Query("category")
+	if category != "" {
 		filters["main_category"] = category
 	}
 	if language := c.Query("language"); language != "" {
 		filters["language"] = language
 	}
+	sortMode, sortPeriod := applySort(c, configService.DefaultSort(c.Context(), category), filters)
+
+	// A cursor continues a listing and replaces the offset
+	cursor, err := parseCursor(c, sortMode)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid cursor",
+		})
+	}
+	if cursor != nil {
+		offset = 0
+	}
 
 	// Get pitches from database
-	pitches, err := repo.ListPitches(c.Context(), filters, limit, offset)
+	page, err := repo.ListPitchPage(c.Context(), category, "", filters, cursor, limit, offset)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to fetch pitches: " + err.Error(),
 		})
 	}
 
+	total, err := repo.CountPitches(c.Context(), filters)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to count pitches: " + err.Error(),
+		})
+	}
+
 	// Return JSON response
 	return c.JSON(fiber.Map{
-		"pitches": pitches,
+		"pitches": page.Pitches,
 		"meta": fiber.Map{
-			"limit":  limit,
-			"offset": offset,
-			"total":  len(pitches), // TODO: Add total count query
+			"limit":       limit,
+			"offset":      offset,
+			"total":       total,
+			"sort":        sortMode,
+			"period":      sortPeriod,
+			"next_cursor": cursorOrNil(c, page.NextCursor),
+			"prev_cursor": cursorOrNil(c, page.PrevCursor),
 		},
 	})
 }
@@ -126,6 +153,14 @@ func APIPitchCreateHandler(c *fiber.Ctx) error {
 		})
 	}
 
+	// ANTISPAM CHECK: API clients are held to the same limits as the web forms
+	if err := middleware.CheckPitchCreationLimit(c, input.Content); err != nil {
+		if err == middleware.ErrAntiSpamResponded {
+			return nil // Error response already sent by middleware
+		}
+		return err
+	}
+
 	// Create pitch
 	pitch := models.NewPitch(
 		userID,
@@ -157,6 +192,9 @@ func APIPitchCreateHandler(c *fiber.Ctx) error {
 		})
 	}
 
+	// Record content hash for future duplicate detection
+	middleware.RecordContentHash(c, userID, input.Content, pitch.ID)
+
 	// Return JSON response
 	return c.Status(fiber.StatusCreated).JSON(pitch)
 }
@@ -220,22 +258,37 @@ func APIPitchUpdateHandler(c *fiber.Ctx) error {
 		})
 	}
 
+	configService := c.Locals("configService").(*config.Service)
+
 	// Validate input
-	if err := validatePitchInput(input, c.Locals("configService").(*config.Service)); err != nil {
+	if err := validatePitchInput(input, configService); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
 
+	// ANTISPAM CHECK: Check if user can edit this pitch
+	if err := middleware.CheckPitchEditLimit(c, userID, pitchID, input.Content); err != nil {
+		if err == middleware.ErrAntiSpamResponded {
+			return nil // Error response already sent by middleware
+		}
+		return err
+	}
+
+	// Small fixes keep the votes, anything larger resets them
+	editKind, err := classifyPitchEdit(c, repo, pitch, input.Content, input.MainCategory, input.AuthorType, input.AuthorHandle)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update pitch: " + err.Error(),
+		})
+	}
+
 	// Update pitch
-	pitch.Content = input.Content
 	pitch.Language = input.Language
 	pitch.MainCategory = input.MainCategory
 	pitch.LengthCategory = input.LengthCategory
 	pitch.SetAuthor(input.AuthorType, input.AuthorName, input.AuthorHandle)
-
-	// Reset votes on edit
-	pitch.Edit(input.Content)
+	pitch.Edit(input.Content, editKind)
 
 	// Update tags if provided
 	if len(input.Tags) > 0 {
@@ -245,8 +298,8 @@ func APIPitchUpdateHandler(c *fiber.Ctx) error {
 		}
 	}
 
-	// Save to database
-	if err := repo.UpdatePitch(c.Context(), pitch); err != nil {
+	// Save to database, keeping the previous text in the pitch's history
+	if err := repo.EditPitch(c.Context(), pitch, userID, nil); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to update pitch: " + err.Error(),
 		})
@@ -340,6 +393,14 @@ func APIPitchVoteHandler(c *fiber.Ctx) error {
 		})
 	}
 
+	// ANTISPAM CHECK: Check if user can vote
+	if err := middleware.CheckVoteLimit(c, pitchID); err != nil {
+		if err == middleware.ErrAntiSpamResponded {
+			return nil // Error response already sent by middleware
+		}
+		return err
+	}
+
 	// Get existing vote if any
 	existingVote, err := repo.GetVote(c.Context(), pitchID, userID)
 	if err != nil && err != database.ErrNotFound {
@@ -434,11 +495,8 @@ func validatePitchInput(input struct {
 		return fmt.Errorf("invalid length category")
 	}
 
-	// Validate main category
-	switch input.MainCategory {
-	case models.MainCategoryBitcoin, models.MainCategoryLightning, models.MainCategoryCashu:
-		// Valid categories
-	default:
+	// Validate main category against the active categories
+	if configService.Category(context.Background(), string(input.MainCategory)) == nil {
 		return fmt.Errorf("invalid main category")
 	}
 
@@ -534,6 +592,9 @@ func APISearchHandler(c *fiber.Ctx) error {
 		filters["length_category"] = lengthCategory
 	}
 
+	// Results are ordered by relevance unless a sort is chosen
+	sortMode, sortPeriod := applySort(c, "", filters)
+
 	// Check if there's a tag filter as well
 	tagFilter := c.Query("tag", "")
 
@@ -586,7 +647,23 @@ func APISearchHandler(c *fiber.Ctx) error {
 			"total_count":  totalCount,
 			"total_pages":  (totalCount + limit - 1) / limit,
 			"current_page": (offset / limit) + 1,
+			"sort":         sortMode,
+			"period":       sortPeriod,
 		},
 		"filters": filters,
 	})
 }
+
+// APICategoriesListHandler returns the active pitch categories in display order
+func APICategoriesListHandler(c *fiber.Ctx) error {
+	configService := c.Locals("configService").(*config.Service)
+
+	categories := configService.Categories(c.Context())
+	if categories == nil {
+		categories = []*models.Category{}
+	}
+
+	return c.JSON(fiber.Map{
+		"categories": categories,
+	})
+}
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"bitcoinpitch.org/internal/auth"
+	"bitcoinpitch.org/internal/database"
+	"bitcoinpitch.org/internal/models"
+
+	"github.com/CloudyKit/jet/v6"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// maxAPITokenNameLength limits the label a user gives a token
+const maxAPITokenNameLength = 100
+
+// apiTokenExpiryOptions maps the expiry choices offered in the form to days
+var apiTokenExpiryOptions = map[string]int{
+	"30":  30,
+	"90":  90,
+	"365": 365,
+}
+
+// UserAPITokensHandler renders the list of the user's personal API tokens
+func UserAPITokensHandler(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*models.User)
+	if !ok || user == nil {
+		return c.Redirect("/auth/login")
+	}
+
+	vars := make(jet.VarMap)
+	if revoked := c.Query("revoked"); revoked != "" {
+		vars.Set("Revoked", revoked)
+	}
+
+	return renderAPITokensPage(c, user, vars)
+}
+
+// UserAPITokenCreateHandler issues a new API token and shows it once
+func UserAPITokenCreateHandler(c *fiber.Ctx) error {
+	repo := c.Locals("repo").(*database.Repository)
+
+	user, ok := c.Locals("user").(*models.User)
+	if !ok || user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+
+	vars := make(jet.VarMap)
+
+	name := strings.TrimSpace(c.FormValue("name"))
+	if name == "" || utf8.RuneCountInString(name) > maxAPITokenNameLength {
+		vars.Set("Error", "Please give the token a name of up to 100 characters.")
+		c.Status(fiber.StatusBadRequest)
+		return renderAPITokensPage(c, user, vars)
+	}
+
+	var scopes []string
+	for _, scope := range c.Context().PostArgs().PeekMulti("scopes") {
+		if !models.IsValidAPITokenScope(string(scope)) {
+			vars.Set("Error", "Unknown scope selected.")
+			c.Status(fiber.StatusBadRequest)
+			return renderAPITokensPage(c, user, vars)
+		}
+		scopes = append(scopes, string(scope))
+	}
+	if len(scopes) == 0 {
+		vars.Set("Error", "Select at least one scope.")
+		c.Status(fiber.StatusBadRequest)
+		return renderAPITokensPage(c, user, vars)
+	}
+
+	var expiresAt *time.Time
+	if expiry := c.FormValue("expires_in"); expiry != "" && expiry != "never" {
+		days, ok := apiTokenExpiryOptions[expiry]
+		if !ok {
+			vars.Set("Error", "Invalid expiry selected.")
+			c.Status(fiber.StatusBadRequest)
+			return renderAPITokensPage(c, user, vars)
+		}
+		t := time.Now().AddDate(0, 0, days)
+		expiresAt = &t
+	}
+
+	token, prefix, err := auth.GenerateAPIToken()
+	if err != nil {
+		log.Printf("[DEBUG] UserAPITokenCreateHandler: failed to generate token: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create API token",
+		})
+	}
+
+	apiToken := models.NewAPIToken(user.ID, name, auth.HashToken(token), prefix, scopes, expiresAt)
+	if err := repo.CreateAPIToken(c.Context(), apiToken); err != nil {
+		log.Printf("[DEBUG] UserAPITokenCreateHandler: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create API token",
+		})
+	}
+
+	log.Printf("User %s created API token %s", user.ID, apiToken.ID)
+
+	// The plaintext token is only ever shown in this response
+	vars.Set("NewToken", token)
+	return renderAPITokensPage(c, user, vars)
+}
+
+// UserAPITokenRevokeHandler deletes one of the current user's API tokens
+func UserAPITokenRevokeHandler(c *fiber.Ctx) error {
+	repo := c.Locals("repo").(*database.Repository)
+
+	user, ok := c.Locals("user").(*models.User)
+	if !ok || user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+
+	tokenID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid token ID",
+		})
+	}
+
+	if err := repo.DeleteAPIToken(c.Context(), user.ID, tokenID); err != nil {
+		if err == database.ErrNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "API token not found",
+			})
+		}
+		log.Printf("[DEBUG] UserAPITokenRevokeHandler: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to revoke API token",
+		})
+	}
+
+	return sessionsRedirect(c, "/user/api-tokens?revoked=1")
+}
+
+// renderAPITokensPage renders the API token page with the user's current tokens
+func renderAPITokensPage(c *fiber.Ctx, user *models.User, vars jet.VarMap) error {
+	view := c.Locals("view").(*jet.Set)
+	repo := c.Locals("repo").(*database.Repository)
+
+	tokens, err := repo.GetAPITokensByUser(c.Context(), user.ID)
+	if err != nil {
+		log.Printf("[DEBUG] renderAPITokensPage: failed to list tokens: %v", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("Failed to load API tokens")
+	}
+
+	tmpl, err := view.GetTemplate("pages/user-api-tokens.jet")
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("Template error: " + err.Error())
+	}
+
+	vars.Set("Title", "API Tokens")
+	vars.Set("User", user)
+	vars.Set("UserDisplayName", user.GetDisplayName())
+	vars.Set("ShowUserMenu", true)
+	vars.Set("APITokens", tokens)
+	vars.Set("APITokenScopes", models.AllAPITokenScopes)
+
+	// Set current language from i18n middleware
+	if currentLang := c.Locals("currentLang"); currentLang != nil {
+		vars.Set("currentLang", currentLang)
+	} else {
+		vars.Set("currentLang", "en") // fallback to English
+	}
+
+	if csrfToken := c.Locals("csrf"); csrfToken != nil {
+		vars.Set("CsrfToken", csrfToken)
+	}
+
+	// Add footer configuration
+	addFooterConfig(c, vars)
+
+	return renderTemplate(c, tmpl, vars)
+}
@@ -8,16 +8,20 @@ import (
 	"fmt"
 	"log"
 	"strings"
+	"time"
 
+	"bitcoinpitch.org/internal/antispam"
 	"bitcoinpitch.org/internal/auth"
 	"bitcoinpitch.org/internal/config"
 	"bitcoinpitch.org/internal/crypto"
 	"bitcoinpitch.org/internal/database"
+	"bitcoinpitch.org/internal/email"
 	"bitcoinpitch.org/internal/middleware"
 	"bitcoinpitch.org/internal/models"
 
 	"github.com/CloudyKit/jet/v6"
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 )
 
 // AuthLoginHandler renders the login modal
@@ -43,6 +47,22 @@ func AuthLoginHandler(c *fiber.Ctx) error {
 	return c.Type("html").Send(buf.Bytes())
 }
 
+// AuthChallengeHandler issues a short-lived, single-use nonce for signature-based logins
+func AuthChallengeHandler(c *fiber.Ctx) error {
+	challengeStore := c.Locals("challengeStore").(*auth.ChallengeStore)
+
+	challenge, err := challengeStore.Issue()
+	if err != nil {
+		log.Printf("[ERROR] AuthChallengeHandler: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to issue login challenge",
+		})
+	}
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.JSON(challenge)
+}
+
 // AuthTrezorHandler handles Trezor hardware wallet authentication
 func AuthTrezorHandler(c *fiber.Ctx) error {
 	var req struct {
@@ -78,6 +98,15 @@ func AuthTrezorHandler(c *fiber.Ctx) error {
 		})
 	}
 
+	// The signed message must carry an unexpired, unused challenge for this site
+	challengeStore := c.Locals("challengeStore").(*auth.ChallengeStore)
+	if err := challengeStore.VerifyMessage(req.Message); err != nil {
+		log.Printf("[DEBUG] AuthTrezorHandler: challenge rejected for %s: %v", req.Address, err)
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid login challenge: " + err.Error(),
+		})
+	}
+
 	repo := c.Locals("repo").(*database.Repository)
 
 	// Check if user already exists
@@ -87,23 +116,28 @@ func AuthTrezorHandler(c *fiber.Ctx) error {
 		user = models.NewUser(models.AuthTypeTrezor, req.Address)
 		user.SetDisplayName("Trezor User")
 
-		if err := repo.CreateUser(c.Context(), user); err != nil {
+		if err := registerUser(c, repo, user); err != nil {
+			if isRegistrationRefused(err) {
+				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+					"error": capitalize(err.Error()),
+				})
+			}
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"error": "Failed to create user: " + err.Error(),
 			})
 		}
 	}
 
-	// Create session
-	token, err := middleware.CreateSession(repo, c.Context(), user.BaseModel.ID)
+	// Create session, or a pending login if a second factor is required
+	requires2FA, err := startLogin(c, repo, user, "trezor")
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to create session: " + err.Error(),
 		})
 	}
-
-	// Set session cookie
-	middleware.SetSessionCookie(c, token)
+	if requires2FA {
+		return secondFactorRequired(c)
+	}
 
 	return c.JSON(fiber.Map{
 		"message": "Authentication successful",
@@ -146,6 +180,15 @@ func AuthNostrHandler(c *fiber.Ctx) error {
 		})
 	}
 
+	// The signed event must carry an unexpired, unused challenge for this site
+	challengeStore := c.Locals("challengeStore").(*auth.ChallengeStore)
+	if err := verifyNostrChallenge(challengeStore, req.Event); err != nil {
+		log.Printf("[DEBUG] AuthNostrHandler: challenge rejected for %s: %v", pubkey, err)
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid login challenge: " + err.Error(),
+		})
+	}
+
 	repo := c.Locals("repo").(*database.Repository)
 
 	// Check if user already exists
@@ -161,23 +204,28 @@ func AuthNostrHandler(c *fiber.Ctx) error {
 		user.SetUsername(username)
 		user.SetDisplayName(displayName)
 
-		if err := repo.CreateUser(c.Context(), user); err != nil {
+		if err := registerUser(c, repo, user); err != nil {
+			if isRegistrationRefused(err) {
+				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+					"error": capitalize(err.Error()),
+				})
+			}
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"error": "Failed to create user: " + err.Error(),
 			})
 		}
 	}
 
-	// Create session
-	token, err := middleware.CreateSession(repo, c.Context(), user.BaseModel.ID)
+	// Create session, or a pending login if a second factor is required
+	requires2FA, err := startLogin(c, repo, user, "nostr")
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to create session: " + err.Error(),
 		})
 	}
-
-	// Set session cookie
-	middleware.SetSessionCookie(c, token)
+	if requires2FA {
+		return secondFactorRequired(c)
+	}
 
 	return c.JSON(fiber.Map{
 		"message": "Authentication successful",
@@ -185,6 +233,35 @@ func AuthNostrHandler(c *fiber.Ctx) error {
 	})
 }
 
+// verifyNostrChallenge checks a signed Nostr event for a login challenge, either as
+// "challenge"/"domain" tags or as the challenge message in the event content
+func verifyNostrChallenge(challengeStore *auth.ChallengeStore, event map[string]interface{}) error {
+	var nonce, domain string
+	if tags, ok := event["tags"].([]interface{}); ok {
+		for _, rawTag := range tags {
+			tag, ok := rawTag.([]interface{})
+			if !ok || len(tag) < 2 {
+				continue
+			}
+			name, _ := tag[0].(string)
+			value, _ := tag[1].(string)
+			switch name {
+			case "challenge":
+				nonce = value
+			case "domain":
+				domain = value
+			}
+		}
+	}
+
+	if nonce != "" {
+		return challengeStore.VerifyNonce(domain, nonce)
+	}
+
+	content, _ := event["content"].(string)
+	return challengeStore.VerifyMessage(content)
+}
+
 // AuthNostrManualHandler handles manual Nostr authentication via private key
 func AuthNostrManualHandler(c *fiber.Ctx) error {
 	var req struct {
@@ -275,23 +352,28 @@ func AuthNostrManualHandler(c *fiber.Ctx) error {
 		user.SetUsername(username)
 		user.SetDisplayName(displayName)
 
-		if err := repo.CreateUser(c.Context(), user); err != nil {
+		if err := registerUser(c, repo, user); err != nil {
+			if isRegistrationRefused(err) {
+				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+					"error": capitalize(err.Error()),
+				})
+			}
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"error": "Failed to create user: " + err.Error(),
 			})
 		}
 	}
 
-	// Create session
-	token, err := middleware.CreateSession(repo, c.Context(), user.BaseModel.ID)
+	// Create session, or a pending login if a second factor is required
+	requires2FA, err := startLogin(c, repo, user, "nostr")
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to create session: " + err.Error(),
 		})
 	}
-
-	// Set session cookie
-	middleware.SetSessionCookie(c, token)
+	if requires2FA {
+		return secondFactorRequired(c)
+	}
 
 	return c.JSON(fiber.Map{
 		"message": "Manual authentication successful",
@@ -299,6 +381,51 @@ func AuthNostrManualHandler(c *fiber.Ctx) error {
 	})
 }
 
+// AuthNostrNpubHandler signs in an existing Nostr account by its public key only.
+// Nothing proves key ownership, so the session is read-only until stepped up.
+func AuthNostrNpubHandler(c *fiber.Ctx) error {
+	var req struct {
+		Npub string `json:"npub"`
+	}
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	pubkey, err := crypto.NpubToHex(strings.TrimSpace(req.Npub))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid npub: " + err.Error(),
+		})
+	}
+
+	repo := c.Locals("repo").(*database.Repository)
+
+	// Read-only logins never create accounts
+	user, err := repo.GetUserByAuth(c.Context(), models.AuthTypeNostr, pubkey)
+	if err != nil || !user.CanLogin() {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "No account found for this npub. Sign with your Nostr key to create one.",
+		})
+	}
+
+	token, err := middleware.CreateReadOnlySession(repo, c, user.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create session: " + err.Error(),
+		})
+	}
+	middleware.SetSessionCookie(c, token)
+
+	return c.JSON(fiber.Map{
+		"message":   "Signed in read-only",
+		"user":      user.GetDisplayName(),
+		"read_only": true,
+	})
+}
+
 // AuthTwitterHandler initiates Twitter OAuth flow
 func AuthTwitterHandler(c *fiber.Ctx) error {
 	// Validate Twitter OAuth configuration
@@ -341,7 +468,6 @@ func AuthPasswordHandler(c *fiber.Ctx) error {
 	var req struct {
 		Username string `form:"username"`
 		Password string `form:"password"`
-		TOTPCode string `form:"totp_code"`
 	}
 
 	if err := c.BodyParser(&req); err != nil {
@@ -351,8 +477,8 @@ func AuthPasswordHandler(c *fiber.Ctx) error {
 		})
 	}
 
-	log.Printf("[DEBUG] AuthPasswordHandler: Username: %s, Has password: %t, Has TOTP: %t",
-		req.Username, req.Password != "", req.TOTPCode != "")
+	log.Printf("[DEBUG] AuthPasswordHandler: Username: %s, Has password: %t",
+		req.Username, req.Password != "")
 
 	// Validate required fields
 	if req.Username == "" || req.Password == "" {
@@ -363,14 +489,20 @@ func AuthPasswordHandler(c *fiber.Ctx) error {
 	}
 
 	repo := c.Locals("repo").(*database.Repository)
-	passwordSvc := auth.NewPasswordService()
-	totpSvc := auth.NewTOTPService("BitcoinPitch.org")
+	passwordSvc := newPasswordService(c)
 
 	// Get user by email
 	log.Printf("[DEBUG] AuthPasswordHandler: Looking up user by email: %s", req.Username)
 	user, err := repo.GetUserByEmail(c.Context(), req.Username)
 	if err != nil {
 		log.Printf("[DEBUG] AuthPasswordHandler: User lookup failed: %v", err)
+		// Unknown accounts still count against the IP
+		if blocked, err := checkLoginLimit(c, nil); blocked {
+			return err
+		}
+		if locked, err := recordLoginFailure(c, nil, antispam.LoginMethodPassword); locked {
+			return err
+		}
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"error": "Invalid email or password",
 		})
@@ -379,9 +511,17 @@ func AuthPasswordHandler(c *fiber.Ctx) error {
 	log.Printf("[DEBUG] AuthPasswordHandler: User found: %s, Email verified: %t, TOTP enabled: %t",
 		user.GetDisplayName(), user.EmailVerified, user.TOTPEnabled)
 
+	// Refuse locked out or backing-off accounts and IPs before checking the password
+	if blocked, err := checkLoginLimit(c, &user.ID); blocked {
+		return err
+	}
+
 	// Verify password
 	if user.PasswordHash == nil || !passwordSvc.VerifyPassword(req.Password, *user.PasswordHash) {
 		log.Printf("[DEBUG] AuthPasswordHandler: Password verification failed - Hash exists: %t", user.PasswordHash != nil)
+		if locked, err := recordLoginFailure(c, user, antispam.LoginMethodPassword); locked {
+			return err
+		}
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"error": "Invalid email or password",
 		})
@@ -389,6 +529,17 @@ func AuthPasswordHandler(c *fiber.Ctx) error {
 
 	log.Printf("[DEBUG] AuthPasswordHandler: Password verification successful")
 
+	// Upgrade legacy or outdated hashes now that the plaintext is known
+	if passwordSvc.NeedsRehash(*user.PasswordHash) {
+		if newHash, err := passwordSvc.HashPassword(req.Password); err != nil {
+			log.Printf("[ERROR] AuthPasswordHandler: Rehashing password failed: %v", err)
+		} else if _, err := repo.RehashPassword(c.Context(), user.ID, *user.PasswordHash, newHash); err != nil {
+			log.Printf("[ERROR] AuthPasswordHandler: Saving rehashed password failed: %v", err)
+		} else {
+			log.Printf("[DEBUG] AuthPasswordHandler: Password hash upgraded for user %s", user.ID)
+		}
+	}
+
 	// Check if email is verified
 	if !user.EmailVerified {
 		log.Printf("[DEBUG] AuthPasswordHandler: Email not verified")
@@ -397,64 +548,18 @@ func AuthPasswordHandler(c *fiber.Ctx) error {
 		})
 	}
 
-	// Check if 2FA is enabled
-	if user.TOTPEnabled {
-		if req.TOTPCode == "" {
-			log.Printf("[DEBUG] AuthPasswordHandler: 2FA required, no code provided")
-			// Return special response indicating 2FA is required
-			return c.JSON(fiber.Map{
-				"requires_2fa": true,
-				"message":      "Two-factor authentication required",
-			})
-		}
-
-		// Validate TOTP code
-		if user.TOTPSecret == nil {
-			log.Printf("[DEBUG] AuthPasswordHandler: 2FA enabled but no secret found")
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "2FA configuration error",
-			})
-		}
-
-		isValid := totpSvc.ValidateCode(*user.TOTPSecret, req.TOTPCode)
-		log.Printf("[DEBUG] AuthPasswordHandler: TOTP validation result: %t", isValid)
-		if !isValid {
-			// Check backup codes
-			backupCodeValid := false
-			if len(user.TOTPBackupCodes) > 0 {
-				for i, code := range user.TOTPBackupCodes {
-					if code == req.TOTPCode {
-						// Remove used backup code
-						user.TOTPBackupCodes = append(user.TOTPBackupCodes[:i], user.TOTPBackupCodes[i+1:]...)
-						if err := repo.UpdateUser(c.Context(), user); err != nil {
-							log.Printf("Error updating user backup codes: %v", err)
-						}
-						backupCodeValid = true
-						break
-					}
-				}
-			}
-
-			if !backupCodeValid {
-				log.Printf("[DEBUG] AuthPasswordHandler: 2FA code validation failed")
-				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-					"error": "Invalid 2FA code",
-				})
-			}
-		}
-	}
-
-	// Create session
-	token, err := middleware.CreateSession(repo, c.Context(), user.BaseModel.ID)
+	// Create session, or a pending login if a second factor is required
+	requires2FA, err := startLogin(c, repo, user, "password")
 	if err != nil {
 		log.Printf("[DEBUG] AuthPasswordHandler: Session creation failed: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to create session: " + err.Error(),
 		})
 	}
-
-	// Set session cookie
-	middleware.SetSessionCookie(c, token)
+	if requires2FA {
+		log.Printf("[DEBUG] AuthPasswordHandler: 2FA required, pending login created")
+		return secondFactorRequired(c)
+	}
 
 	log.Printf("[DEBUG] AuthPasswordHandler: Authentication successful for user: %s", user.GetDisplayName())
 
@@ -478,8 +583,8 @@ func AuthLogoutHandler(c *fiber.Ctx) error {
 	if sessionToken != "" {
 		repo := c.Locals("repo").(*database.Repository)
 
-		// Get session by token
-		session, err := repo.GetSessionByToken(c.Context(), sessionToken)
+		// Get session by token digest
+		session, err := repo.GetSessionByTokenHash(c.Context(), auth.HashToken(sessionToken), middleware.SessionRotationGrace)
 		if err == nil {
 			// Delete session from database
 			repo.DeleteSession(c.Context(), session.BaseModel.ID)
@@ -493,6 +598,39 @@ func AuthLogoutHandler(c *fiber.Ctx) error {
 	return c.Redirect("/")
 }
 
+// AuthSecondFactorPageHandler renders the standalone second-factor page, used
+// when a login flow redirects instead of showing the login modal
+func AuthSecondFactorPageHandler(c *fiber.Ctx) error {
+	view := c.Locals("view").(*jet.Set)
+	pendingStore := c.Locals("pendingLoginStore").(*auth.PendingLoginStore)
+
+	tmpl, err := view.GetTemplate("pages/two-factor.jet")
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("Template error: " + err.Error())
+	}
+
+	vars := make(jet.VarMap)
+	vars.Set("Title", "Two-Factor Authentication")
+	if _, err := pendingStore.Get(c.Cookies(middleware.PendingLoginCookieName)); err != nil {
+		vars.Set("Error", err.Error())
+	}
+	vars.Set("CsrfToken", c.Locals("csrf"))
+	vars.Set("AuthStatus", "anonymous")
+	vars.Set("ShowUserMenu", false)
+
+	// Set current language from i18n middleware
+	if currentLang := c.Locals("currentLang"); currentLang != nil {
+		vars.Set("currentLang", currentLang)
+	} else {
+		vars.Set("currentLang", "en") // fallback to English
+	}
+
+	// Add footer configuration
+	addFooterConfig(c, vars)
+
+	return renderTemplate(c, tmpl, vars)
+}
+
 // AuthCallbackHandler handles OAuth callbacks
 func AuthCallbackHandler(c *fiber.Ctx) error {
 	provider := c.Params("provider")
@@ -555,23 +693,28 @@ func handleTwitterCallback(c *fiber.Ctx) error {
 		user.SetUsername(twitterUser.Username)
 		user.SetDisplayName(twitterUser.Name)
 
-		if err := repo.CreateUser(c.Context(), user); err != nil {
+		if err := registerUser(c, repo, user); err != nil {
+			if isRegistrationRefused(err) {
+				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+					"error": capitalize(err.Error()),
+				})
+			}
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"error": "Failed to create user: " + err.Error(),
 			})
 		}
 	}
 
-	// Create session
-	sessionToken, err := middleware.CreateSession(repo, c.Context(), user.BaseModel.ID)
+	// Create session, or a pending login if a second factor is required
+	requires2FA, err := startLogin(c, repo, user, "twitter")
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to create session: " + err.Error(),
 		})
 	}
-
-	// Set session cookie
-	middleware.SetSessionCookie(c, sessionToken)
+	if requires2FA {
+		return c.Redirect("/auth/2fa")
+	}
 
 	// Redirect to home page with success
 	return c.Redirect("/")
@@ -642,6 +785,39 @@ func UserProfileHandler(c *fiber.Ctx) error {
 		log.Printf("[DEBUG] UserProfileHandler: PitchCount=%d, TotalScore=%d, VoteCount=%d", pitchCount, totalScore, 0)
 	}
 
+	// Linked login methods
+	identities, err := repo.GetUserIdentities(c.Context(), user.ID)
+	if err != nil {
+		log.Printf("Failed to fetch user identities: %v", err)
+	}
+	vars.Set("Identities", identities)
+	hasNostrKey := false
+	for _, identity := range identities {
+		if identity.AuthType == models.AuthTypeNostr {
+			hasNostrKey = true
+		}
+	}
+	vars.Set("HasNostrKey", hasNostrKey)
+	siteNIP05 := ""
+	if name := siteNIP05Name(user); name != "" {
+		siteNIP05 = name + "@" + siteNIP05Domain(c)
+	}
+	vars.Set("SiteNIP05", siteNIP05)
+	vars.Set("LinkedMessage", c.Query("linked"))
+	vars.Set("Unlinked", c.Query("unlinked") == "1")
+
+	// Email change awaiting confirmation of the new address
+	pendingEmail := ""
+	if pending, err := repo.GetPendingEmailChange(c.Context(), user.ID); err == nil {
+		pendingEmail = pending.Email
+	} else if err != database.ErrNotFound {
+		log.Printf("[ERROR] UserProfileHandler: failed to get pending email change: %v", err)
+	}
+	vars.Set("PendingEmail", pendingEmail)
+
+	// Invitation codes the user can hand out
+	setUserInviteVars(c, repo, user, vars)
+
 	// Set current language from i18n middleware
 	if currentLang := c.Locals("currentLang"); currentLang != nil {
 		vars.Set("currentLang", currentLang)
@@ -701,6 +877,11 @@ func UserUpdateHandler(c *fiber.Ctx) error {
 	// Save to database
 	repo := c.Locals("repo").(*database.Repository)
 	if err := repo.UpdateUser(c.Context(), user); err != nil {
+		if err == database.ErrNIP05NameTaken {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "This username is already published as a NIP-05 identifier by another account",
+			})
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to update user: " + err.Error(),
 		})
@@ -962,6 +1143,121 @@ func UserPaginationHandler(c *fiber.Ctx) error {
 
 // Helper functions
 
+// startLogin completes a successful primary authentication. Users without 2FA
+// get a session right away; users with 2FA get a short-lived pending login that
+// must be completed via POST /auth/2fa. It reports whether 2FA is required.
+func startLogin(c *fiber.Ctx, repo *database.Repository, user *models.User, method string) (bool, error) {
+	// A registered passkey also serves as a second factor
+	requires2FA := user.TOTPEnabled
+	if !requires2FA {
+		hasPasskeys, err := repo.HasWebAuthnCredentials(c.Context(), user.ID)
+		if err != nil {
+			return false, err
+		}
+		requires2FA = hasPasskeys
+	}
+
+	if requires2FA {
+		pendingStore := c.Locals("pendingLoginStore").(*auth.PendingLoginStore)
+		pending, err := pendingStore.Create(user.ID, method)
+		if err != nil {
+			return false, err
+		}
+		middleware.SetPendingLoginCookie(c, pending.Token, pending.ExpiresAt)
+		log.Printf("[DEBUG] startLogin: %s login for user %s awaits second factor", method, user.ID)
+		return true, nil
+	}
+
+	token, err := middleware.CreateSession(repo, c, user.ID)
+	if err != nil {
+		return false, err
+	}
+	middleware.SetSessionCookie(c, token)
+	middleware.RecordLoginSuccess(c, user.ID, method)
+	return false, nil
+}
+
+// secondFactorRequired tells the client to collect a second factor
+func secondFactorRequired(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"requires_2fa": true,
+		"message":      "Two-factor authentication required",
+	})
+}
+
+// recordLoginFailure records a failed password or second-factor attempt for the
+// user (nil when the account is unknown) and emails the owner if it locked
+// their account. It reports whether the attempt left the account or IP locked,
+// in which case the 429 response has already been written.
+func recordLoginFailure(c *fiber.Ctx, user *models.User, method string) (bool, error) {
+	var userID *uuid.UUID
+	if user != nil {
+		userID = &user.ID
+	}
+
+	check := middleware.RecordLoginFailure(c, userID, method)
+	if check == nil {
+		return false, nil
+	}
+
+	if locked, _ := check.Metadata["account_locked"].(bool); locked && user != nil && user.Email != nil {
+		configService := c.Locals("configService").(*config.Service)
+		if configService.GetBool(c.Context(), "security.lockout_notify_email", true) {
+			antispamSvc := c.Locals("antispamService").(*antispam.Service)
+			lockedUntil := time.Now().Add(antispamSvc.LockoutDuration(c.Context()))
+			resetURL := siteBaseURL(c) + "/auth/forgot-password"
+			go sendLockoutEmail(*user.Email, user.GetDisplayName(), resetURL, lockedUntil)
+		}
+	}
+
+	if _, locked := check.Metadata["locked"]; locked {
+		log.Printf("[DEBUG] recordLoginFailure: login locked after failed %s attempt from %s", method, c.IP())
+		return true, middleware.LoginLimitResponse(c, check)
+	}
+	return false, nil
+}
+
+// sendLockoutEmail notifies a user that their account was locked
+func sendLockoutEmail(emailAddress, name, resetURL string, lockedUntil time.Time) {
+	emailService := email.NewService(email.NewConfigFromEnv())
+	if err := emailService.SendAccountLockedEmail(emailAddress, name, resetURL, lockedUntil); err != nil {
+		log.Printf("[ERROR] sendLockoutEmail: error sending email: %v", err)
+	}
+}
+
+// checkLoginLimit applies the brute-force limits before a credential is
+// checked. It reports whether the attempt is blocked, in which case the
+// response has already been written.
+func checkLoginLimit(c *fiber.Ctx, userID *uuid.UUID) (bool, error) {
+	if err := middleware.CheckLoginLimit(c, userID); err != nil {
+		if err == middleware.ErrAntiSpamResponded {
+			return true, nil
+		}
+		return true, err
+	}
+	return false, nil
+}
+
+// newPasswordService creates a password service using the argon2id parameters
+// from the security config; invalid values fall back to the defaults
+func newPasswordService(c *fiber.Ctx) *auth.PasswordService {
+	configService := c.Locals("configService").(*config.Service)
+	cfg := configService.PasswordHashing(c.Context())
+
+	params := auth.Argon2Params{}
+	if cfg.Argon2MemoryKiB > 0 {
+		params.Memory = uint32(cfg.Argon2MemoryKiB)
+	}
+	if cfg.Argon2Iterations > 0 {
+		params.Iterations = uint32(cfg.Argon2Iterations)
+	}
+	if cfg.Argon2Parallelism > 0 && cfg.Argon2Parallelism <= 255 {
+		params.Parallelism = uint8(cfg.Argon2Parallelism)
+	}
+
+	return auth.NewPasswordServiceWithParams(params, cfg.CheckBreached)
+}
+
 // generatePasswordAuthID creates a consistent auth ID for password authentication
 func generatePasswordAuthID(username, password string) string {
 	// TODO: Implement proper password hashing (bcrypt, scrypt, etc.)
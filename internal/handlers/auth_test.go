@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"bitcoinpitch.org/internal/auth"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/gofiber/fiber/v2"
+)
+
+// newChallengeTestApp serves the signature login handlers with only the
+// challenge store in place. Requests rejected before the user lookup never
+// need a database.
+func newChallengeTestApp(store *auth.ChallengeStore) *fiber.App {
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("challengeStore", store)
+		return c.Next()
+	})
+	app.Post("/auth/trezor", AuthTrezorHandler)
+	app.Post("/auth/nostr", AuthNostrHandler)
+	return app
+}
+
+// postJSON sends a JSON body to the app and returns the status and body
+func postJSON(t *testing.T, app *fiber.App, path string, body interface{}) (int, string) {
+	t.Helper()
+	payload, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("marshal request: %v", err)
+	}
+	req := httptest.NewRequest("POST", path, bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("request %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(respBody)
+}
+
+// signLegacyMessage signs a message the way a Trezor does for a compressed
+// P2PKH address
+func signLegacyMessage(t *testing.T, key *btcec.PrivateKey, message string) (signature, address string) {
+	t.Helper()
+	var buf bytes.Buffer
+	buf.WriteString("\x18Bitcoin Signed Message:\n")
+	if err := wire.WriteVarString(&buf, 0, message); err != nil {
+		t.Fatalf("write message: %v", err)
+	}
+	first := sha256.Sum256(buf.Bytes())
+	hash := sha256.Sum256(first[:])
+
+	sig := ecdsa.SignCompact(key, hash[:], true)
+	addr, err := btcutil.NewAddressPubKeyHash(btcutil.Hash160(key.PubKey().SerializeCompressed()), &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("build address: %v", err)
+	}
+	return base64.StdEncoding.EncodeToString(sig), addr.EncodeAddress()
+}
+
+// signNostrEvent builds and signs a NIP-01 event
+func signNostrEvent(t *testing.T, key *btcec.PrivateKey, kind int, tags [][]string, content string) map[string]interface{} {
+	t.Helper()
+	pubkey := hex.EncodeToString(schnorr.SerializePubKey(key.PubKey()))
+	createdAt := time.Now().Unix()
+
+	serialized, err := json.Marshal([]interface{}{0, pubkey, createdAt, kind, tags, content})
+	if err != nil {
+		t.Fatalf("serialize event: %v", err)
+	}
+	id := sha256.Sum256(serialized)
+	sig, err := schnorr.Sign(key, id[:])
+	if err != nil {
+		t.Fatalf("sign event: %v", err)
+	}
+
+	return map[string]interface{}{
+		"id":         hex.EncodeToString(id[:]),
+		"pubkey":     pubkey,
+		"created_at": createdAt,
+		"kind":       kind,
+		"tags":       tags,
+		"content":    content,
+		"sig":        hex.EncodeToString(sig.Serialize()),
+	}
+}
+
+func TestAuthTrezorHandlerRejectsReplay(t *testing.T) {
+	store := auth.NewChallengeStore("bitcoinpitch.org", time.Minute)
+	app := newChallengeTestApp(store)
+
+	key, err := btcec.NewPrivateKey()
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	challenge, err := store.Issue()
+	if err != nil {
+		t.Fatalf("issue challenge: %v", err)
+	}
+	signature, address := signLegacyMessage(t, key, challenge.Message)
+	request := fiber.Map{"message": challenge.Message, "signature": signature, "address": address}
+
+	// The first login used the challenge up
+	if err := store.VerifyMessage(challenge.Message); err != nil {
+		t.Fatalf("first login: %v", err)
+	}
+
+	status, body := postJSON(t, app, "/auth/trezor", request)
+	if status != fiber.StatusUnauthorized {
+		t.Fatalf("replayed login status = %d, want %d (%s)", status, fiber.StatusUnauthorized, body)
+	}
+	if !strings.Contains(body, auth.ErrChallengeUsed.Error()) {
+		t.Errorf("replayed login body = %s, want %q", body, auth.ErrChallengeUsed)
+	}
+}
+
+func TestAuthTrezorHandlerRejectsUnissuedChallenge(t *testing.T) {
+	store := auth.NewChallengeStore("bitcoinpitch.org", time.Minute)
+	app := newChallengeTestApp(store)
+
+	key, err := btcec.NewPrivateKey()
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	message := "bitcoinpitch.org wants you to sign in to BitcoinPitch.org\n\nNonce: 0123456789abcdef0123456789abcdef"
+	signature, address := signLegacyMessage(t, key, message)
+
+	status, body := postJSON(t, app, "/auth/trezor", fiber.Map{"message": message, "signature": signature, "address": address})
+	if status != fiber.StatusUnauthorized || !strings.Contains(body, auth.ErrChallengeInvalid.Error()) {
+		t.Errorf("got %d %s, want 401 with %q", status, body, auth.ErrChallengeInvalid)
+	}
+}
+
+func TestAuthNostrHandlerRejectsReplay(t *testing.T) {
+	store := auth.NewChallengeStore("bitcoinpitch.org", time.Minute)
+	app := newChallengeTestApp(store)
+
+	key, err := btcec.NewPrivateKey()
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	challenge, err := store.Issue()
+	if err != nil {
+		t.Fatalf("issue challenge: %v", err)
+	}
+	event := signNostrEvent(t, key, 22242, [][]string{
+		{"challenge", challenge.Nonce},
+		{"domain", challenge.Domain},
+	}, "")
+
+	// The first login used the challenge up
+	if err := store.VerifyNonce(challenge.Domain, challenge.Nonce); err != nil {
+		t.Fatalf("first login: %v", err)
+	}
+
+	status, body := postJSON(t, app, "/auth/nostr", fiber.Map{"event": event})
+	if status != fiber.StatusUnauthorized {
+		t.Fatalf("replayed login status = %d, want %d (%s)", status, fiber.StatusUnauthorized, body)
+	}
+	if !strings.Contains(body, auth.ErrChallengeUsed.Error()) {
+		t.Errorf("replayed login body = %s, want %q", body, auth.ErrChallengeUsed)
+	}
+}
+
+func TestAuthNostrHandlerRejectsReplayedContentChallenge(t *testing.T) {
+	store := auth.NewChallengeStore("bitcoinpitch.org", time.Minute)
+	app := newChallengeTestApp(store)
+
+	key, err := btcec.NewPrivateKey()
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	challenge, err := store.Issue()
+	if err != nil {
+		t.Fatalf("issue challenge: %v", err)
+	}
+	event := signNostrEvent(t, key, 1, [][]string{}, challenge.Message)
+
+	if err := store.VerifyMessage(challenge.Message); err != nil {
+		t.Fatalf("first login: %v", err)
+	}
+
+	status, body := postJSON(t, app, "/auth/nostr", fiber.Map{"event": event})
+	if status != fiber.StatusUnauthorized || !strings.Contains(body, auth.ErrChallengeUsed.Error()) {
+		t.Errorf("got %d %s, want 401 with %q", status, body, auth.ErrChallengeUsed)
+	}
+}
+
+func TestAuthNostrHandlerRejectsForeignDomain(t *testing.T) {
+	store := auth.NewChallengeStore("bitcoinpitch.org", time.Minute)
+	app := newChallengeTestApp(store)
+
+	key, err := btcec.NewPrivateKey()
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	challenge, err := store.Issue()
+	if err != nil {
+		t.Fatalf("issue challenge: %v", err)
+	}
+	event := signNostrEvent(t, key, 22242, [][]string{
+		{"challenge", challenge.Nonce},
+		{"domain", "evil.example"},
+	}, "")
+
+	status, body := postJSON(t, app, "/auth/nostr", fiber.Map{"event": event})
+	if status != fiber.StatusUnauthorized || !strings.Contains(body, auth.ErrChallengeDomain.Error()) {
+		t.Errorf("got %d %s, want 401 with %q", status, body, auth.ErrChallengeDomain)
+	}
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"log"
+	"net/mail"
+	"strings"
+	"time"
+
+	"bitcoinpitch.org/internal/database"
+	"bitcoinpitch.org/internal/email"
+	"bitcoinpitch.org/internal/middleware"
+	"bitcoinpitch.org/internal/models"
+
+	"github.com/CloudyKit/jet/v6"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Lifetimes of the links sent when an email address is changed. The revert
+// link outlives the confirmation so a change can still be undone after it
+// has been applied.
+const (
+	emailChangeTokenTTL = 24 * time.Hour
+	emailRevertTokenTTL = 7 * 24 * time.Hour
+)
+
+// UserChangeEmailHandler starts an email address change. The new address must
+// be confirmed through a link sent to it, and the current address, which stays
+// active until then, receives a link to cancel or undo the change.
+func UserChangeEmailHandler(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*models.User)
+	if !ok || user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+
+	if user.Email == nil || !user.EmailVerified || user.PasswordHash == nil {
+		return identityMessage(c, fiber.StatusBadRequest, "Only accounts with a verified email address can change it.")
+	}
+
+	newEmail := strings.TrimSpace(c.FormValue("new_email"))
+	password := c.FormValue("current_password")
+
+	if newEmail == "" || password == "" {
+		return identityMessage(c, fiber.StatusBadRequest, "New email and current password are required.")
+	}
+	if _, err := mail.ParseAddress(newEmail); err != nil {
+		return identityMessage(c, fiber.StatusBadRequest, "Invalid email address.")
+	}
+	if strings.EqualFold(newEmail, *user.Email) {
+		return identityMessage(c, fiber.StatusBadRequest, "This is already your email address.")
+	}
+
+	if !newPasswordService(c).VerifyPassword(password, *user.PasswordHash) {
+		return identityMessage(c, fiber.StatusUnauthorized, "Current password is incorrect.")
+	}
+
+	repo := c.Locals("repo").(*database.Repository)
+
+	if owner, err := repo.GetUserByEmail(c.Context(), newEmail); err == nil && owner.ID != user.ID {
+		return identityMessage(c, fiber.StatusConflict, "This email address is already in use.")
+	}
+	if owner, err := repo.GetUserByAuth(c.Context(), models.AuthTypeEmail, newEmail); err == nil && owner.ID != user.ID {
+		return identityMessage(c, fiber.StatusConflict, "This email address is already in use.")
+	}
+
+	changeToken, err := generateSecureToken()
+	if err != nil {
+		return identityMessage(c, fiber.StatusInternalServerError, "Failed to change email. Please try again.")
+	}
+	revertToken, err := generateSecureToken()
+	if err != nil {
+		return identityMessage(c, fiber.StatusInternalServerError, "Failed to change email. Please try again.")
+	}
+
+	now := time.Now()
+	change := models.NewEmailChangeToken(user.ID, changeToken, newEmail, models.EmailTokenPurposeChange, now.Add(emailChangeTokenTTL))
+	if err := repo.CreateEmailVerificationToken(c.Context(), change); err != nil {
+		log.Printf("[ERROR] UserChangeEmailHandler: failed to create change token: %v", err)
+		return identityMessage(c, fiber.StatusInternalServerError, "Failed to change email. Please try again.")
+	}
+	revert := models.NewEmailChangeToken(user.ID, revertToken, *user.Email, models.EmailTokenPurposeRevert, now.Add(emailRevertTokenTTL))
+	if err := repo.CreateEmailVerificationToken(c.Context(), revert); err != nil {
+		log.Printf("[ERROR] UserChangeEmailHandler: failed to create revert token: %v", err)
+		return identityMessage(c, fiber.StatusInternalServerError, "Failed to change email. Please try again.")
+	}
+
+	baseURL := siteBaseURL(c)
+	emailService := email.NewService(email.NewConfigFromEnv())
+
+	if err := emailService.SendEmailChangeNoticeEmail(*user.Email, user.GetDisplayName(), newEmail, baseURL+"/auth/verify-email?token="+revertToken); err != nil {
+		log.Printf("[ERROR] UserChangeEmailHandler: failed to send change notice: %v", err)
+	}
+	if err := emailService.SendEmailChangeVerificationEmail(newEmail, user.GetDisplayName(), baseURL+"/auth/verify-email?token="+changeToken); err != nil {
+		log.Printf("[ERROR] UserChangeEmailHandler: failed to send verification email: %v", err)
+		return identityMessage(c, fiber.StatusInternalServerError, "We couldn't send the confirmation email. Please try again later.")
+	}
+
+	log.Printf("Email change requested for user %s", user.ID)
+
+	return identityMessage(c, fiber.StatusOK, "Check your inbox at "+newEmail+" to confirm the change. Your current address stays active until then.")
+}
+
+// renderEmailChangeConfirmPage asks the owner to confirm the change or revert
+// an emailed link stands for. Nothing is applied until the form is posted.
+func renderEmailChangeConfirmPage(c *fiber.Ctx, view *jet.Set, token *models.EmailVerificationToken) error {
+	tmpl, err := view.GetTemplate("pages/verify-email.jet")
+	if err != nil {
+		return c.Status(500).SendString("Internal Server Error")
+	}
+
+	vars := make(jet.VarMap)
+	vars.Set("Confirm", token.Purpose) // "change" or "revert"
+	vars.Set("Token", token.Token)
+	vars.Set("Email", token.Email)
+	return renderVerifyEmailTemplate(c, tmpl, vars)
+}
+
+// ConfirmEmailChangeHandler applies an email change or revert once the owner
+// confirms it on the page an emailed link opens
+func ConfirmEmailChangeHandler(c *fiber.Ctx) error {
+	view := c.Locals("view").(*jet.Set)
+	repo := c.Locals("repo").(*database.Repository)
+
+	token := c.FormValue("token")
+	if token == "" {
+		return renderVerifyEmailPage(c, view, "verify.error_invalid_token", "")
+	}
+	return applyEmailChangeToken(c, view, repo, token)
+}
+
+// applyEmailChangeToken completes an email change or revert confirmed from an
+// emailed link. Every session was invalidated, so a browser signed in to the
+// account gets a fresh session after a confirmed change; after a revert it is
+// signed out like all others and a password reset link goes to the restored
+// address.
+func applyEmailChangeToken(c *fiber.Ctx, view *jet.Set, repo *database.Repository, token string) error {
+	applied, err := repo.ApplyEmailChange(c.Context(), token)
+	if err != nil {
+		switch err {
+		case database.ErrNotFound:
+			return renderVerifyEmailPage(c, view, "verify.error_invalid_token", "")
+		case database.ErrIdentityTaken:
+			return renderVerifyEmailPage(c, view, "verify.error_email_taken", "")
+		}
+		log.Printf("[ERROR] applyEmailChangeToken: %v", err)
+		return renderVerifyEmailPage(c, view, "verify.error_verification_failed", "")
+	}
+
+	current, _ := c.Locals("user").(*models.User)
+	signedIn := current != nil && current.ID == applied.UserID
+
+	if applied.Purpose == models.EmailTokenPurposeRevert {
+		log.Printf("Email change reverted for user %s; sessions, API tokens and password revoked", applied.UserID)
+		if signedIn {
+			c.ClearCookie("session_token")
+		}
+		// The password was cleared, so the owner sets a new one from the
+		// restored address
+		go sendPasswordResetLink(repo, applied.Email)
+		return renderVerifyEmailPage(c, view, "", "reverted")
+	}
+
+	log.Printf("Email changed for user %s; all sessions invalidated", applied.UserID)
+	if signedIn {
+		createSession := middleware.CreateSession
+		if middleware.IsReadOnlySession(c) {
+			createSession = middleware.CreateReadOnlySession
+		}
+		sessionToken, err := createSession(repo, c, applied.UserID)
+		if err != nil {
+			log.Printf("[ERROR] applyEmailChangeToken: failed to re-issue session: %v", err)
+			c.ClearCookie("session_token")
+		} else {
+			middleware.SetSessionCookie(c, sessionToken)
+		}
+	}
+	return renderVerifyEmailPage(c, view, "", "changed")
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"io"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"bitcoinpitch.org/internal/models"
+
+	"github.com/CloudyKit/jet/v6"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestEmailChangeConfirmPage(t *testing.T) {
+	view := jet.NewSet(jet.NewOSFileSystemLoader("../templates"))
+	// The layout needs the globals cmd/server registers
+	view.AddGlobalFunc("categories", func(args jet.Arguments) reflect.Value {
+		return reflect.ValueOf([]*models.Category{})
+	})
+	view.AddGlobalFunc("t", func(args jet.Arguments) reflect.Value {
+		return args.Get(0)
+	})
+
+	for _, purpose := range []string{models.EmailTokenPurposeChange, models.EmailTokenPurposeRevert} {
+		t.Run(purpose, func(t *testing.T) {
+			token := &models.EmailVerificationToken{Token: "link-token", Email: "bob@example.com", Purpose: purpose}
+			app := fiber.New()
+			app.Get("/auth/verify-email", func(c *fiber.Ctx) error {
+				c.Locals("csrf", "csrf-token")
+				return renderEmailChangeConfirmPage(c, view, token)
+			})
+
+			resp, err := app.Test(httptest.NewRequest("GET", "/auth/verify-email?token=link-token", nil))
+			if err != nil {
+				t.Fatalf("request: %v", err)
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			page := string(body)
+
+			// The link only opens a form; the change needs a CSRF-checked POST
+			for _, want := range []string{
+				`<form method="POST" action="/auth/verify-email"`,
+				`name="_token" value="csrf-token"`,
+				`name="token" value="link-token"`,
+				"verify.confirm_" + purpose + "_button",
+			} {
+				if !strings.Contains(page, want) {
+					t.Errorf("confirmation page (status %d) is missing %q", resp.StatusCode, want)
+				}
+			}
+		})
+	}
+}
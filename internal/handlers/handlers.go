@@ -19,6 +19,12 @@ import (
 	"bitcoinpitch.org/internal/validation"
 )
 
+// homeCategoryPitches is one category's tab on the home page
+type homeCategoryPitches struct {
+	Category *models.Category
+	Pitches  []models.Pitch
+}
+
 // HomeHandler renders the home page
 func HomeHandler(c *fiber.Ctx) error {
 	view := c.Locals("view").(*jet.Set)
@@ -135,148 +141,64 @@ func HomeHandler(c *fiber.Ctx) error {
 		additionalFilters["user_id"] = user.ID
 	}
 
-	// Fetch pitches for each category with pagination and filters
-	var bitcoinPitches, lightningPitches, cashuPitches []models.Pitch
+	// Fetch pitches for each active category with pagination and filters
+	categories := configService.Categories(c.Context())
+	categoryPitches := make([]homeCategoryPitches, 0, len(categories))
+	totalPitches := 0
 
-	// Bitcoin pitches
-	bitcoinFilters := map[string]interface{}{"main_category": "bitcoin"}
-	for key, value := range additionalFilters {
-		bitcoinFilters[key] = value
-	}
-
-	var bitcoinPitchList []*models.Pitch
-	var err error
-
-	if tagFilter != "" {
-		bitcoinPitchList, err = repo.ListPitchesByTagAndFilters(c.Context(), "bitcoin", tagFilter, additionalFilters, pageSize, offset)
-	} else {
-		bitcoinPitchList, err = repo.ListPitches(c.Context(), bitcoinFilters, pageSize, offset)
-	}
-
-	if err != nil {
-		println("[HomeHandler] Bitcoin pitches error:", err.Error())
-		return c.Status(fiber.StatusInternalServerError).SendString("Failed to fetch bitcoin pitches: " + err.Error())
-	}
-
-	for _, p := range bitcoinPitchList {
-		p.CurrentUser = user
-		if user != nil {
-			currentVote, err := repo.GetVote(c.Context(), p.ID, user.ID)
-			if err != nil && err != database.ErrNotFound {
-				println("[DEBUG] Error getting current vote for pitch", p.ID.String(), ":", err.Error())
-			}
-			p.CurrentUserVote = currentVote
+	for _, category := range categories {
+		filters := map[string]interface{}{"main_category": category.Slug}
+		for key, value := range additionalFilters {
+			filters[key] = value
 		}
-		bitcoinPitches = append(bitcoinPitches, *p)
-	}
-
-	// Lightning pitches
-	lightningFilters := map[string]interface{}{"main_category": "lightning"}
-	for key, value := range additionalFilters {
-		lightningFilters[key] = value
-	}
-
-	var lightningPitchList []*models.Pitch
-
-	if tagFilter != "" {
-		lightningPitchList, err = repo.ListPitchesByTagAndFilters(c.Context(), "lightning", tagFilter, additionalFilters, pageSize, offset)
-	} else {
-		lightningPitchList, err = repo.ListPitches(c.Context(), lightningFilters, pageSize, offset)
-	}
 
-	if err != nil {
-		println("[HomeHandler] Lightning pitches error:", err.Error())
-		return c.Status(fiber.StatusInternalServerError).SendString("Failed to fetch lightning pitches: " + err.Error())
-	}
+		var pitchList []*models.Pitch
+		var err error
 
-	for _, p := range lightningPitchList {
-		p.CurrentUser = user
-		if user != nil {
-			currentVote, err := repo.GetVote(c.Context(), p.ID, user.ID)
-			if err != nil && err != database.ErrNotFound {
-				println("[DEBUG] Error getting current vote for pitch", p.ID.String(), ":", err.Error())
-			}
-			p.CurrentUserVote = currentVote
+		if tagFilter != "" {
+			pitchList, err = repo.ListPitchesByTagAndFilters(c.Context(), category.Slug, tagFilter, additionalFilters, pageSize, offset)
+		} else {
+			pitchList, err = repo.ListPitches(c.Context(), filters, pageSize, offset)
 		}
-		lightningPitches = append(lightningPitches, *p)
-	}
 
-	// Cashu pitches
-	cashuFilters := map[string]interface{}{"main_category": "cashu"}
-	for key, value := range additionalFilters {
-		cashuFilters[key] = value
-	}
-
-	var cashuPitchList []*models.Pitch
-
-	if tagFilter != "" {
-		cashuPitchList, err = repo.ListPitchesByTagAndFilters(c.Context(), "cashu", tagFilter, additionalFilters, pageSize, offset)
-	} else {
-		cashuPitchList, err = repo.ListPitches(c.Context(), cashuFilters, pageSize, offset)
-	}
-
-	if err != nil {
-		println("[HomeHandler] Cashu pitches error:", err.Error())
-		return c.Status(fiber.StatusInternalServerError).SendString("Failed to fetch cashu pitches: " + err.Error())
-	}
+		if err != nil {
+			log.Printf("[ERROR] HomeHandler: pitches for %s: %v", category.Slug, err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Failed to fetch pitches")
+		}
 
-	for _, p := range cashuPitchList {
-		p.CurrentUser = user
-		if user != nil {
-			currentVote, err := repo.GetVote(c.Context(), p.ID, user.ID)
-			if err != nil && err != database.ErrNotFound {
-				println("[DEBUG] Error getting current vote for pitch", p.ID.String(), ":", err.Error())
+		section := homeCategoryPitches{Category: category}
+		for _, p := range pitchList {
+			p.CurrentUser = user
+			if user != nil {
+				currentVote, err := repo.GetVote(c.Context(), p.ID, user.ID)
+				if err != nil && err != database.ErrNotFound {
+					println("[DEBUG] Error getting current vote for pitch", p.ID.String(), ":", err.Error())
+				}
+				p.CurrentUserVote = currentVote
 			}
-			p.CurrentUserVote = currentVote
+			section.Pitches = append(section.Pitches, *p)
 		}
-		cashuPitches = append(cashuPitches, *p)
-	}
-
-	// Calculate total counts for pagination across all categories
-	var totalBitcoinPitches, totalLightningPitches, totalCashuPitches int
-	var totalPitches int
+		categoryPitches = append(categoryPitches, section)
 
-	if tagFilter != "" {
-		// Count by tag and filters for each category
-		totalBitcoinPitches, err = repo.CountPitchesByTagAndFilters(c.Context(), "bitcoin", tagFilter, additionalFilters)
-		if err != nil {
-			totalBitcoinPitches = len(bitcoinPitches)
-		}
-		totalLightningPitches, err = repo.CountPitchesByTagAndFilters(c.Context(), "lightning", tagFilter, additionalFilters)
-		if err != nil {
-			totalLightningPitches = len(lightningPitches)
-		}
-		totalCashuPitches, err = repo.CountPitchesByTagAndFilters(c.Context(), "cashu", tagFilter, additionalFilters)
-		if err != nil {
-			totalCashuPitches = len(cashuPitches)
-		}
-	} else {
-		// Count by filters for each category
-		totalBitcoinPitches, err = repo.CountPitches(c.Context(), bitcoinFilters)
-		if err != nil {
-			totalBitcoinPitches = len(bitcoinPitches)
-		}
-		totalLightningPitches, err = repo.CountPitches(c.Context(), lightningFilters)
-		if err != nil {
-			totalLightningPitches = len(lightningPitches)
+		// Calculate total counts for pagination across all categories
+		var total int
+		if tagFilter != "" {
+			total, err = repo.CountPitchesByTagAndFilters(c.Context(), category.Slug, tagFilter, additionalFilters)
+		} else {
+			total, err = repo.CountPitches(c.Context(), filters)
 		}
-		totalCashuPitches, err = repo.CountPitches(c.Context(), cashuFilters)
 		if err != nil {
-			totalCashuPitches = len(cashuPitches)
+			total = len(section.Pitches)
 		}
+		totalPitches += total
 	}
 
-	// Calculate total across all categories for the displayed view
-	totalPitches = totalBitcoinPitches + totalLightningPitches + totalCashuPitches
-
 	totalPages := (totalPitches + pageSize - 1) / pageSize
 	if totalPages < 1 {
 		totalPages = 1
 	}
 
-	vars.Set("bitcoinPitches", bitcoinPitches)
-	vars.Set("lightningPitches", lightningPitches)
-	vars.Set("cashuPitches", cashuPitches)
+	vars.Set("CategoryPitches", categoryPitches)
 
 	// Set pagination variables
 	vars.Set("CurrentPage", page)
@@ -308,15 +230,30 @@ func HomeHandler(c *fiber.Ctx) error {
 // PitchListHandler renders the list of pitches for a category
 func PitchListHandler(c *fiber.Ctx) error {
 	view := c.Locals("view").(*jet.Set)
-	category := c.Path()[1:] // Remove leading slash
+	repo := c.Locals("repo").(*database.Repository)
+	configService := c.Locals("configService").(*config.Service)
+
+	// Unknown and inactive categories have no listing
+	category := c.Params("category")
+	currentCategory := configService.Category(c.Context(), category)
+	if currentCategory == nil {
+		return NotFoundHandler(c)
+	}
 
 	// Get user from context if authenticated
 	user, _ := c.Locals("user").(*models.User)
 
+	lang := "en"
+	if currentLang, ok := c.Locals("currentLang").(string); ok && currentLang != "" {
+		lang = currentLang
+	}
+	categoryName := currentCategory.GetName(lang)
+
 	vars := make(jet.VarMap)
-	vars.Set("Title", category+" Pitches")
-	vars.Set("Description", "Browse "+category+" pitches")
+	vars.Set("Title", categoryName+" Pitches")
+	vars.Set("Description", "Browse "+categoryName+" pitches")
 	vars.Set("Category", category)
+	vars.Set("CurrentCategory", currentCategory)
 	vars.Set("TagFilter", "")      // Default empty value
 	vars.Set("LengthFilter", "")   // Default empty value
 	vars.Set("AuthorFilter", "")   // Default empty value
@@ -333,25 +270,11 @@ func PitchListHandler(c *fiber.Ctx) error {
 		vars.Set("ShowUserMenu", false)
 	}
 
-	// Set current language from i18n middleware
-	if currentLang := c.Locals("currentLang"); currentLang != nil {
-		vars.Set("currentLang", currentLang)
-	} else {
-		vars.Set("currentLang", "en") // fallback to English
-	}
-
-	repo := c.Locals("repo").(*database.Repository)
-	configService := c.Locals("configService").(*config.Service)
+	vars.Set("currentLang", lang)
 
 	// Get pagination configuration
 	paginationConfig := configService.PaginationConfig(c.Context())
 
-	// Parse pagination parameters
-	page, _ := strconv.Atoi(c.Query("page", "1"))
-	if page < 1 {
-		page = 1
-	}
-
 	// Determine default page size based on user preference or system default
 	defaultPageSize := paginationConfig.DefaultPageSize
 	if user != nil && user.GetPageSize() > 0 {
@@ -366,8 +289,6 @@ func PitchListHandler(c *fiber.Ctx) error {
 		pageSize = paginationConfig.MaxPageSize
 	}
 
-	offset := (page - 1) * pageSize
-
 	// Get all filters from query parameters
 	tagFilter := c.Query("tag")
 	lengthFilter := c.Query("length")
@@ -425,53 +346,40 @@ func PitchListHandler(c *fiber.Ctx) error {
 		additionalFilters["user_id"] = user.ID
 	}
 
-	var pitches []*models.Pitch
-	var totalPitches int
+	sortMode, sortPeriod := applySort(c, configService.DefaultSort(c.Context(), category), additionalFilters)
+	setSortVars(vars, sortMode, sortPeriod)
+
+	// A broken cursor starts the listing over
+	cursor, _ := parseCursor(c, sortMode)
 
+	page, err := repo.ListPitchPage(c.Context(), category, tagFilter, additionalFilters, cursor, pageSize, 0)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("Failed to fetch pitches: " + err.Error())
+	}
+
+	var totalPitches int
 	if tagFilter != "" {
-		// Use combined tag and filters query
-		pitches, err = repo.ListPitchesByTagAndFilters(c.Context(), category, tagFilter, additionalFilters, pageSize, offset)
-		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).SendString("Failed to fetch pitches: " + err.Error())
-		}
 		totalPitches, err = repo.CountPitchesByTagAndFilters(c.Context(), category, tagFilter, additionalFilters)
-		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).SendString("Failed to count pitches: " + err.Error())
-		}
 	} else {
-		// Use regular filtering with main_category and all other filters
 		filters := map[string]interface{}{"main_category": category}
-
-		// Add all additional filters to the main filters map
 		for key, value := range additionalFilters {
 			filters[key] = value
 		}
-
-		pitches, err = repo.ListPitches(c.Context(), filters, pageSize, offset)
-		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).SendString("Failed to fetch pitches: " + err.Error())
-		}
 		totalPitches, err = repo.CountPitches(c.Context(), filters)
-		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).SendString("Failed to count pitches: " + err.Error())
-		}
 	}
-
-	// Set current user for each pitch to enable edit/delete buttons
-	for i := range pitches {
-		pitches[i].CurrentUser = user
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("Failed to count pitches: " + err.Error())
 	}
 
-	// Calculate pagination info
-	totalPages := (totalPitches + pageSize - 1) / pageSize
-	if totalPages < 1 {
-		totalPages = 1
+	// Set current user for each pitch to enable edit/delete buttons
+	for i := range page.Pitches {
+		page.Pitches[i].CurrentUser = user
 	}
 
 	// Set pagination variables
-	vars.Set("pitches", pitches)
-	vars.Set("CurrentPage", page)
-	vars.Set("TotalPages", totalPages)
+	vars.Set("pitches", page.Pitches)
+	vars.Set("NextURL", cursorURL(c, page.NextCursor))
+	vars.Set("PrevURL", cursorURL(c, page.PrevCursor))
 	vars.Set("TotalPitches", totalPitches)
 	vars.Set("PageSize", pageSize)
 	vars.Set("PaginationConfig", paginationConfig)
@@ -483,11 +391,11 @@ func PitchListHandler(c *fiber.Ctx) error {
 	// Add footer configuration
 	addFooterConfig(c, vars)
 
-	// Check if this is an HTMX request for pagination
+	// Check if this is an HTMX request for the next page of the infinite scroll
 	isHTMX := c.Get("HX-Request") == "true"
 	var templateName string
 	if isHTMX {
-		// For HTMX requests, return only the pitch list fragment
+		// For HTMX requests, return only the pitches and the next page trigger
 		templateName = "partials/pitch-list-fragment.jet"
 	} else {
 		// For regular requests, return the full page
@@ -582,6 +490,13 @@ func PitchEditHandler(c *fiber.Ctx) error {
 		vars.Set("MainCategory", pitch.MainCategory)
 		vars.Set("PitchLimits", configService.PitchLimits(c.Context())) // Pass current pitch limits to template
 
+		// Pass current language for i18n
+		if currentLang := c.Locals("currentLang"); currentLang != nil {
+			vars.Set("currentLang", currentLang)
+		} else {
+			vars.Set("currentLang", "en")
+		}
+
 		// Pass CSRF token to template
 		if csrfToken := c.Locals("csrf"); csrfToken != nil {
 			vars.Set("CsrfToken", csrfToken)
@@ -648,7 +563,10 @@ func PitchEditHandler(c *fiber.Ctx) error {
 
 	// ANTISPAM CHECK: Check if user can edit this pitch
 	if err := middleware.CheckPitchEditLimit(c, user.ID, pitchID, input.Content); err != nil {
-		return err // Error response already sent by middleware
+		if err == middleware.ErrAntiSpamResponded {
+			return nil // Error response already sent by middleware
+		}
+		return err
 	}
 
 	input.Language = c.FormValue("language")
@@ -662,6 +580,7 @@ func PitchEditHandler(c *fiber.Ctx) error {
 	if authorHandle := c.FormValue("author_handle"); authorHandle != "" {
 		input.AuthorHandle = &authorHandle
 	}
+	input.AuthorNIP05 = parseAuthorNIP05(c)
 
 	// Handle tags: split comma-separated string into array
 	if tagsStr := c.FormValue("tags"); tagsStr != "" {
@@ -689,13 +608,22 @@ func PitchEditHandler(c *fiber.Ctx) error {
 		})
 	}
 
+	// Small fixes keep the votes, anything larger resets them
+	editKind, err := classifyPitchEdit(c, repo, pitch, input.Content, input.MainCategory, input.AuthorType, input.AuthorHandle)
+	if err != nil {
+		log.Printf("[ERROR] PitchEditHandler: classifyPitchEdit: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update pitch",
+		})
+	}
+
 	// Update pitch
-	pitch.Content = input.Content
 	pitch.Language = input.Language
 	pitch.MainCategory = input.MainCategory
 	pitch.LengthCategory = input.LengthCategory
 	pitch.SetAuthor(input.AuthorType, input.AuthorName, input.AuthorHandle)
-	pitch.Edit(input.Content)
+	pitch.AuthorNIP05 = input.AuthorNIP05
+	pitch.Edit(input.Content, editKind)
 
 	if len(input.Tags) > 0 {
 		pitch.Tags = make([]models.Tag, len(input.Tags))
@@ -704,13 +632,17 @@ func PitchEditHandler(c *fiber.Ctx) error {
 		}
 	}
 
-	if err := repo.UpdatePitch(c.Context(), pitch); err != nil {
-		println("[DEBUG] repo.UpdatePitch error:", err.Error())
+	if err := repo.EditPitch(c.Context(), pitch, user.ID, nil); err != nil {
+		println("[DEBUG] repo.EditPitch error:", err.Error())
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to update pitch: " + err.Error(),
 		})
 	}
 
+	if pitch.AuthorNIP05 != nil {
+		verifyPitchAuthorNIP05(c, pitch.ID)
+	}
+
 	// If HTMX request, return updated pitch card HTML
 	if c.Get("HX-Request") == "true" {
 		// Fetch the full pitch from DB to ensure all fields are populated
@@ -842,7 +774,10 @@ func PitchVoteHandler(c *fiber.Ctx) error {
 
 	// ANTISPAM CHECK: Check if user can vote
 	if err := middleware.CheckVoteLimit(c, pitchID); err != nil {
-		return err // Error response already sent by middleware
+		if err == middleware.ErrAntiSpamResponded {
+			return nil // Error response already sent by middleware
+		}
+		return err
 	}
 
 	// Parse vote type from JSON body (hx-vals sends JSON)
@@ -1154,6 +1089,10 @@ func SearchHandler(c *fiber.Ctx) error {
 		filters["user_id"] = user.ID
 	}
 
+	// Search results are ordered by relevance unless a sort is chosen
+	sortMode, sortPeriod := applySort(c, "", filters)
+	setSortVars(vars, sortMode, sortPeriod)
+
 	var pitches []*models.Pitch
 	var totalPitches int
 	var err error
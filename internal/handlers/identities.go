@@ -0,0 +1,346 @@
+package handlers
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"net/mail"
+	"os"
+	"strings"
+	"time"
+
+	"bitcoinpitch.org/internal/auth"
+	"bitcoinpitch.org/internal/crypto"
+	"bitcoinpitch.org/internal/database"
+	"bitcoinpitch.org/internal/email"
+	"bitcoinpitch.org/internal/models"
+
+	"github.com/CloudyKit/jet/v6"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// linkIdentity links a verified login identity to the user. It returns a
+// user-facing error if the identity already belongs to this or another account.
+func linkIdentity(c *fiber.Ctx, repo *database.Repository, user *models.User, authType models.AuthType, authID string) error {
+	if owner, err := repo.GetUserByAuth(c.Context(), authType, authID); err == nil {
+		if owner.ID == user.ID {
+			return fmt.Errorf("this login method is already linked to your account")
+		}
+		return fmt.Errorf("this login method belongs to another account; ask an administrator to merge the two accounts")
+	}
+
+	identity := models.NewUserIdentity(user.ID, authType, authID)
+	if err := repo.CreateUserIdentity(c.Context(), identity); err != nil {
+		if err == database.ErrIdentityTaken {
+			return fmt.Errorf("this login method belongs to another account; ask an administrator to merge the two accounts")
+		}
+		log.Printf("[ERROR] linkIdentity: %v", err)
+		return fmt.Errorf("failed to link login method")
+	}
+
+	log.Printf("[DEBUG] linkIdentity: linked %s identity to user %s", authType, user.ID)
+	return nil
+}
+
+// UserLinkNostrHandler links a Nostr key to the current account. Like the login,
+// the key must sign a fresh login challenge to prove control.
+func UserLinkNostrHandler(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*models.User)
+	if !ok || user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+
+	var req struct {
+		Event map[string]interface{} `json:"event"`
+	}
+
+	if err := c.BodyParser(&req); err != nil || req.Event == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Missing event",
+		})
+	}
+
+	pubkey, err := crypto.ExtractPubkeyFromEvent(req.Event)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid event pubkey: " + err.Error(),
+		})
+	}
+
+	if err := crypto.VerifyNostrEvent(req.Event); err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid Nostr signature: " + err.Error(),
+		})
+	}
+
+	challengeStore := c.Locals("challengeStore").(*auth.ChallengeStore)
+	if err := verifyNostrChallenge(challengeStore, req.Event); err != nil {
+		log.Printf("[DEBUG] UserLinkNostrHandler: challenge rejected for %s: %v", pubkey, err)
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid login challenge: " + err.Error(),
+		})
+	}
+
+	repo := c.Locals("repo").(*database.Repository)
+	if err := linkIdentity(c, repo, user, models.AuthTypeNostr, pubkey); err != nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	refreshUserNIP05(c, user.ID)
+
+	return c.JSON(fiber.Map{
+		"message": "Nostr key linked",
+	})
+}
+
+// UserLinkTrezorHandler links a Bitcoin address to the current account after it
+// signed a login challenge
+func UserLinkTrezorHandler(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*models.User)
+	if !ok || user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+
+	var req struct {
+		Message   string `json:"message"`
+		Signature string `json:"signature"`
+		Address   string `json:"address"`
+	}
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if req.Message == "" || req.Signature == "" || req.Address == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Missing required fields",
+		})
+	}
+
+	if err := crypto.ValidateBitcoinAddress(req.Address); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid Bitcoin address",
+		})
+	}
+
+	if err := crypto.VerifyBitcoinMessage(req.Message, req.Signature, req.Address); err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid signature: " + err.Error(),
+		})
+	}
+
+	challengeStore := c.Locals("challengeStore").(*auth.ChallengeStore)
+	if err := challengeStore.VerifyMessage(req.Message); err != nil {
+		log.Printf("[DEBUG] UserLinkTrezorHandler: challenge rejected for %s: %v", req.Address, err)
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid login challenge: " + err.Error(),
+		})
+	}
+
+	repo := c.Locals("repo").(*database.Repository)
+	if err := linkIdentity(c, repo, user, models.AuthTypeTrezor, req.Address); err != nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Bitcoin address linked",
+	})
+}
+
+// UserLinkLNURLHandler renders an LNURL-auth QR code for linking a Lightning wallet
+func UserLinkLNURLHandler(c *fiber.Ctx) error {
+	vars, err := issueLNURLAuth(c, "link", "/user/identities/lnurl/status")
+	if err != nil {
+		log.Printf("[ERROR] UserLinkLNURLHandler: %v", err)
+		return renderLNURLAuth(c, jet.VarMap{}, "Could not start linking a Lightning wallet. Please try again.")
+	}
+	return renderLNURLAuth(c, vars, "")
+}
+
+// UserLinkLNURLStatusHandler is polled by the profile page until the wallet has
+// signed, then links its linking key to the current account
+func UserLinkLNURLStatusHandler(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*models.User)
+	if !ok || user == nil {
+		return renderLNURLAuth(c, jet.VarMap{}, "You are not signed in.")
+	}
+
+	lnurlStore := c.Locals("lnurlAuthStore").(*auth.LNURLAuthStore)
+
+	linkingKey, err := lnurlStore.Claim(c.Cookies(lnurlAuthCookieName))
+	if err == auth.ErrLNURLAuthPending {
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+	c.ClearCookie(lnurlAuthCookieName)
+	if err != nil {
+		return renderLNURLAuth(c, jet.VarMap{}, "This Lightning request has expired. Please start again.")
+	}
+
+	repo := c.Locals("repo").(*database.Repository)
+	if err := linkIdentity(c, repo, user, models.AuthTypeLNURL, linkingKey); err != nil {
+		return renderLNURLAuth(c, jet.VarMap{}, capitalize(err.Error())+".")
+	}
+
+	return sessionsRedirect(c, "/user/profile?linked=lnurl")
+}
+
+// UserLinkEmailHandler adds an email and password login to an account that has
+// none. The email only becomes a login method once it has been verified.
+func UserLinkEmailHandler(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*models.User)
+	if !ok || user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+
+	if user.Email != nil && user.EmailVerified {
+		return identityMessage(c, fiber.StatusBadRequest, "Your account already has a verified email address.")
+	}
+
+	emailAddress := strings.TrimSpace(c.FormValue("email"))
+	password := c.FormValue("password")
+	confirmPassword := c.FormValue("confirm_password")
+
+	if emailAddress == "" || password == "" {
+		return identityMessage(c, fiber.StatusBadRequest, "Email and password are required.")
+	}
+	if password != confirmPassword {
+		return identityMessage(c, fiber.StatusBadRequest, "Passwords do not match.")
+	}
+	if _, err := mail.ParseAddress(emailAddress); err != nil {
+		return identityMessage(c, fiber.StatusBadRequest, "Invalid email address.")
+	}
+
+	passwordService := newPasswordService(c)
+	if err := passwordService.ValidatePasswordStrength(password); err != nil {
+		return identityMessage(c, fiber.StatusBadRequest, capitalize(err.Error())+".")
+	}
+
+	repo := c.Locals("repo").(*database.Repository)
+
+	if owner, err := repo.GetUserByEmail(c.Context(), emailAddress); err == nil && owner.ID != user.ID {
+		return identityMessage(c, fiber.StatusConflict, "This email address belongs to another account; ask an administrator to merge the two accounts.")
+	}
+
+	passwordHash, err := passwordService.HashPassword(password)
+	if err != nil {
+		return identityMessage(c, fiber.StatusInternalServerError, "Failed to add email. Please try again.")
+	}
+
+	user.Email = &emailAddress
+	user.PasswordHash = &passwordHash
+	user.EmailVerified = false
+	user.UpdatedAt = time.Now()
+
+	if err := repo.UpdateUser(c.Context(), user); err != nil {
+		log.Printf("[ERROR] UserLinkEmailHandler: %v", err)
+		return identityMessage(c, fiber.StatusConflict, "This email address is already in use.")
+	}
+
+	token, err := generateSecureToken()
+	if err != nil {
+		return identityMessage(c, fiber.StatusInternalServerError, "Failed to add email. Please try again.")
+	}
+
+	verificationToken := models.NewEmailVerificationToken(user.ID, token, emailAddress, time.Now().Add(24*time.Hour))
+	if err := repo.CreateEmailVerificationToken(c.Context(), verificationToken); err != nil {
+		log.Printf("[ERROR] UserLinkEmailHandler: failed to create verification token: %v", err)
+		return identityMessage(c, fiber.StatusInternalServerError, "Failed to add email. Please try again.")
+	}
+
+	siteURL := os.Getenv("SITE_URL")
+	if siteURL == "" {
+		siteURL = "http://localhost:8090" // fallback for development
+	}
+	verificationURL := fmt.Sprintf("%s/auth/verify-email?token=%s", siteURL, token)
+
+	emailService := email.NewService(email.NewConfigFromEnv())
+	if err := emailService.SendVerificationEmail(emailAddress, user.GetDisplayName(), verificationURL); err != nil {
+		log.Printf("[ERROR] UserLinkEmailHandler: failed to send verification email: %v", err)
+		return identityMessage(c, fiber.StatusInternalServerError, "Email saved, but we couldn't send the verification email. Please contact support.")
+	}
+
+	return identityMessage(c, fiber.StatusOK, "Check your inbox to verify "+emailAddress+". It becomes a login method once verified.")
+}
+
+// UserUnlinkIdentityHandler removes a login method from the current account
+func UserUnlinkIdentityHandler(c *fiber.Ctx) error {
+	repo := c.Locals("repo").(*database.Repository)
+
+	user, ok := c.Locals("user").(*models.User)
+	if !ok || user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+
+	identityID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid login method ID",
+		})
+	}
+
+	if err := repo.DeleteUserIdentity(c.Context(), user.ID, identityID); err != nil {
+		switch err {
+		case database.ErrNotFound:
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Login method not found",
+			})
+		case database.ErrLastIdentity:
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "You cannot remove your only login method",
+			})
+		}
+		log.Printf("[DEBUG] UserUnlinkIdentityHandler: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to remove login method",
+		})
+	}
+
+	refreshUserNIP05(c, user.ID)
+
+	log.Printf("User %s unlinked login method %s", user.ID, identityID)
+	return sessionsRedirect(c, "/user/profile?unlinked=1")
+}
+
+// identityMessage answers the email link form with an HTML message for HTMX,
+// or JSON otherwise
+func identityMessage(c *fiber.Ctx, status int, message string) error {
+	if c.Get("HX-Request") == "true" {
+		class := "identity-success"
+		if status >= fiber.StatusBadRequest {
+			class = "identity-error"
+		}
+		return c.Type("html").SendString(`<div class="` + class + `">` + html.EscapeString(message) + `</div>`)
+	}
+
+	if status >= fiber.StatusBadRequest {
+		return c.Status(status).JSON(fiber.Map{
+			"error": message,
+		})
+	}
+	return c.JSON(fiber.Map{
+		"message": message,
+	})
+}
+
+// capitalize upper-cases the first letter of an error message shown to users
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"bitcoinpitch.org/internal/config"
+	"bitcoinpitch.org/internal/database"
+	"bitcoinpitch.org/internal/models"
+
+	"github.com/CloudyKit/jet/v6"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// inviteCookieName remembers the invitation link a visitor followed, so it
+// applies whichever login method they sign up with
+const inviteCookieName = "bp_invite"
+
+// Reasons a new account is refused
+var (
+	errRegistrationClosed = errors.New("registration is currently closed")
+	errInviteRequired     = errors.New("an invitation code is required to create an account")
+	errInviteInvalid      = errors.New("this invitation code is not valid or has already been used")
+)
+
+// isRegistrationRefused reports whether registerUser refused the account
+// rather than failing to store it
+func isRegistrationRefused(err error) bool {
+	return err == errRegistrationClosed || err == errInviteRequired || err == errInviteInvalid
+}
+
+// registerUser creates an account for a first-time login, following the
+// configured registration mode. An invitation code is required in invite
+// mode and recorded whenever one is given.
+func registerUser(c *fiber.Ctx, repo *database.Repository, user *models.User) error {
+	mode := registrationMode(c)
+	if mode == models.RegistrationModeClosed {
+		return errRegistrationClosed
+	}
+
+	code := registrationInviteCode(c)
+	if code == "" {
+		if mode == models.RegistrationModeInvite {
+			return errInviteRequired
+		}
+		return repo.CreateUser(c.Context(), user)
+	}
+
+	err := repo.CreateUserWithInvite(c.Context(), user, code)
+	if err == database.ErrInviteCodeInvalid {
+		if mode == models.RegistrationModeInvite {
+			return errInviteInvalid
+		}
+		// Registration is open anyway, so a stale invitation link doesn't matter
+		user.InviteCodeID = nil
+		err = repo.CreateUser(c.Context(), user)
+	}
+	if err != nil {
+		return err
+	}
+
+	c.ClearCookie(inviteCookieName)
+	if user.InviteCodeID != nil {
+		log.Printf("User %s signed up with invitation %s", user.ID, *user.InviteCodeID)
+	}
+	return nil
+}
+
+// registrationMode returns who can currently create an account
+func registrationMode(c *fiber.Ctx) string {
+	if configService, ok := c.Locals("configService").(*config.Service); ok {
+		return configService.RegistrationMode(c.Context())
+	}
+	return models.RegistrationModeOpen
+}
+
+// registrationInviteCode returns the invitation code entered on the
+// registration form or remembered from an invitation link
+func registrationInviteCode(c *fiber.Ctx) string {
+	if code := normalizeInviteCode(c.FormValue("invite_code")); code != "" {
+		return code
+	}
+	return normalizeInviteCode(c.Cookies(inviteCookieName))
+}
+
+// normalizeInviteCode uppercases a code and drops the spaces and dashes
+// people add when copying it
+func normalizeInviteCode(code string) string {
+	code = strings.ToUpper(code)
+	code = strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' {
+			return -1
+		}
+		return r
+	}, code)
+	if len(code) > 32 {
+		return ""
+	}
+	return code
+}
+
+// generateInviteCode returns a random 16 character code
+func generateInviteCode() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b), nil
+}
+
+// inviteURL returns the link that signs a visitor up with an invitation code
+func inviteURL(c *fiber.Ctx, code string) string {
+	return siteBaseURL(c) + "/invite/" + code
+}
+
+// InviteLinkHandler remembers the code of a followed invitation link and
+// continues to the registration page
+func InviteLinkHandler(c *fiber.Ctx) error {
+	view := c.Locals("view").(*jet.Set)
+	repo := c.Locals("repo").(*database.Repository)
+
+	code := normalizeInviteCode(c.Params("code"))
+	invite, err := repo.GetInviteCode(c.Context(), code)
+	if err != nil || !invite.IsUsable(time.Now()) {
+		if err != nil && err != database.ErrNotFound {
+			log.Printf("[ERROR] InviteLinkHandler: %v", err)
+		}
+		c.ClearCookie(inviteCookieName)
+		return renderRegisterPage(c, view, capitalize(errInviteInvalid.Error())+".", "", "")
+	}
+
+	expires := time.Now().Add(7 * 24 * time.Hour)
+	if invite.ExpiresAt != nil && invite.ExpiresAt.Before(expires) {
+		expires = *invite.ExpiresAt
+	}
+	c.Cookie(&fiber.Cookie{
+		Name:     inviteCookieName,
+		Value:    invite.Code,
+		Expires:  expires,
+		HTTPOnly: true,
+		Secure:   strings.HasPrefix(c.BaseURL(), "https"),
+		SameSite: "Lax",
+	})
+
+	return c.Redirect("/register")
+}
+
+// UserCreateInviteHandler creates an invitation code within the user's quota
+func UserCreateInviteHandler(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*models.User)
+	if !ok || user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+
+	code, err := generateInviteCode()
+	if err != nil {
+		log.Printf("[ERROR] UserCreateInviteHandler: %v", err)
+		return identityMessage(c, fiber.StatusInternalServerError, "Failed to create invitation.")
+	}
+
+	var expiresAt *time.Time
+	if configService, ok := c.Locals("configService").(*config.Service); ok {
+		if days := configService.GetInt(c.Context(), "users.invite_expiry_days", 30); days > 0 {
+			expiry := time.Now().Add(time.Duration(days) * 24 * time.Hour)
+			expiresAt = &expiry
+		}
+	}
+
+	invite := models.NewInviteCode(code, &user.ID, 1, expiresAt)
+	if note := strings.TrimSpace(c.FormValue("note")); note != "" {
+		note = truncateRunes(note, 200)
+		invite.Note = &note
+	}
+
+	repo := c.Locals("repo").(*database.Repository)
+	if err := repo.CreateUserInviteCode(c.Context(), invite); err != nil {
+		if err == database.ErrInviteQuotaExceeded {
+			return identityMessage(c, fiber.StatusForbidden, "You have no invitations left.")
+		}
+		log.Printf("[ERROR] UserCreateInviteHandler: %v", err)
+		return identityMessage(c, fiber.StatusInternalServerError, "Failed to create invitation.")
+	}
+
+	log.Printf("User %s created invitation %s", user.ID, invite.ID)
+	return sessionsRedirect(c, "/user/profile#invites")
+}
+
+// UserRevokeInviteHandler revokes one of the user's own invitation codes
+func UserRevokeInviteHandler(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*models.User)
+	if !ok || user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+
+	inviteID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid invitation ID",
+		})
+	}
+
+	repo := c.Locals("repo").(*database.Repository)
+	if err := repo.RevokeInviteCode(c.Context(), inviteID, &user.ID); err != nil {
+		if err == database.ErrNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Invitation not found",
+			})
+		}
+		log.Printf("[ERROR] UserRevokeInviteHandler: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to revoke invitation",
+		})
+	}
+
+	return sessionsRedirect(c, "/user/profile#invites")
+}
+
+// setUserInviteVars adds the user's invitation codes to the profile page
+func setUserInviteVars(c *fiber.Ctx, repo *database.Repository, user *models.User, vars jet.VarMap) {
+	vars.Set("Invites", []*models.InviteCode{})
+	vars.Set("InvitesLeft", 0)
+
+	invites, err := repo.GetInviteCodesByCreator(c.Context(), user.ID)
+	if err != nil {
+		log.Printf("[ERROR] setUserInviteVars: %v", err)
+		return
+	}
+
+	// Same rule as the quota check: unused codes that were revoked are returned
+	left := user.InviteQuota
+	for _, invite := range invites {
+		if !invite.IsRevoked() || invite.UseCount > 0 {
+			left--
+		}
+	}
+	if left < 0 {
+		left = 0
+	}
+
+	vars.Set("Invites", invites)
+	vars.Set("InvitesLeft", left)
+	vars.Set("InviteBaseURL", inviteURL(c, ""))
+}
+
+// setRegistrationVars tells the registration page whether it needs an
+// invitation code and fills in the one from a followed link
+func setRegistrationVars(c *fiber.Ctx, vars jet.VarMap) {
+	vars.Set("RegistrationMode", registrationMode(c))
+	vars.Set("InviteCode", registrationInviteCode(c))
+}
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image/png"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+
+	"bitcoinpitch.org/internal/auth"
+	"bitcoinpitch.org/internal/crypto"
+	"bitcoinpitch.org/internal/database"
+	"bitcoinpitch.org/internal/models"
+
+	"github.com/CloudyKit/jet/v6"
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/qr"
+	"github.com/gofiber/fiber/v2"
+)
+
+// lnurlAuthCookieName binds an LNURL-auth k1 to the browser that displayed it,
+// so only that browser can claim the login once the wallet has signed
+const lnurlAuthCookieName = "lnurl_k1"
+
+// AuthLNURLHandler issues an LNURL-auth challenge and renders it as a QR code
+// that polls for the wallet's signature
+func AuthLNURLHandler(c *fiber.Ctx) error {
+	vars, err := issueLNURLAuth(c, "login", "/auth/lnurl/status")
+	if err != nil {
+		log.Printf("[ERROR] AuthLNURLHandler: %v", err)
+		return renderLNURLAuth(c, jet.VarMap{}, "Could not start a Lightning login. Please try again.")
+	}
+	return renderLNURLAuth(c, vars, "")
+}
+
+// issueLNURLAuth issues a k1 for the given LUD-04 action, binds it to the browser
+// and returns the template variables for a QR code that polls statusURL
+func issueLNURLAuth(c *fiber.Ctx, action, statusURL string) (jet.VarMap, error) {
+	lnurlStore := c.Locals("lnurlAuthStore").(*auth.LNURLAuthStore)
+
+	request, err := lnurlStore.Issue()
+	if err != nil {
+		return nil, err
+	}
+
+	callback := siteBaseURL(c) + "/auth/lnurl/callback?" + url.Values{
+		"tag":    {"login"},
+		"k1":     {request.K1},
+		"action": {action},
+	}.Encode()
+
+	lnurl, err := crypto.EncodeLNURL(callback)
+	if err != nil {
+		return nil, err
+	}
+
+	qrImage, err := lnurlQRCode(lnurl)
+	if err != nil {
+		return nil, err
+	}
+
+	c.Cookie(&fiber.Cookie{
+		Name:     lnurlAuthCookieName,
+		Value:    request.K1,
+		Expires:  request.ExpiresAt,
+		HTTPOnly: true,
+		Secure:   strings.HasPrefix(c.BaseURL(), "https"),
+		SameSite: "Lax",
+	})
+	c.Set(fiber.HeaderCacheControl, "no-store")
+
+	vars := make(jet.VarMap)
+	vars.Set("LNURL", lnurl)
+	vars.Set("QRCode", qrImage)
+	vars.Set("StatusURL", statusURL)
+	return vars, nil
+}
+
+// AuthLNURLCallbackHandler is called by the Lightning wallet with its linking key
+// and signature over k1 (LUD-04). Wallets expect a JSON status, not an HTTP error.
+func AuthLNURLCallbackHandler(c *fiber.Ctx) error {
+	if c.Query("tag") != "login" {
+		return lnurlError(c, "Unsupported LNURL tag")
+	}
+
+	k1 := c.Query("k1")
+	linkingKey, err := crypto.VerifyLNURLAuth(k1, c.Query("sig"), c.Query("key"))
+	if err != nil {
+		log.Printf("[DEBUG] AuthLNURLCallbackHandler: signature rejected: %v", err)
+		return lnurlError(c, "Invalid signature: "+err.Error())
+	}
+
+	lnurlStore := c.Locals("lnurlAuthStore").(*auth.LNURLAuthStore)
+	if err := lnurlStore.Sign(k1, linkingKey); err != nil {
+		log.Printf("[DEBUG] AuthLNURLCallbackHandler: k1 rejected for %s: %v", linkingKey, err)
+		return lnurlError(c, err.Error())
+	}
+
+	return c.JSON(fiber.Map{
+		"status": "OK",
+	})
+}
+
+// AuthLNURLStatusHandler is polled by the login modal. Once the wallet has signed,
+// it signs the browser in; until then it answers 204 so HTMX keeps the QR code.
+func AuthLNURLStatusHandler(c *fiber.Ctx) error {
+	lnurlStore := c.Locals("lnurlAuthStore").(*auth.LNURLAuthStore)
+
+	linkingKey, err := lnurlStore.Claim(c.Cookies(lnurlAuthCookieName))
+	if err == auth.ErrLNURLAuthPending {
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+	c.ClearCookie(lnurlAuthCookieName)
+	if err != nil {
+		return renderLNURLAuth(c, jet.VarMap{}, "This Lightning login has expired. Please start again.")
+	}
+
+	repo := c.Locals("repo").(*database.Repository)
+
+	// Check if user already exists
+	user, err := repo.GetUserByAuth(c.Context(), models.AuthTypeLNURL, linkingKey)
+	if err != nil {
+		// User doesn't exist, create new user
+		user = models.NewUser(models.AuthTypeLNURL, linkingKey)
+		user.SetDisplayName("Lightning User")
+
+		if err := registerUser(c, repo, user); err != nil {
+			if isRegistrationRefused(err) {
+				return renderLNURLAuth(c, jet.VarMap{}, capitalize(err.Error())+".")
+			}
+			log.Printf("[ERROR] AuthLNURLStatusHandler: failed to create user: %v", err)
+			return renderLNURLAuth(c, jet.VarMap{}, "Failed to create your account. Please try again.")
+		}
+	}
+	if !user.CanLogin() {
+		return renderLNURLAuth(c, jet.VarMap{}, "This account has been disabled.")
+	}
+
+	// Create session, or a pending login if a second factor is required
+	requires2FA, err := startLogin(c, repo, user, "lnurl")
+	if err != nil {
+		log.Printf("[ERROR] AuthLNURLStatusHandler: %v", err)
+		return renderLNURLAuth(c, jet.VarMap{}, "Failed to create session. Please try again.")
+	}
+	if requires2FA {
+		c.Set("HX-Trigger", "lnurlRequires2FA")
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+
+	log.Printf("[DEBUG] AuthLNURLStatusHandler: Lightning login for user %s", user.ID)
+	c.Set("HX-Refresh", "true")
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// renderLNURLAuth renders the LNURL-auth fragment of the login modal
+func renderLNURLAuth(c *fiber.Ctx, vars jet.VarMap, errorMessage string) error {
+	view := c.Locals("view").(*jet.Set)
+
+	t, err := view.GetTemplate("partials/auth-lnurl.jet")
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("Template error: " + err.Error())
+	}
+
+	if errorMessage != "" {
+		vars.Set("Error", errorMessage)
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, vars, nil); err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("Template execution error: " + err.Error())
+	}
+
+	return c.Type("html").Send(buf.Bytes())
+}
+
+// lnurlError answers a wallet callback with an LNURL error status
+func lnurlError(c *fiber.Ctx, reason string) error {
+	return c.JSON(fiber.Map{
+		"status": "ERROR",
+		"reason": reason,
+	})
+}
+
+// lnurlQRCode renders an LNURL as a PNG data URI
+func lnurlQRCode(lnurl string) (string, error) {
+	qrCode, err := qr.Encode(lnurl, qr.M, qr.Auto)
+	if err != nil {
+		return "", err
+	}
+
+	qrCode, err = barcode.Scale(qrCode, 240, 240)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, qrCode); err != nil {
+		return "", err
+	}
+
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// siteBaseURL returns the public base URL of the site, as seen by wallets and browsers
+func siteBaseURL(c *fiber.Ctx) string {
+	if siteURL := os.Getenv("SITE_URL"); siteURL != "" {
+		return strings.TrimRight(siteURL, "/")
+	}
+	return c.BaseURL()
+}
@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"strings"
+
+	"bitcoinpitch.org/internal/config"
+	"bitcoinpitch.org/internal/crypto"
+	"bitcoinpitch.org/internal/database"
+	"bitcoinpitch.org/internal/models"
+	"bitcoinpitch.org/internal/nip05"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// UserNIP05Handler sets or clears the NIP-05 identifier shown next to the
+// user's name. It must point to one of the Nostr keys linked to the account.
+func UserNIP05Handler(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*models.User)
+	if !ok || user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+
+	service, ok := c.Locals("nip05Service").(*nip05.Service)
+	if !ok {
+		return identityMessage(c, fiber.StatusServiceUnavailable, "NIP-05 verification is not available right now.")
+	}
+
+	identifier := strings.TrimSpace(c.FormValue("nip05"))
+	if identifier == "" {
+		if _, err := service.ClaimForUser(c.Context(), user.ID, ""); err != nil {
+			log.Printf("[ERROR] UserNIP05Handler: failed to clear claim: %v", err)
+			return identityMessage(c, fiber.StatusInternalServerError, "Failed to remove NIP-05 identifier.")
+		}
+		return identityMessage(c, fiber.StatusOK, "NIP-05 identifier removed.")
+	}
+
+	identifier, err := crypto.NormalizeNIP05Identifier(identifier)
+	if err != nil {
+		return identityMessage(c, fiber.StatusBadRequest, capitalize(err.Error())+". Use the form name@example.com.")
+	}
+
+	repo := c.Locals("repo").(*database.Repository)
+	pubkeys, err := repo.GetNostrPubkeys(c.Context(), user.ID)
+	if err != nil {
+		log.Printf("[ERROR] UserNIP05Handler: %v", err)
+		return identityMessage(c, fiber.StatusInternalServerError, "Failed to verify NIP-05 identifier.")
+	}
+	if len(pubkeys) == 0 {
+		return identityMessage(c, fiber.StatusBadRequest, "Link a Nostr key to your account first.")
+	}
+
+	verified, err := service.ClaimForUser(c.Context(), user.ID, identifier)
+	if err != nil {
+		log.Printf("[ERROR] UserNIP05Handler: %v", err)
+		return identityMessage(c, fiber.StatusInternalServerError, "Failed to verify NIP-05 identifier.")
+	}
+	if !verified {
+		_, domain, _ := crypto.ParseNIP05Identifier(identifier)
+		return identityMessage(c, fiber.StatusUnprocessableEntity, "Saved, but "+domain+" doesn't list "+identifier+" for your Nostr key yet. We'll check again regularly.")
+	}
+
+	log.Printf("User %s verified NIP-05 identifier %s", user.ID, identifier)
+	return identityMessage(c, fiber.StatusOK, identifier+" is verified.")
+}
+
+// NostrWellKnownHandler serves /.well-known/nostr.json for users who publish
+// username@<site domain> as their NIP-05 identifier. Only the requested name
+// is returned; the full list is never enumerated.
+func NostrWellKnownHandler(c *fiber.Ctx) error {
+	// NIP-05 requires this so web clients can verify identifiers
+	c.Set("Access-Control-Allow-Origin", "*")
+	c.Set("Access-Control-Allow-Methods", "GET")
+	c.Set("Cache-Control", "public, max-age=300")
+
+	names := map[string]string{}
+	response := fiber.Map{"names": names}
+
+	name := strings.ToLower(c.Query("name"))
+	if !crypto.IsValidNIP05Name(name) {
+		return c.JSON(response)
+	}
+
+	repo := c.Locals("repo").(*database.Repository)
+	pubkey, err := repo.GetPublishedNostrPubkey(c.Context(), name)
+	if err != nil {
+		if err == database.ErrNotFound {
+			return c.JSON(response)
+		}
+		log.Printf("[ERROR] NostrWellKnownHandler: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to look up name",
+		})
+	}
+	names[name] = pubkey
+
+	if configService, ok := c.Locals("configService").(*config.Service); ok {
+		if relays := configService.GetStringSlice(c.Context(), "site.nip05_relays"); len(relays) > 0 {
+			response["relays"] = map[string][]string{pubkey: relays}
+		}
+	}
+
+	return c.JSON(response)
+}
+
+// UserPublishNIP05Handler turns listing the user's username in the site's
+// nostr.json on or off
+func UserPublishNIP05Handler(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*models.User)
+	if !ok || user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+
+	repo := c.Locals("repo").(*database.Repository)
+	publish := c.FormValue("publish") == "on"
+
+	if publish {
+		name := siteNIP05Name(user)
+		if name == "" {
+			return identityMessage(c, fiber.StatusBadRequest, "Your username can only contain lowercase letters, digits, dots, dashes and underscores to be used as a NIP-05 identifier.")
+		}
+		if !user.ShowUsername || !user.ShowProfileInfo {
+			return identityMessage(c, fiber.StatusBadRequest, "Make your username and profile info public in the privacy settings first.")
+		}
+		pubkeys, err := repo.GetNostrPubkeys(c.Context(), user.ID)
+		if err != nil {
+			log.Printf("[ERROR] UserPublishNIP05Handler: %v", err)
+			return identityMessage(c, fiber.StatusInternalServerError, "Failed to update NIP-05 identifier.")
+		}
+		if len(pubkeys) == 0 {
+			return identityMessage(c, fiber.StatusBadRequest, "Link a Nostr key to your account first.")
+		}
+	}
+
+	if err := repo.SetUserPublishNIP05(c.Context(), user.ID, publish); err != nil {
+		if err == database.ErrNIP05NameTaken {
+			return identityMessage(c, fiber.StatusConflict, "Another account already uses this name. Change your username to publish it.")
+		}
+		log.Printf("[ERROR] UserPublishNIP05Handler: %v", err)
+		return identityMessage(c, fiber.StatusInternalServerError, "Failed to update NIP-05 identifier.")
+	}
+
+	if !publish {
+		return identityMessage(c, fiber.StatusOK, "Your username is no longer published as a NIP-05 identifier.")
+	}
+	log.Printf("User %s published NIP-05 name %s", user.ID, siteNIP05Name(user))
+	return identityMessage(c, fiber.StatusOK, siteNIP05Name(user)+"@"+siteNIP05Domain(c)+" now points to your Nostr key.")
+}
+
+// siteNIP05Name returns the name under which a user can be listed in the
+// site's nostr.json, or an empty string if their username can't be used
+func siteNIP05Name(user *models.User) string {
+	if user.Username == nil {
+		return ""
+	}
+	name := strings.ToLower(*user.Username)
+	if name == "_" || !crypto.IsValidNIP05Name(name) {
+		return ""
+	}
+	return name
+}
+
+// siteNIP05Domain returns the domain part of identifiers served by this site
+func siteNIP05Domain(c *fiber.Ctx) string {
+	if u, err := url.Parse(siteBaseURL(c)); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return c.Hostname()
+}
+
+// parseAuthorNIP05 reads the optional NIP-05 identifier claimed for a Nostr
+// author from the pitch form. Invalid identifiers are returned as entered
+// for validation to reject.
+func parseAuthorNIP05(c *fiber.Ctx) *string {
+	identifier := strings.TrimSpace(c.FormValue("author_nip05"))
+	if identifier == "" {
+		return nil
+	}
+	if normalized, err := crypto.NormalizeNIP05Identifier(identifier); err == nil {
+		identifier = normalized
+	}
+	return &identifier
+}
+
+// verifyPitchAuthorNIP05 checks a pitch author's NIP-05 claim in the
+// background, so a slow domain doesn't hold up saving the pitch
+func verifyPitchAuthorNIP05(c *fiber.Ctx, pitchID uuid.UUID) {
+	service, ok := c.Locals("nip05Service").(*nip05.Service)
+	if !ok {
+		return
+	}
+	repo := c.Locals("repo").(*database.Repository)
+
+	go func() {
+		ctx := context.Background()
+		pitch, err := repo.GetPitch(ctx, pitchID)
+		if err != nil {
+			log.Printf("[ERROR] verifyPitchAuthorNIP05: %v", err)
+			return
+		}
+		if err := service.VerifyPitchAuthor(ctx, pitch); err != nil {
+			log.Printf("[ERROR] verifyPitchAuthorNIP05: pitch %s: %v", pitchID, err)
+		}
+	}()
+}
+
+// refreshUserNIP05 re-evaluates the user's NIP-05 claim after their linked
+// Nostr keys changed
+func refreshUserNIP05(c *fiber.Ctx, userID uuid.UUID) {
+	service, ok := c.Locals("nip05Service").(*nip05.Service)
+	if !ok {
+		return
+	}
+	repo := c.Locals("repo").(*database.Repository)
+
+	user, err := repo.GetUserByID(c.Context(), userID)
+	if err != nil {
+		return
+	}
+	if err := service.RefreshUser(c.Context(), user); err != nil {
+		log.Printf("[ERROR] refreshUserNIP05: %v", err)
+	}
+}
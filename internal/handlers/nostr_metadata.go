@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"bitcoinpitch.org/internal/crypto"
+	"bitcoinpitch.org/internal/database"
+	"bitcoinpitch.org/internal/models"
+	"bitcoinpitch.org/internal/nip05"
+
+	"github.com/CloudyKit/jet/v6"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// Limits for imported Nostr profile fields
+const (
+	nostrMetadataMaxName    = 50
+	nostrMetadataMaxAbout   = 1000
+	nostrMetadataMaxPicture = 2048
+	nostrMetadataMaxSkew    = 10 * time.Minute
+)
+
+var lud16Regex = regexp.MustCompile(`^[a-z0-9._+-]{1,64}@[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)+$`)
+
+// UserImportNostrMetadataHandler imports the profile from a signed Nostr
+// kind-0 event. The event must be signed by one of the user's linked Nostr
+// keys and be newer than the one imported before.
+func UserImportNostrMetadataHandler(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*models.User)
+	if !ok || user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+
+	meta, err := parseNostrMetadataEvent(c.FormValue("event"), time.Now())
+	if err != nil {
+		return identityMessage(c, fiber.StatusBadRequest, capitalize(err.Error())+".")
+	}
+
+	repo := c.Locals("repo").(*database.Repository)
+	pubkeys, err := repo.GetNostrPubkeys(c.Context(), user.ID)
+	if err != nil {
+		log.Printf("[ERROR] UserImportNostrMetadataHandler: %v", err)
+		return identityMessage(c, fiber.StatusInternalServerError, "Failed to import Nostr profile.")
+	}
+
+	linked := false
+	placeholders := make([]string, 0, len(pubkeys))
+	for _, pubkey := range pubkeys {
+		linked = linked || pubkey == meta.Pubkey
+		placeholders = append(placeholders, crypto.GenerateNostrDisplayName(pubkey))
+	}
+	if !linked {
+		return identityMessage(c, fiber.StatusBadRequest, "The event must be signed by a Nostr key linked to your account.")
+	}
+
+	previous, err := repo.ImportNostrMetadata(c.Context(), user.ID, meta, placeholders)
+	if err != nil {
+		if err == database.ErrStaleMetadata {
+			return identityMessage(c, fiber.StatusConflict, "A newer Nostr profile was already imported.")
+		}
+		log.Printf("[ERROR] UserImportNostrMetadataHandler: %v", err)
+		return identityMessage(c, fiber.StatusInternalServerError, "Failed to import Nostr profile.")
+	}
+
+	// Like the display name, the NIP-05 identifier is only taken over while
+	// the user hasn't claimed a different one themselves
+	ownClaim := previous.NIP05 != nil && (previous.NostrNIP05 == nil || *previous.NIP05 != *previous.NostrNIP05)
+	if meta.NIP05 != nil && !ownClaim && *meta.NIP05 != previous.GetNIP05() {
+		if service, ok := c.Locals("nip05Service").(*nip05.Service); ok {
+			if _, err := service.ClaimForUser(c.Context(), user.ID, *meta.NIP05); err != nil {
+				log.Printf("[ERROR] UserImportNostrMetadataHandler: failed to claim NIP-05: %v", err)
+			}
+		}
+	}
+
+	log.Printf("User %s imported Nostr profile from %s", user.ID, meta.Pubkey)
+	return sessionsRedirect(c, "/user/profile#nostr-profile")
+}
+
+// UserClearNostrMetadataHandler removes the imported Nostr profile
+func UserClearNostrMetadataHandler(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*models.User)
+	if !ok || user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+
+	repo := c.Locals("repo").(*database.Repository)
+	if err := repo.ClearNostrMetadata(c.Context(), user.ID); err != nil {
+		log.Printf("[ERROR] UserClearNostrMetadataHandler: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to remove Nostr profile",
+		})
+	}
+
+	return sessionsRedirect(c, "/user/profile#nostr-profile")
+}
+
+// PublicProfileHandler renders a user's public profile. Only the name is
+// shown unless the user made their profile info public.
+func PublicProfileHandler(c *fiber.Ctx) error {
+	view := c.Locals("view").(*jet.Set)
+	repo := c.Locals("repo").(*database.Repository)
+
+	userID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return NotFoundHandler(c)
+	}
+	profile, err := repo.GetUserByID(c.Context(), userID)
+	if err != nil || userID == models.AnonymousUserID || !profile.IsVisible() || profile.IsDisabled() || profile.IsDeletionScheduled() {
+		return NotFoundHandler(c)
+	}
+	// An anonymous user's profile would tie their pitches together
+	if !profile.ShouldShowUsername() {
+		return NotFoundHandler(c)
+	}
+
+	vars := make(jet.VarMap)
+	vars.Set("Title", profile.GetPublicDisplayName())
+	vars.Set("Profile", profile)
+	vars.Set("ProfileName", profile.GetPublicDisplayName())
+	vars.Set("ShowProfileInfo", profile.ShouldShowProfileInfo())
+
+	if profile.ShouldShowProfileInfo() {
+		pitchCount, err := repo.CountPitches(c.Context(), map[string]interface{}{
+			"user_id": profile.ID,
+		})
+		if err != nil {
+			log.Printf("[ERROR] PublicProfileHandler: %v", err)
+		}
+		totalScore, err := repo.SumUserPitchScores(c.Context(), profile.ID)
+		if err != nil {
+			log.Printf("[ERROR] PublicProfileHandler: %v", err)
+		}
+		vars.Set("PitchCount", pitchCount)
+		vars.Set("TotalScore", totalScore)
+	}
+
+	if user, ok := c.Locals("user").(*models.User); ok && user != nil {
+		vars.Set("User", user)
+		vars.Set("UserDisplayName", user.GetDisplayName())
+		vars.Set("ShowUserMenu", true)
+	}
+	if currentLang := c.Locals("currentLang"); currentLang != nil {
+		vars.Set("currentLang", currentLang)
+	} else {
+		vars.Set("currentLang", "en")
+	}
+	if csrfToken := c.Locals("csrf"); csrfToken != nil {
+		vars.Set("CsrfToken", csrfToken)
+	}
+
+	addFooterConfig(c, vars)
+
+	t, err := view.GetTemplate("pages/public-profile.jet")
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("Template error: " + err.Error())
+	}
+	return renderTemplate(c, t, vars)
+}
+
+// parseNostrMetadataEvent checks a signed kind-0 event and extracts the
+// profile fields it publishes. Fields that are missing or don't validate are
+// left out rather than failing the import.
+func parseNostrMetadataEvent(raw string, now time.Time) (*models.NostrMetadata, error) {
+	decoder := json.NewDecoder(strings.NewReader(raw))
+	decoder.UseNumber() // keep created_at and kind exactly as signed
+	var event map[string]interface{}
+	if err := decoder.Decode(&event); err != nil || event == nil {
+		return nil, errors.New("paste the signed event as JSON")
+	}
+
+	if kind, ok := event["kind"].(json.Number); !ok || kind.String() != "0" {
+		return nil, errors.New("the event must be a kind 0 profile event")
+	}
+	pubkey, err := crypto.ExtractPubkeyFromEvent(event)
+	if err != nil {
+		return nil, err
+	}
+	if err := crypto.VerifyNostrEvent(event); err != nil {
+		return nil, fmt.Errorf("invalid event signature")
+	}
+
+	createdAtNumber, ok := event["created_at"].(json.Number)
+	if !ok {
+		return nil, errors.New("missing created_at")
+	}
+	createdAtUnix, err := createdAtNumber.Int64()
+	if err != nil {
+		return nil, errors.New("invalid created_at")
+	}
+	createdAt := time.Unix(createdAtUnix, 0)
+	if createdAt.After(now.Add(nostrMetadataMaxSkew)) {
+		return nil, errors.New("the event is dated in the future")
+	}
+
+	content, _ := event["content"].(string)
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(content), &fields); err != nil {
+		return nil, errors.New("the event content is not a profile")
+	}
+	field := func(key string) string {
+		value, _ := fields[key].(string)
+		return strings.TrimSpace(value)
+	}
+
+	meta := &models.NostrMetadata{
+		Pubkey:    pubkey,
+		CreatedAt: createdAt,
+	}
+
+	name := field("display_name")
+	if name == "" {
+		name = field("name")
+	}
+	meta.Name = optionalField(truncateRunes(stripControl(name), nostrMetadataMaxName))
+	meta.About = optionalField(truncateRunes(field("about"), nostrMetadataMaxAbout))
+
+	if picture := field("picture"); len(picture) <= nostrMetadataMaxPicture {
+		if u, err := url.Parse(picture); err == nil && u.Scheme == "https" && u.Host != "" {
+			meta.Picture = optionalField(u.String())
+		}
+	}
+	if lud16 := strings.ToLower(field("lud16")); len(lud16) <= 320 && lud16Regex.MatchString(lud16) {
+		meta.Lud16 = &lud16
+	}
+	if identifier, err := crypto.NormalizeNIP05Identifier(field("nip05")); err == nil {
+		meta.NIP05 = &identifier
+	}
+
+	return meta, nil
+}
+
+// optionalField returns nil for an empty string
+func optionalField(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+// stripControl removes control characters, including newlines, from a name
+func stripControl(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// truncateRunes cuts a string to at most max characters
+func truncateRunes(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return strings.TrimSpace(string(runes[:max]))
+}
@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/mail"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"bitcoinpitch.org/internal/antispam"
+	"bitcoinpitch.org/internal/auth"
+	"bitcoinpitch.org/internal/database"
+	"bitcoinpitch.org/internal/email"
+	"bitcoinpitch.org/internal/models"
+	"github.com/CloudyKit/jet/v6"
+	"github.com/gofiber/fiber/v2"
+)
+
+// passwordResetTokenTTL matches the expiry promised in the reset email
+const passwordResetTokenTTL = 1 * time.Hour
+
+// ForgotPasswordPageHandler renders the forgot password form
+func ForgotPasswordPageHandler(c *fiber.Ctx) error {
+	view := c.Locals("view").(*jet.Set)
+	return renderForgotPasswordPage(c, view, "", false)
+}
+
+// ForgotPasswordHandler sends a password reset link. The response is the same
+// whether or not the email belongs to an account.
+func ForgotPasswordHandler(c *fiber.Ctx) error {
+	view := c.Locals("view").(*jet.Set)
+	repo := c.Locals("repo").(*database.Repository)
+
+	emailAddress := strings.TrimSpace(strings.ToLower(c.FormValue("email")))
+	if emailAddress == "" {
+		return renderForgotPasswordPage(c, view, "Email is required", false)
+	}
+	if _, err := mail.ParseAddress(emailAddress); err != nil {
+		return renderForgotPasswordPage(c, view, "Invalid email address", false)
+	}
+
+	// Requests are limited per address and per IP before the account is
+	// looked up, so the limit applies the same to unregistered addresses
+	antispamSvc := c.Locals("antispamService").(*antispam.Service)
+	ipAddress := net.ParseIP(c.IP())
+	check, err := antispamSvc.CheckPasswordReset(c.Context(), emailAddress, ipAddress)
+	if err != nil {
+		log.Printf("[ERROR] ForgotPasswordHandler: antispam check: %v", err)
+		return renderForgotPasswordPage(c.Status(fiber.StatusInternalServerError), view, "Unable to send a reset link right now. Please try again later.", false)
+	}
+	if !check.Allowed {
+		log.Printf("[DEBUG] ForgotPasswordHandler: reset request from %s refused: %s", c.IP(), check.Reason)
+		if check.RetryAfter != nil {
+			c.Set("Retry-After", strconv.Itoa(int(check.RetryAfter.Seconds())))
+		}
+		return renderForgotPasswordPage(c.Status(fiber.StatusTooManyRequests), view, "Too many password reset requests. Please try again later.", false)
+	}
+	if err := antispamSvc.RecordPasswordReset(c.Context(), emailAddress, ipAddress, c.Get("User-Agent")); err != nil {
+		log.Printf("[ERROR] ForgotPasswordHandler: recording reset request: %v", err)
+	}
+
+	// Look up the account and send the email in the background so the
+	// response time does not reveal whether the address is registered
+	go sendPasswordResetLink(repo, emailAddress)
+
+	return renderForgotPasswordPage(c, view, "", true)
+}
+
+// sendPasswordResetLink creates a reset token for an email account and emails the link
+func sendPasswordResetLink(repo *database.Repository, emailAddress string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	user, err := repo.GetUserByEmail(ctx, emailAddress)
+	if err != nil {
+		log.Printf("[DEBUG] sendPasswordResetLink: no account for reset request: %v", err)
+		return
+	}
+	if !user.CanLogin() {
+		log.Printf("[DEBUG] sendPasswordResetLink: user %s cannot reset a password", user.ID)
+		return
+	}
+	// A password cleared by a reverted email change can be set again as long
+	// as the address is still the account's email login
+	if user.PasswordHash == nil {
+		owner, err := repo.GetUserByAuth(ctx, models.AuthTypeEmail, emailAddress)
+		if err != nil || owner.ID != user.ID {
+			log.Printf("[DEBUG] sendPasswordResetLink: user %s has no email login", user.ID)
+			return
+		}
+	}
+
+	token, err := generateSecureToken()
+	if err != nil {
+		log.Printf("[ERROR] sendPasswordResetLink: error generating token: %v", err)
+		return
+	}
+
+	// Only the digest is stored; the plaintext token travels in the email
+	resetToken := models.NewPasswordResetToken(user.ID, auth.HashToken(token), time.Now().Add(passwordResetTokenTTL))
+	if err := repo.CreatePasswordResetToken(ctx, resetToken); err != nil {
+		log.Printf("[ERROR] sendPasswordResetLink: error saving token: %v", err)
+		return
+	}
+
+	siteURL := os.Getenv("SITE_URL")
+	if siteURL == "" {
+		siteURL = "http://localhost:8090" // fallback for development
+	}
+	resetURL := fmt.Sprintf("%s/auth/reset-password?token=%s", siteURL, token)
+
+	emailService := email.NewService(email.NewConfigFromEnv())
+	if err := emailService.SendPasswordResetEmail(emailAddress, user.GetDisplayName(), resetURL); err != nil {
+		log.Printf("[ERROR] sendPasswordResetLink: error sending email: %v", err)
+		return
+	}
+
+	log.Printf("Password reset link sent for user %s", user.ID)
+}
+
+// ResetPasswordPageHandler renders the reset password form for a token
+func ResetPasswordPageHandler(c *fiber.Ctx) error {
+	view := c.Locals("view").(*jet.Set)
+	repo := c.Locals("repo").(*database.Repository)
+
+	token := c.Query("token")
+	if token == "" {
+		return renderResetPasswordPage(c, view, "", "Invalid or expired password reset link.", false)
+	}
+
+	resetToken, err := repo.GetPasswordResetToken(c.Context(), auth.HashToken(token))
+	if err != nil || resetToken.Used || resetToken.IsExpired() {
+		return renderResetPasswordPage(c, view, "", "Invalid or expired password reset link.", false)
+	}
+
+	return renderResetPasswordPage(c, view, token, "", false)
+}
+
+// ResetPasswordHandler sets a new password from a reset token and signs the
+// user out of all existing sessions
+func ResetPasswordHandler(c *fiber.Ctx) error {
+	view := c.Locals("view").(*jet.Set)
+	repo := c.Locals("repo").(*database.Repository)
+	passwordService := newPasswordService(c)
+
+	token := c.FormValue("token")
+	password := c.FormValue("password")
+	confirmPassword := c.FormValue("confirm_password")
+
+	if token == "" {
+		return renderResetPasswordPage(c, view, "", "Invalid or expired password reset link.", false)
+	}
+	if password != confirmPassword {
+		return renderResetPasswordPage(c, view, token, "Passwords do not match", false)
+	}
+	if err := passwordService.ValidatePasswordStrength(password); err != nil {
+		return renderResetPasswordPage(c, view, token, err.Error(), false)
+	}
+
+	passwordHash, err := passwordService.HashPassword(password)
+	if err != nil {
+		return renderResetPasswordPage(c, view, token, "Password reset failed. Please try again.", false)
+	}
+
+	userID, err := repo.ResetPassword(c.Context(), auth.HashToken(token), passwordHash)
+	if err != nil {
+		if err == database.ErrNotFound {
+			return renderResetPasswordPage(c, view, "", "Invalid or expired password reset link.", false)
+		}
+		log.Printf("[ERROR] ResetPasswordHandler: %v", err)
+		return renderResetPasswordPage(c, view, token, "Password reset failed. Please try again.", false)
+	}
+
+	// The current browser may hold one of the sessions that were just removed
+	c.ClearCookie("session_token")
+
+	log.Printf("Password reset completed for user %s; all sessions and API tokens revoked", userID)
+
+	return renderResetPasswordPage(c, view, "", "", true)
+}
+
+// renderForgotPasswordPage renders the forgot password page
+func renderForgotPasswordPage(c *fiber.Ctx, view *jet.Set, errorMsg string, sent bool) error {
+	tmpl, err := view.GetTemplate("pages/forgot-password.jet")
+	if err != nil {
+		return c.Status(500).SendString("Internal Server Error")
+	}
+
+	vars := make(jet.VarMap)
+	if errorMsg != "" {
+		vars.Set("Error", errorMsg)
+	}
+	if sent {
+		vars.Set("Sent", true)
+	}
+	vars.Set("CsrfToken", c.Locals("csrf"))
+
+	// Set current language from i18n middleware
+	if currentLang := c.Locals("currentLang"); currentLang != nil {
+		vars.Set("currentLang", currentLang)
+	} else {
+		vars.Set("currentLang", "en") // fallback to English
+	}
+	vars.Set("ShowUserMenu", false)
+
+	// Add footer configuration
+	addFooterConfig(c, vars)
+
+	return renderTemplate(c, tmpl, vars)
+}
+
+// renderResetPasswordPage renders the reset password page
+func renderResetPasswordPage(c *fiber.Ctx, view *jet.Set, token, errorMsg string, success bool) error {
+	tmpl, err := view.GetTemplate("pages/reset-password.jet")
+	if err != nil {
+		return c.Status(500).SendString("Internal Server Error")
+	}
+
+	vars := make(jet.VarMap)
+	if token != "" {
+		vars.Set("Token", token)
+	}
+	if errorMsg != "" {
+		vars.Set("Error", errorMsg)
+	}
+	if success {
+		vars.Set("Success", true)
+	}
+	vars.Set("CsrfToken", c.Locals("csrf"))
+
+	// Set current language from i18n middleware
+	if currentLang := c.Locals("currentLang"); currentLang != nil {
+		vars.Set("currentLang", currentLang)
+	} else {
+		vars.Set("currentLang", "en") // fallback to English
+	}
+	vars.Set("ShowUserMenu", false)
+
+	// Add footer configuration
+	addFooterConfig(c, vars)
+
+	return renderTemplate(c, tmpl, vars)
+}
@@ -29,17 +29,15 @@ func PitchFormHandler(c *fiber.Ctx) error {
 		return err
 	}
 
-	// Get category from query parameter, default to bitcoin if not provided
-	category := c.Query("category", "bitcoin")
-	var mainCategory models.MainCategory
-	switch category {
-	case "lightning":
-		mainCategory = models.MainCategoryLightning
-	case "cashu":
-		mainCategory = models.MainCategoryCashu
-	default:
-		mainCategory = models.MainCategoryBitcoin
+	// Get category from query parameter, default to the first active category
+	category := c.Query("category")
+	if configService.Category(c.Context(), category) == nil {
+		category = ""
+		if categories := configService.Categories(c.Context()); len(categories) > 0 {
+			category = categories[0].Slug
+		}
 	}
+	mainCategory := models.MainCategory(category)
 
 	// Get current pitch limits
 	limits := configService.PitchLimits(c.Context())
@@ -141,7 +139,10 @@ func PitchAddHandler(c *fiber.Ctx) error {
 
 	// ANTISPAM CHECK: Check if user can create a pitch
 	if err := middleware.CheckPitchCreationLimit(c, input.Content); err != nil {
-		return err // Error response already sent by middleware
+		if err == middleware.ErrAntiSpamResponded {
+			return nil // Error response already sent by middleware
+		}
+		return err
 	}
 
 	input.Language = c.FormValue("language")
@@ -155,6 +156,7 @@ func PitchAddHandler(c *fiber.Ctx) error {
 	if authorHandle := c.FormValue("author_handle"); authorHandle != "" {
 		input.AuthorHandle = &authorHandle
 	}
+	input.AuthorNIP05 = parseAuthorNIP05(c)
 
 	// Handle tags: split comma-separated string into array
 	if tagsStr := c.FormValue("tags"); tagsStr != "" {
@@ -196,6 +198,7 @@ func PitchAddHandler(c *fiber.Ctx) error {
 	if input.AuthorName != nil || input.AuthorHandle != nil {
 		pitch.SetAuthor(input.AuthorType, input.AuthorName, input.AuthorHandle)
 	}
+	pitch.AuthorNIP05 = input.AuthorNIP05
 
 	// Add tags if provided
 	if len(input.Tags) > 0 {
@@ -218,6 +221,10 @@ func PitchAddHandler(c *fiber.Ctx) error {
 	// Record content hash for future duplicate detection
 	middleware.RecordContentHash(c, user.ID, input.Content, pitch.ID)
 
+	if pitch.AuthorNIP05 != nil {
+		verifyPitchAuthorNIP05(c, pitch.ID)
+	}
+
 	// Check if this is an HTMX request
 	if c.Get("HX-Request") == "true" {
 		// Determine main category from input
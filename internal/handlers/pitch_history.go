@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"strconv"
+
+	"bitcoinpitch.org/internal/config"
+	"bitcoinpitch.org/internal/database"
+	"bitcoinpitch.org/internal/middleware"
+	"bitcoinpitch.org/internal/models"
+
+	"github.com/CloudyKit/jet/v6"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// loadPitchHistory returns a pitch and its revisions, oldest first, each with
+// the changes from the revision before it
+func loadPitchHistory(c *fiber.Ctx, repo *database.Repository, pitchID uuid.UUID) (*models.Pitch, []*models.PitchRevision, error) {
+	pitch, err := repo.GetPitch(c.Context(), pitchID)
+	if err == sql.ErrNoRows {
+		return nil, nil, database.ErrNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	revisions, err := repo.GetPitchRevisions(c.Context(), pitch.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	models.SetPitchRevisionDiffs(revisions)
+	return pitch, revisions, nil
+}
+
+// classifyPitchEdit returns whether an edit keeps a pitch's votes. The new
+// text is compared with the revision the votes were cast for, so a series of
+// minor edits cannot add up to a different pitch.
+func classifyPitchEdit(c *fiber.Ctx, repo *database.Repository, pitch *models.Pitch, content string, mainCategory models.MainCategory, authorType models.AuthorType, authorHandle *string) (string, error) {
+	if pitch.MovesVotes(mainCategory, authorType, authorHandle) {
+		return models.EditKindMajor, nil
+	}
+
+	voted := pitch.Content
+	revision, err := repo.GetPitchVotedRevision(c.Context(), pitch.ID)
+	switch {
+	case err == nil:
+		voted = revision.Content
+	case err != database.ErrNotFound:
+		return "", err
+	}
+
+	configService := c.Locals("configService").(*config.Service)
+	return configService.MinorEditLimits(c.Context()).ClassifyEdit(voted, content), nil
+}
+
+// canRestoreRevision reports whether a user may restore earlier revisions of
+// a pitch: its owner and admins can
+func canRestoreRevision(user *models.User, pitch *models.Pitch) bool {
+	return user != nil && (pitch.UserID == user.ID || user.IsAdmin())
+}
+
+// PitchHistoryHandler shows every revision of a pitch with what each edit changed
+func PitchHistoryHandler(c *fiber.Ctx) error {
+	view := c.Locals("view").(*jet.Set)
+	repo := c.Locals("repo").(*database.Repository)
+
+	pitchID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return NotFoundHandler(c)
+	}
+	pitch, revisions, err := loadPitchHistory(c, repo, pitchID)
+	if err != nil {
+		if err != database.ErrNotFound {
+			log.Printf("[ERROR] PitchHistoryHandler: %v", err)
+		}
+		return NotFoundHandler(c)
+	}
+
+	// Newest first
+	for i, j := 0, len(revisions)-1; i < j; i, j = i+1, j-1 {
+		revisions[i], revisions[j] = revisions[j], revisions[i]
+	}
+
+	vars := make(jet.VarMap)
+	vars.Set("Title", "Pitch History")
+	vars.Set("Pitch", pitch)
+	vars.Set("Revisions", revisions)
+	vars.Set("CanRestore", false)
+
+	if user, ok := c.Locals("user").(*models.User); ok && user != nil {
+		vars.Set("User", user)
+		vars.Set("UserDisplayName", user.GetDisplayName())
+		vars.Set("ShowUserMenu", true)
+		vars.Set("CanRestore", canRestoreRevision(user, pitch))
+	}
+	if currentLang := c.Locals("currentLang"); currentLang != nil {
+		vars.Set("currentLang", currentLang)
+	} else {
+		vars.Set("currentLang", "en")
+	}
+	if csrfToken := c.Locals("csrf"); csrfToken != nil {
+		vars.Set("CsrfToken", csrfToken)
+	}
+
+	addFooterConfig(c, vars)
+
+	t, err := view.GetTemplate("pages/pitch-history.jet")
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("Template error: " + err.Error())
+	}
+	return renderTemplate(c, t, vars)
+}
+
+// APIPitchHistoryHandler returns every revision of a pitch, oldest first,
+// with word-level diffs from the revision before
+func APIPitchHistoryHandler(c *fiber.Ctx) error {
+	repo := c.Locals("repo").(*database.Repository)
+
+	pitchID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid pitch ID",
+		})
+	}
+
+	pitch, revisions, err := loadPitchHistory(c, repo, pitchID)
+	if err != nil {
+		if err == database.ErrNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Pitch not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch pitch history: " + err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"pitch_id":  pitch.ID,
+		"revisions": revisions,
+	})
+}
+
+// PitchRestoreRevisionHandler makes an earlier revision the pitch's content
+// again. The restored text is saved as a new revision, so nothing is lost.
+func PitchRestoreRevisionHandler(c *fiber.Ctx) error {
+	repo := c.Locals("repo").(*database.Repository)
+	configService := c.Locals("configService").(*config.Service)
+
+	user, ok := c.Locals("user").(*models.User)
+	if !ok || user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+
+	pitchID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid pitch ID",
+		})
+	}
+	number, err := strconv.Atoi(c.Params("revision"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid revision",
+		})
+	}
+
+	pitch, err := repo.GetPitch(c.Context(), pitchID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Pitch not found",
+		})
+	}
+	if !canRestoreRevision(user, pitch) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Not authorized to edit this pitch",
+		})
+	}
+
+	revision, err := repo.GetPitchRevision(c.Context(), pitch.ID, number)
+	if err != nil {
+		if err == database.ErrNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Revision not found",
+			})
+		}
+		log.Printf("[ERROR] PitchRestoreRevisionHandler: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch revision",
+		})
+	}
+	if revision.IsCurrent() {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "This revision is already the current text",
+		})
+	}
+
+	// Limits may have changed since the revision was written
+	lengthCategory := CalculateLengthCategory(revision.Content, configService)
+	if lengthCategory == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "This revision is longer than pitches may currently be",
+		})
+	}
+
+	// Admins restoring someone else's pitch are not rate limited as its editor
+	if pitch.UserID == user.ID {
+		if err := middleware.CheckPitchEditLimit(c, user.ID, pitch.ID, revision.Content); err != nil {
+			if err == middleware.ErrAntiSpamResponded {
+				return nil // Error response already sent by middleware
+			}
+			return err
+		}
+	}
+
+	editKind, err := classifyPitchEdit(c, repo, pitch, revision.Content, pitch.MainCategory, pitch.AuthorType, pitch.AuthorHandle)
+	if err != nil {
+		log.Printf("[ERROR] PitchRestoreRevisionHandler: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to restore revision",
+		})
+	}
+	pitch.LengthCategory = lengthCategory
+	pitch.Edit(revision.Content, editKind)
+	pitch.Tags = nil // tags are not part of a revision, leave them as they are
+	if err := repo.EditPitch(c.Context(), pitch, user.ID, &revision.RevisionNumber); err != nil {
+		log.Printf("[ERROR] PitchRestoreRevisionHandler: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to restore revision",
+		})
+	}
+
+	log.Printf("User %s restored revision %d of pitch %s", user.ID, revision.RevisionNumber, pitch.ID)
+	return sessionsRedirect(c, "/pitch/"+pitch.ID.String()+"/history")
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"time"
+
+	"bitcoinpitch.org/internal/ranking"
+
+	"github.com/CloudyKit/jet/v6"
+	"github.com/gofiber/fiber/v2"
+)
+
+// applySort adds the ranking chosen with ?sort= to listing filters, limited to
+// the ?period= window for top. defaultMode is used when no known mode is asked
+// for; an empty defaultMode keeps the listing's own order. It returns the mode
+// and period in use.
+func applySort(c *fiber.Ctx, defaultMode string, filters map[string]interface{}) (string, string) {
+	mode := c.Query("sort")
+	if !ranking.IsValid(mode) {
+		mode = defaultMode
+	}
+	period := c.Query("period", ranking.PeriodAll)
+	if !ranking.IsValidPeriod(period) {
+		period = ranking.PeriodAll
+	}
+
+	if mode == "" {
+		return mode, period
+	}
+	filters["sort"] = mode
+	if mode == ranking.Top && period != ranking.PeriodAll {
+		filters["since"] = ranking.Since(period, time.Now())
+	}
+	return mode, period
+}
+
+// setSortVars passes the ranking in use and the choices to a template
+func setSortVars(vars jet.VarMap, mode, period string) {
+	vars.Set("Sort", mode)
+	vars.Set("SortPeriod", period)
+	vars.Set("SortModes", ranking.Modes())
+	vars.Set("SortPeriods", ranking.Periods())
+}
+
+// parseCursor reads the ?cursor= position in a listing ranked by mode. A
+// cursor from another ranking or made for other filters is ignored, as the
+// listing starts over.
+func parseCursor(c *fiber.Ctx, mode string) (*ranking.Cursor, error) {
+	token := c.Query("cursor")
+	if token == "" {
+		return nil, nil
+	}
+	cursor, err := ranking.DecodeCursor(token)
+	if err != nil {
+		return nil, err
+	}
+	if cursor.Sort != mode || cursor.Listing != listingID(c) {
+		return nil, nil
+	}
+	return cursor, nil
+}
+
+// listingID identifies the listing a request asks for: its path and query
+// without the position in it. Cursors carry it so they are only followed in
+// the listing they came from.
+func listingID(c *fiber.Ctx) string {
+	query, _ := url.ParseQuery(string(c.Request().URI().QueryString()))
+	for _, key := range []string{"cursor", "page", "limit", "offset"} {
+		query.Del(key)
+	}
+	sum := sha256.Sum256([]byte(c.Path() + "?" + query.Encode()))
+	return base64.RawURLEncoding.EncodeToString(sum[:9])
+}
+
+// cursorToken encodes a cursor for the current listing, or returns "" for
+// no cursor
+func cursorToken(c *fiber.Ctx, cursor *ranking.Cursor) string {
+	if cursor == nil {
+		return ""
+	}
+	cursor.Listing = listingID(c)
+	return cursor.Encode()
+}
+
+// cursorURL returns the current listing URL moved to a cursor, keeping its
+// filters and sort, or "" for no cursor
+func cursorURL(c *fiber.Ctx, cursor *ranking.Cursor) string {
+	if cursor == nil {
+		return ""
+	}
+	query, _ := url.ParseQuery(string(c.Request().URI().QueryString()))
+	query.Del("page")
+	query.Set("cursor", cursorToken(c, cursor))
+	return c.Path() + "?" + query.Encode()
+}
+
+// cursorOrNil returns a cursor for a JSON response, with null for no page
+func cursorOrNil(c *fiber.Ctx, cursor *ranking.Cursor) interface{} {
+	if cursor == nil {
+		return nil
+	}
+	return cursorToken(c, cursor)
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"io"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"bitcoinpitch.org/internal/ranking"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// newCursorTestApp serves a listing at /list. Requests with an X-Issue header
+// get the URL of a cursor into the listing; others report whether they
+// continue from their cursor or start over.
+func newCursorTestApp() *fiber.App {
+	app := fiber.New()
+	app.Get("/list", func(c *fiber.Ctx) error {
+		if c.Get("X-Issue") != "" {
+			return c.SendString(cursorURL(c, &ranking.Cursor{Sort: ranking.New, CreatedAt: time.Unix(1700000000, 0), ID: uuid.New()}))
+		}
+		cursor, err := parseCursor(c, ranking.New)
+		switch {
+		case err != nil:
+			return c.SendString("invalid")
+		case cursor == nil:
+			return c.SendString("start")
+		}
+		return c.SendString("continue")
+	})
+	return app
+}
+
+func getBody(t *testing.T, app *fiber.App, target string, issue bool) string {
+	t.Helper()
+	req := httptest.NewRequest("GET", target, nil)
+	if issue {
+		req.Header.Set("X-Issue", "1")
+	}
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("request %s: %v", target, err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return string(body)
+}
+
+func TestParseCursorListing(t *testing.T) {
+	app := newCursorTestApp()
+
+	next, err := url.Parse(getBody(t, app, "/list?language=en&tag=privacy", true))
+	if err != nil {
+		t.Fatalf("cursorURL: %v", err)
+	}
+	token := url.QueryEscape(next.Query().Get("cursor"))
+	if token == "" {
+		t.Fatalf("cursorURL %s has no cursor", next)
+	}
+
+	tests := []struct {
+		name   string
+		target string
+		want   string
+	}{
+		{"next page", next.String(), "continue"},
+		{"same filters in another order", "/list?tag=privacy&language=en&cursor=" + token, "continue"},
+		{"another page size", "/list?language=en&tag=privacy&limit=50&cursor=" + token, "continue"},
+		{"filter added", "/list?language=en&tag=privacy&length=tweet&cursor=" + token, "start"},
+		{"filter changed", "/list?language=cs&tag=privacy&cursor=" + token, "start"},
+		{"filter removed", "/list?language=en&cursor=" + token, "start"},
+		{"malformed cursor", "/list?cursor=not-a-cursor", "invalid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getBody(t, app, tt.target, false); got != tt.want {
+				t.Errorf("GET %s = %s, want %s", tt.target, got, tt.want)
+			}
+		})
+	}
+}
@@ -333,6 +333,7 @@ func RegisterPageHandler(c *fiber.Ctx) error {
 		vars.Set("currentLang", "en") // fallback to English
 	}
 	vars.Set("ShowUserMenu", false) // Not authenticated on register page
+	setRegistrationVars(c, vars)
 
 	// Add i18n translation function
 	if t, ok := c.Locals("t").(func(string, ...interface{}) string); ok {
@@ -367,12 +368,26 @@ func VerifyEmailHandler(c *fiber.Ctx) error {
 		return renderVerifyEmailPage(c, view, "verify.error_expired_token", "")
 	}
 
+	// Links sent when changing the address only show a confirmation form, so
+	// a mail scanner opening them cannot change the account
+	if verificationToken.Purpose != models.EmailTokenPurposeVerify {
+		if verificationToken.Used {
+			return renderVerifyEmailPage(c, view, "verify.error_invalid_token", "")
+		}
+		return renderEmailChangeConfirmPage(c, view, verificationToken)
+	}
+
 	// Get user
 	user, err := repo.GetUserByID(c.Context(), verificationToken.UserID)
 	if err != nil {
 		return renderVerifyEmailPage(c, view, "verify.error_user_not_found", "")
 	}
 
+	// The address may have changed or been removed since the link was sent
+	if user.Email == nil || *user.Email != verificationToken.Email {
+		return renderVerifyEmailPage(c, view, "verify.error_invalid_token", "")
+	}
+
 	// Verify email
 	user.EmailVerified = true
 	user.UpdatedAt = time.Now()
@@ -381,6 +396,13 @@ func VerifyEmailHandler(c *fiber.Ctx) error {
 		return renderVerifyEmailPage(c, view, "verify.error_verification_failed", "")
 	}
 
+	// An email added from the profile becomes a linked login method once verified;
+	// registered email users already have it as their primary identity
+	identity := models.NewUserIdentity(user.ID, models.AuthTypeEmail, verificationToken.Email)
+	if err := repo.CreateUserIdentity(c.Context(), identity); err != nil && err != database.ErrIdentityTaken {
+		log.Printf("Error linking email identity: %v", err)
+	}
+
 	// Delete verification token
 	if err := repo.DeleteEmailVerificationToken(c.Context(), token); err != nil {
 		log.Printf("Error deleting verification token: %v", err)
@@ -402,8 +424,17 @@ func renderVerifyEmailPage(c *fiber.Ctx, view *jet.Set, errorKey, success string
 		vars.Set("Error", errorKey) // TODO: translate error message
 	}
 	if success != "" {
-		vars.Set("Success", true)
+		vars.Set("Success", success) // "success", "changed" or "reverted"
+	}
+	if user, ok := c.Locals("user").(*models.User); ok && user != nil && success != "reverted" {
+		vars.Set("SignedIn", true)
 	}
+	return renderVerifyEmailTemplate(c, tmpl, vars)
+}
+
+// renderVerifyEmailTemplate renders the verify email page with the variables
+// every state of it needs
+func renderVerifyEmailTemplate(c *fiber.Ctx, tmpl *jet.Template, vars jet.VarMap) error {
 	vars.Set("CsrfToken", c.Locals("csrf"))
 
 	// Set current language from i18n middleware
@@ -479,7 +510,7 @@ func RegisterHandler(c *fiber.Ctx) error {
 	// Create services
 	emailConfig := email.NewConfigFromEnv()
 	emailService := email.NewService(emailConfig)
-	passwordService := auth.NewPasswordService()
+	passwordService := newPasswordService(c)
 
 	// Create a repository wrapper that implements UserRepository interface
 	repoWrapper := &userRepositoryWrapper{repo: repo}
@@ -490,6 +521,11 @@ func RegisterHandler(c *fiber.Ctx) error {
 	password := c.FormValue("password")
 	confirmPassword := c.FormValue("confirm_password")
 
+	// Don't make people fill in the form for nothing
+	if registrationMode(c) == models.RegistrationModeClosed {
+		return renderRegisterPage(c, view, capitalize(errRegistrationClosed.Error())+".", email, username)
+	}
+
 	// Basic validation
 	if email == "" || password == "" {
 		return renderRegisterPage(c, view, "Email and password are required", email, username)
@@ -505,8 +541,8 @@ func RegisterHandler(c *fiber.Ctx) error {
 	}
 
 	// Validate password strength
-	if len(password) < 8 {
-		return renderRegisterPage(c, view, "Password must be at least 8 characters long", email, username)
+	if err := passwordService.ValidatePasswordStrength(password); err != nil {
+		return renderRegisterPage(c, view, capitalize(err.Error()), email, username)
 	}
 
 	// Create new user
@@ -532,7 +568,10 @@ func RegisterHandler(c *fiber.Ctx) error {
 	expiresAt := time.Now().Add(24 * time.Hour)
 
 	// Save user
-	if err := repoWrapper.CreateUser(user); err != nil {
+	if err := registerUser(c, repo, user); err != nil {
+		if isRegistrationRefused(err) {
+			return renderRegisterPage(c, view, capitalize(err.Error())+".", email, username)
+		}
 		return renderRegisterPage(c, view, "Email address is already taken", "", username)
 	}
 
@@ -590,6 +629,7 @@ func renderRegisterPage(c *fiber.Ctx, view *jet.Set, errorMsg, email, username s
 		vars.Set("currentLang", "en") // fallback to English
 	}
 	vars.Set("ShowUserMenu", false) // Not authenticated on register page
+	setRegistrationVars(c, vars)
 
 	// Add i18n translation function
 	if t, ok := c.Locals("t").(func(string, ...interface{}) string); ok {
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"log"
+
+	"bitcoinpitch.org/internal/database"
+	"bitcoinpitch.org/internal/models"
+
+	"github.com/CloudyKit/jet/v6"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// UserSessionsHandler renders the list of the user's active sessions
+func UserSessionsHandler(c *fiber.Ctx) error {
+	view := c.Locals("view").(*jet.Set)
+	repo := c.Locals("repo").(*database.Repository)
+
+	user, ok := c.Locals("user").(*models.User)
+	if !ok || user == nil {
+		return c.Redirect("/auth/login")
+	}
+
+	sessions, err := repo.GetSessionsByUser(c.Context(), user.ID)
+	if err != nil {
+		log.Printf("[DEBUG] UserSessionsHandler: failed to list sessions: %v", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("Failed to load sessions")
+	}
+
+	tmpl, err := view.GetTemplate("pages/user-sessions.jet")
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("Template error: " + err.Error())
+	}
+
+	vars := make(jet.VarMap)
+	vars.Set("Title", "Active Sessions")
+	vars.Set("User", user)
+	vars.Set("UserDisplayName", user.GetDisplayName())
+	vars.Set("ShowUserMenu", true)
+	vars.Set("Sessions", sessions)
+	if current, ok := c.Locals("session").(*models.Session); ok {
+		vars.Set("CurrentSessionID", current.ID.String())
+	} else {
+		vars.Set("CurrentSessionID", "")
+	}
+	if revoked := c.Query("revoked"); revoked != "" {
+		vars.Set("Revoked", revoked)
+	}
+
+	// Set current language from i18n middleware
+	if currentLang := c.Locals("currentLang"); currentLang != nil {
+		vars.Set("currentLang", currentLang)
+	} else {
+		vars.Set("currentLang", "en") // fallback to English
+	}
+
+	if csrfToken := c.Locals("csrf"); csrfToken != nil {
+		vars.Set("CsrfToken", csrfToken)
+	}
+
+	// Add footer configuration
+	addFooterConfig(c, vars)
+
+	return renderTemplate(c, tmpl, vars)
+}
+
+// UserSessionRevokeHandler signs out a single session of the current user
+func UserSessionRevokeHandler(c *fiber.Ctx) error {
+	repo := c.Locals("repo").(*database.Repository)
+
+	user, ok := c.Locals("user").(*models.User)
+	if !ok || user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+
+	sessionID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid session ID",
+		})
+	}
+
+	if err := repo.DeleteUserSession(c.Context(), user.ID, sessionID); err != nil {
+		if err == database.ErrNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Session not found",
+			})
+		}
+		log.Printf("[DEBUG] UserSessionRevokeHandler: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to revoke session",
+		})
+	}
+
+	// Revoking the session in use is the same as logging out
+	if current, ok := c.Locals("session").(*models.Session); ok && current.ID == sessionID {
+		c.ClearCookie("session_token")
+		return sessionsRedirect(c, "/")
+	}
+
+	return sessionsRedirect(c, "/user/sessions?revoked=1")
+}
+
+// UserSessionsRevokeOthersHandler signs out every session except the current one
+func UserSessionsRevokeOthersHandler(c *fiber.Ctx) error {
+	repo := c.Locals("repo").(*database.Repository)
+
+	user, ok := c.Locals("user").(*models.User)
+	if !ok || user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+
+	current, ok := c.Locals("session").(*models.Session)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+
+	count, err := repo.DeleteOtherSessions(c.Context(), user.ID, current.ID)
+	if err != nil {
+		log.Printf("[DEBUG] UserSessionsRevokeOthersHandler: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to revoke sessions",
+		})
+	}
+
+	log.Printf("User %s revoked %d other sessions", user.ID, count)
+	return sessionsRedirect(c, "/user/sessions?revoked=all")
+}
+
+// sessionsRedirect redirects after a session change, using HX-Redirect for HTMX requests
+func sessionsRedirect(c *fiber.Ctx, location string) error {
+	if c.Get("HX-Request") == "true" {
+		c.Set("HX-Redirect", location)
+		return c.SendStatus(fiber.StatusOK)
+	}
+	return c.Redirect(location)
+}
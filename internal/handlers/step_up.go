@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"log"
+	"strings"
+
+	"bitcoinpitch.org/internal/auth"
+	"bitcoinpitch.org/internal/crypto"
+	"bitcoinpitch.org/internal/database"
+	"bitcoinpitch.org/internal/middleware"
+	"bitcoinpitch.org/internal/models"
+
+	"github.com/CloudyKit/jet/v6"
+	"github.com/gofiber/fiber/v2"
+)
+
+// AuthStepUpPageHandler renders the page that upgrades a read-only session
+func AuthStepUpPageHandler(c *fiber.Ctx) error {
+	view := c.Locals("view").(*jet.Set)
+
+	tmpl, err := view.GetTemplate("pages/step-up.jet")
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("Template error: " + err.Error())
+	}
+
+	vars := make(jet.VarMap)
+	vars.Set("Title", "Confirm Your Nostr Key")
+	vars.Set("ReturnURL", safeReturnURL(c.Query("return")))
+
+	user, ok := c.Locals("user").(*models.User)
+	switch {
+	case !ok || user == nil:
+		vars.Set("Error", "You are not signed in.")
+	case !middleware.IsReadOnlySession(c):
+		vars.Set("Error", "This session already has full access.")
+	default:
+		vars.Set("User", user)
+	}
+
+	vars.Set("CsrfToken", c.Locals("csrf"))
+	vars.Set("ShowUserMenu", false)
+
+	// Set current language from i18n middleware
+	if currentLang := c.Locals("currentLang"); currentLang != nil {
+		vars.Set("currentLang", currentLang)
+	} else {
+		vars.Set("currentLang", "en") // fallback to English
+	}
+
+	// Add footer configuration
+	addFooterConfig(c, vars)
+
+	return renderTemplate(c, tmpl, vars)
+}
+
+// AuthStepUpHandler upgrades a read-only npub session to a full one once the
+// user signs a login challenge with the same Nostr key
+func AuthStepUpHandler(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*models.User)
+	if !ok || user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+
+	session, ok := c.Locals("session").(*models.Session)
+	if !ok || !session.ReadOnly {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "This session already has full access",
+		})
+	}
+
+	var req struct {
+		Event map[string]interface{} `json:"event"`
+	}
+
+	if err := c.BodyParser(&req); err != nil || req.Event == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Missing event",
+		})
+	}
+
+	pubkey, err := crypto.ExtractPubkeyFromEvent(req.Event)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid event pubkey: " + err.Error(),
+		})
+	}
+
+	repo := c.Locals("repo").(*database.Repository)
+
+	// The key must be one of the identities of the account the read-only session was opened for
+	owner, err := repo.GetUserByAuth(c.Context(), models.AuthTypeNostr, pubkey)
+	if err != nil || owner.ID != user.ID {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "The signing key does not match this account",
+		})
+	}
+
+	if err := crypto.VerifyNostrEvent(req.Event); err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid Nostr signature: " + err.Error(),
+		})
+	}
+
+	challengeStore := c.Locals("challengeStore").(*auth.ChallengeStore)
+	if err := verifyNostrChallenge(challengeStore, req.Event); err != nil {
+		log.Printf("[DEBUG] AuthStepUpHandler: challenge rejected for %s: %v", pubkey, err)
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid login challenge: " + err.Error(),
+		})
+	}
+
+	// Replace the read-only session instead of flipping its flag, so the
+	// upgraded session gets a token the read-only holder never saw
+	if err := repo.DeleteSession(c.Context(), session.ID); err != nil {
+		log.Printf("[DEBUG] AuthStepUpHandler: failed to delete read-only session: %v", err)
+	}
+	c.ClearCookie("session_token")
+
+	requires2FA, err := startLogin(c, repo, user, "nostr")
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create session: " + err.Error(),
+		})
+	}
+	if requires2FA {
+		return secondFactorRequired(c)
+	}
+
+	log.Printf("[DEBUG] AuthStepUpHandler: read-only session upgraded for user %s", user.ID)
+
+	return c.JSON(fiber.Map{
+		"message":  "Session upgraded",
+		"user":     user.GetDisplayName(),
+		"redirect": safeReturnURL(c.Query("return")),
+	})
+}
+
+// safeReturnURL only allows local paths as redirect targets
+func safeReturnURL(returnURL string) string {
+	if !strings.HasPrefix(returnURL, "/") || strings.HasPrefix(returnURL, "//") || strings.HasPrefix(returnURL, "/\\") {
+		return "/"
+	}
+	return returnURL
+}
@@ -5,9 +5,12 @@ import (
 	"context"
 	"image/png"
 	"log"
+	"strings"
 
+	"bitcoinpitch.org/internal/antispam"
 	"bitcoinpitch.org/internal/auth"
 	"bitcoinpitch.org/internal/database"
+	"bitcoinpitch.org/internal/middleware"
 	"bitcoinpitch.org/internal/models"
 	"github.com/boombuler/barcode"
 	"github.com/boombuler/barcode/qr"
@@ -45,17 +48,8 @@ func (h *TOTPHandler) GenerateTOTPSecret(c *fiber.Ctx) error {
 
 	log.Printf("[DEBUG] GenerateTOTPSecret: User found: %s (auth type: %s)", user.GetDisplayName(), user.AuthType)
 
-	// Check if user is using email or password authentication
-	if user.AuthType != models.AuthTypeEmail && user.AuthType != models.AuthTypePassword {
-		log.Printf("[DEBUG] GenerateTOTPSecret: Invalid auth type: %s", user.AuthType)
-		return c.Status(400).JSON(fiber.Map{
-			"success": false,
-			"error":   "2FA is only available for email/password accounts",
-		})
-	}
-
-	// Generate TOTP secret
-	email := ""
+	// Generate TOTP secret, labelled with the email or display name
+	email := user.GetDisplayName()
 	if user.Email != nil {
 		email = *user.Email
 	}
@@ -147,29 +141,28 @@ func (h *TOTPHandler) EnableTOTP(c *fiber.Ctx) error {
 		return c.Status(400).SendString("TOTP secret and code are required")
 	}
 
-	// Validate TOTP code
-	if !h.totpSvc.ValidateCode(secret, totpCode) {
+	// Validate TOTP code; the accepted step cannot be reused to log in
+	step, ok := h.totpSvc.ValidateCodeStep(secret, totpCode, 0)
+	if !ok {
 		return c.Status(400).SendString("Invalid TOTP code")
 	}
 
-	// Check if user is using email or password authentication
-	if user.AuthType != models.AuthTypeEmail && user.AuthType != models.AuthTypePassword {
-		return c.Status(400).JSON(fiber.Map{
-			"success": false,
-			"error":   "2FA is only available for email/password accounts",
-		})
-	}
-
-	// Generate backup codes
+	// Generate backup codes; they are shown once from the profile page
 	backupCodes, err := h.totpSvc.GenerateBackupCodes(10)
 	if err != nil {
 		log.Printf("Error generating backup codes: %v", err)
 		return c.Status(500).SendString("Failed to generate backup codes")
 	}
+	hashedCodes, err := h.totpSvc.HashBackupCodes(backupCodes)
+	if err != nil {
+		log.Printf("Error hashing backup codes: %v", err)
+		return c.Status(500).SendString("Failed to generate backup codes")
+	}
 
 	// Enable TOTP for user
 	user.SetTOTPSecret(secret)
-	user.SetTOTPBackupCodes(backupCodes)
+	user.SetTOTPBackupCodes(hashedCodes)
+	user.TOTPLastStep = &step
 	user.EnableTOTP()
 
 	// Update user in database
@@ -203,8 +196,23 @@ func (h *TOTPHandler) DisableTOTP(c *fiber.Ctx) error {
 		return c.Status(400).SendString("2FA is not enabled")
 	}
 
-	// Validate TOTP code
-	if !h.totpSvc.ValidateCode(*user.TOTPSecret, totpCode) {
+	// The code is checked like at login: locked out accounts are refused,
+	// replayed codes rejected and failures counted towards the lockout
+	if blocked, err := checkLoginLimit(c, &user.ID); blocked {
+		return err
+	}
+	if err := h.ValidateTOTPLogin(c.Context(), user.ID, totpCode); err != nil {
+		if err != auth.ErrTOTPInvalid && err != auth.ErrTOTPReplayed && err != auth.ErrBackupCodeUsed {
+			log.Printf("[ERROR] DisableTOTP: %v", err)
+			return c.Status(500).SendString("Failed to disable 2FA")
+		}
+		method := antispam.LoginMethodTOTP
+		if h.totpSvc.IsBackupCode(totpCode) {
+			method = antispam.LoginMethodBackupCode
+		}
+		if locked, err := recordLoginFailure(c, user, method); locked {
+			return err
+		}
 		return c.Status(400).SendString("Invalid TOTP code")
 	}
 
@@ -223,7 +231,8 @@ func (h *TOTPHandler) DisableTOTP(c *fiber.Ctx) error {
 	return c.Redirect("/user/profile?totp_disabled=1")
 }
 
-// GetBackupCodes returns the user's backup codes
+// GetBackupCodes generates a new set of backup codes and returns them once.
+// Only hashes are stored, so previously issued codes stop working.
 func (h *TOTPHandler) GetBackupCodes(c *fiber.Ctx) error {
 	// Get current user from session
 	user, ok := c.Locals("user").(*models.User)
@@ -242,17 +251,47 @@ func (h *TOTPHandler) GetBackupCodes(c *fiber.Ctx) error {
 		})
 	}
 
-	// Return backup codes
+	backupCodes, err := h.totpSvc.GenerateBackupCodes(10)
+	if err != nil {
+		log.Printf("Error generating backup codes: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to generate backup codes",
+		})
+	}
+	hashedCodes, err := h.totpSvc.HashBackupCodes(backupCodes)
+	if err != nil {
+		log.Printf("Error hashing backup codes: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to generate backup codes",
+		})
+	}
+
+	user.SetTOTPBackupCodes(hashedCodes)
+	if err := h.repo.UpdateUser(c.Context(), user); err != nil {
+		log.Printf("Error updating backup codes: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to save backup codes",
+		})
+	}
+
+	log.Printf("TOTP backup codes regenerated for user %s", user.BaseModel.ID)
+
+	// Return the plaintext codes; they cannot be shown again
 	return c.JSON(fiber.Map{
 		"success": true,
-		"codes":   []string(user.TOTPBackupCodes),
+		"codes":   backupCodes,
 	})
 }
 
-// ValidateTOTPLogin validates TOTP code during login
-func (h *TOTPHandler) ValidateTOTPLogin(userID uuid.UUID, code string) error {
+// ValidateTOTPLogin validates a TOTP or backup code during login. TOTP codes
+// are rejected if their time step was already accepted, and backup codes are
+// consumed atomically so each one works only once.
+func (h *TOTPHandler) ValidateTOTPLogin(ctx context.Context, userID uuid.UUID, code string) error {
 	// Get user from database
-	user, err := h.repo.GetUserByID(context.Background(), userID)
+	user, err := h.repo.GetUserByID(ctx, userID)
 	if err != nil {
 		return err
 	}
@@ -263,28 +302,125 @@ func (h *TOTPHandler) ValidateTOTPLogin(userID uuid.UUID, code string) error {
 	}
 
 	// Check if it's a backup code
-	if len(code) > 6 {
-		// Try backup code
-		valid, newCodes := h.totpSvc.ValidateBackupCode([]string(user.TOTPBackupCodes), code)
-		if valid {
-			// Update backup codes (remove used code)
-			user.SetTOTPBackupCodes(newCodes)
-			if err := h.repo.UpdateUser(context.Background(), user); err != nil {
-				log.Printf("Error updating backup codes: %v", err)
-			}
-			return nil
+	if h.totpSvc.IsBackupCode(code) {
+		hash, ok := h.totpSvc.MatchBackupCode([]string(user.TOTPBackupCodes), code)
+		if !ok {
+			return auth.ErrTOTPInvalid
 		}
-		return auth.ErrTOTPInvalid
+		consumed, err := h.repo.ConsumeTOTPBackupCode(ctx, user.ID, hash)
+		if err != nil {
+			return err
+		}
+		if !consumed {
+			return auth.ErrBackupCodeUsed
+		}
+		return nil
 	}
 
-	// Validate TOTP code
-	if !h.totpSvc.ValidateCode(*user.TOTPSecret, code) {
+	// Validate TOTP code against steps newer than the last accepted one
+	step, ok := h.totpSvc.ValidateCodeStep(*user.TOTPSecret, code, user.GetTOTPLastStep())
+	if !ok {
+		if _, valid := h.totpSvc.ValidateCodeStep(*user.TOTPSecret, code, 0); valid {
+			return auth.ErrTOTPReplayed
+		}
 		return auth.ErrTOTPInvalid
 	}
 
+	accepted, err := h.repo.UpdateTOTPLastStep(ctx, user.ID, step)
+	if err != nil {
+		return err
+	}
+	if !accepted {
+		return auth.ErrTOTPReplayed
+	}
+
 	return nil
 }
 
+// CompleteLogin finishes a pending login with a TOTP or backup code (POST /auth/2fa)
+func (h *TOTPHandler) CompleteLogin(c *fiber.Ctx) error {
+	pendingStore := c.Locals("pendingLoginStore").(*auth.PendingLoginStore)
+	isHTMX := c.Get("HX-Request") == "true"
+
+	token := c.Cookies(middleware.PendingLoginCookieName)
+	pending, err := pendingStore.Get(token)
+	if err != nil {
+		middleware.ClearPendingLoginCookie(c)
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	code := strings.TrimSpace(c.FormValue("totp_code"))
+	if code == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":        "Two-factor authentication code is required",
+			"requires_2fa": true,
+		})
+	}
+
+	// Refuse locked out or backing-off accounts and IPs before checking the code
+	if blocked, err := checkLoginLimit(c, &pending.UserID); blocked {
+		return err
+	}
+
+	if err := h.ValidateTOTPLogin(c.Context(), pending.UserID, code); err != nil {
+		log.Printf("[DEBUG] CompleteLogin: second factor rejected for user %s: %v", pending.UserID, err)
+		method := antispam.LoginMethodTOTP
+		if h.totpSvc.IsBackupCode(code) {
+			method = antispam.LoginMethodBackupCode
+		}
+		user, _ := h.repo.GetUserByID(c.Context(), pending.UserID)
+		if locked, err := recordLoginFailure(c, user, method); locked {
+			// Drop the pending login; the user signs in again once the lock expires
+			pendingStore.Complete(token)
+			middleware.ClearPendingLoginCookie(c)
+			return err
+		}
+		remaining := pendingStore.RecordFailure(token)
+		if remaining == 0 {
+			middleware.ClearPendingLoginCookie(c)
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Too many invalid codes, please sign in again",
+			})
+		}
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":              "Invalid 2FA code",
+			"requires_2fa":       true,
+			"attempts_remaining": remaining,
+		})
+	}
+
+	pendingStore.Complete(token)
+	middleware.ClearPendingLoginCookie(c)
+
+	// Create session
+	sessionToken, err := middleware.CreateSession(h.repo, c, pending.UserID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create session: " + err.Error(),
+		})
+	}
+
+	// Set session cookie
+	middleware.SetSessionCookie(c, sessionToken)
+	middleware.RecordLoginSuccess(c, pending.UserID, pending.Method)
+
+	log.Printf("[DEBUG] CompleteLogin: %s login completed for user %s", pending.Method, pending.UserID)
+
+	if isHTMX {
+		c.Set("HX-Redirect", "/")
+		return c.SendString(`<div class="auth-success">Authentication successful! Redirecting...</div>`)
+	}
+	if strings.Contains(c.Get(fiber.HeaderContentType), fiber.MIMEApplicationJSON) {
+		return c.JSON(fiber.Map{
+			"message": "Authentication successful",
+		})
+	}
+
+	return c.Redirect("/")
+}
+
 // CheckTOTPRequired checks if user has 2FA enabled and returns requirements
 func (h *TOTPHandler) CheckTOTPRequired(userID uuid.UUID) (bool, error) {
 	user, err := h.repo.GetUserByID(context.Background(), userID)
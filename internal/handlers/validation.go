@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"fmt"
 
 	"bitcoinpitch.org/internal/config"
@@ -75,11 +76,8 @@ func ValidatePitchInput(input PitchInput, configService *config.Service) error {
 		return fmt.Errorf("language is required")
 	}
 
-	// Validate main category
-	switch input.MainCategory {
-	case models.MainCategoryBitcoin, models.MainCategoryLightning, models.MainCategoryCashu:
-		// Valid categories
-	default:
+	// Validate main category against the active categories
+	if configService.Category(context.Background(), string(input.MainCategory)) == nil {
 		return fmt.Errorf("invalid main category")
 	}
 
@@ -0,0 +1,588 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"bitcoinpitch.org/internal/auth"
+	"bitcoinpitch.org/internal/crypto"
+	"bitcoinpitch.org/internal/database"
+	"bitcoinpitch.org/internal/middleware"
+	"bitcoinpitch.org/internal/models"
+
+	"github.com/CloudyKit/jet/v6"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+const (
+	// maxPasskeyNameLength limits the label a user gives a passkey
+	maxPasskeyNameLength = 100
+	// webAuthnTimeout is how long the browser waits for the authenticator, in milliseconds
+	webAuthnTimeout = 120000
+)
+
+// webAuthnCredentialRequest is a PublicKeyCredential serialized by the browser,
+// with every binary field encoded as base64url
+type webAuthnCredentialRequest struct {
+	Name     string `json:"name"`
+	ID       string `json:"id"`
+	Response struct {
+		ClientDataJSON    string `json:"clientDataJSON"`
+		AttestationObject string `json:"attestationObject"`
+		AuthenticatorData string `json:"authenticatorData"`
+		Signature         string `json:"signature"`
+		UserHandle        string `json:"userHandle"`
+	} `json:"response"`
+}
+
+// UserPasskeysHandler renders the list of the user's passkeys
+func UserPasskeysHandler(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*models.User)
+	if !ok || user == nil {
+		return c.Redirect("/auth/login")
+	}
+
+	vars := make(jet.VarMap)
+	if c.Query("deleted") == "1" {
+		vars.Set("Deleted", true)
+	}
+	if c.Query("added") == "1" {
+		vars.Set("Added", true)
+	}
+
+	return renderPasskeysPage(c, user, vars)
+}
+
+// UserPasskeyOptionsHandler starts a registration ceremony and returns the
+// options for navigator.credentials.create
+func UserPasskeyOptionsHandler(c *fiber.Ctx) error {
+	repo := c.Locals("repo").(*database.Repository)
+
+	user, ok := c.Locals("user").(*models.User)
+	if !ok || user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+
+	rp, err := webAuthnRelyingParty(c)
+	if err != nil {
+		log.Printf("[ERROR] UserPasskeyOptionsHandler: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Passkeys are not available",
+		})
+	}
+
+	challenge, err := issueWebAuthnChallenge(c)
+	if err != nil {
+		log.Printf("[ERROR] UserPasskeyOptionsHandler: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to start passkey registration",
+		})
+	}
+
+	credentials, err := repo.GetWebAuthnCredentialsByUser(c.Context(), user.ID)
+	if err != nil {
+		log.Printf("[ERROR] UserPasskeyOptionsHandler: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to start passkey registration",
+		})
+	}
+
+	params := make([]fiber.Map, 0, len(crypto.WebAuthnAlgorithms))
+	for _, alg := range crypto.WebAuthnAlgorithms {
+		params = append(params, fiber.Map{"type": "public-key", "alg": alg})
+	}
+
+	userName := user.GetDisplayName()
+	if user.Email != nil {
+		userName = *user.Email
+	}
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.JSON(fiber.Map{
+		"publicKey": fiber.Map{
+			"rp": fiber.Map{
+				"id":   rp.ID,
+				"name": rp.Name,
+			},
+			"user": fiber.Map{
+				"id":          crypto.EncodeWebAuthnBase64(user.ID[:]),
+				"name":        userName,
+				"displayName": user.GetDisplayName(),
+			},
+			"challenge":          challenge,
+			"pubKeyCredParams":   params,
+			"timeout":            webAuthnTimeout,
+			"excludeCredentials": webAuthnDescriptors(credentials),
+			"authenticatorSelection": fiber.Map{
+				"residentKey":      "preferred",
+				"userVerification": "preferred",
+			},
+			"attestation": "none",
+		},
+	})
+}
+
+// UserPasskeyCreateHandler completes a registration ceremony and stores the passkey
+func UserPasskeyCreateHandler(c *fiber.Ctx) error {
+	repo := c.Locals("repo").(*database.Repository)
+
+	user, ok := c.Locals("user").(*models.User)
+	if !ok || user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+
+	var req webAuthnCredentialRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" || utf8.RuneCountInString(name) > maxPasskeyNameLength {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Please give the passkey a name of up to 100 characters",
+		})
+	}
+
+	clientDataJSON, err1 := crypto.DecodeWebAuthnBase64(req.Response.ClientDataJSON)
+	attestationObject, err2 := crypto.DecodeWebAuthnBase64(req.Response.AttestationObject)
+	if err1 != nil || err2 != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid credential encoding",
+		})
+	}
+
+	rp, err := webAuthnRelyingParty(c)
+	if err != nil {
+		log.Printf("[ERROR] UserPasskeyCreateHandler: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Passkeys are not available",
+		})
+	}
+
+	credential, err := crypto.VerifyWebAuthnRegistration(rp, clientDataJSON, attestationObject, false)
+	if err != nil {
+		log.Printf("[DEBUG] UserPasskeyCreateHandler: registration rejected for user %s: %v", user.ID, err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid passkey registration: " + err.Error(),
+		})
+	}
+
+	if err := consumeWebAuthnChallenge(c, credential.Challenge); err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid registration challenge: " + err.Error(),
+		})
+	}
+
+	if _, err := repo.GetWebAuthnCredentialByCredentialID(c.Context(), credential.ID); err == nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "This passkey is already registered",
+		})
+	}
+
+	passkey := models.NewWebAuthnCredential(user.ID, name, credential.ID, credential.PublicKey, credential.Algorithm, credential.SignCount)
+	passkey.BackupEligible = credential.BackupEligible
+	if aaguid, err := uuid.FromBytes(credential.AAGUID); err == nil && aaguid != uuid.Nil {
+		aaguidString := aaguid.String()
+		passkey.AAGUID = &aaguidString
+	}
+
+	if err := repo.CreateWebAuthnCredential(c.Context(), passkey); err != nil {
+		log.Printf("[ERROR] UserPasskeyCreateHandler: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to save passkey",
+		})
+	}
+
+	log.Printf("User %s registered passkey %s", user.ID, passkey.ID)
+
+	return c.JSON(fiber.Map{
+		"message":  "Passkey added",
+		"redirect": "/user/passkeys?added=1",
+	})
+}
+
+// UserPasskeyDeleteHandler removes one of the current user's passkeys
+func UserPasskeyDeleteHandler(c *fiber.Ctx) error {
+	repo := c.Locals("repo").(*database.Repository)
+
+	user, ok := c.Locals("user").(*models.User)
+	if !ok || user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+
+	passkeyID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid passkey ID",
+		})
+	}
+
+	if err := repo.DeleteWebAuthnCredential(c.Context(), user.ID, passkeyID); err != nil {
+		if err == database.ErrNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Passkey not found",
+			})
+		}
+		log.Printf("[DEBUG] UserPasskeyDeleteHandler: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to remove passkey",
+		})
+	}
+
+	log.Printf("User %s removed passkey %s", user.ID, passkeyID)
+	return sessionsRedirect(c, "/user/passkeys?deleted=1")
+}
+
+// AuthWebAuthnOptionsHandler starts a passwordless login and returns the options
+// for navigator.credentials.get. No credentials are listed, so the browser
+// offers the passkeys it has for this site.
+func AuthWebAuthnOptionsHandler(c *fiber.Ctx) error {
+	rp, err := webAuthnRelyingParty(c)
+	if err != nil {
+		log.Printf("[ERROR] AuthWebAuthnOptionsHandler: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Passkeys are not available",
+		})
+	}
+
+	challenge, err := issueWebAuthnChallenge(c)
+	if err != nil {
+		log.Printf("[ERROR] AuthWebAuthnOptionsHandler: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to start passkey login",
+		})
+	}
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.JSON(fiber.Map{
+		"publicKey": fiber.Map{
+			"rpId":             rp.ID,
+			"challenge":        challenge,
+			"timeout":          webAuthnTimeout,
+			"userVerification": "required",
+		},
+	})
+}
+
+// AuthWebAuthnLoginHandler completes a passwordless login. A passkey with user
+// verification already combines possession and a PIN or biometric, so it signs
+// the user in without asking for another factor.
+func AuthWebAuthnLoginHandler(c *fiber.Ctx) error {
+	repo := c.Locals("repo").(*database.Repository)
+
+	var req webAuthnCredentialRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	passkey, err := verifyWebAuthnLogin(c, repo, &req, true)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Passkey login failed: " + err.Error(),
+		})
+	}
+
+	user, err := repo.GetUserByID(c.Context(), passkey.UserID)
+	if err != nil || !user.CanLogin() {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "No active account for this passkey",
+		})
+	}
+
+	token, err := middleware.CreateSession(repo, c, user.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create session: " + err.Error(),
+		})
+	}
+	middleware.SetSessionCookie(c, token)
+	middleware.RecordLoginSuccess(c, user.ID, "passkey")
+
+	log.Printf("[DEBUG] AuthWebAuthnLoginHandler: passkey login for user %s", user.ID)
+
+	return c.JSON(fiber.Map{
+		"message": "Authentication successful",
+		"user":    user.GetDisplayName(),
+	})
+}
+
+// AuthWebAuthnSecondFactorOptionsHandler returns the options for using a passkey
+// as the second factor of a pending login, and which second factors it accepts
+func AuthWebAuthnSecondFactorOptionsHandler(c *fiber.Ctx) error {
+	repo := c.Locals("repo").(*database.Repository)
+	pendingStore := c.Locals("pendingLoginStore").(*auth.PendingLoginStore)
+
+	pending, err := pendingStore.Get(c.Cookies(middleware.PendingLoginCookieName))
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	user, err := repo.GetUserByID(c.Context(), pending.UserID)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+
+	credentials, err := repo.GetWebAuthnCredentialsByUser(c.Context(), user.ID)
+	if err != nil {
+		log.Printf("[ERROR] AuthWebAuthnSecondFactorOptionsHandler: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load passkeys",
+		})
+	}
+
+	response := fiber.Map{
+		"totp":     user.TOTPEnabled,
+		"webauthn": len(credentials) > 0,
+	}
+
+	if len(credentials) > 0 {
+		rp, err := webAuthnRelyingParty(c)
+		if err != nil {
+			log.Printf("[ERROR] AuthWebAuthnSecondFactorOptionsHandler: %v", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Passkeys are not available",
+			})
+		}
+
+		challenge, err := issueWebAuthnChallenge(c)
+		if err != nil {
+			log.Printf("[ERROR] AuthWebAuthnSecondFactorOptionsHandler: %v", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to start passkey verification",
+			})
+		}
+
+		response["publicKey"] = fiber.Map{
+			"rpId":             rp.ID,
+			"challenge":        challenge,
+			"timeout":          webAuthnTimeout,
+			"allowCredentials": webAuthnDescriptors(credentials),
+			"userVerification": "preferred",
+		}
+	}
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.JSON(response)
+}
+
+// AuthWebAuthnSecondFactorHandler completes a pending login with a passkey
+// instead of a TOTP code
+func AuthWebAuthnSecondFactorHandler(c *fiber.Ctx) error {
+	repo := c.Locals("repo").(*database.Repository)
+	pendingStore := c.Locals("pendingLoginStore").(*auth.PendingLoginStore)
+
+	token := c.Cookies(middleware.PendingLoginCookieName)
+	pending, err := pendingStore.Get(token)
+	if err != nil {
+		middleware.ClearPendingLoginCookie(c)
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	var req webAuthnCredentialRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	passkey, err := verifyWebAuthnLogin(c, repo, &req, false)
+	if err == nil && passkey.UserID != pending.UserID {
+		err = errors.New("passkey belongs to a different account")
+	}
+	if err != nil {
+		log.Printf("[DEBUG] AuthWebAuthnSecondFactorHandler: passkey rejected for user %s: %v", pending.UserID, err)
+		if remaining := pendingStore.RecordFailure(token); remaining == 0 {
+			middleware.ClearPendingLoginCookie(c)
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Too many failed attempts, please sign in again",
+			})
+		}
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":        "Passkey verification failed: " + err.Error(),
+			"requires_2fa": true,
+		})
+	}
+
+	pendingStore.Complete(token)
+	middleware.ClearPendingLoginCookie(c)
+
+	sessionToken, err := middleware.CreateSession(repo, c, pending.UserID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create session: " + err.Error(),
+		})
+	}
+	middleware.SetSessionCookie(c, sessionToken)
+	middleware.RecordLoginSuccess(c, pending.UserID, pending.Method)
+
+	log.Printf("[DEBUG] AuthWebAuthnSecondFactorHandler: %s login completed with a passkey for user %s", pending.Method, pending.UserID)
+
+	return c.JSON(fiber.Map{
+		"message": "Authentication successful",
+	})
+}
+
+// verifyWebAuthnLogin verifies an authentication ceremony against the stored
+// credential, consumes its challenge and records the new signature counter
+func verifyWebAuthnLogin(c *fiber.Ctx, repo *database.Repository, req *webAuthnCredentialRequest, requireUV bool) (*models.WebAuthnCredential, error) {
+	credentialID, err := crypto.DecodeWebAuthnBase64(req.ID)
+	if err != nil || len(credentialID) == 0 {
+		return nil, errors.New("invalid credential ID")
+	}
+
+	var assertion crypto.WebAuthnAssertion
+	var err1, err2, err3 error
+	assertion.ClientDataJSON, err1 = crypto.DecodeWebAuthnBase64(req.Response.ClientDataJSON)
+	assertion.AuthenticatorData, err2 = crypto.DecodeWebAuthnBase64(req.Response.AuthenticatorData)
+	assertion.Signature, err3 = crypto.DecodeWebAuthnBase64(req.Response.Signature)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return nil, errors.New("invalid credential encoding")
+	}
+
+	passkey, err := repo.GetWebAuthnCredentialByCredentialID(c.Context(), credentialID)
+	if err != nil {
+		return nil, errors.New("unknown passkey")
+	}
+
+	// Discoverable credentials return the user handle set at registration
+	if req.Response.UserHandle != "" {
+		userHandle, err := crypto.DecodeWebAuthnBase64(req.Response.UserHandle)
+		if err != nil || !bytes.Equal(userHandle, passkey.UserID[:]) {
+			return nil, errors.New("user handle does not match the passkey")
+		}
+	}
+
+	rp, err := webAuthnRelyingParty(c)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := crypto.VerifyWebAuthnAssertion(rp, assertion, passkey.PublicKey, requireUV)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := consumeWebAuthnChallenge(c, result.Challenge); err != nil {
+		return nil, fmt.Errorf("invalid login challenge: %v", err)
+	}
+
+	if err := crypto.CheckWebAuthnSignCount(uint32(passkey.SignCount), result.SignCount); err != nil {
+		log.Printf("[ERROR] verifyWebAuthnLogin: passkey %s: %v, possible cloned authenticator", passkey.ID, err)
+		return nil, errors.New("passkey signature counter mismatch")
+	}
+
+	// The update repeats the check so concurrent logins cannot both pass
+	ok, err := repo.UpdateWebAuthnSignCount(c.Context(), passkey.ID, result.SignCount, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		log.Printf("[ERROR] verifyWebAuthnLogin: signature counter of passkey %s went backwards, possible cloned authenticator", passkey.ID)
+		return nil, errors.New("passkey signature counter mismatch")
+	}
+
+	return passkey, nil
+}
+
+// issueWebAuthnChallenge issues a login challenge and returns its nonce as the
+// base64url challenge expected by the WebAuthn API
+func issueWebAuthnChallenge(c *fiber.Ctx) (string, error) {
+	challengeStore := c.Locals("challengeStore").(*auth.ChallengeStore)
+
+	challenge, err := challengeStore.Issue()
+	if err != nil {
+		return "", err
+	}
+
+	nonce, err := hex.DecodeString(challenge.Nonce)
+	if err != nil {
+		return "", err
+	}
+	return crypto.EncodeWebAuthnBase64(nonce), nil
+}
+
+// consumeWebAuthnChallenge checks that the challenge signed by the authenticator
+// was issued by this site and has not been used yet
+func consumeWebAuthnChallenge(c *fiber.Ctx, challenge []byte) error {
+	challengeStore := c.Locals("challengeStore").(*auth.ChallengeStore)
+	return challengeStore.VerifyNonce(challengeStore.Domain(), hex.EncodeToString(challenge))
+}
+
+// webAuthnRelyingParty returns the relying party for the public site URL
+func webAuthnRelyingParty(c *fiber.Ctx) (crypto.WebAuthnRelyingParty, error) {
+	return crypto.NewWebAuthnRelyingParty(siteBaseURL(c), "BitcoinPitch.org")
+}
+
+// webAuthnDescriptors lists credentials in the form navigator.credentials expects
+func webAuthnDescriptors(credentials []*models.WebAuthnCredential) []fiber.Map {
+	descriptors := make([]fiber.Map, 0, len(credentials))
+	for _, credential := range credentials {
+		descriptors = append(descriptors, fiber.Map{
+			"type": "public-key",
+			"id":   crypto.EncodeWebAuthnBase64(credential.CredentialID),
+		})
+	}
+	return descriptors
+}
+
+// renderPasskeysPage renders the passkeys page with the user's current passkeys
+func renderPasskeysPage(c *fiber.Ctx, user *models.User, vars jet.VarMap) error {
+	view := c.Locals("view").(*jet.Set)
+	repo := c.Locals("repo").(*database.Repository)
+
+	credentials, err := repo.GetWebAuthnCredentialsByUser(c.Context(), user.ID)
+	if err != nil {
+		log.Printf("[DEBUG] renderPasskeysPage: failed to list passkeys: %v", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("Failed to load passkeys")
+	}
+
+	tmpl, err := view.GetTemplate("pages/user-passkeys.jet")
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("Template error: " + err.Error())
+	}
+
+	vars.Set("Title", "Passkeys")
+	vars.Set("User", user)
+	vars.Set("UserDisplayName", user.GetDisplayName())
+	vars.Set("ShowUserMenu", true)
+	vars.Set("Passkeys", credentials)
+
+	// Set current language from i18n middleware
+	if currentLang := c.Locals("currentLang"); currentLang != nil {
+		vars.Set("currentLang", currentLang)
+	} else {
+		vars.Set("currentLang", "en") // fallback to English
+	}
+
+	if csrfToken := c.Locals("csrf"); csrfToken != nil {
+		vars.Set("CsrfToken", csrfToken)
+	}
+
+	// Add footer configuration
+	addFooterConfig(c, vars)
+
+	return renderTemplate(c, tmpl, vars)
+}
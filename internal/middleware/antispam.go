@@ -1,9 +1,11 @@
 package middleware
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net"
+	"strconv"
 	"time"
 
 	"bitcoinpitch.org/internal/antispam"
@@ -13,6 +15,10 @@ import (
 	"github.com/google/uuid"
 )
 
+// ErrAntiSpamResponded is returned by the Check* helpers after they have written
+// an error response. Callers should stop processing and return nil.
+var ErrAntiSpamResponded = errors.New("antispam check failed, response already sent")
+
 // AntiSpamMiddleware creates middleware for antispam protection
 func AntiSpamMiddleware(antispamSvc *antispam.Service) fiber.Handler {
 	return func(c *fiber.Ctx) error {
@@ -40,9 +46,12 @@ func CheckPitchCreationLimit(c *fiber.Ctx, content string) error {
 	check, err := antispamSvc.CheckPitchCreation(c.Context(), userID, content, ipAddress, userAgent)
 	if err != nil {
 		log.Printf("Antispam check error: %v", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		if err := c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Unable to verify request",
-		})
+		}); err != nil {
+			return err
+		}
+		return ErrAntiSpamResponded
 	}
 
 	if !check.Allowed {
@@ -61,7 +70,10 @@ func CheckPitchCreationLimit(c *fiber.Ctx, content string) error {
 			response["penalties"] = check.Penalties
 		}
 
-		return c.Status(status).JSON(response)
+		if err := c.Status(status).JSON(response); err != nil {
+			return err
+		}
+		return ErrAntiSpamResponded
 	}
 
 	// Record the activity for tracking
@@ -101,9 +113,12 @@ func CheckPitchEditLimit(c *fiber.Ctx, userID uuid.UUID, pitchID uuid.UUID, cont
 	check, err := antispamSvc.CheckPitchEdit(c.Context(), userID, pitchID, content, ipAddress, userAgent)
 	if err != nil {
 		log.Printf("Antispam check error: %v", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		if err := c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Unable to verify request",
-		})
+		}); err != nil {
+			return err
+		}
+		return ErrAntiSpamResponded
 	}
 
 	if !check.Allowed {
@@ -122,7 +137,10 @@ func CheckPitchEditLimit(c *fiber.Ctx, userID uuid.UUID, pitchID uuid.UUID, cont
 			response["penalties"] = check.Penalties
 		}
 
-		return c.Status(status).JSON(response)
+		if err := c.Status(status).JSON(response); err != nil {
+			return err
+		}
+		return ErrAntiSpamResponded
 	}
 
 	// Record the activity for tracking
@@ -169,9 +187,12 @@ func CheckVoteLimit(c *fiber.Ctx, pitchID uuid.UUID) error {
 	check, err := antispamSvc.CheckVote(c.Context(), userID, pitchID, ipAddress, userAgent)
 	if err != nil {
 		log.Printf("Antispam check error: %v", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		if err := c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Unable to verify request",
-		})
+		}); err != nil {
+			return err
+		}
+		return ErrAntiSpamResponded
 	}
 
 	if !check.Allowed {
@@ -186,7 +207,10 @@ func CheckVoteLimit(c *fiber.Ctx, pitchID uuid.UUID) error {
 			response["retry_after_human"] = formatDuration(*check.RetryAfter)
 		}
 
-		return c.Status(status).JSON(response)
+		if err := c.Status(status).JSON(response); err != nil {
+			return err
+		}
+		return ErrAntiSpamResponded
 	}
 
 	// Record the activity for tracking
@@ -227,6 +251,73 @@ func RecordContentHash(c *fiber.Ctx, userID uuid.UUID, content string, pitchID u
 	}()
 }
 
+// CheckLoginLimit checks if a login attempt may proceed for the account (nil
+// when unknown) from the client's IP. It must run before the credential or
+// second-factor code is checked.
+func CheckLoginLimit(c *fiber.Ctx, userID *uuid.UUID) error {
+	antispamSvc := c.Locals("antispamService").(*antispam.Service)
+
+	check, err := antispamSvc.CheckLogin(c.Context(), userID, net.ParseIP(c.IP()))
+	if err != nil {
+		log.Printf("Antispam check error: %v", err)
+		if err := c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Unable to verify request",
+		}); err != nil {
+			return err
+		}
+		return ErrAntiSpamResponded
+	}
+
+	if !check.Allowed {
+		if err := LoginLimitResponse(c, check); err != nil {
+			return err
+		}
+		return ErrAntiSpamResponded
+	}
+
+	return nil
+}
+
+// RecordLoginFailure records a failed password, TOTP or backup code attempt.
+// It returns the resulting check, or nil if the failure could not be recorded.
+func RecordLoginFailure(c *fiber.Ctx, userID *uuid.UUID, method string) *models.AntiSpamCheck {
+	antispamSvc := c.Locals("antispamService").(*antispam.Service)
+
+	check, err := antispamSvc.RecordLoginFailure(c.Context(), userID, method, net.ParseIP(c.IP()), c.Get("User-Agent"))
+	if err != nil {
+		log.Printf("Failed to record login failure: %v", err)
+		return nil
+	}
+	return check
+}
+
+// RecordLoginSuccess records a completed login, resetting the account's failure count
+func RecordLoginSuccess(c *fiber.Ctx, userID uuid.UUID, method string) {
+	antispamSvc := c.Locals("antispamService").(*antispam.Service)
+
+	if err := antispamSvc.RecordLoginSuccess(c.Context(), userID, method, net.ParseIP(c.IP()), c.Get("User-Agent")); err != nil {
+		log.Printf("Failed to record activity: %v", err)
+	}
+}
+
+// LoginLimitResponse rejects a login attempt blocked by the brute-force limits
+func LoginLimitResponse(c *fiber.Ctx, check *models.AntiSpamCheck) error {
+	response := fiber.Map{
+		"error":   "Too many failed login attempts, please try again later",
+		"blocked": true,
+	}
+
+	if check.RetryAfter != nil {
+		seconds := int(check.RetryAfter.Seconds()) + 1
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(seconds))
+		response["error"] = fmt.Sprintf("Too many failed login attempts, please try again in %s", formatDuration(*check.RetryAfter))
+		response["retry_after_seconds"] = seconds
+		response["retry_after_human"] = formatDuration(*check.RetryAfter)
+	}
+
+	return c.Status(fiber.StatusTooManyRequests).JSON(response)
+}
+
 // formatDuration formats a duration into a human-readable string
 func formatDuration(d time.Duration) string {
 	if d < time.Minute {
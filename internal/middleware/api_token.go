@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"bitcoinpitch.org/internal/auth"
+	"bitcoinpitch.org/internal/database"
+	"bitcoinpitch.org/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// apiTokenTouchInterval limits how often a token's last-used time is written
+const apiTokenTouchInterval = 1 * time.Minute
+
+// APITokenMiddleware authenticates /api requests that carry a personal API token
+// in an "Authorization: Bearer" header. A presented token replaces any cookie
+// session for the request, and an invalid one is rejected outright.
+func APITokenMiddleware(repo *database.Repository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		header := c.Get(fiber.HeaderAuthorization)
+		if !strings.HasPrefix(c.Path(), "/api/") || !strings.HasPrefix(header, "Bearer ") {
+			return c.Next()
+		}
+
+		tokenValue := strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+		if tokenValue == "" {
+			return apiTokenUnauthorized(c, "Missing API token")
+		}
+
+		apiToken, err := repo.GetAPITokenByHash(c.Context(), auth.HashToken(tokenValue))
+		if err != nil {
+			log.Printf("[DEBUG] APITokenMiddleware: token lookup failed: %v", err)
+			return apiTokenUnauthorized(c, "Invalid API token")
+		}
+		if apiToken.IsExpired() {
+			return apiTokenUnauthorized(c, "API token has expired")
+		}
+
+		user, err := repo.GetUserByID(c.Context(), apiToken.UserID)
+		if err != nil || !user.CanLogin() {
+			return apiTokenUnauthorized(c, "Invalid API token")
+		}
+
+		now := time.Now()
+		if apiToken.LastUsedAt == nil || now.Sub(*apiToken.LastUsedAt) >= apiTokenTouchInterval {
+			if err := repo.TouchAPIToken(c.Context(), apiToken.ID, now); err != nil {
+				log.Printf("[DEBUG] APITokenMiddleware: failed to update last used: %v", err)
+			}
+		}
+
+		c.Locals("user", user)
+		c.Locals("user_id", user.ID)
+		c.Locals("session", nil)
+		c.Locals("readOnlySession", false)
+		c.Locals("apiToken", apiToken)
+		return c.Next()
+	}
+}
+
+// APITokenFromContext returns the API token that authenticated the request, if any
+func APITokenFromContext(c *fiber.Ctx) (*models.APIToken, bool) {
+	apiToken, ok := c.Locals("apiToken").(*models.APIToken)
+	return apiToken, ok && apiToken != nil
+}
+
+// RequireScope checks that a token-authenticated request was granted a scope.
+// Cookie sessions are not limited by scopes.
+func RequireScope(scope models.APITokenScope) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		apiToken, ok := APITokenFromContext(c)
+		if !ok || apiToken.HasScope(scope) {
+			return c.Next()
+		}
+
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error":          "API token is missing the required scope",
+			"required_scope": scope,
+		})
+	}
+}
+
+// apiTokenUnauthorized rejects a request with a bad bearer token
+func apiTokenUnauthorized(c *fiber.Ctx, message string) error {
+	c.Set(fiber.HeaderWWWAuthenticate, `Bearer realm="api"`)
+	return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+		"error": message,
+	})
+}
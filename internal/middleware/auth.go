@@ -1,14 +1,17 @@
 package middleware
 
 import (
-	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"log"
 	"net/http"
+	"net/url"
+	"os"
+	"strconv"
 	"strings"
 	"time"
 
+	"bitcoinpitch.org/internal/auth"
 	"bitcoinpitch.org/internal/database"
 	"bitcoinpitch.org/internal/models"
 
@@ -16,8 +19,31 @@ import (
 	"github.com/google/uuid"
 )
 
+const (
+	// sessionTouchInterval limits how often a session's last-seen time is written
+	sessionTouchInterval = 5 * time.Minute
+	// DefaultSessionRotationInterval is how long a session token is used before a fresh one is issued
+	DefaultSessionRotationInterval = 12 * time.Hour
+	// SessionRotationGrace is how long the previous token stays valid after rotation,
+	// so parallel requests sent with the old cookie are not logged out
+	SessionRotationGrace = 1 * time.Minute
+)
+
+// sessionRotationIntervalFromEnv reads SESSION_ROTATION_HOURS, falling back to the default
+func sessionRotationIntervalFromEnv() time.Duration {
+	if value := os.Getenv("SESSION_ROTATION_HOURS"); value != "" {
+		if hours, err := strconv.Atoi(value); err == nil && hours > 0 {
+			return time.Duration(hours) * time.Hour
+		}
+		log.Printf("[DEBUG] Invalid SESSION_ROTATION_HOURS=%q, using default", value)
+	}
+	return DefaultSessionRotationInterval
+}
+
 // AuthMiddleware handles authentication for protected routes
 func AuthMiddleware(repo *database.Repository) fiber.Handler {
+	rotationInterval := sessionRotationIntervalFromEnv()
+
 	return func(c *fiber.Ctx) error {
 		// Get session token from cookie
 		sessionToken := c.Cookies("session_token")
@@ -31,8 +57,9 @@ func AuthMiddleware(repo *database.Repository) fiber.Handler {
 
 		log.Printf("[DEBUG] AuthMiddleware: Found session token: %s", sessionToken[:10]+"...")
 
-		// Get session from database
-		session, err := repo.GetSessionByToken(c.Context(), sessionToken)
+		// Get session from database; only the token digest is stored
+		tokenHash := auth.HashToken(sessionToken)
+		session, err := repo.GetSessionByTokenHash(c.Context(), tokenHash, SessionRotationGrace)
 		if err != nil {
 			// Invalid session, clear cookie and continue as unauthenticated
 			log.Printf("[DEBUG] AuthMiddleware: Invalid session token, error: %v", err)
@@ -63,8 +90,27 @@ func AuthMiddleware(repo *database.Repository) fiber.Handler {
 
 		log.Printf("[DEBUG] AuthMiddleware: User found: %s (%s)", user.GetDisplayName(), user.ID)
 
-		// Set user in context
+		// Refresh last-seen time, at most once per interval to avoid a write per request
+		now := time.Now()
+		if now.Sub(session.GetLastSeen()) >= sessionTouchInterval {
+			if err := repo.TouchSession(c.Context(), session.BaseModel.ID, now); err != nil {
+				log.Printf("[DEBUG] AuthMiddleware: Failed to update session last seen: %v", err)
+			} else {
+				session.LastSeenAt = &now
+			}
+		}
+
+		// Issue a fresh token once the current one is old enough. Requests that
+		// arrive with the previous token during the grace period are not rotated again.
+		if session.Token == tokenHash && now.Sub(session.GetTokenIssuedAt()) >= rotationInterval {
+			rotateSessionToken(c, repo, session, tokenHash)
+		}
+
+		// Set user and session in context
 		c.Locals("user", user)
+		c.Locals("user_id", user.ID)
+		c.Locals("session", session)
+		c.Locals("readOnlySession", session.ReadOnly)
 		return c.Next()
 	}
 }
@@ -89,29 +135,127 @@ func RequireAuthMiddleware() fiber.Handler {
 	}
 }
 
-// CreateSession creates a new session for the user
-func CreateSession(repo *database.Repository, ctx context.Context, userID uuid.UUID) (string, error) {
-	// Generate session token
+// StepUpPath is where read-only sessions are sent to prove key ownership
+const StepUpPath = "/auth/step-up"
+
+// IsReadOnlySession reports whether the current request uses a read-only session
+func IsReadOnlySession(c *fiber.Ctx) bool {
+	readOnly, _ := c.Locals("readOnlySession").(bool)
+	return readOnly
+}
+
+// RequireWriteSession blocks read-only sessions from routes that change data.
+// Unauthenticated requests pass through; handlers enforce login themselves.
+func RequireWriteSession() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !IsReadOnlySession(c) {
+			return c.Next()
+		}
+
+		log.Printf("[DEBUG] RequireWriteSession: read-only session blocked on %s %s", c.Method(), c.Path())
+
+		if c.Get("HX-Request") == "true" {
+			// HTMX request, return fragment
+			return c.Status(fiber.StatusForbidden).SendString(`
+				<div class="auth-error">
+					You are signed in read-only. <a href="` + StepUpPath + `">Sign with your Nostr key</a> to make changes.
+				</div>
+			`)
+		}
+
+		if strings.HasPrefix(c.Path(), "/api/") || strings.Contains(c.Get("Accept"), "application/json") {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error":            "This session is read-only. Sign with your Nostr key to make changes.",
+				"requires_step_up": true,
+				"step_up_url":      StepUpPath,
+			})
+		}
+
+		// Regular request, send the user to the step-up page and back afterwards
+		returnURL := c.OriginalURL()
+		if c.Method() != fiber.MethodGet {
+			returnURL = "/"
+			if referer, err := url.Parse(c.Get("Referer")); err == nil && referer.Path != "" {
+				returnURL = referer.RequestURI()
+			}
+		}
+		return c.Redirect(StepUpPath+"?return="+url.QueryEscape(returnURL), http.StatusSeeOther)
+	}
+}
+
+// rotateSessionToken replaces the session token and sends the new cookie
+func rotateSessionToken(c *fiber.Ctx, repo *database.Repository, session *models.Session, oldHash string) {
+	token, err := generateSessionToken()
+	if err != nil {
+		log.Printf("[DEBUG] AuthMiddleware: Failed to generate rotated session token: %v", err)
+		return
+	}
+
+	newHash := auth.HashToken(token)
+	rotated, err := repo.RotateSessionToken(c.Context(), session.BaseModel.ID, oldHash, newHash)
+	if err != nil {
+		log.Printf("[DEBUG] AuthMiddleware: Failed to rotate session token: %v", err)
+		return
+	}
+	if !rotated {
+		// Another request rotated the token first; the old cookie is still within its grace period
+		return
+	}
+
+	now := time.Now()
+	session.Token = newHash
+	session.PreviousToken = &oldHash
+	session.TokenRotatedAt = &now
+	SetSessionCookie(c, token)
+
+	log.Printf("[DEBUG] AuthMiddleware: Rotated token for session %s", session.BaseModel.ID)
+}
+
+// generateSessionToken returns a random hex session token
+func generateSessionToken() (string, error) {
 	tokenBytes := make([]byte, 32)
 	if _, err := rand.Read(tokenBytes); err != nil {
 		return "", err
 	}
-	token := hex.EncodeToString(tokenBytes)
+	return hex.EncodeToString(tokenBytes), nil
+}
+
+// CreateSession creates a new session for the user, recording the client's IP and
+// user agent. It returns the cookie token; only its digest is stored.
+func CreateSession(repo *database.Repository, c *fiber.Ctx, userID uuid.UUID) (string, error) {
+	return createSession(repo, c, userID, false)
+}
+
+// CreateReadOnlySession creates a session that can browse as the user but not change anything
+func CreateReadOnlySession(repo *database.Repository, c *fiber.Ctx, userID uuid.UUID) (string, error) {
+	return createSession(repo, c, userID, true)
+}
+
+// createSession stores a new session and returns its cookie token
+func createSession(repo *database.Repository, c *fiber.Ctx, userID uuid.UUID, readOnly bool) (string, error) {
+	// Generate session token
+	token, err := generateSessionToken()
+	if err != nil {
+		return "", err
+	}
 
 	// Create session
+	now := time.Now()
 	session := &models.Session{
 		BaseModel: models.BaseModel{
 			ID:        uuid.New(),
-			CreatedAt: time.Now(),
-			UpdatedAt: time.Now(),
+			CreatedAt: now,
+			UpdatedAt: now,
 		},
-		UserID:    userID,
-		Token:     token,
-		ExpiresAt: time.Now().Add(30 * 24 * time.Hour), // 30 days
+		UserID:         userID,
+		Token:          auth.HashToken(token),
+		ExpiresAt:      now.Add(30 * 24 * time.Hour), // 30 days
+		ReadOnly:       readOnly,
+		TokenRotatedAt: &now,
 	}
+	session.SetDevice(c.IP(), c.Get("User-Agent"))
 
-	err := repo.CreateSession(ctx, session)
-	if err != nil {
+	if err := repo.CreateSession(c.Context(), session); err != nil {
 		return "", err
 	}
 
@@ -134,3 +278,23 @@ func SetSessionCookie(c *fiber.Ctx, token string) {
 	
 	c.Cookie(cookie)
 }
+
+// PendingLoginCookieName is the cookie carrying a login that awaits its second factor
+const PendingLoginCookieName = "pending_login"
+
+// SetPendingLoginCookie sets the cookie for a login awaiting its second factor
+func SetPendingLoginCookie(c *fiber.Ctx, token string, expires time.Time) {
+	c.Cookie(&fiber.Cookie{
+		Name:     PendingLoginCookieName,
+		Value:    token,
+		Expires:  expires,
+		HTTPOnly: true,
+		Secure:   strings.HasPrefix(c.BaseURL(), "https"),
+		SameSite: "Lax",
+	})
+}
+
+// ClearPendingLoginCookie removes the pending login cookie
+func ClearPendingLoginCookie(c *fiber.Ctx) {
+	c.ClearCookie(PendingLoginCookieName)
+}
@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"bitcoinpitch.org/internal/crypto"
+	"bitcoinpitch.org/internal/database"
+	"bitcoinpitch.org/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// NIP98AuthMiddleware authenticates /api requests signed with a NIP-98 event in
+// an "Authorization: Nostr" header. Each request carries its own signature, so
+// no cookie or session is involved, and an invalid signature is rejected outright.
+func NIP98AuthMiddleware(repo *database.Repository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		header := c.Get(fiber.HeaderAuthorization)
+		if !strings.HasPrefix(c.Path(), "/api/") || !strings.HasPrefix(header, crypto.NIP98AuthScheme+" ") {
+			return c.Next()
+		}
+
+		pubkey, err := crypto.VerifyNIP98Auth(header, crypto.NIP98Request{
+			URL:    nip98RequestURL(c),
+			Method: c.Method(),
+			Body:   c.Body(),
+		}, time.Now())
+		if err != nil {
+			log.Printf("[DEBUG] NIP98AuthMiddleware: rejected %s %s: %v", c.Method(), c.Path(), err)
+			return nip98Unauthorized(c, "Invalid NIP-98 authorization: "+err.Error())
+		}
+
+		user, err := repo.GetUserByAuth(c.Context(), models.AuthTypeNostr, pubkey)
+		if err != nil || !user.CanLogin() {
+			return nip98Unauthorized(c, "No active account for this Nostr key")
+		}
+
+		c.Locals("user", user)
+		c.Locals("user_id", user.ID)
+		c.Locals("session", nil)
+		c.Locals("readOnlySession", false)
+		c.Locals("nip98Pubkey", pubkey)
+		return c.Next()
+	}
+}
+
+// IsNIP98Request reports whether the request was authenticated with NIP-98
+func IsNIP98Request(c *fiber.Ctx) bool {
+	pubkey, ok := c.Locals("nip98Pubkey").(string)
+	return ok && pubkey != ""
+}
+
+// nip98RequestURL rebuilds the absolute URL the client signed. SITE_URL is
+// preferred so the check still works behind a reverse proxy.
+func nip98RequestURL(c *fiber.Ctx) string {
+	if siteURL := os.Getenv("SITE_URL"); siteURL != "" {
+		return strings.TrimRight(siteURL, "/") + c.OriginalURL()
+	}
+	return c.BaseURL() + c.OriginalURL()
+}
+
+// nip98Unauthorized rejects a request with a bad NIP-98 signature
+func nip98Unauthorized(c *fiber.Ctx, message string) error {
+	c.Set(fiber.HeaderWWWAuthenticate, crypto.NIP98AuthScheme)
+	return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+		"error": message,
+	})
+}
@@ -62,7 +62,7 @@ func SecurityMiddleware(app *fiber.App) {
 	// Custom security headers
 	app.Use(func(c *fiber.Ctx) error {
 		c.Set("Strict-Transport-Security", "max-age=31536000; includeSubDomains")
-		c.Set("Content-Security-Policy", "default-src 'self'; script-src 'self' 'unsafe-inline' https://unpkg.com https://connect.trezor.io; style-src 'self' 'unsafe-inline'; img-src 'self' data:; font-src 'self'; connect-src 'self' https://connect.trezor.io wss://connect.trezor.io https://api.twitter.com;")
+		c.Set("Content-Security-Policy", "default-src 'self'; script-src 'self' 'unsafe-inline' https://unpkg.com https://connect.trezor.io; style-src 'self' 'unsafe-inline'; img-src 'self' data: https:; font-src 'self'; connect-src 'self' https://connect.trezor.io wss://connect.trezor.io https://api.twitter.com;")
 		return c.Next()
 	})
 
@@ -119,6 +119,11 @@ func SecurityMiddleware(app *fiber.App) {
 			return c.Next()
 		}
 
+		// API token and NIP-98 requests carry no cookies, so they cannot be forged cross-site
+		if _, ok := APITokenFromContext(c); ok || IsNIP98Request(c) {
+			return c.Next()
+		}
+
 		// For POST/PUT/DELETE requests, get existing token from cookie
 		expectedToken := c.Cookies("_csrf")
 		if expectedToken == "" {
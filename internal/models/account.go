@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AnonymousUserID is the tombstone account that the pitches of deleted
+// accounts are reassigned to when the owner chose to anonymize them
+var AnonymousUserID = uuid.MustParse("00000000-0000-0000-0000-000000000002")
+
+// What happens to the pitches of a deleted account
+const (
+	DeletionModeAnonymize = "anonymize"
+	DeletionModeDelete    = "delete"
+)
+
+// IsValidDeletionMode checks if a deletion mode is known
+func IsValidDeletionMode(mode string) bool {
+	return mode == DeletionModeAnonymize || mode == DeletionModeDelete
+}
+
+// UserDataExport holds everything stored about a user, for the "download my
+// data" archive. Secrets such as password hashes and token digests are left
+// out by the models' JSON tags.
+type UserDataExport struct {
+	ExportedAt    time.Time       `json:"exported_at"`
+	Profile       *User           `json:"profile"`
+	Identities    []*UserIdentity `json:"identities"`
+	Pitches       []*Pitch        `json:"pitches"`
+	Votes         []*Vote         `json:"votes"`
+	Sessions      []*Session      `json:"sessions"`
+	Activities    []*UserActivity `json:"activities"`
+	ContentHashes []*ContentHash  `json:"content_hashes"`
+	InviteCodes   []*InviteCode   `json:"invite_codes"`
+}
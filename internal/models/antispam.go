@@ -12,12 +12,15 @@ import (
 type ActivityType string
 
 const (
-	ActivityTypePitchCreate ActivityType = "pitch_create"
-	ActivityTypePitchEdit   ActivityType = "pitch_edit"
-	ActivityTypePitchDelete ActivityType = "pitch_delete"
-	ActivityTypeVote        ActivityType = "vote"
-	ActivityTypeLogin       ActivityType = "login"
-	ActivityTypeRegister    ActivityType = "register"
+	ActivityTypePitchCreate   ActivityType = "pitch_create"
+	ActivityTypePitchEdit     ActivityType = "pitch_edit"
+	ActivityTypePitchDelete   ActivityType = "pitch_delete"
+	ActivityTypeVote          ActivityType = "vote"
+	ActivityTypeLogin         ActivityType = "login"
+	ActivityTypeRegister      ActivityType = "register"
+	ActivityTypeLoginFailed   ActivityType = "login_failed"
+	ActivityTypeLockout       ActivityType = "login_lockout"
+	ActivityTypePasswordReset ActivityType = "password_reset"
 )
 
 // PenaltyType represents the type of penalty applied to a user
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// APITokenScope grants a personal API token access to a group of endpoints
+type APITokenScope string
+
+const (
+	ScopePitchesRead  APITokenScope = "pitches:read"
+	ScopePitchesWrite APITokenScope = "pitches:write"
+	ScopeVotesWrite   APITokenScope = "votes:write"
+)
+
+// AllAPITokenScopes lists the scopes a user can grant, in display order
+var AllAPITokenScopes = []APITokenScope{ScopePitchesRead, ScopePitchesWrite, ScopeVotesWrite}
+
+// IsValidAPITokenScope checks if a scope name is known
+func IsValidAPITokenScope(scope string) bool {
+	for _, s := range AllAPITokenScopes {
+		if string(s) == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// APIToken is a personal access token for the JSON API
+type APIToken struct {
+	BaseModel
+	UserID      uuid.UUID      `json:"user_id" db:"user_id"`
+	Name        string         `json:"name" db:"name"`
+	TokenHash   string         `json:"-" db:"token_hash"`
+	TokenPrefix string         `json:"token_prefix" db:"token_prefix"`
+	Scopes      pq.StringArray `json:"scopes" db:"scopes"`
+	ExpiresAt   *time.Time     `json:"expires_at,omitempty" db:"expires_at"`
+	LastUsedAt  *time.Time     `json:"last_used_at,omitempty" db:"last_used_at"`
+}
+
+// NewAPIToken creates a new API token record from the digest of a generated token
+func NewAPIToken(userID uuid.UUID, name, tokenHash, tokenPrefix string, scopes []string, expiresAt *time.Time) *APIToken {
+	now := time.Now()
+	return &APIToken{
+		BaseModel: BaseModel{
+			ID:        uuid.New(),
+			CreatedAt: now,
+			UpdatedAt: now,
+		},
+		UserID:      userID,
+		Name:        name,
+		TokenHash:   tokenHash,
+		TokenPrefix: tokenPrefix,
+		Scopes:      pq.StringArray(scopes),
+		ExpiresAt:   expiresAt,
+	}
+}
+
+// IsExpired checks if the token has an expiry that has passed
+func (t *APIToken) IsExpired() bool {
+	return t.ExpiresAt != nil && time.Now().After(*t.ExpiresAt)
+}
+
+// HasScope checks if the token was granted a scope
+func (t *APIToken) HasScope(scope APITokenScope) bool {
+	for _, s := range t.Scopes {
+		if s == string(scope) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetExpiresAt returns the expiry time, or the zero time if the token never expires
+func (t *APIToken) GetExpiresAt() time.Time {
+	if t.ExpiresAt == nil {
+		return time.Time{}
+	}
+	return *t.ExpiresAt
+}
+
+// GetLastUsed returns when the token was last used, or the zero time if never
+func (t *APIToken) GetLastUsed() time.Time {
+	if t.LastUsedAt == nil {
+		return time.Time{}
+	}
+	return *t.LastUsedAt
+}
@@ -0,0 +1,103 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// MaxCategorySlugLength is the longest slug a category may have
+const MaxCategorySlugLength = 50
+
+var categorySlugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// IsValidCategorySlug checks if a slug is lowercase letters and digits,
+// optionally separated by single dashes
+func IsValidCategorySlug(slug string) bool {
+	return len(slug) <= MaxCategorySlugLength && categorySlugPattern.MatchString(slug)
+}
+
+// LocalizedNames maps language codes to a translated name. It is stored as
+// a JSON object.
+type LocalizedNames map[string]string
+
+// Scan implements the sql.Scanner interface for the JSONB column
+func (n *LocalizedNames) Scan(src interface{}) error {
+	if src == nil {
+		*n = LocalizedNames{}
+		return nil
+	}
+	b, ok := src.([]byte)
+	if !ok {
+		return fmt.Errorf("unexpected type %T for localized names", src)
+	}
+	return json.Unmarshal(b, n)
+}
+
+// Value implements the driver.Valuer interface for the JSONB column
+func (n LocalizedNames) Value() (driver.Value, error) {
+	if n == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(n)
+}
+
+// Category is a pitch category such as Bitcoin or Lightning. Admins manage
+// categories, and each active one is listed at /<slug>.
+type Category struct {
+	Slug         string         `json:"slug" db:"slug"`
+	Names        LocalizedNames `json:"names" db:"names"`
+	Description  string         `json:"description" db:"description"`
+	Icon         string         `json:"icon" db:"icon"`
+	DisplayOrder int            `json:"display_order" db:"display_order"`
+	Active       bool           `json:"active" db:"active"`
+	DefaultSort  string         `json:"default_sort" db:"default_sort"`
+	CreatedAt    time.Time      `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at" db:"updated_at"`
+
+	// Number of pitches in the category, only set by admin listings
+	PitchCount int `json:"-" db:"pitch_count"`
+}
+
+// NewCategory creates a new active category
+func NewCategory(slug string, names LocalizedNames, description, icon string, displayOrder int, defaultSort string) *Category {
+	now := time.Now()
+	return &Category{
+		Slug:         slug,
+		Names:        names,
+		Description:  description,
+		Icon:         icon,
+		DisplayOrder: displayOrder,
+		Active:       true,
+		DefaultSort:  defaultSort,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+}
+
+// GetName returns the category's name in a language, falling back to
+// English and then to the slug
+func (c *Category) GetName(lang string) string {
+	if name := c.Names[lang]; name != "" {
+		return name
+	}
+	if name := c.Names["en"]; name != "" {
+		return name
+	}
+	return c.Slug
+}
+
+// GetDisplayName returns the name in a language with the icon in front
+func (c *Category) GetDisplayName(lang string) string {
+	if c.Icon != "" {
+		return c.Icon + " " + c.GetName(lang)
+	}
+	return c.GetName(lang)
+}
+
+// URL returns the path of the category's pitch listing
+func (c *Category) URL() string {
+	return "/" + c.Slug
+}
@@ -0,0 +1,104 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Who can create an account
+const (
+	RegistrationModeOpen   = "open"
+	RegistrationModeInvite = "invite"
+	RegistrationModeClosed = "closed"
+)
+
+// IsValidRegistrationMode checks if a registration mode is known
+func IsValidRegistrationMode(mode string) bool {
+	switch mode {
+	case RegistrationModeOpen, RegistrationModeInvite, RegistrationModeClosed:
+		return true
+	}
+	return false
+}
+
+// InviteCode lets new users sign up while registration is invite-only
+type InviteCode struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	Code      string     `json:"code" db:"code"`
+	CreatedBy *uuid.UUID `json:"created_by,omitempty" db:"created_by"`
+	Note      *string    `json:"note,omitempty" db:"note"`
+	MaxUses   int        `json:"max_uses" db:"max_uses"`
+	UseCount  int        `json:"use_count" db:"use_count"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+
+	// Display name of the creator, only set by admin listings
+	CreatedByName *string `json:"-" db:"created_by_name"`
+}
+
+// NewInviteCode creates a new invitation code. A nil expiry means it never
+// expires.
+func NewInviteCode(code string, createdBy *uuid.UUID, maxUses int, expiresAt *time.Time) *InviteCode {
+	return &InviteCode{
+		ID:        uuid.New(),
+		Code:      code,
+		CreatedBy: createdBy,
+		MaxUses:   maxUses,
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now(),
+	}
+}
+
+// IsRevoked returns true if the code was revoked
+func (i *InviteCode) IsRevoked() bool {
+	return i.RevokedAt != nil
+}
+
+// IsExpired returns true if the code expired before now
+func (i *InviteCode) IsExpired(now time.Time) bool {
+	return i.ExpiresAt != nil && !now.Before(*i.ExpiresAt)
+}
+
+// IsUsedUp returns true if the code was redeemed as often as allowed
+func (i *InviteCode) IsUsedUp() bool {
+	return i.UseCount >= i.MaxUses
+}
+
+// IsUsable returns true if the code can still be used to sign up
+func (i *InviteCode) IsUsable(now time.Time) bool {
+	return !i.IsRevoked() && !i.IsExpired(now) && !i.IsUsedUp()
+}
+
+// GetStatus returns "active", "revoked", "expired" or "used"
+func (i *InviteCode) GetStatus() string {
+	switch {
+	case i.IsRevoked():
+		return "revoked"
+	case i.IsUsedUp():
+		return "used"
+	case i.IsExpired(time.Now()):
+		return "expired"
+	}
+	return "active"
+}
+
+// GetNote returns the note or an empty string
+func (i *InviteCode) GetNote() string {
+	if i.Note == nil {
+		return ""
+	}
+	return *i.Note
+}
+
+// GetCreatedByName returns who created the code, for admin listings
+func (i *InviteCode) GetCreatedByName() string {
+	if i.CreatedByName != nil && *i.CreatedByName != "" {
+		return *i.CreatedByName
+	}
+	if i.CreatedBy == nil {
+		return "-"
+	}
+	return i.CreatedBy.String()
+}
@@ -22,6 +22,7 @@ const (
 	AuthTypeTwitter  AuthType = "twitter"
 	AuthTypePassword AuthType = "password"
 	AuthTypeEmail    AuthType = "email" // New email/password authentication
+	AuthTypeLNURL    AuthType = "lnurl" // Lightning wallet login (LNURL-auth)
 )
 
 // UserRole represents the role of a user in the system
@@ -33,7 +34,8 @@ const (
 	UserRoleAdmin     UserRole = "admin"
 )
 
-// MainCategory represents the main category of a pitch
+// MainCategory is the slug of the category a pitch belongs to. Categories
+// are managed by admins; these are the ones every site starts with.
 type MainCategory string
 
 const (
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+)
+
+// NIP05Lookup is the cached result of fetching a NIP-05 identifier's
+// nostr.json. A failed fetch keeps the last pubkey the domain listed, so a
+// short outage doesn't drop verified badges.
+type NIP05Lookup struct {
+	Identifier string     `json:"identifier" db:"identifier"`
+	Pubkey     *string    `json:"pubkey,omitempty" db:"pubkey"`
+	LastError  *string    `json:"last_error,omitempty" db:"last_error"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty" db:"resolved_at"`
+	CheckedAt  time.Time  `json:"checked_at" db:"checked_at"`
+}
+
+// PointsTo returns true if the identifier resolved to one of the given hex
+// pubkeys within the grace period
+func (l *NIP05Lookup) PointsTo(pubkeys []string, now time.Time, grace time.Duration) bool {
+	if l.Pubkey == nil || l.ResolvedAt == nil || now.Sub(*l.ResolvedAt) > grace {
+		return false
+	}
+	for _, pubkey := range pubkeys {
+		if pubkey == *l.Pubkey {
+			return true
+		}
+	}
+	return false
+}
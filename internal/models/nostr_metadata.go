@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+)
+
+// NostrMetadata is the profile a user published in a Nostr kind-0 event.
+// Fields the event leaves out or that fail validation are nil.
+type NostrMetadata struct {
+	Pubkey    string
+	Name      *string
+	About     *string
+	Picture   *string
+	Lud16     *string
+	NIP05     *string
+	CreatedAt time.Time
+}
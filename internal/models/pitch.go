@@ -4,6 +4,9 @@ import (
 	"encoding/json"
 	"strings"
 	"time"
+	"unicode/utf8"
+
+	"bitcoinpitch.org/internal/textdiff"
 
 	"github.com/google/uuid"
 )
@@ -23,10 +26,13 @@ type Pitch struct {
 	Score                   int            `json:"score" db:"score"`
 	LastVoteAt              *time.Time     `json:"last_vote_at,omitempty" db:"last_vote_at"`
 	LastEditAt              *time.Time     `json:"last_edit_at,omitempty" db:"last_edit_at"`
+	LastEditKind            *string        `json:"last_edit_kind,omitempty" db:"last_edit_kind"`
 	PostedBy                uuid.UUID      `json:"posted_by" db:"posted_by"`
 	AuthorType              AuthorType     `json:"author_type" db:"author_type"`
 	AuthorName              *string        `json:"author_name,omitempty" db:"author_name"`
 	AuthorHandle            *string        `json:"author_handle,omitempty" db:"author_handle"`
+	AuthorNIP05             *string        `json:"author_nip05,omitempty" db:"author_nip05"`
+	AuthorNIP05VerifiedAt   *time.Time     `json:"author_nip05_verified_at,omitempty" db:"author_nip05_verified_at"`
 	Tags                    Tags           `json:"tags,omitempty" db:"tags"`
 	PostedByDisplayName     *string        `json:"posted_by_display_name,omitempty" db:"posted_by_display_name"`
 	PostedByAuthType        *AuthType      `json:"posted_by_auth_type,omitempty" db:"posted_by_auth_type"`
@@ -34,10 +40,13 @@ type Pitch struct {
 	PostedByShowAuthMethod  *bool          `json:"posted_by_show_auth_method,omitempty" db:"posted_by_show_auth_method"`
 	PostedByShowUsername    *bool          `json:"posted_by_show_username,omitempty" db:"posted_by_show_username"`
 	PostedByShowProfileInfo *bool          `json:"posted_by_show_profile_info,omitempty" db:"posted_by_show_profile_info"`
+	PostedByNIP05           *string        `json:"posted_by_nip05,omitempty" db:"posted_by_nip05"`
 	// Full-text search vector (automatically managed by database trigger)
 	SearchVector *string `json:"-" db:"search_vector"`
 	// Search ranking (only populated during search queries)
 	SearchRank *float64 `json:"search_rank,omitempty" db:"search_rank"`
+	// Sort key of the listing's ranking mode (only populated by ranked listings)
+	RankKey *float64 `json:"-" db:"rank_key"`
 	// Admin management fields
 	Hidden bool `json:"hidden" db:"hidden"`
 	// CurrentUser is set at runtime for template access, not stored in database
@@ -46,6 +55,32 @@ type Pitch struct {
 	CurrentUserVote *Vote `json:"-" db:"-"`
 }
 
+// How much an edit changed a pitch's text
+const (
+	EditKindMinor = "minor"
+	EditKindMajor = "major"
+)
+
+// ClassifyPitchEdit returns EditKindMinor when new differs from old by at most
+// maxDistance characters and at most maxPercent percent of old's length, and
+// EditKindMajor otherwise
+func ClassifyPitchEdit(old, new string, maxDistance, maxPercent int) string {
+	distance := textdiff.Distance(old, new)
+	if distance > maxDistance || distance*100 > maxPercent*utf8.RuneCountInString(old) {
+		return EditKindMajor
+	}
+	return EditKindMinor
+}
+
+// MovesVotes returns true if an edit would give the pitch another category
+// or author. Votes were cast for the pitch as it was, so such an edit is
+// always major.
+func (p *Pitch) MovesVotes(mainCategory MainCategory, authorType AuthorType, authorHandle *string) bool {
+	return mainCategory != p.MainCategory || authorType != p.AuthorType ||
+		(authorHandle == nil) != (p.AuthorHandle == nil) ||
+		(authorHandle != nil && *authorHandle != *p.AuthorHandle)
+}
+
 // NewPitch creates a new pitch with the given details
 func NewPitch(userID, postedBy uuid.UUID, content, language string, mainCategory MainCategory, lengthCategory LengthCategory, authorType AuthorType) *Pitch {
 	now := time.Now()
@@ -73,12 +108,17 @@ func (p *Pitch) SetAuthor(authorType AuthorType, authorName, authorHandle *strin
 	p.UpdatedAt = time.Now()
 }
 
-// Edit updates the pitch content and resets votes
-func (p *Pitch) Edit(content string) {
+// Edit updates the pitch content. A major edit resets the votes, which were
+// cast for different text; a minor edit keeps them.
+func (p *Pitch) Edit(content, kind string) {
 	p.Content = content
 	now := time.Now()
 	p.UpdatedAt = now
 	p.LastEditAt = &now
+	p.LastEditKind = &kind
+	if kind == EditKindMinor {
+		return
+	}
 	p.VoteCount = 0
 	p.UpvoteCount = 0
 	p.DownvoteCount = 0
@@ -86,6 +126,11 @@ func (p *Pitch) Edit(content string) {
 	p.LastVoteAt = nil
 }
 
+// IsMinorEdit returns true if the latest edit kept the pitch's votes
+func (p *Pitch) IsMinorEdit() bool {
+	return p.LastEditKind != nil && *p.LastEditKind == EditKindMinor
+}
+
 // Delete marks the pitch as deleted
 func (p *Pitch) Delete() {
 	now := time.Now()
@@ -119,6 +164,15 @@ func (p *Pitch) GetPostedByDisplayName() string {
 	return "Anonymous"
 }
 
+// GetPostedByProfileURL returns the link to the poster's public profile, or
+// "#" for pitches kept from deleted accounts and posters who stay anonymous
+func (p *Pitch) GetPostedByProfileURL() string {
+	if p.PostedBy == AnonymousUserID || p.PostedByShowUsername == nil || !*p.PostedByShowUsername {
+		return "#"
+	}
+	return "/u/" + p.PostedBy.String()
+}
+
 // GetPostedByPublicAuthType returns the auth type only if user allows it
 func (p *Pitch) GetPostedByPublicAuthType() string {
 	if p.PostedByShowAuthMethod != nil && *p.PostedByShowAuthMethod && p.PostedByAuthType != nil {
@@ -131,6 +185,8 @@ func (p *Pitch) GetPostedByPublicAuthType() string {
 			return "Twitter"
 		case AuthTypePassword:
 			return "Password"
+		case AuthTypeLNURL:
+			return "Lightning"
 		default:
 			return ""
 		}
@@ -148,6 +204,15 @@ func (p *Pitch) ShouldShowPostedByUsername() bool {
 	return p.PostedByShowUsername == nil || *p.PostedByShowUsername
 }
 
+// GetPostedByNIP05 returns the poster's verified NIP-05 identifier, or an
+// empty string
+func (p *Pitch) GetPostedByNIP05() string {
+	if p.PostedByNIP05 == nil {
+		return ""
+	}
+	return *p.PostedByNIP05
+}
+
 // GetAuthorHandle returns the author handle as a string, safe for templates
 func (p *Pitch) GetAuthorHandle() string {
 	if p.AuthorHandle == nil {
@@ -168,6 +233,20 @@ func (p *Pitch) GetAuthorHandleForTwitter() string {
 	return handle
 }
 
+// GetAuthorNIP05 returns the NIP-05 identifier claimed for a Nostr author
+func (p *Pitch) GetAuthorNIP05() string {
+	if p.AuthorNIP05 == nil {
+		return ""
+	}
+	return *p.AuthorNIP05
+}
+
+// IsAuthorNIP05Verified returns true if the claimed NIP-05 identifier points
+// to the author's npub
+func (p *Pitch) IsAuthorNIP05Verified() bool {
+	return p.AuthorType == AuthorTypeNostr && p.AuthorNIP05 != nil && p.AuthorNIP05VerifiedAt != nil
+}
+
 // Admin management methods
 
 // IsHidden returns true if the pitch is hidden
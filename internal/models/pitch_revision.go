@@ -0,0 +1,99 @@
+package models
+
+import (
+	"time"
+
+	"bitcoinpitch.org/internal/textdiff"
+
+	"github.com/google/uuid"
+)
+
+// PitchRevision is one version of a pitch's content. A revision keeps the
+// votes it had collected when a later edit replaced it; the current revision's
+// votes are the pitch's own.
+type PitchRevision struct {
+	ID             uuid.UUID  `json:"id" db:"id"`
+	PitchID        uuid.UUID  `json:"pitch_id" db:"pitch_id"`
+	RevisionNumber int        `json:"revision_number" db:"revision_number"`
+	Content        string     `json:"content" db:"content"`
+	EditedBy       uuid.UUID  `json:"edited_by" db:"edited_by"`
+	RestoredFrom   *int       `json:"restored_from,omitempty" db:"restored_from"`
+	EditKind       *string    `json:"edit_kind,omitempty" db:"edit_kind"`
+	VoteCount      int        `json:"vote_count" db:"vote_count"`
+	UpvoteCount    int        `json:"upvote_count" db:"upvote_count"`
+	DownvoteCount  int        `json:"downvote_count" db:"downvote_count"`
+	Score          int        `json:"score" db:"score"`
+	ReplacedAt     *time.Time `json:"replaced_at,omitempty" db:"replaced_at"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+
+	// Public name of the editor, set by listings
+	EditedByName *string `json:"edited_by_name,omitempty" db:"edited_by_name"`
+	// Changes from the previous revision, set by history views
+	Diff []textdiff.Op `json:"diff,omitempty" db:"-"`
+}
+
+// NewPitchRevision creates the revision for a pitch's current content. The
+// revision number is assigned when it is stored.
+func NewPitchRevision(pitch *Pitch, editedBy uuid.UUID, restoredFrom *int) *PitchRevision {
+	return &PitchRevision{
+		ID:           uuid.New(),
+		PitchID:      pitch.ID,
+		Content:      pitch.Content,
+		EditedBy:     editedBy,
+		RestoredFrom: restoredFrom,
+		EditKind:     pitch.LastEditKind,
+		CreatedAt:    time.Now(),
+	}
+}
+
+// IsCurrent returns true if no later edit replaced the revision
+func (r *PitchRevision) IsCurrent() bool {
+	return r.ReplacedAt == nil
+}
+
+// GetRestoredFrom returns the revision number this one restored, or 0
+func (r *PitchRevision) GetRestoredFrom() int {
+	if r.RestoredFrom == nil {
+		return 0
+	}
+	return *r.RestoredFrom
+}
+
+// GetEditKind returns whether the edit that created the revision was minor or
+// major, or "" for the original text
+func (r *PitchRevision) GetEditKind() string {
+	if r.EditKind == nil {
+		return ""
+	}
+	return *r.EditKind
+}
+
+// GetEditedByName returns the editor's public name
+func (r *PitchRevision) GetEditedByName() string {
+	if r.EditedByName != nil && *r.EditedByName != "" {
+		return *r.EditedByName
+	}
+	return "Anonymous"
+}
+
+// GetEditedByProfileURL returns the link to the editor's public profile, or
+// "#" for edits kept from deleted accounts and editors who stay anonymous
+func (r *PitchRevision) GetEditedByProfileURL() string {
+	if r.EditedBy == AnonymousUserID || r.EditedByName == nil {
+		return "#"
+	}
+	return "/u/" + r.EditedBy.String()
+}
+
+// SetPitchRevisionDiffs fills in each revision's changes from the one before
+// it. The first revision has nothing to compare to, so its text is unchanged.
+// Revisions must be ordered oldest first.
+func SetPitchRevisionDiffs(revisions []*PitchRevision) {
+	for i, revision := range revisions {
+		if i == 0 {
+			revision.Diff = []textdiff.Op{{Type: textdiff.OpEqual, Text: revision.Content}}
+			continue
+		}
+		revision.Diff = textdiff.Words(revisions[i-1].Content, revision.Content)
+	}
+}
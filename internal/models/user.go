@@ -1,6 +1,7 @@
 package models
 
 import (
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -28,7 +29,9 @@ type User struct {
 	// TOTP 2FA fields
 	TOTPSecret      *string        `json:"-" db:"totp_secret"`
 	TOTPEnabled     bool           `json:"totp_enabled" db:"totp_enabled"`
-	TOTPBackupCodes pq.StringArray `json:"-" db:"totp_backup_codes"`
+	TOTPBackupCodes pq.StringArray `json:"-" db:"totp_backup_codes"` // bcrypt hashes, never plaintext
+	TOTPLastStep    *int64         `json:"-" db:"totp_last_step"`    // last accepted TOTP time step (replay protection)
+	TOTPEnabledAt   *time.Time     `json:"-" db:"totp_enabled_at"`
 	// Password reset fields
 	PasswordResetToken     *string    `json:"-" db:"password_reset_token"`
 	PasswordResetExpiresAt *time.Time `json:"-" db:"password_reset_expires_at"`
@@ -38,6 +41,24 @@ type User struct {
 	Disabled  bool       `json:"disabled" db:"disabled"`
 	Hidden    bool       `json:"hidden" db:"hidden"`
 	DeletedAt *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+	// Self-service deletion, applied once the grace period has passed
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at,omitempty" db:"deletion_scheduled_at"`
+	DeletionMode        *string    `json:"deletion_mode,omitempty" db:"deletion_mode"`
+	// NIP-05 identifier, verified against the user's linked Nostr keys
+	NIP05           *string    `json:"nip05,omitempty" db:"nip05"`
+	NIP05VerifiedAt *time.Time `json:"nip05_verified_at,omitempty" db:"nip05_verified_at"`
+	// Whether the username is published in our own /.well-known/nostr.json
+	PublishNIP05 bool `json:"publish_nip05" db:"publish_nip05"`
+	// Profile metadata imported from the user's Nostr kind-0 event
+	NostrName       *string    `json:"nostr_name,omitempty" db:"nostr_name"`
+	NostrAbout      *string    `json:"nostr_about,omitempty" db:"nostr_about"`
+	NostrPicture    *string    `json:"nostr_picture,omitempty" db:"nostr_picture"`
+	NostrLud16      *string    `json:"nostr_lud16,omitempty" db:"nostr_lud16"`
+	NostrNIP05      *string    `json:"nostr_nip05,omitempty" db:"nostr_nip05"`
+	NostrMetadataAt *time.Time `json:"nostr_metadata_at,omitempty" db:"nostr_metadata_at"`
+	// Invitation the account signed up with, and how many codes it may hand out
+	InviteCodeID *uuid.UUID `json:"invite_code_id,omitempty" db:"invite_code_id"`
+	InviteQuota  int        `json:"invite_quota" db:"invite_quota"`
 }
 
 // NewUser creates a new user with the given authentication details
@@ -102,6 +123,14 @@ func (u *User) SetPrivacySettings(showAuthMethod, showUsername, showProfileInfo
 	u.UpdatedAt = time.Now()
 }
 
+// GetEmail returns the email address or an empty string
+func (u *User) GetEmail() string {
+	if u.Email == nil {
+		return ""
+	}
+	return *u.Email
+}
+
 // GetDisplayName returns the display name or username or a default value
 func (u *User) GetDisplayName() string {
 	if u.DisplayName != nil && *u.DisplayName != "" {
@@ -134,6 +163,8 @@ func (u *User) GetAuthMethodString() string {
 		return "Username/Password"
 	case AuthTypeEmail:
 		return "Email/Password"
+	case AuthTypeLNURL:
+		return "Lightning (LNURL-auth)"
 	default:
 		return "Unknown"
 	}
@@ -169,6 +200,11 @@ func (u *User) SetEmail(email string) {
 	u.UpdatedAt = time.Now()
 }
 
+// HasPassword returns true if the user can sign in with a password
+func (u *User) HasPassword() bool {
+	return u.PasswordHash != nil && *u.PasswordHash != ""
+}
+
 // SetPasswordHash sets the password hash for the user
 func (u *User) SetPasswordHash(hash string) {
 	u.PasswordHash = &hash
@@ -217,8 +253,10 @@ func (u *User) SetTOTPSecret(secret string) {
 
 // EnableTOTP enables TOTP 2FA for the user
 func (u *User) EnableTOTP() {
+	now := time.Now()
 	u.TOTPEnabled = true
-	u.UpdatedAt = time.Now()
+	u.TOTPEnabledAt = &now
+	u.UpdatedAt = now
 }
 
 // DisableTOTP disables TOTP 2FA for the user
@@ -226,10 +264,20 @@ func (u *User) DisableTOTP() {
 	u.TOTPEnabled = false
 	u.TOTPSecret = nil
 	u.TOTPBackupCodes = pq.StringArray{}
+	u.TOTPLastStep = nil
+	u.TOTPEnabledAt = nil
 	u.UpdatedAt = time.Now()
 }
 
-// SetTOTPBackupCodes sets the TOTP backup codes
+// GetTOTPLastStep returns the last accepted TOTP time step, or 0 if none
+func (u *User) GetTOTPLastStep() int64 {
+	if u.TOTPLastStep == nil {
+		return 0
+	}
+	return *u.TOTPLastStep
+}
+
+// SetTOTPBackupCodes sets the (hashed) TOTP backup codes
 func (u *User) SetTOTPBackupCodes(codes []string) {
 	u.TOTPBackupCodes = pq.StringArray(codes)
 	u.UpdatedAt = time.Now()
@@ -317,6 +365,96 @@ func (u *User) CanLogin() bool {
 	return !u.Disabled && !u.IsDeleted()
 }
 
+// IsDeletionScheduled returns true if the user asked for their account to be deleted
+func (u *User) IsDeletionScheduled() bool {
+	return u.DeletionScheduledAt != nil
+}
+
+// GetDeletionScheduledAt returns when the account will be deleted, or the zero time
+func (u *User) GetDeletionScheduledAt() time.Time {
+	if u.DeletionScheduledAt == nil {
+		return time.Time{}
+	}
+	return *u.DeletionScheduledAt
+}
+
+// GetDeletionMode returns what happens to the user's pitches on deletion
+func (u *User) GetDeletionMode() string {
+	if u.DeletionMode == nil {
+		return ""
+	}
+	return *u.DeletionMode
+}
+
+// GetNIP05 returns the claimed NIP-05 identifier, or an empty string
+func (u *User) GetNIP05() string {
+	if u.NIP05 == nil {
+		return ""
+	}
+	return *u.NIP05
+}
+
+// IsNIP05Verified returns true if the claimed NIP-05 identifier points to one
+// of the user's Nostr keys
+func (u *User) IsNIP05Verified() bool {
+	return u.NIP05 != nil && u.NIP05VerifiedAt != nil
+}
+
+// HasNostrMetadata returns true if the user imported their Nostr profile
+func (u *User) HasNostrMetadata() bool {
+	return u.NostrMetadataAt != nil
+}
+
+// GetNostrMetadataAt returns when the imported Nostr profile was published,
+// or the zero time
+func (u *User) GetNostrMetadataAt() time.Time {
+	if u.NostrMetadataAt == nil {
+		return time.Time{}
+	}
+	return *u.NostrMetadataAt
+}
+
+// GetNostrName returns the imported Nostr name, or an empty string
+func (u *User) GetNostrName() string {
+	if u.NostrName == nil {
+		return ""
+	}
+	return *u.NostrName
+}
+
+// GetNostrAbout returns the imported Nostr bio, or an empty string
+func (u *User) GetNostrAbout() string {
+	if u.NostrAbout == nil {
+		return ""
+	}
+	return *u.NostrAbout
+}
+
+// GetNostrPicture returns the imported Nostr picture URL, or an empty string
+func (u *User) GetNostrPicture() string {
+	if u.NostrPicture == nil {
+		return ""
+	}
+	return *u.NostrPicture
+}
+
+// GetNostrLud16 returns the imported Lightning address, or an empty string
+func (u *User) GetNostrLud16() string {
+	if u.NostrLud16 == nil {
+		return ""
+	}
+	return *u.NostrLud16
+}
+
+// GetNostrNIP05 returns the NIP-05 identifier from the imported Nostr
+// profile, or an empty string
+func (u *User) GetNostrNIP05() string {
+	if u.NostrNIP05 == nil {
+		return ""
+	}
+	return *u.NostrNIP05
+}
+
 // IsVisible returns true if user should be visible in public lists
 func (u *User) IsVisible() bool {
 	return !u.Hidden && !u.IsDeleted()
@@ -326,9 +464,18 @@ func (u *User) IsVisible() bool {
 type Session struct {
 	BaseModel
 	UserID    uuid.UUID `json:"user_id" db:"user_id"`
-	Token     string    `json:"token" db:"token"`
+	Token     string    `json:"-" db:"token"` // SHA-256 digest of the cookie value
 	ExpiresAt time.Time `json:"expires_at" db:"expires_at"`
 	ReadOnly  bool      `json:"read_only" db:"read_only"`
+
+	// Token rotation
+	TokenRotatedAt *time.Time `json:"-" db:"token_rotated_at"`
+	PreviousToken  *string    `json:"-" db:"previous_token"`
+
+	// Device metadata
+	IPAddress  *string    `json:"ip_address,omitempty" db:"ip_address"`
+	UserAgent  *string    `json:"user_agent,omitempty" db:"user_agent"`
+	LastSeenAt *time.Time `json:"last_seen_at,omitempty" db:"last_seen_at"`
 }
 
 // NewSession creates a new session for a user
@@ -352,12 +499,99 @@ func (s *Session) IsExpired() bool {
 	return time.Now().After(s.ExpiresAt)
 }
 
+// GetTokenIssuedAt returns when the current session token was issued
+func (s *Session) GetTokenIssuedAt() time.Time {
+	if s.TokenRotatedAt != nil {
+		return *s.TokenRotatedAt
+	}
+	return s.CreatedAt
+}
+
+// SetDevice records the client that created the session
+func (s *Session) SetDevice(ipAddress, userAgent string) {
+	if ipAddress != "" {
+		s.IPAddress = &ipAddress
+	}
+	if userAgent != "" {
+		s.UserAgent = &userAgent
+	}
+	now := time.Now()
+	s.LastSeenAt = &now
+}
+
+// GetIPAddress returns the IP address that created the session
+func (s *Session) GetIPAddress() string {
+	if s.IPAddress != nil {
+		return *s.IPAddress
+	}
+	return ""
+}
+
+// GetLastSeen returns when the session was last used
+func (s *Session) GetLastSeen() time.Time {
+	if s.LastSeenAt != nil {
+		return *s.LastSeenAt
+	}
+	return s.CreatedAt
+}
+
+// DeviceName returns a short browser and OS description from the user agent
+func (s *Session) DeviceName() string {
+	if s.UserAgent == nil || *s.UserAgent == "" {
+		return "Unknown device"
+	}
+	ua := *s.UserAgent
+
+	browser := "Unknown browser"
+	switch {
+	case strings.Contains(ua, "Edg/"):
+		browser = "Edge"
+	case strings.Contains(ua, "OPR/") || strings.Contains(ua, "Opera"):
+		browser = "Opera"
+	case strings.Contains(ua, "Firefox/"):
+		browser = "Firefox"
+	case strings.Contains(ua, "Chrome/"):
+		browser = "Chrome"
+	case strings.Contains(ua, "Safari/"):
+		browser = "Safari"
+	case strings.Contains(ua, "curl/"):
+		browser = "curl"
+	}
+
+	platform := ""
+	switch {
+	case strings.Contains(ua, "Android"):
+		platform = "Android"
+	case strings.Contains(ua, "iPhone") || strings.Contains(ua, "iPad"):
+		platform = "iOS"
+	case strings.Contains(ua, "Windows"):
+		platform = "Windows"
+	case strings.Contains(ua, "Mac OS X") || strings.Contains(ua, "Macintosh"):
+		platform = "macOS"
+	case strings.Contains(ua, "Linux"):
+		platform = "Linux"
+	}
+
+	if platform == "" {
+		return browser
+	}
+	return browser + " on " + platform
+}
+
+// Email token purposes
+const (
+	EmailTokenPurposeVerify = "verify" // confirms the account's current address
+	EmailTokenPurposeChange = "change" // confirms a new address the user is switching to
+	EmailTokenPurposeRevert = "revert" // sent to the old address to undo a change
+)
+
 // EmailVerificationToken represents an email verification token
 type EmailVerificationToken struct {
 	BaseModel
 	UserID    uuid.UUID `json:"user_id" db:"user_id"`
 	Token     string    `json:"token" db:"token"`
 	Email     string    `json:"email" db:"email"`
+	Purpose   string    `json:"purpose" db:"purpose"`
 	ExpiresAt time.Time `json:"expires_at" db:"expires_at"`
 	Used      bool      `json:"used" db:"used"`
 }
@@ -374,11 +608,20 @@ func NewEmailVerificationToken(userID uuid.UUID, token, email string, expiresAt
 		UserID:    userID,
 		Token:     token,
 		Email:     email,
+		Purpose:   EmailTokenPurposeVerify,
 		ExpiresAt: expiresAt,
 		Used:      false,
 	}
 }
 
+// NewEmailChangeToken creates a token for the change or revert step of an
+// email address change; email is the address the account switches to
+func NewEmailChangeToken(userID uuid.UUID, token, email, purpose string, expiresAt time.Time) *EmailVerificationToken {
+	t := NewEmailVerificationToken(userID, token, email, expiresAt)
+	t.Purpose = purpose
+	return t
+}
+
 // IsExpired checks if the token has expired
 func (t *EmailVerificationToken) IsExpired() bool {
 	return time.Now().After(t.ExpiresAt)
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserIdentity is one login method attached to an account. A user's own
+// auth_type/auth_id is their primary identity; others can be linked later.
+type UserIdentity struct {
+	BaseModel
+	UserID   uuid.UUID `json:"user_id" db:"user_id"`
+	AuthType AuthType  `json:"auth_type" db:"auth_type"`
+	AuthID   string    `json:"auth_id" db:"auth_id"`
+}
+
+// NewUserIdentity creates a new identity for a user
+func NewUserIdentity(userID uuid.UUID, authType AuthType, authID string) *UserIdentity {
+	now := time.Now()
+	return &UserIdentity{
+		BaseModel: BaseModel{
+			ID:        uuid.New(),
+			CreatedAt: now,
+			UpdatedAt: now,
+		},
+		UserID:   userID,
+		AuthType: authType,
+		AuthID:   authID,
+	}
+}
+
+// IsPrimaryFor checks if this identity is the user's primary login
+func (i *UserIdentity) IsPrimaryFor(user *User) bool {
+	return user != nil && i.AuthType == user.AuthType && i.AuthID == user.AuthID
+}
+
+// GetDisplayID returns a shortened auth ID that is safe to show on the profile
+func (i *UserIdentity) GetDisplayID() string {
+	switch i.AuthType {
+	case AuthTypeEmail, AuthTypeTrezor:
+		return i.AuthID
+	default:
+		if len(i.AuthID) > 16 {
+			return i.AuthID[:8] + "…" + i.AuthID[len(i.AuthID)-8:]
+		}
+		return i.AuthID
+	}
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WebAuthnCredential is a passkey or security key registered to a user
+type WebAuthnCredential struct {
+	BaseModel
+	UserID         uuid.UUID  `json:"user_id" db:"user_id"`
+	Name           string     `json:"name" db:"name"`
+	CredentialID   []byte     `json:"-" db:"credential_id"`
+	PublicKey      []byte     `json:"-" db:"public_key"`
+	Algorithm      int        `json:"algorithm" db:"algorithm"`
+	SignCount      int64      `json:"-" db:"sign_count"`
+	AAGUID         *string    `json:"aaguid,omitempty" db:"aaguid"`
+	BackupEligible bool       `json:"backup_eligible" db:"backup_eligible"`
+	LastUsedAt     *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+}
+
+// NewWebAuthnCredential creates a new credential record from a verified registration
+func NewWebAuthnCredential(userID uuid.UUID, name string, credentialID, publicKey []byte, algorithm int, signCount uint32) *WebAuthnCredential {
+	now := time.Now()
+	return &WebAuthnCredential{
+		BaseModel: BaseModel{
+			ID:        uuid.New(),
+			CreatedAt: now,
+			UpdatedAt: now,
+		},
+		UserID:       userID,
+		Name:         name,
+		CredentialID: credentialID,
+		PublicKey:    publicKey,
+		Algorithm:    algorithm,
+		SignCount:    int64(signCount),
+	}
+}
+
+// IsSynced reports whether the credential can be backed up, i.e. is a synced passkey
+func (c *WebAuthnCredential) IsSynced() bool {
+	return c.BackupEligible
+}
+
+// GetLastUsed returns when the credential was last used, or the zero time if never
+func (c *WebAuthnCredential) GetLastUsed() time.Time {
+	if c.LastUsedAt == nil {
+		return time.Time{}
+	}
+	return *c.LastUsedAt
+}
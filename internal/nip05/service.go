@@ -0,0 +1,232 @@
+package nip05
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"bitcoinpitch.org/internal/config"
+	"bitcoinpitch.org/internal/crypto"
+	"bitcoinpitch.org/internal/database"
+	"bitcoinpitch.org/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// recheckBatchSize limits how many identifiers one recheck run looks up
+const recheckBatchSize = 100
+
+// Repository interface for NIP-05 database operations
+type Repository interface {
+	GetUserByID(ctx context.Context, userID uuid.UUID) (*models.User, error)
+	GetNIP05Lookup(ctx context.Context, identifier string) (*models.NIP05Lookup, error)
+	SaveNIP05Lookup(ctx context.Context, lookup *models.NIP05Lookup) error
+	GetStaleNIP05Identifiers(ctx context.Context, checkedBefore time.Time, limit int) ([]string, error)
+	GetNostrPubkeys(ctx context.Context, userID uuid.UUID) ([]string, error)
+	GetUserIDsByNIP05(ctx context.Context, identifier string) ([]uuid.UUID, error)
+	GetPitchesByAuthorNIP05(ctx context.Context, identifier string) ([]*models.Pitch, error)
+	SetUserNIP05(ctx context.Context, userID uuid.UUID, identifier *string, verifiedAt *time.Time) error
+	SetUserNIP05Verified(ctx context.Context, userID uuid.UUID, identifier string, verifiedAt *time.Time) error
+	SetPitchAuthorNIP05Verified(ctx context.Context, pitchID uuid.UUID, identifier string, verifiedAt *time.Time) error
+}
+
+// Service verifies NIP-05 identifiers claimed by users and pitch authors.
+// Lookups are cached in the database and shared by every claim of the same
+// identifier.
+type Service struct {
+	repo          Repository
+	configService *config.Service
+	resolver      crypto.NIP05Resolver
+}
+
+// NewService creates a new NIP-05 service. A nil resolver uses HTTPS with
+// the configured timeout.
+func NewService(repo Repository, configService *config.Service, resolver crypto.NIP05Resolver) *Service {
+	if resolver == nil {
+		timeout := configService.GetInt(context.Background(), "users.nip05_timeout_seconds", 5)
+		resolver = crypto.NewNIP05Resolver(time.Duration(timeout) * time.Second)
+	}
+	return &Service{
+		repo:          repo,
+		configService: configService,
+		resolver:      resolver,
+	}
+}
+
+// ClaimForUser sets the NIP-05 identifier a user claims and checks it right
+// away against the user's linked Nostr keys. An empty identifier clears the
+// claim. The claim is kept even if it doesn't verify yet, so a nostr.json
+// published later is picked up by the next recheck.
+func (s *Service) ClaimForUser(ctx context.Context, userID uuid.UUID, identifier string) (bool, error) {
+	if identifier == "" {
+		return false, s.repo.SetUserNIP05(ctx, userID, nil, nil)
+	}
+
+	lookup, err := s.lookup(ctx, identifier, true)
+	if err != nil {
+		return false, err
+	}
+	verifiedAt, err := s.userVerifiedAt(ctx, userID, lookup, nil)
+	if err != nil {
+		return false, err
+	}
+	if err := s.repo.SetUserNIP05(ctx, userID, &identifier, verifiedAt); err != nil {
+		return false, err
+	}
+	return verifiedAt != nil, nil
+}
+
+// RefreshUser re-evaluates a user's claim against the cached lookup, for
+// when the user's Nostr keys changed
+func (s *Service) RefreshUser(ctx context.Context, user *models.User) error {
+	if user.NIP05 == nil {
+		return nil
+	}
+	lookup, err := s.lookup(ctx, *user.NIP05, false)
+	if err != nil {
+		return err
+	}
+	verifiedAt, err := s.userVerifiedAt(ctx, user.ID, lookup, user.NIP05VerifiedAt)
+	if err != nil {
+		return err
+	}
+	return s.repo.SetUserNIP05Verified(ctx, user.ID, *user.NIP05, verifiedAt)
+}
+
+// VerifyPitchAuthor checks the NIP-05 identifier claimed for a pitch's Nostr
+// author against its npub, using the cached lookup while it is fresh
+func (s *Service) VerifyPitchAuthor(ctx context.Context, pitch *models.Pitch) error {
+	if pitch.AuthorNIP05 == nil {
+		return nil
+	}
+	lookup, err := s.lookup(ctx, *pitch.AuthorNIP05, false)
+	if err != nil {
+		return err
+	}
+	return s.repo.SetPitchAuthorNIP05Verified(ctx, pitch.ID, *pitch.AuthorNIP05, s.pitchVerifiedAt(ctx, pitch, lookup))
+}
+
+// RecheckStale looks up identifiers whose cached result is older than the
+// recheck interval and updates every claim of them
+func (s *Service) RecheckStale(ctx context.Context) error {
+	cutoff := time.Now().Add(-s.recheckInterval(ctx))
+	identifiers, err := s.repo.GetStaleNIP05Identifiers(ctx, cutoff, recheckBatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, identifier := range identifiers {
+		lookup, err := s.lookup(ctx, identifier, true)
+		if err != nil {
+			log.Printf("[ERROR] RecheckStale: %s: %v", identifier, err)
+			continue
+		}
+
+		userIDs, err := s.repo.GetUserIDsByNIP05(ctx, identifier)
+		if err != nil {
+			return err
+		}
+		for _, userID := range userIDs {
+			user, err := s.repo.GetUserByID(ctx, userID)
+			if err != nil {
+				continue
+			}
+			verifiedAt, err := s.userVerifiedAt(ctx, userID, lookup, user.NIP05VerifiedAt)
+			if err != nil {
+				return err
+			}
+			if err := s.repo.SetUserNIP05Verified(ctx, userID, identifier, verifiedAt); err != nil {
+				return err
+			}
+		}
+
+		pitches, err := s.repo.GetPitchesByAuthorNIP05(ctx, identifier)
+		if err != nil {
+			return err
+		}
+		for _, pitch := range pitches {
+			if err := s.repo.SetPitchAuthorNIP05Verified(ctx, pitch.ID, identifier, s.pitchVerifiedAt(ctx, pitch, lookup)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// lookup returns the cached lookup of an identifier, fetching nostr.json if
+// forced, never fetched or older than the recheck interval. Unreachable
+// domains are recorded without forgetting the last pubkey they listed.
+func (s *Service) lookup(ctx context.Context, identifier string, force bool) (*models.NIP05Lookup, error) {
+	now := time.Now()
+
+	lookup, err := s.repo.GetNIP05Lookup(ctx, identifier)
+	switch {
+	case err == database.ErrNotFound:
+		lookup = &models.NIP05Lookup{Identifier: identifier}
+	case err != nil:
+		return nil, err
+	case !force && now.Sub(lookup.CheckedAt) < s.recheckInterval(ctx):
+		return lookup, nil
+	}
+
+	pubkey, err := s.resolver.ResolveNIP05(ctx, identifier)
+	lookup.CheckedAt = now
+	switch {
+	case err == nil:
+		lookup.Pubkey = &pubkey
+		lookup.ResolvedAt = &now
+		lookup.LastError = nil
+	case errors.Is(err, crypto.ErrNIP05NameNotFound):
+		msg := err.Error()
+		lookup.Pubkey = nil
+		lookup.ResolvedAt = &now
+		lookup.LastError = &msg
+	default:
+		msg := err.Error()
+		lookup.LastError = &msg
+	}
+
+	if err := s.repo.SaveNIP05Lookup(ctx, lookup); err != nil {
+		return nil, err
+	}
+	return lookup, nil
+}
+
+// userVerifiedAt returns when a user's claim became verified, keeping the
+// earlier time if it already was, or nil if it doesn't verify
+func (s *Service) userVerifiedAt(ctx context.Context, userID uuid.UUID, lookup *models.NIP05Lookup, previous *time.Time) (*time.Time, error) {
+	pubkeys, err := s.repo.GetNostrPubkeys(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.verifiedAt(ctx, lookup, pubkeys, previous), nil
+}
+
+// pitchVerifiedAt is userVerifiedAt for a pitch's Nostr author
+func (s *Service) pitchVerifiedAt(ctx context.Context, pitch *models.Pitch, lookup *models.NIP05Lookup) *time.Time {
+	if pitch.AuthorType != models.AuthorTypeNostr {
+		return nil
+	}
+	pubkey, err := crypto.NpubToHex(pitch.GetAuthorHandle())
+	if err != nil {
+		return nil
+	}
+	return s.verifiedAt(ctx, lookup, []string{pubkey}, pitch.AuthorNIP05VerifiedAt)
+}
+
+func (s *Service) verifiedAt(ctx context.Context, lookup *models.NIP05Lookup, pubkeys []string, previous *time.Time) *time.Time {
+	now := time.Now()
+	grace := time.Duration(s.configService.GetInt(ctx, "users.nip05_grace_hours", 168)) * time.Hour
+	if !lookup.PointsTo(pubkeys, now, grace) {
+		return nil
+	}
+	if previous != nil {
+		return previous
+	}
+	return &now
+}
+
+func (s *Service) recheckInterval(ctx context.Context) time.Duration {
+	return time.Duration(s.configService.GetInt(ctx, "users.nip05_recheck_hours", 24)) * time.Hour
+}
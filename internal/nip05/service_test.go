@@ -0,0 +1,334 @@
+package nip05
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"bitcoinpitch.org/internal/config"
+	"bitcoinpitch.org/internal/crypto"
+	"bitcoinpitch.org/internal/database"
+	"bitcoinpitch.org/internal/models"
+
+	"github.com/google/uuid"
+)
+
+const (
+	testPubkey  = "b0635d6a9851d3aed0cd6c495b282167acf761729078d975fc341b22650b07b9"
+	otherPubkey = "32e1827635450ebb3c5a7d12c1f8e7b2b514439ac10a67eef3d9fd9c5c68e245"
+)
+
+// fakeRepo keeps lookups and claims in memory
+type fakeRepo struct {
+	Repository
+	lookups map[string]*models.NIP05Lookup
+	saves   int
+	pubkeys map[uuid.UUID][]string
+	claims  map[uuid.UUID]*time.Time
+}
+
+func newFakeRepo() *fakeRepo {
+	return &fakeRepo{
+		lookups: make(map[string]*models.NIP05Lookup),
+		pubkeys: make(map[uuid.UUID][]string),
+		claims:  make(map[uuid.UUID]*time.Time),
+	}
+}
+
+func (r *fakeRepo) GetNIP05Lookup(ctx context.Context, identifier string) (*models.NIP05Lookup, error) {
+	lookup, ok := r.lookups[identifier]
+	if !ok {
+		return nil, database.ErrNotFound
+	}
+	copied := *lookup
+	return &copied, nil
+}
+
+func (r *fakeRepo) SaveNIP05Lookup(ctx context.Context, lookup *models.NIP05Lookup) error {
+	copied := *lookup
+	r.lookups[lookup.Identifier] = &copied
+	r.saves++
+	return nil
+}
+
+func (r *fakeRepo) GetNostrPubkeys(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	return r.pubkeys[userID], nil
+}
+
+func (r *fakeRepo) SetUserNIP05(ctx context.Context, userID uuid.UUID, identifier *string, verifiedAt *time.Time) error {
+	r.claims[userID] = verifiedAt
+	return nil
+}
+
+// fakeResolver answers every lookup with the same result
+type fakeResolver struct {
+	pubkey string
+	err    error
+	calls  int
+}
+
+func (r *fakeResolver) ResolveNIP05(ctx context.Context, identifier string) (string, error) {
+	r.calls++
+	return r.pubkey, r.err
+}
+
+// fakeConfig serves integer settings; anything else falls back to defaults
+type fakeConfig struct {
+	config.ConfigRepository
+	values map[string]int
+}
+
+func (c *fakeConfig) GetConfigSetting(ctx context.Context, key string) (*models.ConfigSetting, error) {
+	value, ok := c.values[key]
+	if !ok {
+		return nil, database.ErrNotFound
+	}
+	return &models.ConfigSetting{Key: key, Value: strconv.Itoa(value), DataType: models.ConfigDataTypeInteger}, nil
+}
+
+func newTestService(repo *fakeRepo, resolver *fakeResolver, settings map[string]int) *Service {
+	return NewService(repo, config.NewService(&fakeConfig{values: settings}), resolver)
+}
+
+func TestLookupCache(t *testing.T) {
+	const identifier = "bob@example.com"
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		cached    *models.NIP05Lookup
+		force     bool
+		wantFetch bool
+	}{
+		{
+			name:      "not cached",
+			wantFetch: true,
+		},
+		{
+			name:   "fresh",
+			cached: &models.NIP05Lookup{Identifier: identifier, Pubkey: strPtr(otherPubkey), CheckedAt: time.Now().Add(-time.Hour)},
+		},
+		{
+			name:      "fresh but forced",
+			cached:    &models.NIP05Lookup{Identifier: identifier, Pubkey: strPtr(otherPubkey), CheckedAt: time.Now().Add(-time.Hour)},
+			force:     true,
+			wantFetch: true,
+		},
+		{
+			name:      "stale",
+			cached:    &models.NIP05Lookup{Identifier: identifier, Pubkey: strPtr(otherPubkey), CheckedAt: time.Now().Add(-25 * time.Hour)},
+			wantFetch: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeRepo()
+			if tt.cached != nil {
+				repo.lookups[identifier] = tt.cached
+			}
+			resolver := &fakeResolver{pubkey: testPubkey}
+			s := newTestService(repo, resolver, nil)
+
+			lookup, err := s.lookup(ctx, identifier, tt.force)
+			if err != nil {
+				t.Fatalf("lookup: %v", err)
+			}
+
+			if fetched := resolver.calls > 0; fetched != tt.wantFetch {
+				t.Fatalf("fetched = %v, want %v", fetched, tt.wantFetch)
+			}
+			if !tt.wantFetch {
+				if repo.saves != 0 || *lookup.Pubkey != otherPubkey {
+					t.Errorf("fresh lookup was not served from the cache")
+				}
+				return
+			}
+			if repo.saves != 1 {
+				t.Errorf("saved %d times, want 1", repo.saves)
+			}
+			if lookup.Pubkey == nil || *lookup.Pubkey != testPubkey || lookup.ResolvedAt == nil || lookup.LastError != nil {
+				t.Errorf("lookup = %+v, want it resolved to %s", lookup, testPubkey)
+			}
+		})
+	}
+}
+
+func TestLookupRecheckInterval(t *testing.T) {
+	repo := newFakeRepo()
+	repo.lookups["bob@example.com"] = &models.NIP05Lookup{Identifier: "bob@example.com", CheckedAt: time.Now().Add(-2 * time.Hour)}
+	resolver := &fakeResolver{pubkey: testPubkey}
+	s := newTestService(repo, resolver, map[string]int{"users.nip05_recheck_hours": 1})
+
+	if _, err := s.lookup(context.Background(), "bob@example.com", false); err != nil {
+		t.Fatalf("lookup: %v", err)
+	}
+	if resolver.calls != 1 {
+		t.Errorf("a lookup older than the configured interval was not refetched")
+	}
+}
+
+func TestLookupFailures(t *testing.T) {
+	resolvedAt := time.Now().Add(-48 * time.Hour)
+	cached := func() *models.NIP05Lookup {
+		return &models.NIP05Lookup{
+			Identifier: "bob@example.com",
+			Pubkey:     strPtr(testPubkey),
+			ResolvedAt: timePtr(resolvedAt),
+			CheckedAt:  resolvedAt,
+		}
+	}
+
+	t.Run("unreachable domain keeps the last pubkey", func(t *testing.T) {
+		repo := newFakeRepo()
+		repo.lookups["bob@example.com"] = cached()
+		s := newTestService(repo, &fakeResolver{err: errors.New("connection refused")}, nil)
+
+		lookup, err := s.lookup(context.Background(), "bob@example.com", true)
+		if err != nil {
+			t.Fatalf("lookup: %v", err)
+		}
+		if lookup.Pubkey == nil || *lookup.Pubkey != testPubkey || !lookup.ResolvedAt.Equal(resolvedAt) {
+			t.Errorf("lookup = %+v, want the last resolved pubkey kept", lookup)
+		}
+		if lookup.LastError == nil || !lookup.CheckedAt.After(resolvedAt) {
+			t.Errorf("lookup = %+v, want the failed check recorded", lookup)
+		}
+	})
+
+	t.Run("name removed forgets the pubkey", func(t *testing.T) {
+		repo := newFakeRepo()
+		repo.lookups["bob@example.com"] = cached()
+		s := newTestService(repo, &fakeResolver{err: crypto.ErrNIP05NameNotFound}, nil)
+
+		lookup, err := s.lookup(context.Background(), "bob@example.com", true)
+		if err != nil {
+			t.Fatalf("lookup: %v", err)
+		}
+		if lookup.Pubkey != nil || !lookup.ResolvedAt.After(resolvedAt) || lookup.LastError == nil {
+			t.Errorf("lookup = %+v, want the pubkey cleared", lookup)
+		}
+	})
+}
+
+func TestVerifiedAt(t *testing.T) {
+	now := time.Now()
+	previous := now.Add(-30 * 24 * time.Hour)
+
+	tests := []struct {
+		name       string
+		lookup     models.NIP05Lookup
+		pubkeys    []string
+		previous   *time.Time
+		settings   map[string]int
+		wantNil    bool
+		wantResult *time.Time
+	}{
+		{
+			name:    "matching key",
+			lookup:  models.NIP05Lookup{Pubkey: strPtr(testPubkey), ResolvedAt: timePtr(now)},
+			pubkeys: []string{otherPubkey, testPubkey},
+		},
+		{
+			name:       "keeps the earlier verification",
+			lookup:     models.NIP05Lookup{Pubkey: strPtr(testPubkey), ResolvedAt: timePtr(now)},
+			pubkeys:    []string{testPubkey},
+			previous:   &previous,
+			wantResult: &previous,
+		},
+		{
+			name:    "other key",
+			lookup:  models.NIP05Lookup{Pubkey: strPtr(otherPubkey), ResolvedAt: timePtr(now)},
+			pubkeys: []string{testPubkey},
+			wantNil: true,
+		},
+		{
+			name:    "name not listed",
+			lookup:  models.NIP05Lookup{ResolvedAt: timePtr(now)},
+			pubkeys: []string{testPubkey},
+			wantNil: true,
+		},
+		{
+			name:       "unreachable within the grace period",
+			lookup:     models.NIP05Lookup{Pubkey: strPtr(testPubkey), ResolvedAt: timePtr(now.Add(-100 * time.Hour))},
+			pubkeys:    []string{testPubkey},
+			previous:   &previous,
+			wantResult: &previous,
+		},
+		{
+			name:     "unreachable past the grace period",
+			lookup:   models.NIP05Lookup{Pubkey: strPtr(testPubkey), ResolvedAt: timePtr(now.Add(-200 * time.Hour))},
+			pubkeys:  []string{testPubkey},
+			previous: &previous,
+			wantNil:  true,
+		},
+		{
+			name:     "configured grace period",
+			lookup:   models.NIP05Lookup{Pubkey: strPtr(testPubkey), ResolvedAt: timePtr(now.Add(-2 * time.Hour))},
+			pubkeys:  []string{testPubkey},
+			previous: &previous,
+			settings: map[string]int{"users.nip05_grace_hours": 1},
+			wantNil:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(newFakeRepo(), &fakeResolver{}, tt.settings)
+			got := s.verifiedAt(context.Background(), &tt.lookup, tt.pubkeys, tt.previous)
+
+			switch {
+			case tt.wantNil:
+				if got != nil {
+					t.Errorf("verifiedAt = %v, want nil", got)
+				}
+			case got == nil:
+				t.Errorf("verifiedAt = nil, want a time")
+			case tt.wantResult != nil && !got.Equal(*tt.wantResult):
+				t.Errorf("verifiedAt = %v, want %v", got, tt.wantResult)
+			case tt.wantResult == nil && got.Before(now):
+				t.Errorf("verifiedAt = %v, want the current time", got)
+			}
+		})
+	}
+}
+
+func TestClaimForUser(t *testing.T) {
+	userID := uuid.New()
+	repo := newFakeRepo()
+	repo.pubkeys[userID] = []string{testPubkey}
+	// A fresh cached result must not stop a new claim from being checked
+	repo.lookups["bob@example.com"] = &models.NIP05Lookup{Identifier: "bob@example.com", CheckedAt: time.Now()}
+	resolver := &fakeResolver{pubkey: testPubkey}
+	s := newTestService(repo, resolver, nil)
+
+	verified, err := s.ClaimForUser(context.Background(), userID, "bob@example.com")
+	if err != nil {
+		t.Fatalf("ClaimForUser: %v", err)
+	}
+	if !verified || repo.claims[userID] == nil {
+		t.Errorf("ClaimForUser = %v, want the claim verified", verified)
+	}
+	if resolver.calls != 1 {
+		t.Errorf("resolver called %d times, want 1", resolver.calls)
+	}
+
+	resolver.pubkey = otherPubkey
+	verified, err = s.ClaimForUser(context.Background(), userID, "bob@example.com")
+	if err != nil {
+		t.Fatalf("ClaimForUser: %v", err)
+	}
+	if verified || repo.claims[userID] != nil {
+		t.Errorf("ClaimForUser = %v, want a claim of another key unverified", verified)
+	}
+}
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
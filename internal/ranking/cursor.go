@@ -0,0 +1,49 @@
+package ranking
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrInvalidCursor is returned for cursor tokens that cannot be decoded
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor is a position in a ranked listing: the sort key, creation time and
+// id of the pitch at the edge of a page. Listings continue below it, or above
+// it when Before is set. Listing identifies the filters the cursor was made
+// for, since a position means nothing in a differently filtered listing.
+type Cursor struct {
+	Sort      string    `json:"s"`
+	Key       *float64  `json:"k,omitempty"`
+	CreatedAt time.Time `json:"t"`
+	ID        uuid.UUID `json:"i"`
+	Before    bool      `json:"b,omitempty"`
+	Listing   string    `json:"l,omitempty"`
+}
+
+// Encode returns the cursor as an opaque URL-safe token
+func (c *Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses a token made by Encode
+func DecodeCursor(token string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor Cursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	mode, ok := Get(cursor.Sort)
+	if !ok || (mode.Key != "") != (cursor.Key != nil) || cursor.ID == uuid.Nil {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
@@ -0,0 +1,121 @@
+// Package ranking defines the orders pitch listings can be sorted in
+package ranking
+
+import "time"
+
+// Ranking modes
+const (
+	Hot           = "hot"
+	New           = "new"
+	Top           = "top"
+	Controversial = "controversial"
+	Wilson        = "wilson"
+)
+
+// Periods the top mode can be limited to
+const (
+	PeriodDay   = "day"
+	PeriodWeek  = "week"
+	PeriodMonth = "month"
+	PeriodAll   = "all"
+)
+
+// Mode is a way of ordering pitches
+type Mode struct {
+	Name string
+	// Key is the SQL expression over the pitches table aliased as p that the
+	// mode ranks by, highest first, and KeyType is its SQL type. Ties go to
+	// the newest pitch. New ranks by creation time alone and has no key.
+	Key     string
+	KeyType string
+}
+
+var modes = []Mode{
+	{
+		Name:    Hot,
+		Key:     "pitch_hot_rank(p.score, COALESCE(p.last_vote_at, p.created_at))",
+		KeyType: "double precision",
+	},
+	{
+		Name: New,
+	},
+	{
+		Name:    Top,
+		Key:     "p.score",
+		KeyType: "integer",
+	},
+	{
+		Name:    Controversial,
+		Key:     "pitch_controversy(p.upvote_count, p.downvote_count)",
+		KeyType: "double precision",
+	},
+	{
+		Name:    Wilson,
+		Key:     "pitch_wilson_lower_bound(p.upvote_count, p.downvote_count)",
+		KeyType: "double precision",
+	},
+}
+
+// OrderBy returns the ORDER BY clause for the mode, best first or, when
+// reversed, worst first. The id breaks any remaining tie so every pitch has
+// an exact position for cursors. Each mode has a matching index on pitches.
+func (m Mode) OrderBy(reverse bool) string {
+	dir := "DESC"
+	if reverse {
+		dir = "ASC"
+	}
+	order := "p.created_at " + dir + ", p.id " + dir
+	if m.Key != "" {
+		order = m.Key + " " + dir + ", " + order
+	}
+	return order
+}
+
+// Modes returns every ranking mode
+func Modes() []Mode {
+	return modes
+}
+
+// Get returns the ranking mode with the given name
+func Get(name string) (Mode, bool) {
+	for _, mode := range modes {
+		if mode.Name == name {
+			return mode, true
+		}
+	}
+	return Mode{}, false
+}
+
+// IsValid checks if a ranking mode is known
+func IsValid(name string) bool {
+	_, ok := Get(name)
+	return ok
+}
+
+// Periods returns the periods the top mode can be limited to, shortest first
+func Periods() []string {
+	return []string{PeriodDay, PeriodWeek, PeriodMonth, PeriodAll}
+}
+
+// IsValidPeriod checks if a top period is known
+func IsValidPeriod(period string) bool {
+	switch period {
+	case PeriodDay, PeriodWeek, PeriodMonth, PeriodAll:
+		return true
+	}
+	return false
+}
+
+// Since returns the earliest creation time of pitches in a period ending at
+// now. The zero time means all pitches.
+func Since(period string, now time.Time) time.Time {
+	switch period {
+	case PeriodDay:
+		return now.AddDate(0, 0, -1)
+	case PeriodWeek:
+		return now.AddDate(0, 0, -7)
+	case PeriodMonth:
+		return now.AddDate(0, -1, 0)
+	}
+	return time.Time{}
+}
@@ -1,6 +1,7 @@
 package routes
 
 import (
+	"net/url"
 	"strings"
 
 	"bitcoinpitch.org/internal/auth"
@@ -30,6 +31,15 @@ func SetupRoutes(app *fiber.App, view *jet.Set, repo *database.Repository, confi
 	// Initialize admin handler
 	adminHandler := handlers.NewAdminHandler(configService, repo)
 
+	// Login challenges for signature-based authentication (Trezor, Nostr)
+	challengeStore := auth.NewChallengeStore(auth.ChallengeDomainFromEnv(), auth.DefaultChallengeTTL)
+
+	// Logins awaiting a second factor
+	pendingLoginStore := auth.NewPendingLoginStore(auth.DefaultPendingLoginTTL, auth.DefaultPendingLoginAttempts)
+
+	// Lightning wallet logins waiting for the LNURL-auth callback
+	lnurlAuthStore := auth.NewLNURLAuthStore(auth.DefaultLNURLAuthTTL)
+
 	// Ensure Jet view is always set in context for every request
 	app.Use(func(c *fiber.Ctx) error {
 		c.Locals("view", view)
@@ -48,6 +58,14 @@ func SetupRoutes(app *fiber.App, view *jet.Set, repo *database.Repository, confi
 		return c.Next()
 	})
 
+	// Set login challenge, pending login and LNURL-auth stores in context for auth handlers
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("challengeStore", challengeStore)
+		c.Locals("pendingLoginStore", pendingLoginStore)
+		c.Locals("lnurlAuthStore", lnurlAuthStore)
+		return c.Next()
+	})
+
 	// DB health check endpoint
 	app.Get("/api/health/db", func(c *fiber.Ctx) error {
 		ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
@@ -92,15 +110,15 @@ func SetupRoutes(app *fiber.App, view *jet.Set, repo *database.Repository, confi
 	// Health check endpoints (no auth required)
 	app.Get("/health", middleware.HealthCheck)
 
+	// NIP-05 identifiers for users who publish their username
+	app.Get("/.well-known/nostr.json", handlers.NostrWellKnownHandler)
+
 	// Static files
 	app.Static("/static", "./static")
 
 	// Public routes (no auth required)
 	public := app.Group("/")
 	public.Get("/", handlers.HomeHandler)
-	public.Get("/bitcoin", handlers.PitchListHandler)
-	public.Get("/lightning", handlers.PitchListHandler)
-	public.Get("/cashu", handlers.PitchListHandler)
 
 	// Language switching routes
 	app.Get("/lang/:lang", func(c *fiber.Ctx) error {
@@ -152,20 +170,27 @@ func SetupRoutes(app *fiber.App, view *jet.Set, repo *database.Repository, confi
 	})
 
 	// TEMP: Register /pitch/form as a top-level route to bypass /pitch group middleware
-	app.Get("/pitch/form", handlers.PitchFormHandler)
+	app.Get("/pitch/form", middleware.RequireWriteSession(), handlers.PitchFormHandler)
 
 	// Parameterized route MUST come after specific routes
 	public.Get("/pitch/:id", handlers.PitchViewHandler)
 	public.Get("/p/:id", handlers.PitchShareHandler) // Clean share URL
+	public.Get("/u/:id", handlers.PublicProfileHandler)
+	public.Get("/invite/:code", handlers.InviteLinkHandler)
+
+	// Read-only sessions may browse but not change anything
+	requireWrite := middleware.RequireWriteSession()
 
 	// Pitch routes (auth required for create/edit/delete)
 	pitches := app.Group("/pitch")
-	pitches.Get("/add", handlers.PitchFormHandler)       // Show form
-	pitches.Post("/add", handlers.PitchAddHandler)       // Create pitch
-	pitches.Get("/:id/edit", handlers.PitchEditHandler)  // Show edit form
-	pitches.Post("/:id/edit", handlers.PitchEditHandler) // Update pitch (edit)
-	pitches.Delete("/:id", handlers.PitchDeleteHandler)
-	pitches.Post("/:id/vote", handlers.PitchVoteHandler) // Vote on pitch (HTMX)
+	pitches.Get("/add", requireWrite, handlers.PitchFormHandler)       // Show form
+	pitches.Post("/add", requireWrite, handlers.PitchAddHandler)       // Create pitch
+	pitches.Get("/:id/edit", requireWrite, handlers.PitchEditHandler)  // Show edit form
+	pitches.Post("/:id/edit", requireWrite, handlers.PitchEditHandler) // Update pitch (edit)
+	pitches.Delete("/:id", requireWrite, handlers.PitchDeleteHandler)
+	pitches.Post("/:id/vote", requireWrite, handlers.PitchVoteHandler) // Vote on pitch (HTMX)
+	pitches.Get("/:id/history", handlers.PitchHistoryHandler)
+	pitches.Post("/:id/history/:revision/restore", requireWrite, handlers.PitchRestoreRevisionHandler)
 	pitches.Get("/delete-confirm", func(c *fiber.Ctx) error {
 		println("[DEBUG] Route matched: /pitch/delete-confirm")
 		return handlers.PitchDeleteConfirmHandler(c)
@@ -174,7 +199,7 @@ func SetupRoutes(app *fiber.App, view *jet.Set, repo *database.Repository, confi
 		println("[DEBUG] Route matched: /pitch/:idOrAny/delete-confirm with idOrAny =", c.Params("idOrAny"))
 		return handlers.PitchDeleteConfirmHandler(c)
 	})
-	pitches.Post("/:id/delete", handlers.PitchDeleteHandler)
+	pitches.Post("/:id/delete", requireWrite, handlers.PitchDeleteHandler)
 
 	// Catch-all for any unmatched /pitch/* route (for modal/HTMX 404s) - MUST BE LAST
 	// TEMP: Commented out to test if this is interfering with delete-confirm routes
@@ -183,10 +208,23 @@ func SetupRoutes(app *fiber.App, view *jet.Set, repo *database.Repository, confi
 	// Authentication routes
 	authGroup := app.Group("/auth")
 	authGroup.Get("/login", handlers.AuthLoginHandler)
+	authGroup.Get("/challenge", handlers.AuthChallengeHandler)
 	authGroup.Post("/password", handlers.AuthPasswordHandler)
 	authGroup.Post("/trezor", handlers.AuthTrezorHandler)
 	authGroup.Post("/nostr", handlers.AuthNostrHandler)
 	authGroup.Post("/nostr-manual", handlers.AuthNostrManualHandler)
+	authGroup.Post("/nostr-npub", handlers.AuthNostrNpubHandler)
+	authGroup.Get("/lnurl", handlers.AuthLNURLHandler)
+	authGroup.Get("/lnurl/callback", handlers.AuthLNURLCallbackHandler)
+	authGroup.Get("/lnurl/status", handlers.AuthLNURLStatusHandler)
+	authGroup.Get("/step-up", handlers.AuthStepUpPageHandler)
+	authGroup.Post("/step-up", handlers.AuthStepUpHandler)
+	authGroup.Get("/2fa", handlers.AuthSecondFactorPageHandler)
+	authGroup.Post("/2fa", totpHandler.CompleteLogin)
+	authGroup.Get("/2fa/webauthn", handlers.AuthWebAuthnSecondFactorOptionsHandler)
+	authGroup.Post("/2fa/webauthn", handlers.AuthWebAuthnSecondFactorHandler)
+	authGroup.Get("/webauthn/options", handlers.AuthWebAuthnOptionsHandler)
+	authGroup.Post("/webauthn/login", handlers.AuthWebAuthnLoginHandler)
 	// authGroup.Post("/twitter", handlers.AuthTwitterHandler) // DISABLED
 	authGroup.Post("/logout", handlers.AuthLogoutHandler)
 
@@ -194,6 +232,13 @@ func SetupRoutes(app *fiber.App, view *jet.Set, repo *database.Repository, confi
 	app.Get("/register", handlers.RegisterPageHandler)
 	authGroup.Post("/register", handlers.RegisterHandler)
 	authGroup.Get("/verify-email", handlers.VerifyEmailHandler)
+	authGroup.Post("/verify-email", handlers.ConfirmEmailChangeHandler)
+
+	// Password reset routes
+	authGroup.Get("/forgot-password", handlers.ForgotPasswordPageHandler)
+	authGroup.Post("/forgot-password", handlers.ForgotPasswordHandler)
+	authGroup.Get("/reset-password", handlers.ResetPasswordPageHandler)
+	authGroup.Post("/reset-password", handlers.ResetPasswordHandler)
 
 	// Search routes
 	app.Get("/search", handlers.SearchHandler) // Main search page
@@ -202,22 +247,53 @@ func SetupRoutes(app *fiber.App, view *jet.Set, repo *database.Repository, confi
 	userGroup := app.Group("/user")
 	userGroup.Use(middleware.AuthMiddleware(repo))
 	userGroup.Use(middleware.RequireAuthMiddleware())
-	userGroup.Get("/profile", handlers.UserProfileHandler)
-	userGroup.Post("/profile", handlers.UserUpdateHandler)
-	userGroup.Post("/privacy", handlers.UserPrivacyHandler)
-	userGroup.Post("/pagination", handlers.UserPaginationHandler)
+	// Read-only sessions only need a public npub, so private pages need a write session
+	userGroup.Get("/profile", requireWrite, handlers.UserProfileHandler)
+	userGroup.Post("/profile", requireWrite, handlers.UserUpdateHandler)
+	userGroup.Post("/privacy", requireWrite, handlers.UserPrivacyHandler)
+	userGroup.Post("/pagination", requireWrite, handlers.UserPaginationHandler)
+	userGroup.Post("/email", requireWrite, handlers.UserChangeEmailHandler)
+	userGroup.Post("/nip05", requireWrite, handlers.UserNIP05Handler)
+	userGroup.Post("/nip05/publish", requireWrite, handlers.UserPublishNIP05Handler)
+	userGroup.Post("/nostr-metadata", requireWrite, handlers.UserImportNostrMetadataHandler)
+	userGroup.Post("/nostr-metadata/clear", requireWrite, handlers.UserClearNostrMetadataHandler)
+	userGroup.Post("/invites", requireWrite, handlers.UserCreateInviteHandler)
+	userGroup.Post("/invites/:id/revoke", requireWrite, handlers.UserRevokeInviteHandler)
+	userGroup.Get("/export", requireWrite, handlers.UserExportHandler)
+	userGroup.Post("/delete", requireWrite, handlers.UserDeleteAccountHandler)
+	userGroup.Post("/delete/cancel", requireWrite, handlers.UserCancelDeletionHandler)
+	userGroup.Get("/sessions", requireWrite, handlers.UserSessionsHandler)
+	userGroup.Post("/sessions/revoke-others", requireWrite, handlers.UserSessionsRevokeOthersHandler)
+	userGroup.Post("/sessions/:id/revoke", requireWrite, handlers.UserSessionRevokeHandler)
+	userGroup.Get("/api-tokens", requireWrite, handlers.UserAPITokensHandler)
+	userGroup.Post("/api-tokens", requireWrite, handlers.UserAPITokenCreateHandler)
+	userGroup.Post("/api-tokens/:id/revoke", requireWrite, handlers.UserAPITokenRevokeHandler)
+	userGroup.Get("/passkeys", requireWrite, handlers.UserPasskeysHandler)
+	userGroup.Post("/passkeys/options", requireWrite, handlers.UserPasskeyOptionsHandler)
+	userGroup.Post("/passkeys", requireWrite, handlers.UserPasskeyCreateHandler)
+	userGroup.Post("/passkeys/:id/delete", requireWrite, handlers.UserPasskeyDeleteHandler)
+	userGroup.Post("/identities/nostr", requireWrite, handlers.UserLinkNostrHandler)
+	userGroup.Post("/identities/trezor", requireWrite, handlers.UserLinkTrezorHandler)
+	userGroup.Get("/identities/lnurl", requireWrite, handlers.UserLinkLNURLHandler)
+	userGroup.Get("/identities/lnurl/status", requireWrite, handlers.UserLinkLNURLStatusHandler)
+	userGroup.Post("/identities/email", requireWrite, handlers.UserLinkEmailHandler)
+	userGroup.Post("/identities/:id/unlink", requireWrite, handlers.UserUnlinkIdentityHandler)
 	userGroup.Get("/pitches", func(c *fiber.Ctx) error {
 		// Smart redirect for "My Pitches" based on context and user activity
 
+		configService := c.Locals("configService").(*config.Service)
+		categories := configService.Categories(c.Context())
+		if len(categories) == 0 {
+			return c.Redirect("/", fiber.StatusTemporaryRedirect)
+		}
+
 		// Option 1: Check if user came from a specific category page (referer-based)
-		referer := c.Get("Referer")
-		if referer != "" {
-			if strings.Contains(referer, "/lightning") {
-				return c.Redirect("/lightning?author=me", fiber.StatusTemporaryRedirect)
-			} else if strings.Contains(referer, "/cashu") {
-				return c.Redirect("/cashu?author=me", fiber.StatusTemporaryRedirect)
-			} else if strings.Contains(referer, "/bitcoin") {
-				return c.Redirect("/bitcoin?author=me", fiber.StatusTemporaryRedirect)
+		if referer, err := url.Parse(c.Get("Referer")); err == nil {
+			refererPath := strings.Trim(referer.Path, "/")
+			for _, category := range categories {
+				if refererPath == category.Slug {
+					return c.Redirect(category.URL()+"?author=me", fiber.StatusTemporaryRedirect)
+				}
 			}
 		}
 
@@ -226,13 +302,12 @@ func SetupRoutes(app *fiber.App, view *jet.Set, repo *database.Repository, confi
 		repo := c.Locals("repo").(*database.Repository)
 
 		// Query user's pitch count by category to find their most active category
-		categories := []string{"bitcoin", "lightning", "cashu"}
-		var bestCategory string
+		var bestCategory *models.Category
 		maxCount := 0
 
 		for _, category := range categories {
 			filters := map[string]interface{}{
-				"main_category": category,
+				"main_category": category.Slug,
 				"user_id":       user.ID,
 			}
 			pitches, err := repo.ListPitches(c.Context(), filters, 1000, 0) // Get all pitches for counting
@@ -242,20 +317,20 @@ func SetupRoutes(app *fiber.App, view *jet.Set, repo *database.Repository, confi
 			}
 		}
 
-		// Option 3: Default to bitcoin if no activity found
-		if bestCategory == "" {
-			bestCategory = "bitcoin"
+		// Option 3: Default to the first category if no activity found
+		if bestCategory == nil {
+			bestCategory = categories[0]
 		}
 
-		return c.Redirect("/"+bestCategory+"?author=me", fiber.StatusTemporaryRedirect)
+		return c.Redirect(bestCategory.URL()+"?author=me", fiber.StatusTemporaryRedirect)
 	})
 
 	// 2FA routes (must be under userGroup to have authentication middleware)
-	userGroup.Post("/2fa/generate", totpHandler.GenerateTOTPSecret)
-	userGroup.Get("/2fa/qr", totpHandler.GenerateQRCode)
-	userGroup.Post("/2fa/enable", totpHandler.EnableTOTP)
-	userGroup.Post("/2fa/disable", totpHandler.DisableTOTP)
-	userGroup.Post("/2fa/backup-codes", totpHandler.GetBackupCodes)
+	userGroup.Post("/2fa/generate", requireWrite, totpHandler.GenerateTOTPSecret)
+	userGroup.Get("/2fa/qr", requireWrite, totpHandler.GenerateQRCode)
+	userGroup.Post("/2fa/enable", requireWrite, totpHandler.EnableTOTP)
+	userGroup.Post("/2fa/disable", requireWrite, totpHandler.DisableTOTP)
+	userGroup.Post("/2fa/backup-codes", requireWrite, totpHandler.GetBackupCodes)
 
 	// API routes (for HTMX and other AJAX requests)
 	api := app.Group("/api")
@@ -276,12 +351,21 @@ func SetupRoutes(app *fiber.App, view *jet.Set, repo *database.Repository, confi
 			"message": "Route handlers are working",
 		})
 	})
-	api.Get("/pitches", handlers.APIPitchesListHandler)
-	api.Get("/pitches/:id", handlers.APIPitchGetHandler)
-	api.Post("/pitches", handlers.APIPitchCreateHandler)
-	api.Put("/pitches/:id", handlers.APIPitchUpdateHandler)
-	api.Delete("/pitches/:id", handlers.APIPitchDeleteHandler)
-	api.Post("/pitches/:id/vote", handlers.APIPitchVoteHandler)
+	// Personal API tokens are limited to their scopes; cookie sessions are not
+	readPitches := middleware.RequireScope(models.ScopePitchesRead)
+	writePitches := middleware.RequireScope(models.ScopePitchesWrite)
+	writeVotes := middleware.RequireScope(models.ScopeVotesWrite)
+
+	api.Get("/pitches", readPitches, handlers.APIPitchesListHandler)
+	api.Get("/pitches/:id", readPitches, handlers.APIPitchGetHandler)
+	api.Get("/pitches/:id/history", readPitches, handlers.APIPitchHistoryHandler)
+	api.Post("/pitches", requireWrite, writePitches, handlers.APIPitchCreateHandler)
+	api.Put("/pitches/:id", requireWrite, writePitches, handlers.APIPitchUpdateHandler)
+	api.Delete("/pitches/:id", requireWrite, writePitches, handlers.APIPitchDeleteHandler)
+	api.Post("/pitches/:id/vote", requireWrite, writeVotes, handlers.APIPitchVoteHandler)
+
+	// Category routes
+	api.Get("/categories", handlers.APICategoriesListHandler)
 
 	// Tag routes
 	api.Get("/tags/suggestions", handlers.TagSuggestionsHandler)
@@ -306,6 +390,7 @@ func SetupRoutes(app *fiber.App, view *jet.Set, repo *database.Repository, confi
 	// First apply auth middleware to populate user in context
 	adminRoutes.Use(middleware.AuthMiddleware(repo))
 	adminRoutes.Use(middleware.RequireAuthMiddleware())
+	adminRoutes.Use(requireWrite)
 	// Then check for admin role
 	adminRoutes.Use(func(c *fiber.Ctx) error {
 		user := c.Locals("user").(*models.User)
@@ -326,14 +411,29 @@ func SetupRoutes(app *fiber.App, view *jet.Set, repo *database.Repository, confi
 	adminRoutes.Get("/users", adminHandler.AdminUsersHandler)
 	adminRoutes.Post("/users/:id/role", adminHandler.AdminUserUpdateRoleHandler)
 	adminRoutes.Post("/users/:id/disable", adminHandler.AdminUserDisableHandler)
+	adminRoutes.Post("/users/:id/sessions/revoke", adminHandler.AdminUserRevokeSessionsHandler)
 	adminRoutes.Post("/users/:id/hide", adminHandler.AdminUserHideHandler)
 	adminRoutes.Post("/users/:id/delete", adminHandler.AdminUserDeleteHandler)
+	adminRoutes.Post("/users/:id/merge", adminHandler.AdminUserMergeHandler)
+	adminRoutes.Post("/users/:id/invite-quota", adminHandler.AdminUserInviteQuotaHandler)
+	adminRoutes.Get("/invites", adminHandler.AdminInvitesHandler)
+	adminRoutes.Post("/invites", adminHandler.AdminInviteCreateHandler)
+	adminRoutes.Post("/invites/:id/revoke", adminHandler.AdminInviteRevokeHandler)
+	adminRoutes.Post("/registration-mode", adminHandler.AdminRegistrationModeHandler)
 	adminRoutes.Get("/pitches", adminHandler.AdminPitchesHandler)
 	adminRoutes.Post("/pitches/:id/delete", adminHandler.AdminPitchDeleteHandler)
 	adminRoutes.Post("/pitches/:id/hide", adminHandler.AdminPitchHideHandler)
+	adminRoutes.Get("/categories", adminHandler.AdminCategoriesHandler)
+	adminRoutes.Post("/categories", adminHandler.AdminCategoryCreateHandler)
+	adminRoutes.Post("/categories/:slug", adminHandler.AdminCategoryUpdateHandler)
+	adminRoutes.Post("/categories/:slug/delete", adminHandler.AdminCategoryDeleteHandler)
 	adminRoutes.Get("/audit-logs", adminHandler.AdminAuditLogsHandler)
 	log.Println("[DEBUG] Admin routes registered successfully")
 
+	// Category listings, registered after every other page so a category can
+	// never hide one. Unknown and inactive categories get the 404 page.
+	public.Get("/:category", handlers.PitchListHandler)
+
 	// Error handlers
 	app.Use(handlers.NotFoundHandler) // Global 404 handler (HTMX/modal aware)
 	// app.Use(handlers.ErrorHandler)    // 500 handler (not implemented)
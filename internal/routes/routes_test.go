@@ -0,0 +1,54 @@
+package routes
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"bitcoinpitch.org/internal/config"
+	"bitcoinpitch.org/internal/middleware"
+	"bitcoinpitch.org/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// emptyConfig has no settings, so every lookup uses its default
+type emptyConfig struct {
+	config.ConfigRepository
+}
+
+func (emptyConfig) GetConfigSetting(ctx context.Context, key string) (*models.ConfigSetting, error) {
+	return nil, fiber.ErrNotFound
+}
+
+func (emptyConfig) ListCategories(ctx context.Context) ([]*models.Category, error) {
+	return nil, nil
+}
+
+func TestProfileRequiresWriteSession(t *testing.T) {
+	user := &models.User{BaseModel: models.BaseModel{ID: uuid.New()}}
+
+	app := fiber.New()
+	// Stands in for a session opened with /auth/nostr/npub; without a
+	// session cookie the auth middleware keeps these locals
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("user", user)
+		c.Locals("user_id", user.ID)
+		c.Locals("readOnlySession", true)
+		return c.Next()
+	})
+	SetupRoutes(app, nil, nil, config.NewService(emptyConfig{}))
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/user/profile", nil))
+	if err != nil {
+		t.Fatalf("GET /user/profile: %v", err)
+	}
+	defer resp.Body.Close()
+
+	location := resp.Header.Get("Location")
+	if resp.StatusCode != fiber.StatusSeeOther || !strings.HasPrefix(location, middleware.StepUpPath) {
+		t.Errorf("GET /user/profile = %d to %q, want a redirect to the step-up page", resp.StatusCode, location)
+	}
+}
@@ -0,0 +1,160 @@
+// Package textdiff compares two texts word by word, for showing what an edit
+// changed
+package textdiff
+
+import (
+	"unicode"
+)
+
+// Kinds of diff operations
+const (
+	OpEqual  = "equal"
+	OpInsert = "insert"
+	OpDelete = "delete"
+)
+
+// maxCells bounds the comparison table. Longer texts are shown as a full
+// replacement instead of a word diff.
+const maxCells = 4_000_000
+
+// Op is a run of text that was kept, added or removed
+type Op struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// Words returns the operations that turn old into new. Words and the
+// whitespace between them are compared as separate tokens, so joining the
+// equal and delete texts gives old and joining the equal and insert texts
+// gives new.
+func Words(old, new string) []Op {
+	a := tokenize(old)
+	b := tokenize(new)
+
+	// Keep the common prefix and suffix out of the table
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var ops []Op
+	ops = appendOp(ops, OpEqual, a[:prefix]...)
+	ops = appendMiddle(ops, a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])
+	ops = appendOp(ops, OpEqual, a[len(a)-suffix:]...)
+	return ops
+}
+
+// appendMiddle diffs the differing middle of both texts using their longest
+// common subsequence
+func appendMiddle(ops []Op, a, b []string) []Op {
+	if len(a) == 0 || len(b) == 0 || (len(a)+1)*(len(b)+1) > maxCells {
+		ops = appendOp(ops, OpDelete, a...)
+		return appendOp(ops, OpInsert, b...)
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = appendOp(ops, OpEqual, a[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = appendOp(ops, OpDelete, a[i])
+			i++
+		default:
+			ops = appendOp(ops, OpInsert, b[j])
+			j++
+		}
+	}
+	ops = appendOp(ops, OpDelete, a[i:]...)
+	return appendOp(ops, OpInsert, b[j:]...)
+}
+
+// appendOp adds tokens to the last operation if it has the same type, so
+// consecutive changes read as one run
+func appendOp(ops []Op, opType string, tokens ...string) []Op {
+	for _, token := range tokens {
+		if n := len(ops); n > 0 && ops[n-1].Type == opType {
+			ops[n-1].Text += token
+			continue
+		}
+		ops = append(ops, Op{Type: opType, Text: token})
+	}
+	return ops
+}
+
+// tokenize splits text into words and runs of whitespace
+func tokenize(text string) []string {
+	var tokens []string
+	start := 0
+	inSpace := false
+	for i, r := range text {
+		space := unicode.IsSpace(r)
+		if i > 0 && space != inSpace {
+			tokens = append(tokens, text[start:i])
+			start = i
+		}
+		inSpace = space
+	}
+	if start < len(text) {
+		tokens = append(tokens, text[start:])
+	}
+	return tokens
+}
+
+// Distance returns the number of characters that must be inserted, deleted
+// or replaced to turn old into new
+func Distance(old, new string) int {
+	a := []rune(old)
+	b := []rune(new)
+
+	// Keep the common prefix and suffix out of the table
+	for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+		a, b = a[1:], b[1:]
+	}
+	for len(a) > 0 && len(b) > 0 && a[len(a)-1] == b[len(b)-1] {
+		a, b = a[:len(a)-1], b[:len(b)-1]
+	}
+	if len(a) == 0 || len(b) == 0 {
+		return len(a) + len(b)
+	}
+
+	// prev[j] is the distance between the runes of a seen so far and b[:j]
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := range a {
+		cur[0] = i + 1
+		for j := range b {
+			cost := 1
+			if a[i] == b[j] {
+				cost = 0
+			}
+			cur[j+1] = min(prev[j+1]+1, cur[j]+1, prev[j]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
@@ -1,9 +1,11 @@
 package validation
 
 import (
+	"context"
 	"fmt"
 
 	"bitcoinpitch.org/internal/config"
+	"bitcoinpitch.org/internal/crypto"
 	"bitcoinpitch.org/internal/models"
 )
 
@@ -16,6 +18,7 @@ type PitchInput struct {
 	AuthorType     models.AuthorType     `form:"author_type" json:"author_type"`
 	AuthorName     *string               `form:"author_name" json:"author_name,omitempty"`
 	AuthorHandle   *string               `form:"author_handle" json:"author_handle,omitempty"`
+	AuthorNIP05    *string               `form:"author_nip05" json:"author_nip05,omitempty"`
 	Tags           []string              `form:"tags" json:"tags,omitempty"`
 }
 
@@ -56,11 +59,8 @@ func ValidatePitchInput(input PitchInput, configService *config.Service) error {
 		return fmt.Errorf("language is required")
 	}
 
-	// Validate main category
-	switch input.MainCategory {
-	case models.MainCategoryBitcoin, models.MainCategoryLightning, models.MainCategoryCashu:
-		// Valid categories
-	default:
+	// Validate main category against the active categories
+	if configService.Category(context.Background(), string(input.MainCategory)) == nil {
 		return fmt.Errorf("invalid main category")
 	}
 
@@ -92,6 +92,16 @@ func ValidatePitchInput(input PitchInput, configService *config.Service) error {
 		return fmt.Errorf("invalid author type")
 	}
 
+	// A NIP-05 identifier can only be claimed for a Nostr author
+	if input.AuthorNIP05 != nil {
+		if input.AuthorType != models.AuthorTypeNostr {
+			return fmt.Errorf("NIP-05 identifier is only allowed for Nostr authors")
+		}
+		if _, _, err := crypto.ParseNIP05Identifier(*input.AuthorNIP05); err != nil {
+			return fmt.Errorf("invalid NIP-05 identifier")
+		}
+	}
+
 	// Validate tags
 	if len(input.Tags) > 5 {
 		return fmt.Errorf("maximum 5 tags allowed")
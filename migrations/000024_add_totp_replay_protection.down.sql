@@ -0,0 +1,6 @@
+-- Remove TOTP replay protection
+ALTER TABLE users DROP COLUMN IF EXISTS totp_last_step;
+
+-- Backup codes stay hashed because the plaintext cannot be recovered. Code
+-- that compares plaintext backup codes will not accept them, so users have to
+-- regenerate their codes after rolling back.
@@ -0,0 +1,23 @@
+-- Track the last accepted TOTP time step so a code cannot be used twice
+ALTER TABLE users ADD COLUMN totp_last_step BIGINT NULL;
+
+COMMENT ON COLUMN users.totp_last_step IS 'Last accepted TOTP time step (unix time / 30), used to reject replayed codes';
+
+-- Backup codes are now stored as bcrypt hashes. Existing plaintext codes are
+-- hashed in place, normalized the same way the application normalizes input
+-- (no spaces or dashes, uppercase). pgcrypto writes $2a$ hashes, which Go's
+-- bcrypt accepts. Codes that are already hashed are left alone.
+CREATE EXTENSION IF NOT EXISTS pgcrypto;
+
+UPDATE users
+SET totp_backup_codes = ARRAY(
+    SELECT CASE
+        WHEN code LIKE '$2%' THEN code
+        ELSE crypt(upper(replace(replace(btrim(code), ' ', ''), '-', '')), gen_salt('bf', 10))
+    END
+    FROM unnest(totp_backup_codes) WITH ORDINALITY AS codes(code, position)
+    ORDER BY position
+)
+WHERE totp_backup_codes IS NOT NULL AND cardinality(totp_backup_codes) > 0;
+
+COMMENT ON COLUMN users.totp_backup_codes IS 'bcrypt hashes of unused 2FA backup codes';
@@ -0,0 +1,12 @@
+-- Record where a session was created and when it was last used
+ALTER TABLE sessions ADD COLUMN ip_address VARCHAR(45) NULL;
+ALTER TABLE sessions ADD COLUMN user_agent TEXT NULL;
+ALTER TABLE sessions ADD COLUMN last_seen_at TIMESTAMP WITH TIME ZONE NULL;
+
+UPDATE sessions SET last_seen_at = COALESCE(updated_at, created_at);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id);
+
+COMMENT ON COLUMN sessions.ip_address IS 'Client IP address that created the session';
+COMMENT ON COLUMN sessions.user_agent IS 'User agent of the browser that created the session';
+COMMENT ON COLUMN sessions.last_seen_at IS 'Last time the session was used, refreshed by the auth middleware';
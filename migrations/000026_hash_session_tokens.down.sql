@@ -0,0 +1,9 @@
+-- Hashed tokens cannot be turned back into cookie values, so all sessions are dropped
+DELETE FROM sessions;
+
+DROP INDEX IF EXISTS idx_sessions_previous_token;
+DROP INDEX IF EXISTS idx_sessions_token;
+ALTER TABLE sessions DROP COLUMN IF EXISTS previous_token;
+ALTER TABLE sessions DROP COLUMN IF EXISTS token_rotated_at;
+
+COMMENT ON COLUMN sessions.token IS NULL;
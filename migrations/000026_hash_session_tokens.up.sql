@@ -0,0 +1,17 @@
+-- Store session tokens as SHA-256 digests instead of raw cookie values.
+-- Existing tokens are rehashed in place so current sessions keep working.
+UPDATE sessions SET token = encode(sha256(convert_to(token, 'UTF8')), 'hex');
+
+-- Track token rotation; the previous digest stays valid for a short grace
+-- period so requests already in flight with the old cookie still succeed
+ALTER TABLE sessions ADD COLUMN token_rotated_at TIMESTAMP WITH TIME ZONE NULL;
+ALTER TABLE sessions ADD COLUMN previous_token TEXT NULL;
+
+UPDATE sessions SET token_rotated_at = created_at;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_sessions_token ON sessions(token);
+CREATE INDEX IF NOT EXISTS idx_sessions_previous_token ON sessions(previous_token) WHERE previous_token IS NOT NULL;
+
+COMMENT ON COLUMN sessions.token IS 'SHA-256 hex digest of the session cookie value';
+COMMENT ON COLUMN sessions.token_rotated_at IS 'When the current session token was issued';
+COMMENT ON COLUMN sessions.previous_token IS 'Digest of the token replaced by the last rotation, accepted briefly after rotation';
@@ -0,0 +1,3 @@
+-- Remove personal API tokens
+DROP TRIGGER IF EXISTS update_api_tokens_updated_at ON api_tokens;
+DROP TABLE IF EXISTS api_tokens;
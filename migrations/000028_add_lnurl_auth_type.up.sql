@@ -0,0 +1,3 @@
+-- Update auth_type constraint to include 'lnurl'
+ALTER TABLE users DROP CONSTRAINT users_auth_type_check;
+ALTER TABLE users ADD CONSTRAINT users_auth_type_check CHECK (auth_type IN ('trezor', 'nostr', 'twitter', 'password', 'email', 'lnurl'));
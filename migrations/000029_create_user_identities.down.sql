@@ -0,0 +1,2 @@
+-- Drop login identities; users keep their primary auth_type/auth_id
+DROP TABLE IF EXISTS user_identities;
@@ -0,0 +1,21 @@
+-- Login identities, so one account can sign in with several methods
+CREATE TABLE user_identities (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    auth_type TEXT NOT NULL CHECK (auth_type IN ('trezor', 'nostr', 'twitter', 'password', 'email', 'lnurl')),
+    auth_id TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    UNIQUE (auth_type, auth_id)
+);
+
+CREATE INDEX idx_user_identities_user_id ON user_identities(user_id);
+
+CREATE TRIGGER update_user_identities_updated_at BEFORE UPDATE ON user_identities FOR EACH ROW EXECUTE PROCEDURE update_updated_at_column();
+
+-- Every existing login becomes its account's first identity; if duplicate
+-- accounts share a login, the oldest one keeps it
+INSERT INTO user_identities (user_id, auth_type, auth_id, created_at, updated_at)
+SELECT DISTINCT ON (auth_type, auth_id) id, auth_type, auth_id, created_at, created_at
+FROM users
+ORDER BY auth_type, auth_id, created_at;
@@ -0,0 +1,3 @@
+-- Remove WebAuthn credentials
+DROP TRIGGER IF EXISTS update_webauthn_credentials_updated_at ON webauthn_credentials;
+DROP TABLE IF EXISTS webauthn_credentials;
@@ -0,0 +1,19 @@
+-- WebAuthn credentials (passkeys and security keys) for passwordless login and 2FA
+CREATE TABLE webauthn_credentials (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    credential_id BYTEA NOT NULL UNIQUE,
+    public_key BYTEA NOT NULL,             -- COSE_Key as sent by the authenticator
+    algorithm INTEGER NOT NULL,            -- COSE algorithm identifier
+    sign_count BIGINT NOT NULL DEFAULT 0,  -- signature counter, used to detect cloned authenticators
+    aaguid VARCHAR(36) NULL,
+    backup_eligible BOOLEAN NOT NULL DEFAULT FALSE,
+    last_used_at TIMESTAMP WITH TIME ZONE NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_webauthn_credentials_user_id ON webauthn_credentials(user_id);
+
+CREATE TRIGGER update_webauthn_credentials_updated_at BEFORE UPDATE ON webauthn_credentials FOR EACH ROW EXECUTE PROCEDURE update_updated_at_column();
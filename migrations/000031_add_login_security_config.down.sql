@@ -0,0 +1,12 @@
+-- Remove brute-force protection settings
+DELETE FROM config_settings WHERE key IN (
+    'security.login_failure_window_minutes',
+    'security.account_backoff_after_failures',
+    'security.account_lockout_failures',
+    'security.ip_backoff_after_failures',
+    'security.ip_lockout_failures',
+    'security.login_backoff_base_seconds',
+    'security.login_backoff_max_seconds',
+    'security.lockout_duration_minutes',
+    'security.lockout_notify_email'
+);
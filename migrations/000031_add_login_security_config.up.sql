@@ -0,0 +1,11 @@
+-- Add brute-force protection settings for password and 2FA logins
+INSERT INTO config_settings (key, value, description, category, data_type) VALUES
+    ('security.login_failure_window_minutes', '15', 'Time window for counting failed login attempts (minutes)', 'security', 'integer'),
+    ('security.account_backoff_after_failures', '3', 'Failed logins on an account before exponential backoff starts', 'security', 'integer'),
+    ('security.account_lockout_failures', '10', 'Failed logins on an account before it is temporarily locked', 'security', 'integer'),
+    ('security.ip_backoff_after_failures', '10', 'Failed logins from an IP before exponential backoff starts', 'security', 'integer'),
+    ('security.ip_lockout_failures', '50', 'Failed logins from an IP before it is temporarily locked', 'security', 'integer'),
+    ('security.login_backoff_base_seconds', '1', 'Initial backoff delay, doubled with each further failure (seconds)', 'security', 'integer'),
+    ('security.login_backoff_max_seconds', '60', 'Maximum backoff delay between login attempts (seconds)', 'security', 'integer'),
+    ('security.lockout_duration_minutes', '30', 'How long a locked account or IP stays locked (minutes)', 'security', 'integer'),
+    ('security.lockout_notify_email', 'true', 'Email users when their account is locked', 'security', 'boolean');
@@ -0,0 +1,7 @@
+-- Remove password hashing settings
+DELETE FROM config_settings WHERE key IN (
+    'security.argon2_memory_kib',
+    'security.argon2_iterations',
+    'security.argon2_parallelism',
+    'security.breached_password_check'
+);
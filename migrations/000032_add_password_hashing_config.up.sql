@@ -0,0 +1,6 @@
+-- Add argon2id password hashing and breached password check settings
+INSERT INTO config_settings (key, value, description, category, data_type) VALUES
+    ('security.argon2_memory_kib', '65536', 'Argon2id memory cost for new password hashes (KiB)', 'security', 'integer'),
+    ('security.argon2_iterations', '3', 'Argon2id iterations for new password hashes', 'security', 'integer'),
+    ('security.argon2_parallelism', '2', 'Argon2id parallelism for new password hashes', 'security', 'integer'),
+    ('security.breached_password_check', 'true', 'Reject new passwords found in the local breached password list (BREACHED_PASSWORDS_FILE)', 'security', 'boolean');
@@ -0,0 +1,4 @@
+-- Remove email change token purposes
+DELETE FROM email_verification_tokens WHERE purpose <> 'verify';
+DROP INDEX IF EXISTS idx_email_verification_tokens_user_purpose;
+ALTER TABLE email_verification_tokens DROP COLUMN IF EXISTS purpose;
@@ -0,0 +1,6 @@
+-- Distinguish plain verification tokens from email change and revert tokens
+ALTER TABLE email_verification_tokens
+    ADD COLUMN purpose VARCHAR(20) NOT NULL DEFAULT 'verify'
+    CHECK (purpose IN ('verify', 'change', 'revert'));
+
+CREATE INDEX idx_email_verification_tokens_user_purpose ON email_verification_tokens(user_id, purpose);
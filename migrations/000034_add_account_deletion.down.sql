@@ -0,0 +1,8 @@
+-- Remove self-service account deletion
+DELETE FROM config_settings WHERE key = 'users.account_deletion_grace_days';
+
+DROP INDEX IF EXISTS idx_users_deletion_scheduled_at;
+ALTER TABLE users DROP COLUMN IF EXISTS deletion_mode;
+ALTER TABLE users DROP COLUMN IF EXISTS deletion_scheduled_at;
+
+-- The Anonymous account is kept because anonymized pitches still belong to it
@@ -0,0 +1,25 @@
+-- Add self-service account deletion with a grace period
+ALTER TABLE users ADD COLUMN deletion_scheduled_at TIMESTAMP WITH TIME ZONE NULL;
+ALTER TABLE users ADD COLUMN deletion_mode VARCHAR(20) NULL CHECK (deletion_mode IN ('anonymize', 'delete'));
+
+CREATE INDEX idx_users_deletion_scheduled_at ON users(deletion_scheduled_at) WHERE deletion_scheduled_at IS NOT NULL;
+
+COMMENT ON COLUMN users.deletion_scheduled_at IS 'When the account will be permanently removed - NULL means no deletion requested';
+COMMENT ON COLUMN users.deletion_mode IS 'What happens to the pitches of a deleted account: anonymize or delete';
+
+-- Tombstone account that anonymized pitches are reassigned to. It cannot sign in.
+INSERT INTO users (id, auth_type, auth_id, display_name, show_username, disabled, hidden, created_at, updated_at)
+VALUES (
+  '00000000-0000-0000-0000-000000000002',
+  'password',
+  'anonymous',
+  'Anonymous',
+  TRUE,
+  TRUE,
+  TRUE,
+  NOW(),
+  NOW()
+) ON CONFLICT (id) DO NOTHING;
+
+INSERT INTO config_settings (key, value, description, category, data_type) VALUES
+    ('users.account_deletion_grace_days', '14', 'Days before a self-deleted account is permanently removed; the user can cancel until then', 'users', 'integer');
@@ -0,0 +1,12 @@
+-- Remove NIP-05 identifier claims
+DELETE FROM config_settings WHERE key IN ('users.nip05_recheck_hours', 'users.nip05_timeout_seconds', 'users.nip05_grace_hours');
+
+DROP TABLE IF EXISTS nip05_lookups;
+
+DROP INDEX IF EXISTS idx_pitches_author_nip05;
+DROP INDEX IF EXISTS idx_users_nip05;
+
+ALTER TABLE pitches DROP COLUMN IF EXISTS author_nip05_verified_at;
+ALTER TABLE pitches DROP COLUMN IF EXISTS author_nip05;
+ALTER TABLE users DROP COLUMN IF EXISTS nip05_verified_at;
+ALTER TABLE users DROP COLUMN IF EXISTS nip05;
@@ -0,0 +1,34 @@
+-- Add NIP-05 identifier claims for users and Nostr-attributed pitch authors
+ALTER TABLE users ADD COLUMN nip05 VARCHAR(320) NULL;
+ALTER TABLE users ADD COLUMN nip05_verified_at TIMESTAMP WITH TIME ZONE NULL;
+
+ALTER TABLE pitches ADD COLUMN author_nip05 VARCHAR(320) NULL;
+ALTER TABLE pitches ADD COLUMN author_nip05_verified_at TIMESTAMP WITH TIME ZONE NULL;
+
+CREATE INDEX idx_users_nip05 ON users(nip05) WHERE nip05 IS NOT NULL;
+CREATE INDEX idx_pitches_author_nip05 ON pitches(author_nip05) WHERE author_nip05 IS NOT NULL;
+
+COMMENT ON COLUMN users.nip05 IS 'NIP-05 identifier (name@domain) claimed by the user';
+COMMENT ON COLUMN users.nip05_verified_at IS 'Since when the claimed identifier resolves to one of the user''s Nostr keys - NULL means unverified';
+COMMENT ON COLUMN pitches.author_nip05 IS 'NIP-05 identifier claimed for a Nostr-attributed author';
+COMMENT ON COLUMN pitches.author_nip05_verified_at IS 'Since when the claimed identifier resolves to the author''s npub - NULL means unverified';
+
+-- Cached nostr.json lookups, shared by every claim of the same identifier
+CREATE TABLE nip05_lookups (
+    identifier VARCHAR(320) PRIMARY KEY,
+    pubkey VARCHAR(64) NULL,
+    last_error TEXT NULL,
+    resolved_at TIMESTAMP WITH TIME ZONE NULL,
+    checked_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX idx_nip05_lookups_checked_at ON nip05_lookups(checked_at);
+
+COMMENT ON COLUMN nip05_lookups.pubkey IS 'Pubkey the domain listed at the last successful lookup - NULL if the name is not listed';
+COMMENT ON COLUMN nip05_lookups.resolved_at IS 'When the domain last gave a definite answer';
+COMMENT ON COLUMN nip05_lookups.checked_at IS 'When the lookup was last attempted';
+
+INSERT INTO config_settings (key, value, description, category, data_type) VALUES
+    ('users.nip05_recheck_hours', '24', 'Hours before a NIP-05 identifier is looked up again', 'users', 'integer'),
+    ('users.nip05_timeout_seconds', '5', 'Timeout for fetching a domain''s nostr.json', 'users', 'integer'),
+    ('users.nip05_grace_hours', '168', 'Hours a verified NIP-05 badge is kept while its domain cannot be reached', 'users', 'integer');
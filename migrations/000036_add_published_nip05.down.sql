@@ -0,0 +1,5 @@
+-- Stop serving NIP-05 identifiers for usernames
+DELETE FROM config_settings WHERE key = 'site.nip05_relays';
+
+DROP INDEX IF EXISTS idx_users_published_nip05_name;
+ALTER TABLE users DROP COLUMN IF EXISTS publish_nip05;
@@ -0,0 +1,10 @@
+-- Let users publish username@<site domain> as their NIP-05 identifier
+ALTER TABLE users ADD COLUMN publish_nip05 BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Usernames are not unique in general, but a published name must be
+CREATE UNIQUE INDEX idx_users_published_nip05_name ON users(lower(username)) WHERE publish_nip05;
+
+COMMENT ON COLUMN users.publish_nip05 IS 'Whether /.well-known/nostr.json lists the username with the user''s Nostr key';
+
+INSERT INTO config_settings (key, value, description, category, data_type) VALUES
+    ('site.nip05_relays', '[]', 'JSON array of relay URLs suggested for the NIP-05 identifiers served in /.well-known/nostr.json', 'site', 'json');
@@ -0,0 +1,7 @@
+-- Remove imported Nostr profile metadata
+ALTER TABLE users DROP COLUMN IF EXISTS nostr_metadata_at;
+ALTER TABLE users DROP COLUMN IF EXISTS nostr_nip05;
+ALTER TABLE users DROP COLUMN IF EXISTS nostr_lud16;
+ALTER TABLE users DROP COLUMN IF EXISTS nostr_picture;
+ALTER TABLE users DROP COLUMN IF EXISTS nostr_about;
+ALTER TABLE users DROP COLUMN IF EXISTS nostr_name;
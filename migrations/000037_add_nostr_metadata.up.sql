@@ -0,0 +1,12 @@
+-- Store profile metadata imported from a user's signed Nostr kind-0 event.
+-- Kept apart from the fields users edit here so a re-import never overwrites them.
+ALTER TABLE users ADD COLUMN nostr_name VARCHAR(50) NULL;
+ALTER TABLE users ADD COLUMN nostr_about TEXT NULL;
+ALTER TABLE users ADD COLUMN nostr_picture VARCHAR(2048) NULL;
+ALTER TABLE users ADD COLUMN nostr_lud16 VARCHAR(320) NULL;
+ALTER TABLE users ADD COLUMN nostr_nip05 VARCHAR(320) NULL;
+ALTER TABLE users ADD COLUMN nostr_metadata_at TIMESTAMP WITH TIME ZONE NULL;
+
+COMMENT ON COLUMN users.nostr_name IS 'Display name from the imported kind-0 event';
+COMMENT ON COLUMN users.nostr_lud16 IS 'Lightning address from the imported kind-0 event';
+COMMENT ON COLUMN users.nostr_metadata_at IS 'created_at of the imported kind-0 event - older events are rejected';
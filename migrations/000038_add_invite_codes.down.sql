@@ -0,0 +1,8 @@
+-- Remove invitation codes and registration modes
+DELETE FROM config_settings WHERE key IN ('users.registration_mode', 'users.invite_expiry_days');
+
+DROP INDEX IF EXISTS idx_users_invite_code_id;
+ALTER TABLE users DROP COLUMN IF EXISTS invite_quota;
+ALTER TABLE users DROP COLUMN IF EXISTS invite_code_id;
+
+DROP TABLE IF EXISTS invite_codes;
@@ -0,0 +1,29 @@
+-- Invitation codes for when registration is limited to invited users
+CREATE TABLE invite_codes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    code VARCHAR(32) NOT NULL UNIQUE,
+    created_by UUID NULL REFERENCES users(id) ON DELETE SET NULL,
+    note VARCHAR(200) NULL,
+    max_uses INTEGER NOT NULL DEFAULT 1 CHECK (max_uses > 0),
+    use_count INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP WITH TIME ZONE NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_invite_codes_created_by ON invite_codes(created_by);
+
+COMMENT ON COLUMN invite_codes.created_by IS 'User who created the code - NULL once that account is gone';
+COMMENT ON COLUMN invite_codes.expires_at IS 'NULL means the code does not expire';
+
+ALTER TABLE users ADD COLUMN invite_code_id UUID NULL REFERENCES invite_codes(id) ON DELETE SET NULL;
+ALTER TABLE users ADD COLUMN invite_quota INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX idx_users_invite_code_id ON users(invite_code_id) WHERE invite_code_id IS NOT NULL;
+
+COMMENT ON COLUMN users.invite_code_id IS 'Invitation the account signed up with';
+COMMENT ON COLUMN users.invite_quota IS 'How many invitation codes the user may create - granted by admins to trusted users';
+
+INSERT INTO config_settings (key, value, description, category, data_type) VALUES
+    ('users.registration_mode', 'open', 'Who can create an account: open, invite (invitation code required) or closed', 'users', 'string'),
+    ('users.invite_expiry_days', '30', 'Days before an invitation code created by a user expires', 'users', 'integer');
@@ -0,0 +1,2 @@
+-- Remove pitch revision history
+DROP TABLE IF EXISTS pitch_revisions;
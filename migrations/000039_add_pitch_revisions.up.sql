@@ -0,0 +1,29 @@
+-- Every version of a pitch, so an edit no longer loses the text people voted on
+CREATE TABLE pitch_revisions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    pitch_id UUID NOT NULL REFERENCES pitches(id) ON DELETE CASCADE,
+    revision_number INTEGER NOT NULL,
+    content TEXT NOT NULL,
+    edited_by UUID NOT NULL REFERENCES users(id),
+    restored_from INTEGER NULL,
+    vote_count INTEGER NOT NULL DEFAULT 0,
+    upvote_count INTEGER NOT NULL DEFAULT 0,
+    downvote_count INTEGER NOT NULL DEFAULT 0,
+    score INTEGER NOT NULL DEFAULT 0,
+    replaced_at TIMESTAMP WITH TIME ZONE NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    UNIQUE (pitch_id, revision_number)
+);
+
+CREATE INDEX idx_pitch_revisions_edited_by ON pitch_revisions(edited_by);
+
+COMMENT ON COLUMN pitch_revisions.restored_from IS 'Revision number this one restored, if any';
+COMMENT ON COLUMN pitch_revisions.vote_count IS 'Votes the revision had collected when it was replaced - the current revision''s votes live on the pitch';
+COMMENT ON COLUMN pitch_revisions.replaced_at IS 'When a later edit replaced this revision - NULL for the current one';
+
+-- Existing pitches start their history with the text they have now. Owners
+-- that no longer exist are credited to the Anonymous account.
+INSERT INTO pitch_revisions (pitch_id, revision_number, content, edited_by, created_at)
+SELECT p.id, 1, p.content, COALESCE(u.id, '00000000-0000-0000-0000-000000000002'), COALESCE(p.last_edit_at, p.created_at)
+FROM pitches p
+LEFT JOIN users u ON u.id = p.user_id;
@@ -0,0 +1,5 @@
+-- Remove minor edit classification
+DELETE FROM config_settings WHERE key IN ('pitch.minor_edit.max_distance', 'pitch.minor_edit.max_percent');
+
+ALTER TABLE pitch_revisions DROP COLUMN IF EXISTS edit_kind;
+ALTER TABLE pitches DROP COLUMN IF EXISTS last_edit_kind;
@@ -0,0 +1,14 @@
+-- Classify pitch edits as minor (votes kept) or major (votes reset)
+ALTER TABLE pitches ADD COLUMN last_edit_kind VARCHAR(10) CHECK (last_edit_kind IN ('minor', 'major'));
+ALTER TABLE pitch_revisions ADD COLUMN edit_kind VARCHAR(10) CHECK (edit_kind IN ('minor', 'major'));
+
+-- Every edit before this migration reset the votes
+UPDATE pitches SET last_edit_kind = 'major' WHERE last_edit_at IS NOT NULL;
+UPDATE pitch_revisions SET edit_kind = 'major' WHERE revision_number > 1;
+
+COMMENT ON COLUMN pitches.last_edit_kind IS 'Whether the latest edit was minor (votes kept) or major (votes reset)';
+COMMENT ON COLUMN pitch_revisions.edit_kind IS 'Whether the edit that created the revision was minor or major - NULL for the original text';
+
+INSERT INTO config_settings (key, value, description, category, data_type) VALUES
+    ('pitch.minor_edit.max_distance', '10', 'Most characters an edit may add, remove or change and still keep the votes as a minor edit', 'pitch_limits', 'integer'),
+    ('pitch.minor_edit.max_percent', '10', 'Most an edit may change, as a percentage of the previous text length, and still keep the votes as a minor edit', 'pitch_limits', 'integer');
@@ -0,0 +1,12 @@
+-- Remove pitch ranking functions and indexes
+DELETE FROM config_settings WHERE key IN ('ranking.default_sort.bitcoin', 'ranking.default_sort.lightning', 'ranking.default_sort.cashu');
+
+DROP INDEX IF EXISTS idx_pitches_rank_wilson;
+DROP INDEX IF EXISTS idx_pitches_rank_controversial;
+DROP INDEX IF EXISTS idx_pitches_rank_top;
+DROP INDEX IF EXISTS idx_pitches_rank_new;
+DROP INDEX IF EXISTS idx_pitches_rank_hot;
+
+DROP FUNCTION IF EXISTS pitch_wilson_lower_bound(INTEGER, INTEGER);
+DROP FUNCTION IF EXISTS pitch_controversy(INTEGER, INTEGER);
+DROP FUNCTION IF EXISTS pitch_hot_rank(INTEGER, TIMESTAMP WITH TIME ZONE);
@@ -0,0 +1,47 @@
+-- Ranking functions and indexes for sorting pitch listings
+
+-- Hot: the score's order of magnitude plus a bonus for recent votes, so a
+-- pitch needs ten times the score to outrank one voted on 12.5 hours later
+CREATE OR REPLACE FUNCTION pitch_hot_rank(score INTEGER, active_at TIMESTAMP WITH TIME ZONE)
+RETURNS DOUBLE PRECISION
+LANGUAGE sql IMMUTABLE
+AS $$
+    SELECT SIGN(COALESCE(score, 0))::DOUBLE PRECISION * LOG(GREATEST(ABS(COALESCE(score, 0)), 1)::DOUBLE PRECISION)
+        + EXTRACT(EPOCH FROM active_at)::DOUBLE PRECISION / 45000
+$$;
+
+-- Controversial: many votes split evenly between up and down
+CREATE OR REPLACE FUNCTION pitch_controversy(upvotes INTEGER, downvotes INTEGER)
+RETURNS DOUBLE PRECISION
+LANGUAGE sql IMMUTABLE
+AS $$
+    SELECT CASE WHEN up <= 0 OR down <= 0 THEN 0
+        ELSE POWER(up + down, LEAST(up, down) / GREATEST(up, down))
+    END
+    FROM (SELECT COALESCE(upvotes, 0)::DOUBLE PRECISION AS up,
+                 COALESCE(downvotes, 0)::DOUBLE PRECISION AS down) v
+$$;
+
+-- Wilson: lower bound of the 95% confidence interval for the share of upvotes
+CREATE OR REPLACE FUNCTION pitch_wilson_lower_bound(upvotes INTEGER, downvotes INTEGER)
+RETURNS DOUBLE PRECISION
+LANGUAGE sql IMMUTABLE
+AS $$
+    SELECT CASE WHEN up + down = 0 THEN 0
+        ELSE ((up + 1.9208) / (up + down) - 1.96 * SQRT(up * down / (up + down) + 0.9604) / (up + down))
+            / (1 + 3.8416 / (up + down))
+    END
+    FROM (SELECT COALESCE(upvotes, 0)::DOUBLE PRECISION AS up,
+                 COALESCE(downvotes, 0)::DOUBLE PRECISION AS down) v
+$$;
+
+CREATE INDEX idx_pitches_rank_hot ON pitches (main_category, pitch_hot_rank(score, COALESCE(last_vote_at, created_at)) DESC, created_at DESC) WHERE deleted_at IS NULL;
+CREATE INDEX idx_pitches_rank_new ON pitches (main_category, created_at DESC) WHERE deleted_at IS NULL;
+CREATE INDEX idx_pitches_rank_top ON pitches (main_category, score DESC, created_at DESC) WHERE deleted_at IS NULL;
+CREATE INDEX idx_pitches_rank_controversial ON pitches (main_category, pitch_controversy(upvote_count, downvote_count) DESC, created_at DESC) WHERE deleted_at IS NULL;
+CREATE INDEX idx_pitches_rank_wilson ON pitches (main_category, pitch_wilson_lower_bound(upvote_count, downvote_count) DESC, created_at DESC) WHERE deleted_at IS NULL;
+
+INSERT INTO config_settings (key, value, description, category, data_type) VALUES
+    ('ranking.default_sort.bitcoin', 'hot', 'How Bitcoin pitches are sorted when no sort is chosen: hot, new, top, controversial or wilson', 'ranking', 'string'),
+    ('ranking.default_sort.lightning', 'hot', 'How Lightning pitches are sorted when no sort is chosen: hot, new, top, controversial or wilson', 'ranking', 'string'),
+    ('ranking.default_sort.cashu', 'hot', 'How Cashu pitches are sorted when no sort is chosen: hot, new, top, controversial or wilson', 'ranking', 'string');
@@ -0,0 +1,14 @@
+-- Restore the ranking indexes without the id
+DROP INDEX IF EXISTS idx_pitches_rank_hot;
+DROP INDEX IF EXISTS idx_pitches_rank_new;
+DROP INDEX IF EXISTS idx_pitches_rank_top;
+DROP INDEX IF EXISTS idx_pitches_rank_controversial;
+DROP INDEX IF EXISTS idx_pitches_rank_wilson;
+
+CREATE INDEX idx_pitches_rank_hot ON pitches (main_category, pitch_hot_rank(score, COALESCE(last_vote_at, created_at)) DESC, created_at DESC) WHERE deleted_at IS NULL;
+CREATE INDEX idx_pitches_rank_new ON pitches (main_category, created_at DESC) WHERE deleted_at IS NULL;
+CREATE INDEX idx_pitches_rank_top ON pitches (main_category, score DESC, created_at DESC) WHERE deleted_at IS NULL;
+CREATE INDEX idx_pitches_rank_controversial ON pitches (main_category, pitch_controversy(upvote_count, downvote_count) DESC, created_at DESC) WHERE deleted_at IS NULL;
+CREATE INDEX idx_pitches_rank_wilson ON pitches (main_category, pitch_wilson_lower_bound(upvote_count, downvote_count) DESC, created_at DESC) WHERE deleted_at IS NULL;
+
+ALTER TABLE pitches ALTER COLUMN score DROP NOT NULL;
@@ -0,0 +1,12 @@
+-- Go back to the fixed list of pitch categories
+ALTER TABLE pitches DROP CONSTRAINT IF EXISTS pitches_main_category_fkey;
+ALTER TABLE pitches ADD CONSTRAINT pitches_main_category_check CHECK (main_category IN ('bitcoin', 'lightning', 'cashu'));
+
+INSERT INTO config_settings (key, value, description, category, data_type)
+SELECT 'ranking.default_sort.' || slug, default_sort,
+       'How ' || (names->>'en') || ' pitches are sorted when no sort is chosen: hot, new, top, controversial or wilson',
+       'ranking', 'string'
+FROM categories
+WHERE slug IN ('bitcoin', 'lightning', 'cashu');
+
+DROP TABLE IF EXISTS categories;
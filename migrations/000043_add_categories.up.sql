@@ -0,0 +1,35 @@
+-- Pitch categories managed by admins instead of a fixed list
+CREATE TABLE categories (
+    slug TEXT PRIMARY KEY CHECK (slug ~ '^[a-z0-9]+(-[a-z0-9]+)*$' AND length(slug) <= 50),
+    names JSONB NOT NULL DEFAULT '{}',
+    description TEXT NOT NULL DEFAULT '',
+    icon VARCHAR(16) NOT NULL DEFAULT '',
+    display_order INTEGER NOT NULL DEFAULT 0,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    default_sort VARCHAR(20) NOT NULL DEFAULT 'hot' CHECK (default_sort IN ('hot', 'new', 'top', 'controversial', 'wilson')),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE TRIGGER update_categories_updated_at BEFORE UPDATE ON categories FOR EACH ROW EXECUTE PROCEDURE update_updated_at_column();
+
+COMMENT ON COLUMN categories.slug IS 'URL of the category listing, e.g. /bitcoin - cannot change once pitches use it';
+COMMENT ON COLUMN categories.names IS 'Display name by language code, falling back to English';
+COMMENT ON COLUMN categories.active IS 'Inactive categories are hidden and take no new pitches';
+COMMENT ON COLUMN categories.default_sort IS 'Ranking mode used when visitors have not picked a sort';
+
+-- The categories pitches could use until now, keeping their default sort
+INSERT INTO categories (slug, names, description, icon, display_order, default_sort)
+SELECT c.slug, c.names::jsonb, c.description, c.icon, c.display_order,
+       COALESCE((SELECT value FROM config_settings WHERE key = 'ranking.default_sort.' || c.slug
+                 AND value IN ('hot', 'new', 'top', 'controversial', 'wilson')), 'hot')
+FROM (VALUES
+    ('bitcoin', '{"en": "Bitcoin", "cs": "Bitcoin", "sk": "Bitcoin"}', 'Pitches for Bitcoin itself', '₿', 1),
+    ('lightning', '{"en": "Lightning", "cs": "Lightning", "sk": "Lightning"}', 'Pitches for fast, cheap payments over the Lightning Network', '⚡', 2),
+    ('cashu', '{"en": "Cashu", "cs": "Cashu", "sk": "Cashu"}', 'Pitches for private Chaumian ecash with Cashu', '🥜', 3)
+) AS c(slug, names, description, icon, display_order);
+
+DELETE FROM config_settings WHERE key IN ('ranking.default_sort.bitcoin', 'ranking.default_sort.lightning', 'ranking.default_sort.cashu');
+
+ALTER TABLE pitches DROP CONSTRAINT IF EXISTS pitches_main_category_check;
+ALTER TABLE pitches ADD CONSTRAINT pitches_main_category_fkey FOREIGN KEY (main_category) REFERENCES categories(slug);
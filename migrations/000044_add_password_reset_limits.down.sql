@@ -0,0 +1,9 @@
+-- Remove password reset rate limits
+DROP INDEX IF EXISTS idx_user_activities_password_reset_email;
+
+DELETE FROM config_settings WHERE key IN (
+    'security.password_reset_per_email_per_hour',
+    'security.password_reset_per_ip_per_hour'
+);
+
+DELETE FROM user_activities WHERE action_type = 'password_reset';
@@ -0,0 +1,9 @@
+-- Limit how many password reset emails an address or an IP can trigger
+INSERT INTO config_settings (key, value, description, category, data_type) VALUES
+    ('security.password_reset_per_email_per_hour', '3', 'Password reset emails per email address per hour (0 disables the limit)', 'security', 'integer'),
+    ('security.password_reset_per_ip_per_hour', '10', 'Password reset requests per IP address per hour (0 disables the limit)', 'security', 'integer');
+
+-- Reset requests are counted by the hashed email address in their metadata
+CREATE INDEX idx_user_activities_password_reset_email
+    ON user_activities ((metadata->>'email_hash'), created_at DESC)
+    WHERE action_type = 'password_reset';
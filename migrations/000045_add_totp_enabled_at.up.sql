@@ -0,0 +1,5 @@
+-- Record when 2FA was turned on, so reverting an email change can tell
+-- whether 2FA was set up after the change was requested
+ALTER TABLE users ADD COLUMN totp_enabled_at TIMESTAMP WITH TIME ZONE NULL;
+
+COMMENT ON COLUMN users.totp_enabled_at IS 'When TOTP 2FA was last enabled; NULL if disabled or enabled before this was tracked';
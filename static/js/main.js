@@ -733,10 +733,11 @@ document.addEventListener('htmx:afterRequest', function(evt) {
             if (form) {
                 const selectedCategory = form.querySelector('#main-category').value;
                 const currentPath = window.location.pathname;
-                const currentCategory = currentPath.split('/')[1] || 'bitcoin';
+                // The homepage lists every category, a category page only its own
+                const showsCategory = currentPath === '/' || currentPath === `/${selectedCategory}`;
                 
-                // If the selected category is different from current page, redirect
-                if (selectedCategory && selectedCategory !== currentCategory) {
+                // If the selected category is not shown on the current page, redirect
+                if (selectedCategory && !showsCategory) {
                     window.location.href = `/${selectedCategory}`;
                 } else {
                     // Same category, reload to show the new pitch
@@ -938,12 +939,15 @@ function addFilter(filterType, filterValue) {
         currentUrl.searchParams.set(filterType, filterValue);
     }
     
+    // A listing position does not carry over to other filters
+    currentUrl.searchParams.delete('cursor');
     window.location.href = currentUrl.toString();
 }
 
 function removeFilter(filterType) {
     const currentUrl = new URL(window.location);
     currentUrl.searchParams.delete(filterType);
+    currentUrl.searchParams.delete('cursor');
     window.location.href = currentUrl.toString();
 }
 
@@ -1002,14 +1006,12 @@ document.addEventListener('DOMContentLoaded', function() {
     const tabs = document.querySelectorAll('.nav-tab');
     tabs.forEach(tab => tab.classList.remove('active'));
     
-    // Add active class to current tab
-    if (currentPath === '/' || currentPath === '/bitcoin') {
-        document.querySelector('.nav-tab[href="/bitcoin"]')?.classList.add('active');
-    } else if (currentPath === '/lightning') {
-        document.querySelector('.nav-tab[href="/lightning"]')?.classList.add('active');
-    } else if (currentPath === '/cashu') {
-        document.querySelector('.nav-tab[href="/cashu"]')?.classList.add('active');
-    }
+    // Add active class to the current category's tab; the homepage has none
+    tabs.forEach(tab => {
+        if (tab.getAttribute('href') === currentPath) {
+            tab.classList.add('active');
+        }
+    });
 });
 
 // Language picker functionality
@@ -134,7 +134,9 @@ class TutorialEngine {
         // Only show on homepage or category pages
         const path = window.location.pathname;
         console.log('[Tutorial] Current path:', path);
-        if (path === '/' || path === '/bitcoin' || path === '/lightning' || path === '/cashu') {
+        const onCategoryPage = Array.from(document.querySelectorAll('.nav-tab'))
+            .some(tab => tab.getAttribute('href') === path);
+        if (path === '/' || onCategoryPage) {
             return true;
         }
 
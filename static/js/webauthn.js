@@ -0,0 +1,114 @@
+// WebAuthn (passkey) helpers shared by the login modal, the 2FA page and the passkeys page.
+// The server speaks JSON with binary fields encoded as base64url.
+(function () {
+  function toBuffer(base64url) {
+    const base64 = base64url.replace(/-/g, '+').replace(/_/g, '/');
+    const padded = base64 + '='.repeat((4 - base64.length % 4) % 4);
+    const binary = atob(padded);
+    const bytes = new Uint8Array(binary.length);
+    for (let i = 0; i < binary.length; i++) {
+      bytes[i] = binary.charCodeAt(i);
+    }
+    return bytes.buffer;
+  }
+
+  function toBase64URL(buffer) {
+    const bytes = new Uint8Array(buffer);
+    let binary = '';
+    for (let i = 0; i < bytes.length; i++) {
+      binary += String.fromCharCode(bytes[i]);
+    }
+    return btoa(binary).replace(/\+/g, '-').replace(/\//g, '_').replace(/=+$/, '');
+  }
+
+  function csrfToken() {
+    return document.querySelector('meta[name="csrf-token"]')?.getAttribute('content') || window.BITCOINPITCH_CSRF || '';
+  }
+
+  async function request(method, url, body) {
+    const options = {
+      method: method,
+      credentials: 'same-origin',
+      headers: { 'X-CSRF-Token': csrfToken() }
+    };
+    if (body !== undefined) {
+      options.headers['Content-Type'] = 'application/json';
+      options.body = JSON.stringify(body);
+    }
+
+    const response = await fetch(url, options);
+    const data = await response.json().catch(() => ({}));
+    if (!response.ok) {
+      throw new Error(data.error || 'Request failed');
+    }
+    return data;
+  }
+
+  function decodeDescriptors(descriptors) {
+    return (descriptors || []).map(descriptor => ({ ...descriptor, id: toBuffer(descriptor.id) }));
+  }
+
+  function encodeCredential(credential) {
+    const response = credential.response;
+    const encoded = {
+      id: toBase64URL(credential.rawId),
+      response: { clientDataJSON: toBase64URL(response.clientDataJSON) }
+    };
+    if (response.attestationObject) {
+      encoded.response.attestationObject = toBase64URL(response.attestationObject);
+    }
+    if (response.authenticatorData) {
+      encoded.response.authenticatorData = toBase64URL(response.authenticatorData);
+      encoded.response.signature = toBase64URL(response.signature);
+    }
+    if (response.userHandle) {
+      encoded.response.userHandle = toBase64URL(response.userHandle);
+    }
+    return encoded;
+  }
+
+  async function getAssertion(publicKey) {
+    publicKey.challenge = toBuffer(publicKey.challenge);
+    publicKey.allowCredentials = decodeDescriptors(publicKey.allowCredentials);
+    const credential = await navigator.credentials.get({ publicKey: publicKey });
+    return encodeCredential(credential);
+  }
+
+  window.BitcoinPitchWebAuthn = {
+    isSupported: function () {
+      return !!(window.PublicKeyCredential && navigator.credentials);
+    },
+
+    // Register a new passkey for the signed-in user
+    register: async function (name) {
+      const options = await request('POST', '/user/passkeys/options', {});
+      const publicKey = options.publicKey;
+      publicKey.challenge = toBuffer(publicKey.challenge);
+      publicKey.user.id = toBuffer(publicKey.user.id);
+      publicKey.excludeCredentials = decodeDescriptors(publicKey.excludeCredentials);
+
+      const credential = await navigator.credentials.create({ publicKey: publicKey });
+      const encoded = encodeCredential(credential);
+      encoded.name = name;
+      return await request('POST', '/user/passkeys', encoded);
+    },
+
+    // Sign in without a password using a discoverable passkey
+    login: async function () {
+      const options = await request('GET', '/auth/webauthn/options');
+      const assertion = await getAssertion(options.publicKey);
+      return await request('POST', '/auth/webauthn/login', assertion);
+    },
+
+    // Find out which second factors the pending login accepts
+    secondFactorOptions: async function () {
+      return await request('GET', '/auth/2fa/webauthn');
+    },
+
+    // Complete a pending login with a passkey instead of a TOTP code
+    secondFactor: async function (publicKey) {
+      const assertion = await getAssertion(publicKey);
+      return await request('POST', '/auth/2fa/webauthn', assertion);
+    }
+  };
+})();